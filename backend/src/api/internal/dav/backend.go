@@ -0,0 +1,592 @@
+package dav
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"luna-backend/api/internal/util"
+	"luna-backend/cache"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	icalProtocol "luna-backend/protocols/ical"
+	"luna-backend/scheduling"
+	"luna-backend/types"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+// The CalDAV server exposes every calendar of a user regardless of its source.
+// The resulting tree looks like this:
+//
+//	/dav/                                             root
+//	/dav/{userId}/                                    user principal
+//	/dav/{userId}/calendars/                          calendar home set
+//	/dav/{userId}/calendars/{calendarId}/             calendar
+//	/dav/{userId}/calendars/{calendarId}/{eventId}.ics event
+//
+// Events created by a client keep the resource name and UID the client chose instead.
+// A new backend is created for each request, so that it can use the request's transaction.
+
+const Prefix = "/dav"
+
+// Window of events returned when a client lists a whole calendar without a time range
+const (
+	listWindowPast   = 365 * 24 * time.Hour
+	listWindowFuture = 2 * 365 * 24 * time.Hour
+)
+
+type Backend struct {
+	u      *util.HandlerUtility
+	userId types.ID
+
+	// The first error trace encountered while handling the request.
+	// go-webdav only works with plain errors, so we keep the original trace around for logging.
+	tr *errors.ErrorTrace
+	// Set if the response names a failed precondition, which the client needs to see
+	preconditionFailed bool
+}
+
+func NewBackend(u *util.HandlerUtility, userId types.ID) *Backend {
+	return &Backend{
+		u:      u,
+		userId: userId,
+	}
+}
+
+func (b *Backend) GetErrorTrace() *errors.ErrorTrace {
+	return b.tr
+}
+
+func (b *Backend) PreconditionFailed() bool {
+	return b.preconditionFailed
+}
+
+func (b *Backend) fail(tr *errors.ErrorTrace) error {
+	if b.tr == nil {
+		b.tr = tr
+	}
+	return webdav.NewHTTPError(tr.GetStatus(), fmt.Errorf("%s", tr.Serialize(errors.LvlPlain)))
+}
+
+// Tells the client which precondition of RFC 4791 5.3.2.1 failed
+func (b *Backend) failPrecondition(tr *errors.ErrorTrace, precondition caldav.PreconditionType) error {
+	if b.tr == nil {
+		b.tr = tr
+	}
+	b.preconditionFailed = true
+	return caldav.NewPreconditionError(precondition)
+}
+
+func (b *Backend) principalPath() string {
+	return fmt.Sprintf("%s/%s/", Prefix, b.userId.String())
+}
+
+func (b *Backend) homeSetPath() string {
+	return fmt.Sprintf("%scalendars/", b.principalPath())
+}
+
+func (b *Backend) calendarPath(calendarId types.ID) string {
+	return fmt.Sprintf("%s%s/", b.homeSetPath(), calendarId.String())
+}
+
+func (b *Backend) eventPath(event types.Event, obj *types.DavObject) string {
+	if obj != nil {
+		return b.calendarPath(event.GetCalendar().GetId()) + obj.FileName
+	}
+	return fmt.Sprintf("%s%s.ics", b.calendarPath(event.GetCalendar().GetId()), event.GetId().String())
+}
+
+// Splits a path into the calendar id and the event id (if any)
+func (b *Backend) parsePath(p string) (types.ID, string, *errors.ErrorTrace) {
+	rest, found := strings.CutPrefix(path.Clean(p), strings.TrimSuffix(b.homeSetPath(), "/"))
+	if !found {
+		return types.EmptyId(), "", errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Path %v is outside of the calendar home set", p).
+			AltStr(errors.LvlPlain, "Not found")
+	}
+
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(parts) == 0 || len(parts) > 2 || parts[0] == "" {
+		return types.EmptyId(), "", errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Malformed path %v", p).
+			AltStr(errors.LvlPlain, "Not found")
+	}
+
+	calendarId, err := types.IdFromString(parts[0])
+	if err != nil {
+		return types.EmptyId(), "", errors.New().Status(http.StatusNotFound).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Malformed calendar id in path %v", p).
+			AltStr(errors.LvlPlain, "Not found")
+	}
+
+	if len(parts) == 1 {
+		return calendarId, "", nil
+	}
+
+	return calendarId, parts[1], nil
+}
+
+func (b *Backend) getCalendar(calendarId types.ID) (types.Calendar, *errors.ErrorTrace) {
+	calendar, tr := cache.GetCached(b.u.Config.Cache, b.userId, calendarId, b.u.Context, func() (types.Calendar, *errors.ErrorTrace) {
+		return b.u.Tx.Queries().GetCalendar(b.userId, calendarId, b.u.Context, b.u.Config)
+	})
+	if tr != nil {
+		return nil, tr
+	}
+
+	return b.u.Tx.Queries().OverrideCalendar(calendar)
+}
+
+// Also returns the object of the event if a client created it
+func (b *Backend) getEvent(calendarId types.ID, fileName string) (types.Event, *types.DavObject, *errors.ErrorTrace) {
+	obj, tr := b.u.Tx.Queries().GetDavObjectByFileName(calendarId, fileName)
+	if tr != nil {
+		return nil, nil, tr
+	}
+
+	var eventId types.ID
+	if obj != nil {
+		eventId = obj.EventId
+	} else {
+		var err error
+		eventId, err = types.IdFromString(strings.TrimSuffix(fileName, ".ics"))
+		if err != nil {
+			return nil, nil, errors.New().Status(http.StatusNotFound).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Unknown event file name %v", fileName).
+				AltStr(errors.LvlPlain, "Event not found")
+		}
+	}
+
+	eventFromCal, tr := b.u.Tx.Queries().GetEvent(b.userId, eventId, b.u.Context, b.u.Config)
+	if tr != nil {
+		return nil, nil, tr
+	}
+
+	if eventFromCal.GetCalendar().GetId() != calendarId {
+		return nil, nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Event %v does not belong to calendar %v", eventId, calendarId).
+			AltStr(errors.LvlPlain, "Event not found")
+	}
+
+	event, tr := b.u.Tx.Queries().OverrideEvent(eventFromCal)
+	if tr != nil {
+		return nil, nil, tr
+	}
+
+	return event, obj, nil
+}
+
+func (b *Backend) toCalendar(calendar types.Calendar) caldav.Calendar {
+	return caldav.Calendar{
+		Path:                  b.calendarPath(calendar.GetId()),
+		Name:                  calendar.GetName(),
+		Description:           calendar.GetDesc(),
+		SupportedComponentSet: []string{ical.CompEvent},
+	}
+}
+
+func (b *Backend) toCalendarObject(event types.Event, obj *types.DavObject) (*caldav.CalendarObject, *errors.ErrorTrace) {
+	uid := event.GetId().String()
+	if obj != nil {
+		uid = obj.Uid
+	}
+
+	vevent, tr := icalProtocol.EventToIcal(event, uid)
+	if tr != nil {
+		return nil, tr
+	}
+
+	cal := icalProtocol.NewIcalCalendar("", "", nil)
+	cal.Children = append(cal.Children, vevent)
+
+	// DTSTAMP changes on every serialization, so it is left out of the ETag
+	stamp := vevent.Props.Get(ical.PropDateTimeStamp)
+	vevent.Props.SetDateTime(ical.PropDateTimeStamp, time.Unix(0, 0).UTC())
+
+	var buf bytes.Buffer
+	err := ical.NewEncoder(&buf).Encode(cal)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not encode event %v", event.GetId()).
+			AltStr(errors.LvlWordy, "Could not convert event to iCal")
+	}
+
+	if stamp != nil {
+		vevent.Props.Set(stamp)
+	} else {
+		vevent.Props.Del(ical.PropDateTimeStamp)
+	}
+
+	return &caldav.CalendarObject{
+		Path: b.eventPath(event, obj),
+		ETag: hex.EncodeToString(crypto.GetSha256Hash(buf.Bytes())),
+		Data: cal,
+	}, nil
+}
+
+func (b *Backend) listEvents(calendar types.Calendar, start time.Time, end time.Time) ([]caldav.CalendarObject, *errors.ErrorTrace) {
+	eventsFromCal, tr := calendar.GetEvents(start, end, b.u.Tx.Queries())
+	b.u.Tx.Queries().ReportSourceHealth(calendar.GetSource().GetId(), tr)
+	if tr != nil {
+		return nil, tr
+	}
+
+	events, tr := b.u.Tx.Queries().OverrideEvents(eventsFromCal)
+	if tr != nil {
+		return nil, tr
+	}
+
+	davObjects, tr := b.u.Tx.Queries().GetDavObjects(calendar.GetId())
+	if tr != nil {
+		return nil, tr
+	}
+
+	objects := make([]caldav.CalendarObject, 0, len(events))
+	for _, event := range events {
+		obj, tr := b.toCalendarObject(event, davObjects[event.GetId()])
+		if tr != nil {
+			return nil, tr
+		}
+		objects = append(objects, *obj)
+	}
+
+	return objects, nil
+}
+
+func (b *Backend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	return b.principalPath(), nil
+}
+
+func (b *Backend) CalendarHomeSetPath(ctx context.Context) (string, error) {
+	return b.homeSetPath(), nil
+}
+
+func (b *Backend) CreateCalendar(ctx context.Context, calendar *caldav.Calendar) error {
+	return b.fail(errors.New().Status(http.StatusForbidden).
+		Append(errors.LvlPlain, "Calendars must be created through a source"))
+}
+
+func (b *Backend) ListCalendars(ctx context.Context) ([]caldav.Calendar, error) {
+	sources, tr := b.u.Tx.Queries().GetSourcesByUser(b.userId, b.u.Context, b.u.Config)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	result := []caldav.Calendar{}
+	for _, source := range sources {
+		calsFromSource, tr := source.GetCalendars(b.u.Tx.Queries())
+		b.u.Tx.Queries().ReportSourceHealth(source.GetId(), tr)
+		if tr != nil {
+			// One unreachable source should not hide all other calendars
+			b.u.Logger.Warn(tr.Append(errors.LvlDebug, "Could not list calendars of source %v over CalDAV", source.GetId()).Serialize(errors.LvlDebug))
+			continue
+		}
+
+		cals, tr := b.u.Tx.Queries().OverrideCalendars(calsFromSource)
+		if tr != nil {
+			return nil, b.fail(tr)
+		}
+
+		for _, cal := range cals {
+			b.u.Config.Cache.Cache(b.userId, cal)
+			result = append(result, b.toCalendar(cal))
+		}
+	}
+
+	return result, nil
+}
+
+func (b *Backend) GetCalendar(ctx context.Context, p string) (*caldav.Calendar, error) {
+	calendarId, fileName, tr := b.parsePath(p)
+	if tr == nil && fileName != "" {
+		tr = errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Path %v is not a calendar", p)
+	}
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	calendar, tr := b.getCalendar(calendarId)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	converted := b.toCalendar(calendar)
+	return &converted, nil
+}
+
+func (b *Backend) GetCalendarObject(ctx context.Context, p string, req *caldav.CalendarCompRequest) (*caldav.CalendarObject, error) {
+	calendarId, fileName, tr := b.parsePath(p)
+	if tr == nil && fileName == "" {
+		tr = errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Path %v is not an event", p)
+	}
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	event, davObject, tr := b.getEvent(calendarId, fileName)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	obj, tr := b.toCalendarObject(event, davObject)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	return obj, nil
+}
+
+func (b *Backend) ListCalendarObjects(ctx context.Context, p string, req *caldav.CalendarCompRequest) ([]caldav.CalendarObject, error) {
+	calendarId, _, tr := b.parsePath(p)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	calendar, tr := b.getCalendar(calendarId)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	now := time.Now()
+	objects, tr := b.listEvents(calendar, now.Add(-listWindowPast), now.Add(listWindowFuture))
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	return objects, nil
+}
+
+func (b *Backend) QueryCalendarObjects(ctx context.Context, p string, query *caldav.CalendarQuery) ([]caldav.CalendarObject, error) {
+	calendarId, _, tr := b.parsePath(p)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	calendar, tr := b.getCalendar(calendarId)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	// Only the VEVENT time range filter is taken into account, everything else is left to the client
+	now := time.Now()
+	start := now.Add(-listWindowPast)
+	end := now.Add(listWindowFuture)
+	for _, comp := range query.CompFilter.Comps {
+		if comp.Name != ical.CompEvent {
+			continue
+		}
+		if !comp.Start.IsZero() {
+			start = comp.Start
+		}
+		if !comp.End.IsZero() {
+			end = comp.End
+		}
+	}
+
+	objects, tr := b.listEvents(calendar, start, end)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	return objects, nil
+}
+
+// Clients change the participation status of the user by editing the whole event,
+// so attendees reply to the organizer instead of sending invitations
+func (b *Backend) scheduleEdit(before types.Event, after types.Event) {
+	user, tr := b.u.Tx.Queries().GetUser(b.userId)
+	if tr != nil {
+		b.u.Warn(tr)
+		return
+	}
+
+	if scheduling.IsOrganizer(user, before) || scheduling.IsOrganizer(user, after) {
+		util.ScheduleEventChange(b.u, b.userId, before, after)
+		return
+	}
+
+	previous := before.GetParticipants().FindSelf(user.Email)
+	attendee := after.GetParticipants().FindSelf(user.Email)
+	if previous != nil && attendee != nil && previous.Status != attendee.Status {
+		util.ScheduleParticipationChange(b.u, user, after, attendee)
+	}
+}
+
+func (b *Backend) PutCalendarObject(ctx context.Context, p string, cal *ical.Calendar, opts *caldav.PutCalendarObjectOptions) (*caldav.CalendarObject, error) {
+	calendarId, fileName, tr := b.parsePath(p)
+	if tr == nil && fileName == "" {
+		tr = errors.New().Status(http.StatusMethodNotAllowed).
+			Append(errors.LvlDebug, "Path %v is not an event", p)
+	}
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	calendar, tr := b.getCalendar(calendarId)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	var vevent *ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompEvent {
+			vevent = child
+			break
+		}
+	}
+	if vevent == nil {
+		return nil, b.fail(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Only VEVENT components are supported"))
+	}
+
+	parsedProps, err := icalProtocol.ParseIcalEvent(vevent)
+	if err != nil {
+		return nil, b.fail(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Could not parse event"))
+	}
+
+	existingEvent, davObject, tr := b.getEvent(calendarId, fileName)
+	if tr != nil && tr.GetStatus() != http.StatusNotFound {
+		return nil, b.fail(tr)
+	}
+	exists := tr == nil
+
+	if exists && opts.IfNoneMatch.IsWildcard() {
+		return nil, b.fail(errors.New().Status(http.StatusPreconditionFailed).
+			Append(errors.LvlPlain, "Event already exists"))
+	}
+	if !exists && opts.IfMatch.IsSet() {
+		return nil, b.fail(errors.New().Status(http.StatusPreconditionFailed).
+			Append(errors.LvlPlain, "Event does not exist"))
+	}
+	if exists && opts.IfMatch.IsSet() && !opts.IfMatch.IsWildcard() {
+		current, tr := b.toCalendarObject(existingEvent, davObject)
+		if tr != nil {
+			return nil, b.fail(tr)
+		}
+		expected, err := opts.IfMatch.ETag()
+		if err != nil || expected != current.ETag {
+			return nil, b.fail(errors.New().Status(http.StatusPreconditionFailed).
+				Append(errors.LvlPlain, "Event was modified in the meantime"))
+		}
+	}
+
+	var event types.Event
+	if exists {
+		if !existingEvent.CanEdit() {
+			return nil, b.fail(errors.New().Status(http.StatusForbidden).
+				Append(errors.LvlPlain, "This event cannot be edited"))
+		}
+
+		event, tr = calendar.EditEvent(existingEvent, parsedProps.Name, parsedProps.Desc, parsedProps.Color, parsedProps.EventDate, parsedProps.Location, parsedProps.Participants, parsedProps.Reminders, false, b.u.Tx.Queries())
+		if tr != nil {
+			return nil, b.fail(tr)
+		}
+
+		b.u.Tx.Queries().NotifyChange(b.userId, types.NewEventChange(constants.ChangeActionUpdated, event.GetId(), calendar.GetId()))
+
+		b.scheduleEdit(existingEvent, event)
+	} else {
+		if !calendar.CanAddEvents() {
+			return nil, b.fail(errors.New().Status(http.StatusForbidden).
+				Append(errors.LvlPlain, "Events cannot be added to this calendar"))
+		}
+
+		// RFC 4791 4.1 and 5.3.2.1
+		uid := vevent.Props.Get(ical.PropUID)
+		if uid == nil || uid.Value == "" {
+			return nil, b.fail(errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "The event has no UID"))
+		}
+		uidUsed, tr := b.u.Tx.Queries().IsUidUsedInCalendar(calendarId, uid.Value)
+		if tr != nil {
+			return nil, b.fail(tr)
+		}
+		if uidUsed {
+			return nil, b.failPrecondition(errors.New().Status(http.StatusConflict).
+				Append(errors.LvlDebug, "UID %v is already used in calendar %v", uid.Value, calendarId).
+				Append(errors.LvlPlain, "An event with this UID already exists in this calendar"),
+				caldav.PreconditionNoUIDConflict)
+		}
+
+		event, tr = calendar.AddEvent(parsedProps.Name, parsedProps.Desc, parsedProps.Color, parsedProps.EventDate, parsedProps.Location, parsedProps.Participants, parsedProps.Reminders, b.u.Tx.Queries())
+		if tr != nil {
+			return nil, b.fail(tr)
+		}
+
+		tr = b.u.Tx.Queries().InsertEvent(event)
+		if tr != nil {
+			return nil, b.fail(tr)
+		}
+
+		davObject = &types.DavObject{
+			EventId:  event.GetId(),
+			FileName: fileName,
+			Uid:      uid.Value,
+		}
+		tr = b.u.Tx.Queries().InsertDavObject(calendar.GetId(), davObject)
+		if tr != nil {
+			return nil, b.fail(tr)
+		}
+
+		b.u.Tx.Queries().NotifyChange(b.userId, types.NewEventChange(constants.ChangeActionCreated, event.GetId(), calendar.GetId()))
+
+		util.ScheduleEventChange(b.u, b.userId, nil, event)
+	}
+
+	obj, tr := b.toCalendarObject(event, davObject)
+	if tr != nil {
+		return nil, b.fail(tr)
+	}
+
+	return obj, nil
+}
+
+func (b *Backend) DeleteCalendarObject(ctx context.Context, p string) error {
+	calendarId, fileName, tr := b.parsePath(p)
+	if tr == nil && fileName == "" {
+		tr = errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlPlain, "Calendars must be deleted through their source")
+	}
+	if tr != nil {
+		return b.fail(tr)
+	}
+
+	event, _, tr := b.getEvent(calendarId, fileName)
+	if tr != nil {
+		return b.fail(tr)
+	}
+
+	if !event.CanDelete() {
+		return b.fail(errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlPlain, "This event cannot be deleted"))
+	}
+
+	tr = event.GetCalendar().DeleteEvent(event, b.u.Tx.Queries())
+	if tr != nil {
+		return b.fail(tr)
+	}
+
+	util.ScheduleEventChange(b.u, b.userId, event, nil)
+
+	tr = b.u.Tx.Queries().DeleteEvent(b.userId, event.GetId())
+	if tr != nil {
+		return b.fail(tr)
+	}
+
+	return nil
+}
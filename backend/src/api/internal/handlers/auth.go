@@ -15,71 +15,131 @@ import (
 // because detailed error messages about authenticatino checks might pose a
 // security risk.
 
-func Login(c *gin.Context) {
-	// Parsing
-	u := util.GetUtil(c)
+// Creates a session for a user whose identity was fully verified and returns its token
+func createSession(u *util.HandlerUtility, c *gin.Context, userId types.ID, isShortLived bool) (string, *errors.ErrorTrace) {
+	secret, tr := crypto.GenerateRandomBytes(256)
+	if tr != nil {
+		return "", tr.
+			Append(errors.LvlWordy, "Could not generate random bytes")
+	}
 
-	credentials := auth.BasicAuth{}
-	if err := c.ShouldBind(&credentials); err != nil {
-		u.Error(errors.New().Status(http.StatusBadRequest).
-			AddErr(errors.LvlDebug, err).
-			Append(errors.LvlDebug, "Could not parse credentials").
-			Append(errors.LvlWordy, "Malformed request").
-			Append(errors.LvlBroad, "Could not log in"),
-		)
-		return
+	session := &types.Session{
+		UserId:           userId,
+		UserAgent:        c.Request.UserAgent(),
+		InitialIpAddress: util.DetermineClientAddress(c),
+		LastIpAddress:    util.DetermineClientAddress(c),
+		IsShortLived:     isShortLived,
+		IsApi:            false,
+		SecretHash:       []byte{},
+	}
+	tr = u.Tx.Queries().InsertSession(session)
+	if tr != nil {
+		return "", tr
 	}
 
-	usernameErr := util.IsValidUsername(credentials.Username)
-	passwordErr := util.IsValidPassword(credentials.Password)
-	if usernameErr != nil || passwordErr != nil {
-		u.Error(errors.New().Status(http.StatusBadRequest).
-			AddErr(errors.LvlDebug, usernameErr).AndErr(passwordErr).
-			Append(errors.LvlDebug, "Input did not pass validation").
-			Append(errors.LvlWordy, "Malformed request").
-			Append(errors.LvlBroad, "Could not log in"),
-		)
-		return
+	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
+	if tr != nil {
+		return "", tr
+	}
+	tr = u.Tx.Queries().UpdateSessionHash(session.SessionId, crypto.GetSha256Hash(serverSecret, session.SessionId.Bytes(), secret))
+	if tr != nil {
+		return "", tr
+	}
+
+	token, tr := auth.NewToken(u.Config, u.Tx, userId, session.SessionId, secret)
+	if tr != nil {
+		return "", tr.
+			Append(errors.LvlWordy, "Could not generate token")
+	}
+
+	return token, nil
+}
+
+// Creates a session for a user who proved their identity with a first factor.
+// Users with a second factor only get a pre-authentication token for now.
+func completeLogin(u *util.HandlerUtility, c *gin.Context, userId types.ID, isShortLived bool) (*gin.H, *errors.ErrorTrace) {
+	tr := checkEmailVerified(u, userId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	factors, tr := getSecondFactors(u, userId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not check if user %v uses two-factor authentication", userId.String()).
+			Append(errors.LvlWordy, "Database error")
+	}
+	if factors.Any() || u.Config.Settings.RequireTwoFactor.Enabled {
+		preauthToken, tr := createPreauthSession(u, userId, isShortLived)
+		if tr != nil {
+			return nil, tr
+		}
+
+		return &gin.H{
+			"mfa_required":        true,
+			"enrollment_required": !factors.Any(),
+			"methods":             factors.Methods(),
+			"preauth_token":       preauthToken,
+		}, nil
+	}
+
+	token, tr := createSession(u, c, userId, isShortLived)
+	if tr != nil {
+		return nil, tr
 	}
 
+	return &gin.H{"token": token}, nil
+}
+
+// Checks the password of a local user, or of a directory user against the LDAP server
+func authenticatePassword(u *util.HandlerUtility, credentials *auth.BasicAuth) (types.ID, *errors.ErrorTrace) {
 	// Check if the user exists
 	userId, err := u.Tx.Queries().GetUserIdFromUsername(credentials.Username)
 	if err != nil {
-		u.Error(err.Status(http.StatusUnauthorized).
-			Append(errors.LvlDebug, "Could not find ID for user %v", credentials.Username).
-			Append(errors.LvlPlain, "Invalid credentials").
-			Append(errors.LvlBroad, "Could not log in"),
-		)
+		// Users that are not known yet might be in the directory
+		if u.Config.Env.LdapEnabled() {
+			return loginLdapUser(u, credentials, types.EmptyId())
+		}
 
 		// Hash the wrong password to prevent timing attacks
 		_, _ = auth.SecurePassword(credentials.Password, u.Config)
 
-		return
+		return types.EmptyId(), err.Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Could not find ID for user %v", credentials.Username).
+			Append(errors.LvlPlain, "Invalid credentials")
+	}
+
+	// Users provisioned from the directory do not have a password of their own
+	isLdapUser, err := u.Tx.Queries().IsLdapUser(userId)
+	if err != nil {
+		return types.EmptyId(), err.
+			Append(errors.LvlWordy, "Database error")
+	}
+	if isLdapUser {
+		if !u.Config.Env.LdapEnabled() {
+			return types.EmptyId(), errors.New().Status(http.StatusUnauthorized).
+				Append(errors.LvlDebug, "User %v was provisioned from LDAP, but LDAP is disabled", userId.String()).
+				Append(errors.LvlPlain, "Invalid credentials")
+		}
+		return loginLdapUser(u, credentials, userId)
 	}
 
 	// Get the user's password
 	savedPassword, err := u.Tx.Queries().GetPassword(userId)
 	if err != nil {
-		u.Error(err.Status(http.StatusUnauthorized).
-			Append(errors.LvlDebug, "Could not get password for user %v", userId.String()).
-			Append(errors.LvlPlain, "Invalid credentials").
-			Append(errors.LvlBroad, "Could not log in"),
-		)
-
 		// Hash the wrong password to prevent timing attacks
 		_, _ = auth.SecurePassword(credentials.Password, u.Config)
 
-		return
+		return types.EmptyId(), err.Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Could not get password for user %v", userId.String()).
+			Append(errors.LvlPlain, "Invalid credentials")
 	}
 
 	// Verify the password
 	if !auth.VerifyPassword(credentials.Password, savedPassword, u.Config) {
-		u.Error(errors.New().Status(http.StatusUnauthorized).
+		return types.EmptyId(), errors.New().Status(http.StatusUnauthorized).
 			Append(errors.LvlDebug, "Wrong password").
-			Append(errors.LvlPlain, "Invalid credentials").
-			Append(errors.LvlBroad, "Could not log in"),
-		)
-		return
+			Append(errors.LvlPlain, "Invalid credentials")
 	}
 
 	// Silently update the user's password to a newer algorithm if applicable
@@ -87,93 +147,82 @@ func Login(c *gin.Context) {
 		u.Logger.Infof("updating password %v for user to newer algorithm", credentials.Username)
 		newPassword, err := auth.SecurePassword(credentials.Password, u.Config)
 		if err != nil {
-			u.Error(err.
+			return types.EmptyId(), err.
 				Append(errors.LvlDebug, "Could not rehash password").
-				Append(errors.LvlWordy, "Internal server error").
-				Append(errors.LvlBroad, "Could not log in"),
-			)
-			return
+				Append(errors.LvlWordy, "Internal server error")
 		}
 		err = u.Tx.Queries().UpdatePassword(userId, newPassword)
 		if err != nil {
-			u.Error(err.
+			return types.EmptyId(), err.
 				Append(errors.LvlDebug, "Could not update password").
-				Append(errors.LvlWordy, "Database error").
-				Append(errors.LvlBroad, "Could not log in"),
-			)
-			return
+				Append(errors.LvlWordy, "Database error")
 		}
 	}
 
-	// Check if the user account is disabled
-	enabled, err := u.Tx.Queries().IsUserEnabled(userId)
-	if err != nil {
-		u.Error(err.
-			Append(errors.LvlDebug, "Could not check if user %v is enabled", userId.String()).
-			Append(errors.LvlWordy, "Database error").
+	return userId, nil
+}
+
+func Login(c *gin.Context) {
+	// Parsing
+	u := util.GetUtil(c)
+
+	credentials := auth.BasicAuth{}
+	if err := c.ShouldBind(&credentials); err != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse credentials").
+			Append(errors.LvlWordy, "Malformed request").
 			Append(errors.LvlBroad, "Could not log in"),
 		)
 		return
 	}
-	if !enabled {
-		u.Error(errors.New().Status(http.StatusForbidden).
-			Append(errors.LvlPlain, "Your account is disabled."),
+
+	usernameErr := util.IsValidUsername(credentials.Username)
+	passwordErr := util.IsValidPassword(credentials.Password)
+	if usernameErr != nil || passwordErr != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, usernameErr).AndErr(passwordErr).
+			Append(errors.LvlDebug, "Input did not pass validation").
+			Append(errors.LvlWordy, "Malformed request").
+			Append(errors.LvlBroad, "Could not log in"),
 		)
 		return
 	}
 
-	// Create new session
-	secret, err := crypto.GenerateRandomBytes(256)
+	userId, err := authenticatePassword(u, &credentials)
 	if err != nil {
 		u.Error(err.
-			Append(errors.LvlWordy, "Could not generate random bytes").
-			AltStr(errors.LvlBroad, "Could not create API key"),
+			Append(errors.LvlBroad, "Could not log in"),
 		)
 		return
 	}
 
-	session := &types.Session{
-		UserId:           userId,
-		UserAgent:        c.Request.UserAgent(),
-		LastIpAddress:    util.DetermineClientAddress(c),
-		InitialIpAddress: util.DetermineClientAddress(c),
-		IsShortLived:     c.PostForm("remember") != "true",
-		IsApi:            false,
-		SecretHash:       []byte{},
-	}
-	err = u.Tx.Queries().InsertSession(session)
+	// Check if the user account is disabled
+	enabled, err := u.Tx.Queries().IsUserEnabled(userId)
 	if err != nil {
 		u.Error(err.
+			Append(errors.LvlDebug, "Could not check if user %v is enabled", userId.String()).
+			Append(errors.LvlWordy, "Database error").
 			Append(errors.LvlBroad, "Could not log in"),
 		)
 		return
 	}
-
-	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
-	if tr != nil {
-		u.Error(tr)
-		c.Abort()
-		return
-	}
-	err = u.Tx.Queries().UpdateSessionHash(session.SessionId, crypto.GetSha256Hash(serverSecret, session.SessionId.Bytes(), secret))
-	if err != nil {
-		u.Error(err.
-			Append(errors.LvlBroad, "Could not log in"),
+	if !enabled {
+		u.Error(errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlPlain, "Your account is disabled."),
 		)
 		return
 	}
 
-	// Generate the token
-	token, err := auth.NewToken(u.Config, u.Tx, userId, session.SessionId, secret)
+	response, err := completeLogin(u, c, userId, c.PostForm("remember") != "true")
 	if err != nil {
 		u.Error(err.
-			Append(errors.LvlWordy, "Could not generate token").
 			Append(errors.LvlBroad, "Could not log in"),
 		)
 		return
 	}
 
-	u.Success(&gin.H{"token": token})
+	u.Success(response)
 }
 
 type registerPayload struct {
@@ -300,26 +349,13 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Create new session
-	secret, err := crypto.GenerateRandomBytes(256)
-	if err != nil {
-		u.Error(err.
-			Append(errors.LvlWordy, "Could not generate random bytes").
-			AltStr(errors.LvlBroad, "Could not create API key"),
-		)
-		return
-	}
-
-	session := &types.Session{
-		UserId:           userId,
-		UserAgent:        c.Request.UserAgent(),
-		InitialIpAddress: util.DetermineClientAddress(c),
-		LastIpAddress:    util.DetermineClientAddress(c),
-		IsShortLived:     c.PostForm("remember") != "true",
-		IsApi:            false,
-		SecretHash:       []byte{},
+	// An invite sent to the email address proves that the user owns it
+	if invite != nil && invite.Email != "" {
+		err = u.Tx.Queries().SetUserVerified(userId, user.Email)
+		user.Verified = true
+	} else {
+		err = sendEmailVerification(u, user)
 	}
-	err = u.Tx.Queries().InsertSession(session)
 	if err != nil {
 		u.Error(err.
 			Append(errors.LvlBroad, "Could not register"),
@@ -327,31 +363,21 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
-	if tr != nil {
-		u.Error(tr)
-		c.Abort()
-		return
-	}
-	err = u.Tx.Queries().UpdateSessionHash(session.SessionId, crypto.GetSha256Hash(serverSecret, session.SessionId.Bytes(), secret))
-	if err != nil {
-		u.Error(err.
-			Append(errors.LvlBroad, "Could not register"),
-		)
+	if isEmailVerificationRequired(u, user) {
+		u.Success(&gin.H{"verification_required": true})
 		return
 	}
 
-	// Generate the token
-	token, err := auth.NewToken(u.Config, u.Tx, userId, session.SessionId, secret)
+	// New users are held to the same two-factor requirements as everyone logging in
+	response, err := completeLogin(u, c, userId, c.PostForm("remember") != "true")
 	if err != nil {
 		u.Error(err.
-			Append(errors.LvlWordy, "Could not generate token").
 			Append(errors.LvlBroad, "Could not register"),
 		)
 		return
 	}
 
-	u.Success(&gin.H{"token": token})
+	u.Success(response)
 }
 
 func RegistrationEnabled(c *gin.Context) {
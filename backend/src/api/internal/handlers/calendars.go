@@ -3,6 +3,7 @@ package handlers
 import (
 	"luna-backend/api/internal/util"
 	"luna-backend/cache"
+	"luna-backend/constants"
 	"luna-backend/errors"
 	"luna-backend/types"
 	"net/http"
@@ -12,15 +13,17 @@ import (
 )
 
 type exposedCalendar struct {
-	Id           types.ID     `json:"id"`
-	Source       types.ID     `json:"source"`
-	Name         string       `json:"name"`
-	Desc         string       `json:"desc"`
-	Color        *types.Color `json:"color"`
-	Overridden   bool         `json:"overridden"`
-	CanEdit      bool         `json:"can_edit"` // TODO: might exclude from here and add to "detailed" view instead
-	CanDelete    bool         `json:"can_delete"`
-	CanAddEvents bool         `json:"can_add_events"`
+	Id           types.ID                 `json:"id"`
+	Source       types.ID                 `json:"source"`
+	Name         string                   `json:"name"`
+	Desc         string                   `json:"desc"`
+	Color        *types.Color             `json:"color"`
+	Overridden   bool                     `json:"overridden"`
+	CanEdit      bool                     `json:"can_edit"` // TODO: might exclude from here and add to "detailed" view instead
+	CanDelete    bool                     `json:"can_delete"`
+	CanAddEvents bool                     `json:"can_add_events"`
+	CanAddTasks  bool                     `json:"can_add_tasks"`
+	Sync         *types.CalendarSyncState `json:"sync"` // only set for calendars that are mirrored locally
 }
 
 func GetCalendars(c *gin.Context) {
@@ -45,7 +48,7 @@ func GetCalendars(c *gin.Context) {
 
 	// Get the associated calendars
 	calsFromSource, err := source.GetCalendars(u.Tx.Queries())
-
+	u.Tx.Queries().ReportSourceHealth(source.GetId(), err)
 	if err != nil {
 		u.Error(err)
 		return
@@ -57,6 +60,16 @@ func GetCalendars(c *gin.Context) {
 		return
 	}
 
+	calIds := make([]types.ID, len(cals))
+	for i, cal := range cals {
+		calIds[i] = cal.GetId()
+	}
+	syncStates, err := u.Tx.Queries().GetCalendarSyncStates(calIds)
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
 	// Convert to exposed format
 	convertedCals := make([]exposedCalendar, len(cals))
 	for i, cal := range cals {
@@ -73,6 +86,8 @@ func GetCalendars(c *gin.Context) {
 			CanEdit:      cal.CanEdit(),
 			CanDelete:    cal.CanDelete(),
 			CanAddEvents: cal.CanAddEvents(),
+			CanAddTasks:  canAddTasks(cal),
+			Sync:         syncStates[cal.GetId()],
 		}
 	}
 
@@ -105,6 +120,15 @@ func GetCalendar(c *gin.Context) {
 
 	u.Config.Cache.Cache(userId, cal)
 
+	syncState, err := u.Tx.Queries().GetCalendarSyncState(cal.GetId())
+	if err != nil {
+		u.Error(err)
+		return
+	}
+	if syncState.Method == "" {
+		syncState = nil
+	}
+
 	// Convert to exposed format
 	convertedCal := exposedCalendar{
 		Id:         cal.GetId(),
@@ -117,6 +141,8 @@ func GetCalendar(c *gin.Context) {
 		CanEdit:      cal.CanEdit(),
 		CanDelete:    cal.CanDelete(),
 		CanAddEvents: cal.CanAddEvents(),
+		CanAddTasks:  canAddTasks(cal),
+		Sync:         syncState,
 	}
 
 	u.Success(&gin.H{"calendar": convertedCal})
@@ -170,6 +196,8 @@ func PutCalendar(c *gin.Context) {
 		return
 	}
 
+	u.Tx.Queries().NotifyChange(userId, types.NewCalendarChange(constants.ChangeActionCreated, cal.GetId(), sourceId))
+
 	u.Success(&gin.H{"id": cal.GetId().String()})
 }
 
@@ -218,6 +246,8 @@ func PatchCalendar(c *gin.Context) {
 		return
 	}
 
+	u.Tx.Queries().NotifyChange(userId, types.NewCalendarChange(constants.ChangeActionUpdated, calendarId, calendar.GetSource().GetId()))
+
 	u.Success(nil)
 }
 
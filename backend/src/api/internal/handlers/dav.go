@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"luna-backend/api/internal/dav"
+	"luna-backend/api/internal/util"
+	"luna-backend/errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/gin-gonic/gin"
+)
+
+func ServeDav(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	backend := dav.NewBackend(u, userId)
+	handler := caldav.Handler{
+		Backend: backend,
+		Prefix:  dav.Prefix,
+	}
+
+	// The response is only written once the request's transaction is committed,
+	// so the CalDAV handler has to write into a buffer first.
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, c.Request.WithContext(u.Context))
+
+	// Errors are reported through the regular error channel, so that the transaction is rolled back.
+	// Failed preconditions are checked before anything is written, and their body tells the client which one failed.
+	if recorder.Code >= http.StatusBadRequest && !backend.PreconditionFailed() {
+		tr := backend.GetErrorTrace()
+		if tr == nil {
+			tr = errors.New().Status(recorder.Code).
+				Append(errors.LvlDebug, "%s", recorder.Body.String())
+		}
+		u.Error(tr.
+			Append(errors.LvlDebug, "Could not handle %v request for %v", c.Request.Method, c.Request.URL.Path).
+			AltStr(errors.LvlBroad, "CalDAV request failed"))
+		return
+	}
+
+	for key, values := range recorder.Header() {
+		if key == "Content-Type" {
+			continue
+		}
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
+		}
+	}
+
+	u.ResponseRawWithStatus(recorder.Code, recorder.Body.Bytes(), recorder.Header().Get("Content-Type"))
+}
+
+func RedirectDav(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, dav.Prefix+"/")
+}
@@ -1,88 +1,200 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"luna-backend/api/internal/util"
 	"luna-backend/cache"
+	"luna-backend/constants"
+	"luna-backend/db"
 	"luna-backend/errors"
 	"luna-backend/types"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type exposedEvent struct {
-	Id         types.ID         `json:"id"`
-	Calendar   types.ID         `json:"calendar"`
-	Name       string           `json:"name"`
-	Desc       string           `json:"desc"`
-	Color      *types.Color     `json:"color"`
-	Date       *types.EventDate `json:"date"`
-	Overridden bool             `json:"overridden"`
-	CanEdit    bool             `json:"can_edit"` // TODO: might exclude from here and add to "detailed" view instead
-	CanDelete  bool             `json:"can_delete"`
+	Id           types.ID                 `json:"id"`
+	Calendar     types.ID                 `json:"calendar"`
+	Name         string                   `json:"name"`
+	Desc         string                   `json:"desc"`
+	Color        *types.Color             `json:"color"`
+	Date         *types.EventDate         `json:"date"`
+	Location     *types.EventLocation     `json:"location"`
+	Participants *types.EventParticipants `json:"participants"`
+	Reminders    []*types.EventReminder   `json:"reminders"`
+	Overridden   bool                     `json:"overridden"`
+	CanEdit      bool                     `json:"can_edit"` // TODO: might exclude from here and add to "detailed" view instead
+	CanDelete    bool                     `json:"can_delete"`
 }
 
-func GetEvents(c *gin.Context) {
-	u := util.GetUtil(c)
+// Parses the optional "date_recurrence" field containing an RRULE.
+// An empty field keeps the current recurrence, while "false" removes it.
+func parseEventRecurrence(c *gin.Context, current *types.EventRecurrence) (*types.EventRecurrence, *errors.ErrorTrace) {
+	rawRecurrence := c.PostForm("date_recurrence")
+	switch rawRecurrence {
+	case "":
+		return current, nil
+	case "false":
+		return types.EmptyEventRecurrence(), nil
+	}
 
-	userId := util.GetUserId(c)
+	recurrence, err := types.EventRecurrenceFromLines([]string{rawRecurrence})
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed recurrence rule")
+	}
 
-	calendarId, tr := util.GetId(c, "calendar")
-	if tr != nil {
-		u.Error(tr)
-		return
+	return recurrence, nil
+}
+
+// Parses the optional "location", "url" and "geo" fields, with the coordinates given as "latitude,longitude".
+// Fields that are not sent keep their current value, while empty fields remove it.
+func parseEventLocation(c *gin.Context, current *types.EventLocation) (*types.EventLocation, bool, *errors.ErrorTrace) {
+	location := current.Clone()
+	if location == nil {
+		location = &types.EventLocation{}
 	}
+	changed := false
 
-	// Get the requested calendar
-	calendar, tr := cache.GetCached(u.Config.Cache, userId, calendarId, u.Context, func() (types.Calendar, *errors.ErrorTrace) {
-		return u.Tx.Queries().GetCalendar(userId, calendarId, u.Context, u.Config)
-	})
-	if tr != nil {
-		u.Error(tr)
-		return
+	if name, exists := c.GetPostForm("location"); exists {
+		location.Name = name
+		changed = true
 	}
 
-	// Get the associated events
+	if rawUrl, exists := c.GetPostForm("url"); exists {
+		if rawUrl == "" {
+			location.Url = nil
+		} else {
+			url, err := types.NewUrl(rawUrl)
+			if err != nil || url.URL().Scheme == "" {
+				return nil, false, errors.New().Status(http.StatusBadRequest).
+					AddErr(errors.LvlDebug, err).
+					Append(errors.LvlPlain, "Malformed URL")
+			}
+			location.Url = url
+		}
+		changed = true
+	}
+
+	if rawGeo, exists := c.GetPostForm("geo"); exists {
+		if rawGeo == "" {
+			location.Geo = nil
+		} else {
+			geo, err := types.ParseGeoCoordinates(rawGeo, ",")
+			if err != nil {
+				return nil, false, errors.New().Status(http.StatusBadRequest).
+					AddErr(errors.LvlDebug, err).
+					Append(errors.LvlPlain, "Malformed coordinates")
+			}
+			location.Geo = geo
+		}
+		changed = true
+	}
+
+	if location.IsEmpty() {
+		location = nil
+	}
+
+	return location, changed, nil
+}
+
+// Parses the optional "reminders" field containing a JSON array of reminders.
+// An empty field keeps the current reminders, while "[]" removes all of them.
+func parseEventReminders(c *gin.Context, current []*types.EventReminder) ([]*types.EventReminder, *errors.ErrorTrace) {
+	rawReminders := c.PostForm("reminders")
+	if rawReminders == "" {
+		return current, nil
+	}
+
+	reminders := []*types.EventReminder{}
+	err := json.Unmarshal([]byte(rawReminders), &reminders)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed reminders")
+	}
+	for _, reminder := range reminders {
+		if reminder == nil {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "Malformed reminders")
+		}
+	}
+
+	return reminders, nil
+}
+
+// Parses the optional "participants" field containing a JSON object with the organizer and attendees.
+// An empty field keeps the current participants, while "{}" removes all of them.
+// Whether an attendee is the account owner is decided by the source, so the self flags are taken from the current participants.
+func parseEventParticipants(c *gin.Context, current *types.EventParticipants) (*types.EventParticipants, *errors.ErrorTrace) {
+	rawParticipants := c.PostForm("participants")
+	if rawParticipants == "" {
+		return current, nil
+	}
+
+	participants := &types.EventParticipants{}
+	err := json.Unmarshal([]byte(rawParticipants), participants)
+	if err == nil {
+		err = participants.Validate()
+	}
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed participants")
+	}
+
+	for _, attendee := range participants.Attendees {
+		currentAttendee := current.Find(attendee.Email)
+		attendee.Self = currentAttendee != nil && currentAttendee.Self
+	}
+
+	if participants.IsEmpty() {
+		return nil, nil
+	}
+	return participants, nil
+}
+
+// Parses the "start" and "end" query parameters, limiting the range to one year
+func parseEventTimeRange(c *gin.Context) (time.Time, time.Time, *errors.ErrorTrace) {
 	startStr := c.Query("start")
 	startTime, err := time.Parse(time.RFC3339, startStr)
 	if err != nil {
-		u.Error(errors.New().
+		return time.Time{}, time.Time{}, errors.New().
 			AddErr(errors.LvlDebug, err).
-			Append(errors.LvlPlain, "Missing or malformed start time"))
-		return
+			Append(errors.LvlPlain, "Missing or malformed start time")
 	}
 	endStr := c.Query("end")
 	endTime, err := time.Parse(time.RFC3339, endStr)
 	if err != nil {
-		u.Error(errors.New().
+		return time.Time{}, time.Time{}, errors.New().
 			AddErr(errors.LvlDebug, err).
-			Append(errors.LvlPlain, "Missing or malformed end time"))
-		return
+			Append(errors.LvlPlain, "Missing or malformed end time")
 	}
 	if startTime.After(endTime) {
-		u.Error(errors.New().
-			Append(errors.LvlPlain, "Start time must not be after end time"))
-		return
+		return time.Time{}, time.Time{}, errors.New().
+			Append(errors.LvlPlain, "Start time must not be after end time")
 	}
 	if endTime.Sub(startTime) > time.Hour*24*365 {
 		endTime = startTime.Add(time.Hour * 24 * 365)
 	}
+	return startTime, endTime, nil
+}
 
-	eventsFromCal, tr := calendar.GetEvents(startTime, endTime, u.Tx.Queries())
-	if tr != nil {
-		u.Error(tr)
-		return
-	}
-
+// Expands recurring events within the time range, applies overrides and converts them to the exposed format
+func exposeEvents(eventsFromCal []types.Event, startTime time.Time, endTime time.Time, tx *db.Transaction) ([]exposedEvent, *errors.ErrorTrace) {
 	// Expand recurring events
 	expandedEvents := make([]types.Event, len(eventsFromCal))
 	count := 0
 	for _, event := range eventsFromCal {
 		expanded, tr := types.ExpandRecurrence(event, &startTime, &endTime)
 		if tr != nil {
-			u.Error(tr)
-			return
+			return nil, tr
 		}
 
 		if len(expanded) > 1 {
@@ -98,10 +210,9 @@ func GetEvents(c *gin.Context) {
 	}
 
 	// Save in the database and apply overrides
-	events, tr := u.Tx.Queries().OverrideEvents(expandedEvents[:count])
+	events, tr := tx.Queries().OverrideEvents(expandedEvents[:count])
 	if tr != nil {
-		u.Error(tr)
-		return
+		return nil, tr
 	}
 
 	// Convert to exposed format
@@ -112,21 +223,267 @@ func GetEvents(c *gin.Context) {
 		}
 
 		convertedEvents[i] = exposedEvent{
-			Id:         event.GetId(),
-			Calendar:   event.GetCalendar().GetId(),
-			Name:       event.GetName(),
-			Desc:       event.GetDesc(),
-			Color:      event.GetColor(),
-			Date:       event.GetDate(),
-			Overridden: event.GetOverridden(),
-			CanEdit:    event.CanEdit(),
-			CanDelete:  event.CanDelete(),
+			Id:           event.GetId(),
+			Calendar:     event.GetCalendar().GetId(),
+			Name:         event.GetName(),
+			Desc:         event.GetDesc(),
+			Color:        event.GetColor(),
+			Date:         event.GetDate(),
+			Location:     event.GetLocation(),
+			Participants: event.GetParticipants(),
+			Reminders:    event.GetReminders(),
+			Overridden:   event.GetOverridden(),
+			CanEdit:      event.CanEdit(),
+			CanDelete:    event.CanDelete(),
 		}
 	}
 
+	return convertedEvents, nil
+}
+
+func GetEvents(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	calendarId, tr := util.GetId(c, "calendar")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Get the requested calendar
+	calendar, tr := cache.GetCached(u.Config.Cache, userId, calendarId, u.Context, func() (types.Calendar, *errors.ErrorTrace) {
+		return u.Tx.Queries().GetCalendar(userId, calendarId, u.Context, u.Config)
+	})
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Get the associated events
+	startTime, endTime, tr := parseEventTimeRange(c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	eventsFromCal, tr := calendar.GetEvents(startTime, endTime, u.Tx.Queries())
+	u.Tx.Queries().ReportSourceHealth(calendar.GetSource().GetId(), tr)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	convertedEvents, tr := exposeEvents(eventsFromCal, startTime, endTime, u.Tx)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
 	u.Success(&gin.H{"events": convertedEvents})
 }
 
+type exposedEventsError struct {
+	Source   *types.ID `json:"source,omitempty"`
+	Calendar *types.ID `json:"calendar,omitempty"` // missing if the calendars of the source could not be listed
+	Error    string    `json:"error"`
+}
+
+// Either all calendars of a source or the requested calendars of one source.
+// Calendars of the same source share its connection, so they are always
+// fetched by the same job.
+type eventsFetchJob struct {
+	source    types.Source
+	calendars []types.Calendar
+}
+
+type eventsFetchResult struct {
+	events []exposedEvent
+	errors []exposedEventsError
+}
+
+// Fetches the events of many calendars at once, so that one unreachable source does not fail the whole request
+func GetAllEvents(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	startTime, endTime, tr := parseEventTimeRange(c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	jobs := []*eventsFetchJob{}
+	eventsErrors := []exposedEventsError{}
+	if rawCalendars := c.Query("calendars"); rawCalendars != "" {
+		calendarIds := []types.ID{}
+		for _, rawId := range strings.Split(rawCalendars, ",") {
+			calendarId, err := types.IdFromString(strings.TrimSpace(rawId))
+			if err != nil || calendarId.IsEmpty() {
+				u.Error(errors.New().Status(http.StatusBadRequest).
+					AddErr(errors.LvlDebug, err).
+					Append(errors.LvlWordy, "Malformed calendar id %v", rawId).
+					Append(errors.LvlPlain, "Malformed request"))
+				return
+			}
+			calendarIds = append(calendarIds, calendarId)
+		}
+
+		jobsBySource := map[types.ID]*eventsFetchJob{}
+		for _, calendarId := range calendarIds {
+			calendar, tr := cache.GetCached(u.Config.Cache, userId, calendarId, u.Context, func() (types.Calendar, *errors.ErrorTrace) {
+				return u.Tx.Queries().GetCalendar(userId, calendarId, u.Context, u.Config)
+			})
+			if tr != nil {
+				eventsErrors = append(eventsErrors, exposedEventsError{
+					Calendar: &calendarId,
+					Error:    tr.Serialize(u.Config.LoggingVerbosity()),
+				})
+				continue
+			}
+
+			sourceId := calendar.GetSource().GetId()
+			job, exists := jobsBySource[sourceId]
+			if !exists {
+				job = &eventsFetchJob{}
+				jobsBySource[sourceId] = job
+				jobs = append(jobs, job)
+			}
+			job.calendars = append(job.calendars, calendar)
+		}
+	} else {
+		sources, tr := u.Tx.Queries().GetSourcesByUser(userId, u.Context, u.Config)
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+		for _, source := range sources {
+			jobs = append(jobs, &eventsFetchJob{source: source})
+		}
+	}
+
+	// Every worker uses its own transaction, since a transaction cannot be used concurrently
+	results := make([]*eventsFetchResult, len(jobs))
+	jobIndices := make(chan int)
+	wg := sync.WaitGroup{}
+	for range min(u.Config.Env.EVENTS_FETCH_WORKERS, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobIndices {
+				results[i] = fetchEventsJob(u, userId, jobs[i], startTime, endTime)
+			}
+		}()
+	}
+	for i := range jobs {
+		jobIndices <- i
+	}
+	close(jobIndices)
+	wg.Wait()
+
+	events := []exposedEvent{}
+	for _, result := range results {
+		events = append(events, result.events...)
+		eventsErrors = append(eventsErrors, result.errors...)
+	}
+
+	u.Success(&gin.H{"events": events, "errors": eventsErrors})
+}
+
+func fetchEventsJob(u *util.HandlerUtility, userId types.ID, job *eventsFetchJob, startTime time.Time, endTime time.Time) *eventsFetchResult {
+	result := &eventsFetchResult{
+		events: []exposedEvent{},
+		errors: []exposedEventsError{},
+	}
+	fail := func(sourceId *types.ID, calendarId *types.ID, tr *errors.ErrorTrace) {
+		result.errors = append(result.errors, exposedEventsError{
+			Source:   sourceId,
+			Calendar: calendarId,
+			Error:    tr.Serialize(u.Config.LoggingVerbosity()),
+		})
+	}
+
+	var jobSourceId types.ID
+	if job.source != nil {
+		jobSourceId = job.source.GetId()
+	} else {
+		jobSourceId = job.calendars[0].GetSource().GetId()
+	}
+	sourceId := &jobSourceId
+
+	ctx, cancel := context.WithTimeout(u.Context, u.Config.Env.EVENTS_FETCH_SOURCE_TIMEOUT)
+	defer cancel()
+
+	tx, tr := u.Db.BeginTransaction(ctx)
+	if tr != nil {
+		fail(sourceId, nil, tr)
+		return result
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			tr := tx.Rollback(u.Logger)
+			if tr != nil {
+				u.Logger.Error(tr.Serialize(errors.LvlDebug))
+			}
+		}
+	}()
+
+	calendars := []types.Calendar{}
+	if job.source != nil {
+		job.source.SupplyContext(ctx)
+		calsFromSource, tr := job.source.GetCalendars(tx.Queries())
+		tx.Queries().ReportSourceHealth(job.source.GetId(), tr)
+		if tr != nil {
+			fail(sourceId, nil, tr)
+			return result
+		}
+		calendars, tr = tx.Queries().OverrideCalendars(calsFromSource)
+		if tr != nil {
+			fail(sourceId, nil, tr)
+			return result
+		}
+		for _, cal := range calendars {
+			u.Config.Cache.Cache(userId, cal)
+		}
+	} else {
+		for _, calendar := range job.calendars {
+			calendar.SupplyContext(ctx)
+		}
+		calendars = job.calendars
+	}
+
+	for _, calendar := range calendars {
+		calendarSourceId := calendar.GetSource().GetId()
+		calendarId := calendar.GetId()
+
+		eventsFromCal, tr := calendar.GetEvents(startTime, endTime, tx.Queries())
+		tx.Queries().ReportSourceHealth(calendarSourceId, tr)
+		if tr != nil {
+			fail(&calendarSourceId, &calendarId, tr)
+			continue
+		}
+
+		convertedEvents, tr := exposeEvents(eventsFromCal, startTime, endTime, tx)
+		if tr != nil {
+			fail(&calendarSourceId, &calendarId, tr)
+			continue
+		}
+		result.events = append(result.events, convertedEvents...)
+	}
+
+	// The events are returned even if the synchronization state could not be saved
+	finished = true
+	tr = tx.Commit(u.Logger)
+	if tr != nil {
+		u.Warn(tr.Append(errors.LvlWordy, "Could not save the events of some calendars"))
+	}
+
+	return result
+}
+
 func GetEvent(c *gin.Context) {
 	u := util.GetUtil(c)
 
@@ -153,13 +510,16 @@ func GetEvent(c *gin.Context) {
 
 	// Convert to exposed format
 	convertedCal := exposedEvent{
-		Id:         event.GetId(),
-		Calendar:   event.GetCalendar().GetId(),
-		Name:       event.GetName(),
-		Desc:       event.GetDesc(),
-		Color:      event.GetColor(),
-		Date:       event.GetDate(),
-		Overridden: event.GetOverridden(),
+		Id:           event.GetId(),
+		Calendar:     event.GetCalendar().GetId(),
+		Name:         event.GetName(),
+		Desc:         event.GetDesc(),
+		Color:        event.GetColor(),
+		Date:         event.GetDate(),
+		Location:     event.GetLocation(),
+		Participants: event.GetParticipants(),
+		Reminders:    event.GetReminders(),
+		Overridden:   event.GetOverridden(),
 		//Settings: event.GetSettings(),
 		CanEdit:   event.CanEdit(),
 		CanDelete: event.CanDelete(),
@@ -231,13 +591,52 @@ func PutEvent(c *gin.Context) {
 		u.Error(errors.New().Status(http.StatusBadRequest).
 			Append(errors.LvlPlain, "Cannot specify both end and duration"))
 		return
-	} else if endErr == nil {
-		date = types.NewEventDateFromEndTime(&eventDateStart, &eventDateEnd, eventDateAllDay, nil)
+	}
+
+	eventDateRecurrence, tr := parseEventRecurrence(c, nil)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	if endErr == nil {
+		date = types.NewEventDateFromEndTime(&eventDateStart, &eventDateEnd, eventDateAllDay, eventDateRecurrence)
 	} else {
-		date = types.NewEventDateFromDuration(&eventDateStart, &eventDateDuration, eventDateAllDay, nil)
+		date = types.NewEventDateFromDuration(&eventDateStart, &eventDateDuration, eventDateAllDay, eventDateRecurrence)
 	}
 
-	event, tr := calendar.AddEvent(eventName, eventDesc, eventColor, date, u.Tx.Queries())
+	eventLocation, _, tr := parseEventLocation(c, nil)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	eventParticipants, tr := parseEventParticipants(c, nil)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Events with attendees are organized by the user creating them unless specified otherwise
+	if eventParticipants != nil && eventParticipants.Organizer == nil && len(eventParticipants.Attendees) > 0 {
+		user, tr := u.Tx.Queries().GetUser(userId)
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+		eventParticipants.Organizer = &types.EventOrganizer{
+			Email: user.Email,
+			Name:  user.Username,
+		}
+	}
+
+	eventReminders, tr := parseEventReminders(c, []*types.EventReminder{})
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	event, tr := calendar.AddEvent(eventName, eventDesc, eventColor, date, eventLocation, eventParticipants, eventReminders, u.Tx.Queries())
 	if tr != nil {
 		u.Error(tr)
 		return
@@ -249,6 +648,10 @@ func PutEvent(c *gin.Context) {
 		return
 	}
 
+	u.Tx.Queries().NotifyChange(userId, types.NewEventChange(constants.ChangeActionCreated, event.GetId(), calendar.GetId()))
+
+	util.ScheduleEventChange(u, userId, nil, event)
+
 	u.Success(&gin.H{"id": event.GetId().String()})
 }
 
@@ -284,6 +687,9 @@ func PatchEvent(c *gin.Context) {
 	}
 
 	eventDateAllDay := c.PostForm("date_all_day") == "true"
+	if c.PostForm("date_all_day") == "" {
+		eventDateAllDay = event.GetDate().AllDay()
+	}
 
 	eventDateStartStr := c.PostForm("date_start")
 	eventDateStart, startErr := time.Parse(time.RFC3339, eventDateStartStr)
@@ -294,7 +700,34 @@ func PatchEvent(c *gin.Context) {
 	eventDateEnd, endErr := time.Parse(time.RFC3339, eventDateEndStr)
 	eventDateDuration, durationErr := time.ParseDuration(eventDateDurationStr)
 
-	if !isOverridden && (newEventName == "" && newEventDesc == event.GetDesc() && (newEventColor == event.GetColor() || colErr != nil) && startErr != nil && endErr != nil && durationErr != nil) {
+	recurrenceChanged := c.PostForm("date_recurrence") != ""
+	eventDateRecurrence, err := parseEventRecurrence(c, event.GetDate().Recurrence())
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
+	newEventLocation, locationChanged, err := parseEventLocation(c, event.GetLocation())
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
+	participantsChanged := c.PostForm("participants") != ""
+	newEventParticipants, err := parseEventParticipants(c, event.GetParticipants())
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
+	remindersChanged := c.PostForm("reminders") != ""
+	newEventReminders, err := parseEventReminders(c, event.GetReminders())
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
+	if !isOverridden && (newEventName == "" && newEventDesc == event.GetDesc() && (newEventColor == event.GetColor() || colErr != nil) && startErr != nil && endErr != nil && durationErr != nil && !recurrenceChanged && !locationChanged && !participantsChanged && !remindersChanged) {
 		u.Error(errors.New().Status(http.StatusBadRequest).
 			Append(errors.LvlPlain, "Nothing to change"))
 		return
@@ -309,37 +742,103 @@ func PatchEvent(c *gin.Context) {
 	}
 
 	var newEventDate *types.EventDate
-	if startErr != nil && endErr != nil && durationErr != nil {
+	if startErr != nil && endErr != nil && durationErr != nil && !recurrenceChanged {
 		newEventDate = event.GetDate()
 	} else {
 		if startErr != nil {
 			eventDateStart = *event.GetDate().Start()
 		}
-		if endErr != nil && durationErr == nil {
+		if endErr != nil && durationErr != nil {
 			if event.GetDate().SpecifyDuration() {
 				eventDateDuration = *event.GetDate().Duration()
-				newEventDate = types.NewEventDateFromDuration(&eventDateStart, &eventDateDuration, eventDateAllDay, nil)
+				newEventDate = types.NewEventDateFromDuration(&eventDateStart, &eventDateDuration, eventDateAllDay, eventDateRecurrence)
 			} else {
 				eventDateEnd = *event.GetDate().End()
-				newEventDate = types.NewEventDateFromEndTime(&eventDateStart, &eventDateEnd, eventDateAllDay, nil)
+				newEventDate = types.NewEventDateFromEndTime(&eventDateStart, &eventDateEnd, eventDateAllDay, eventDateRecurrence)
 			}
 		} else if endErr == nil && durationErr == nil {
 			u.Error(errors.New().Status(http.StatusBadRequest).
 				Append(errors.LvlPlain, "Cannot specify both end and duration"))
 			return
 		} else if endErr == nil {
-			newEventDate = types.NewEventDateFromEndTime(&eventDateStart, &eventDateEnd, eventDateAllDay, nil)
+			newEventDate = types.NewEventDateFromEndTime(&eventDateStart, &eventDateEnd, eventDateAllDay, eventDateRecurrence)
 		} else {
-			newEventDate = types.NewEventDateFromDuration(&eventDateStart, &eventDateDuration, eventDateAllDay, nil)
+			newEventDate = types.NewEventDateFromDuration(&eventDateStart, &eventDateDuration, eventDateAllDay, eventDateRecurrence)
 		}
 	}
 
-	_, err = event.GetCalendar().EditEvent(event, newEventName, newEventDesc, newEventColor, newEventDate, isOverridden, u.Tx.Queries())
+	newEvent, err := event.GetCalendar().EditEvent(event, newEventName, newEventDesc, newEventColor, newEventDate, newEventLocation, newEventParticipants, newEventReminders, isOverridden, u.Tx.Queries())
 	if err != nil {
 		u.Error(err)
 		return
 	}
 
+	u.Tx.Queries().NotifyChange(userId, types.NewEventChange(constants.ChangeActionUpdated, eventId, event.GetCalendar().GetId()))
+
+	// Overrides are only visible in Luna, so attendees do not need to know about them
+	if !isOverridden {
+		util.ScheduleEventChange(u, userId, event, newEvent)
+	}
+
+	u.Success(nil)
+}
+
+// Changes the participation status of the current user, who must be one of the event's attendees
+func PostEventParticipation(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	eventId, tr := util.GetId(c, "event")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	status := c.PostForm("status")
+	if !types.IsValidParticipationStatus(status) {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing or malformed participation status"))
+		return
+	}
+
+	event, tr := u.Tx.Queries().GetEvent(userId, eventId, u.Context, u.Config)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	participants := event.GetParticipants().Clone()
+	attendee := participants.FindSelf(user.Email)
+	if attendee == nil {
+		u.Error(errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "No attendee of event %v matches %v", eventId, user.Email).
+			Append(errors.LvlPlain, "You are not an attendee of this event"))
+		return
+	}
+
+	if attendee.Status == status {
+		u.Success(nil)
+		return
+	}
+	attendee.Status = status
+
+	newEvent, tr := event.GetCalendar().EditEvent(event, event.GetName(), event.GetDesc(), event.GetColor(), event.GetDate(), event.GetLocation(), participants, event.GetReminders(), false, u.Tx.Queries())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Tx.Queries().NotifyChange(userId, types.NewEventChange(constants.ChangeActionUpdated, eventId, event.GetCalendar().GetId()))
+
+	util.ScheduleParticipationChange(u, user, newEvent, attendee)
+
 	u.Success(nil)
 }
 
@@ -368,6 +867,8 @@ func DeleteEvent(c *gin.Context) {
 		return
 	}
 
+	util.ScheduleEventChange(u, userId, event, nil)
+
 	// Delete event entry from the database
 	err = u.Tx.Queries().DeleteEvent(userId, eventId)
 	if err != nil {
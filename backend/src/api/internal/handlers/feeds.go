@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"luna-backend/api/internal/util"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	icalProtocol "luna-backend/protocols/ical"
+	"luna-backend/types"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/gin-gonic/gin"
+)
+
+func parseFeedDays(c *gin.Context, field string, defaultValue int) (int, *errors.ErrorTrace) {
+	rawDays := c.PostForm(field)
+	if rawDays == "" {
+		return defaultValue, nil
+	}
+
+	days, err := strconv.Atoi(rawDays)
+	if err != nil {
+		return 0, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed %v", field)
+	}
+
+	if days < 0 || days > constants.MaxFeedDays {
+		return 0, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlDebug, "%v must be between 0 and %d", field, constants.MaxFeedDays).
+			Append(errors.LvlPlain, "Invalid %v", field)
+	}
+
+	return days, nil
+}
+
+func getFeedUrl(u *util.HandlerUtility, feedId types.ID, token string) string {
+	return fmt.Sprintf("%s/api/feeds/%s/%s.ics", u.Config.Env.PUBLIC_URL.String(), feedId.String(), token)
+}
+
+func GetCalendarFeeds(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	feeds, tr := u.Tx.Queries().GetCalendarFeeds(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{"feeds": feeds})
+}
+
+func PutCalendarFeed(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	calendarId, tr := util.GetId(c, "calendar")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Make sure that the calendar exists and belongs to the user
+	calendar, tr := u.Tx.Queries().GetCalendar(userId, calendarId, u.Context, u.Config)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	feedName := c.PostForm("name")
+	if feedName == "" {
+		feedName = calendar.GetName()
+	}
+
+	daysPast, tr := parseFeedDays(c, "days_past", constants.DefaultFeedDaysPast)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	daysFuture, tr := parseFeedDays(c, "days_future", constants.DefaultFeedDaysFuture)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	secret, tr := crypto.GenerateRandomBytes(32)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlWordy, "Could not generate random bytes").
+			AltStr(errors.LvlBroad, "Could not create feed"),
+		)
+		return
+	}
+
+	feed := &types.CalendarFeed{
+		UserId:     userId,
+		CalendarId: calendarId,
+		Name:       feedName,
+		DaysPast:   daysPast,
+		DaysFuture: daysFuture,
+		SecretHash: []byte{},
+	}
+	tr = u.Tx.Queries().InsertCalendarFeed(feed)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not create feed"),
+		)
+		return
+	}
+
+	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	tr = u.Tx.Queries().UpdateCalendarFeedHash(feed.FeedId, crypto.GetSha256Hash(serverSecret, feed.FeedId.Bytes(), secret))
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not create feed"),
+		)
+		return
+	}
+
+	// The secret is only ever shown once
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	u.Success(&gin.H{
+		"feed": feed,
+		"url":  getFeedUrl(u, feed.FeedId, token),
+	})
+}
+
+func DeleteCalendarFeed(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	feedId, tr := util.GetId(c, "feed")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	deleted, tr := u.Tx.Queries().DeleteCalendarFeed(userId, feedId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	if deleted {
+		u.Success(nil)
+	} else {
+		u.Error(errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlPlain, "Feed not found"))
+	}
+}
+
+// Public endpoint for other applications to subscribe to.
+// The feed ID and the secret in the URL are the only means of authentication.
+func GetCalendarFeedContent(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	feedId, tr := util.GetId(c, "feed")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	secret, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(c.Param("token"), ".ics"))
+	if err != nil {
+		u.Error(errors.New().Status(http.StatusNotFound).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not decode feed secret").
+			Append(errors.LvlPlain, "Feed not found"))
+		return
+	}
+
+	feed, tr := u.Tx.Queries().GetCalendarFeed(feedId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	actualHash := crypto.GetSha256Hash(serverSecret, feed.FeedId.Bytes(), secret)
+	if !bytes.Equal(actualHash, feed.SecretHash) {
+		u.Error(errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Feed secret produces incorrect hash value").
+			Append(errors.LvlPlain, "Feed not found"))
+		return
+	}
+
+	enabled, tr := u.Tx.Queries().IsUserEnabled(feed.UserId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if !enabled {
+		u.Error(errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Feed owner %v is disabled", feed.UserId).
+			Append(errors.LvlPlain, "Feed not found"))
+		return
+	}
+
+	tr = u.Tx.Queries().UpdateCalendarFeedLastUsed(feed.FeedId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Get the calendar on behalf of the feed owner
+	calFromSource, tr := u.Tx.Queries().GetCalendar(feed.UserId, feed.CalendarId, u.Context, u.Config)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	calendar, tr := u.Tx.Queries().OverrideCalendar(calFromSource)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	now := time.Now()
+	startTime := now.AddDate(0, 0, -feed.DaysPast)
+	endTime := now.AddDate(0, 0, feed.DaysFuture)
+
+	eventsFromCal, tr := calendar.GetEvents(startTime, endTime, u.Tx.Queries())
+	u.Tx.Queries().ReportSourceHealth(calendar.GetSource().GetId(), tr)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Every instance is exported on its own, so that modified instances are represented correctly
+	expandedEvents := []types.Event{}
+	for _, event := range eventsFromCal {
+		expanded, tr := types.ExpandRecurrence(event, &startTime, &endTime)
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+		expandedEvents = append(expandedEvents, expanded...)
+	}
+
+	events, tr := u.Tx.Queries().OverrideEvents(expandedEvents)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	cal := icalProtocol.NewIcalCalendar(calendar.GetName(), calendar.GetDesc(), calendar.GetColor())
+	for _, event := range events {
+		vevent, tr := icalProtocol.EventInstanceToIcal(event, event.GetId().String())
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+		cal.Children = append(cal.Children, vevent)
+	}
+
+	var buf bytes.Buffer
+	err = ical.NewEncoder(&buf).Encode(cal)
+	if err != nil {
+		u.Error(errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not encode iCal file").
+			AltStr(errors.LvlPlain, "Could not export calendar"))
+		return
+	}
+
+	u.ResponseRawWithStatus(http.StatusOK, buf.Bytes(), "text/calendar; charset=utf-8")
+}
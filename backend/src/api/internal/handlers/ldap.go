@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"luna-backend/api/internal/util"
+	"luna-backend/auth"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+)
+
+// Checks the credentials against the directory and returns the user the entry
+// belongs to, provisioning a new one on the first login. If a user ID is given,
+// the entry has to belong to that user.
+func loginLdapUser(u *util.HandlerUtility, credentials *auth.BasicAuth, userId types.ID) (types.ID, *errors.ErrorTrace) {
+	ldapUser, tr := auth.AuthenticateLdap(u.Config.Env, credentials.Username, credentials.Password)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	linkedId, tr := u.Tx.Queries().GetUserIdFromLdapDn(ldapUser.Dn)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	if !userId.IsEmpty() && linkedId != userId {
+		return types.EmptyId(), errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "LDAP entry %v does not belong to user %v", ldapUser.Dn, userId).
+			Append(errors.LvlPlain, "Invalid credentials")
+	}
+
+	if linkedId.IsEmpty() {
+		linkedId, tr = provisionLdapUser(u, ldapUser)
+		if tr != nil {
+			return types.EmptyId(), tr
+		}
+	}
+
+	// The directory decides who is an administrator
+	if u.Config.Env.LDAP_ADMIN_GROUP != "" {
+		tr = u.Tx.Queries().SetUserAdmin(linkedId, ldapUser.InGroup(u.Config.Env.LDAP_ADMIN_GROUP))
+		if tr != nil {
+			return types.EmptyId(), tr.
+				Append(errors.LvlWordy, "Database error")
+		}
+	}
+
+	return linkedId, nil
+}
+
+func provisionLdapUser(u *util.HandlerUtility, ldapUser *auth.LdapUser) (types.ID, *errors.ErrorTrace) {
+	usernameErr := util.IsValidUsername(ldapUser.Username)
+	emailErr := util.IsValidEmail(ldapUser.Email)
+	if usernameErr != nil || emailErr != nil {
+		return types.EmptyId(), errors.New().Status(http.StatusForbidden).
+			AddErr(errors.LvlDebug, usernameErr).AndErr(emailErr).
+			Append(errors.LvlDebug, "LDAP entry %v has no valid username or email address", ldapUser.Dn).
+			Append(errors.LvlPlain, "Your directory entry has no valid username or email address")
+	}
+
+	// Local users are never taken over by directory entries with the same name
+	taken, tr := u.Tx.Queries().IsUsernameOrEmailTaken(ldapUser.Username, ldapUser.Email)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+	if taken {
+		return types.EmptyId(), errors.New().Status(http.StatusConflict).
+			Append(errors.LvlDebug, "Username %v or email %v of LDAP entry %v is already taken", ldapUser.Username, ldapUser.Email, ldapUser.Dn).
+			Append(errors.LvlPlain, "A user with this username or email address already exists")
+	}
+
+	user := &types.User{
+		Username:           ldapUser.Username,
+		Email:              ldapUser.Email,
+		Searchable:         true,
+		ProfilePictureType: "static",
+		ProfilePictureFile: types.EmptyId(),
+		ProfilePictureUrl:  util.GetDefaultProfilePictureUrl(!u.Config.Settings.EnableGravatar.Enabled, ldapUser.Email),
+	}
+
+	userId, tr := u.Tx.Queries().AddUser(user)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	tr = u.Tx.Queries().InitializeUserSettings(userId)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	tr = u.Tx.Queries().InsertLdapUser(userId, ldapUser.Dn)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	// The directory is trusted with the email addresses of its users
+	tr = u.Tx.Queries().SetUserVerified(userId, ldapUser.Email)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	u.Logger.Infof("provisioned user %v from LDAP entry %v", userId, ldapUser.Dn)
+
+	return userId, nil
+}
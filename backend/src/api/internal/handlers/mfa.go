@@ -0,0 +1,624 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"luna-backend/api/internal/util"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A login with a second factor happens in two steps. The password is checked
+// by Login, which then only hands out a pre-authentication token. That token
+// and a TOTP code, a passkey, or a recovery code are exchanged for a real
+// token by LoginSecondFactor. If two-factor authentication is required but the
+// user has not set it up yet, they can enroll in between using the same token.
+
+type secondFactors struct {
+	totp     bool
+	passkeys int
+}
+
+func (f *secondFactors) Any() bool {
+	return f.totp || f.passkeys > 0
+}
+
+// Names of the methods that can complete a login, in the order they should be offered
+func (f *secondFactors) Methods() []string {
+	methods := []string{}
+	if f.passkeys > 0 {
+		methods = append(methods, "passkey")
+	}
+	if f.totp {
+		methods = append(methods, "totp")
+	}
+	if f.Any() {
+		methods = append(methods, "recovery_code")
+	}
+	return methods
+}
+
+func getSecondFactors(u *util.HandlerUtility, userId types.ID) (*secondFactors, *errors.ErrorTrace) {
+	totp, tr := u.Tx.Queries().GetTotp(userId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	passkeys, tr := u.Tx.Queries().GetWebauthnCredentials(userId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return &secondFactors{
+		totp:     totp != nil && totp.Enabled,
+		passkeys: len(passkeys),
+	}, nil
+}
+
+// Recovery codes are useless without a second factor to recover, and users
+// cannot remove their last one if the server requires it
+func afterSecondFactorRemoved(u *util.HandlerUtility, userId types.ID) *errors.ErrorTrace {
+	factors, tr := getSecondFactors(u, userId)
+	if tr != nil {
+		return tr
+	}
+	if factors.Any() {
+		return nil
+	}
+
+	if u.Config.Settings.RequireTwoFactor.Enabled {
+		return errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlPlain, "Two-factor authentication is required on this server")
+	}
+
+	return u.Tx.Queries().DeleteRecoveryCodes(userId)
+}
+
+func createPreauthSession(u *util.HandlerUtility, userId types.ID, isShortLived bool) (string, *errors.ErrorTrace) {
+	secret, tr := crypto.GenerateRandomBytes(256)
+	if tr != nil {
+		return "", tr.
+			Append(errors.LvlWordy, "Could not generate random bytes")
+	}
+
+	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
+	if tr != nil {
+		return "", tr
+	}
+
+	session := &types.PreauthSession{
+		UserId:       userId,
+		IsShortLived: isShortLived,
+		SecretHash:   []byte{},
+	}
+	tr = u.Tx.Queries().InsertPreauthSession(session)
+	if tr != nil {
+		return "", tr
+	}
+
+	// The hash depends on the ID, which is only known after inserting
+	session.SecretHash = crypto.GetSha256Hash(serverSecret, session.Id.Bytes(), secret)
+	tr = u.Tx.Queries().UpdatePreauthSessionHash(session.Id, session.SecretHash)
+	if tr != nil {
+		return "", tr
+	}
+
+	token, tr := auth.NewPreauthToken(u.Config, userId, session.Id, secret)
+	if tr != nil {
+		return "", tr.
+			Append(errors.LvlWordy, "Could not generate pre-authentication token")
+	}
+
+	return token, nil
+}
+
+func getPreauthSession(u *util.HandlerUtility, c *gin.Context) (*types.PreauthSession, *errors.ErrorTrace) {
+	rawToken := c.PostForm("preauth_token")
+	if rawToken == "" {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing pre-authentication token")
+	}
+
+	token, tr := auth.ParsePreauthToken(u.Config, rawToken)
+	if tr != nil {
+		return nil, tr
+	}
+
+	session, tr := u.Tx.Queries().GetPreauthSession(token.UserId, token.PreauthId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(token.Secret)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not decode secret from pre-authentication token").
+			Append(errors.LvlPlain, "Login expired, please log in again")
+	}
+
+	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
+	if tr != nil {
+		return nil, tr
+	}
+	actualHash := crypto.GetSha256Hash(serverSecret, session.Id.Bytes(), secret)
+	if !bytes.Equal(actualHash, session.SecretHash) {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Pre-authentication token secret produces incorrect hash value").
+			Append(errors.LvlPlain, "Login expired, please log in again")
+	}
+
+	return session, nil
+}
+
+// Counts an attempt that did not complete the login, so that second factors cannot be guessed.
+// This happens in a separate transaction, since the transaction of the request is rolled back on errors.
+// Returns an error once the session was deleted after too many attempts.
+func recordPreauthAttempt(u *util.HandlerUtility, preauth *types.PreauthSession) *errors.ErrorTrace {
+	tx, tr := u.Db.BeginTransaction(u.Context)
+	if tr != nil {
+		return tr
+	}
+	defer tx.Rollback(u.Logger)
+
+	deleted, tr := tx.Queries().RecordPreauthAttempt(preauth.Id)
+	if tr != nil {
+		return tr
+	}
+
+	tr = tx.Commit(u.Logger)
+	if tr != nil {
+		return tr
+	}
+
+	if deleted {
+		u.Logger.Warnf("pre-authentication session %v of user %v was deleted after %v attempts", preauth.Id, preauth.UserId, constants.MaxPreauthAttempts)
+		return errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Pre-authentication session %v reached the maximum number of attempts", preauth.Id).
+			Append(errors.LvlPlain, "Too many attempts, please log in again")
+	}
+	return nil
+}
+
+// Checks either a TOTP code, a passkey, or a recovery code. Recovery codes are
+// deleted once used. If enroll is set, a TOTP secret that was not confirmed yet
+// is enabled by a valid code, which is reported back to the caller.
+func verifySecondFactor(u *util.HandlerUtility, c *gin.Context, userId types.ID, enroll bool) (bool, *errors.ErrorTrace) {
+	code := c.PostForm("code")
+	recoveryCode := c.PostForm("recovery_code")
+
+	switch {
+	case c.PostForm("credential") != "":
+		_, tr := finishWebauthnLogin(u, c, userId, constants.WebauthnCeremonySecondFactor)
+		return false, tr
+
+	case code != "":
+		totp, tr := u.Tx.Queries().GetTotp(userId)
+		if tr != nil {
+			return false, tr
+		}
+		if totp == nil || (!totp.Enabled && !enroll) {
+			return false, errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "Two-factor authentication is not set up")
+		}
+
+		step, valid := auth.VerifyTotp(totp.Secret, code, time.Now())
+		if !valid {
+			return false, errors.New().Status(http.StatusUnauthorized).
+				Append(errors.LvlDebug, "Wrong TOTP code").
+				Append(errors.LvlPlain, "Invalid code")
+		}
+
+		fresh, tr := u.Tx.Queries().UseTotpStep(userId, step, enroll)
+		if tr != nil {
+			return false, tr
+		}
+		if !fresh {
+			return false, errors.New().Status(http.StatusUnauthorized).
+				Append(errors.LvlDebug, "TOTP code for step %v was already used", step).
+				Append(errors.LvlPlain, "Invalid code")
+		}
+
+		return !totp.Enabled, nil
+
+	case recoveryCode != "":
+		codes, tr := u.Tx.Queries().GetRecoveryCodes(userId)
+		if tr != nil {
+			return false, tr
+		}
+
+		recoveryCode = auth.NormalizeRecoveryCode(recoveryCode)
+		for _, candidate := range codes {
+			if !auth.VerifyPassword(recoveryCode, candidate.PasswordEntry, u.Config) {
+				continue
+			}
+			tr = u.Tx.Queries().DeleteRecoveryCode(candidate.Id)
+			if tr != nil {
+				return false, tr
+			}
+			u.Logger.Infof("user %v used a recovery code, %v remaining", userId, len(codes)-1)
+			return false, nil
+		}
+
+		return false, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Wrong recovery code").
+			Append(errors.LvlPlain, "Invalid code")
+
+	default:
+		return false, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing code")
+	}
+}
+
+// Replaces all previous recovery codes of the user and returns the new ones in plain text
+func generateRecoveryCodes(u *util.HandlerUtility, userId types.ID) ([]string, *errors.ErrorTrace) {
+	codes := make([]string, constants.RecoveryCodeCount)
+	entries := make([]*types.PasswordEntry, constants.RecoveryCodeCount)
+
+	for i := range codes {
+		code, tr := auth.NewRecoveryCode()
+		if tr != nil {
+			return nil, tr
+		}
+		entry, tr := auth.SecurePassword(code, u.Config)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlDebug, "Could not hash recovery code")
+		}
+		codes[i] = code
+		entries[i] = entry
+	}
+
+	tr := u.Tx.Queries().SetRecoveryCodes(userId, entries)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return codes, nil
+}
+
+func verifyOwnPassword(u *util.HandlerUtility, c *gin.Context, userId types.ID) *errors.ErrorTrace {
+	password := c.PostForm("password")
+	if password == "" {
+		return errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing password")
+	}
+
+	// Users provisioned from the directory are checked against it
+	isLdapUser, tr := u.Tx.Queries().IsLdapUser(userId)
+	if tr != nil {
+		return tr
+	}
+	if isLdapUser && u.Config.Env.LdapEnabled() {
+		user, tr := u.Tx.Queries().GetUser(userId)
+		if tr != nil {
+			return tr
+		}
+		_, tr = loginLdapUser(u, &auth.BasicAuth{Username: user.Username, Password: password}, userId)
+		return tr
+	}
+
+	savedPassword, tr := u.Tx.Queries().GetPassword(userId)
+	if tr != nil {
+		return tr.Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Could not get password for user %v", userId.String()).
+			Append(errors.LvlPlain, "Invalid credentials")
+	}
+
+	if !auth.VerifyPassword(password, savedPassword, u.Config) {
+		return errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Wrong password").
+			Append(errors.LvlPlain, "Invalid credentials")
+	}
+
+	return nil
+}
+
+// Changes to the second factors of a user require them to prove who they are again, either with
+// their password or with one of their second factors. Users without a password, e.g. because they
+// only log in through an identity provider, can instead use a session that they logged in with recently.
+func verifyReauthentication(u *util.HandlerUtility, c *gin.Context, userId types.ID) *errors.ErrorTrace {
+	if c.PostForm("password") != "" {
+		return verifyOwnPassword(u, c, userId)
+	}
+
+	if c.PostForm("code") != "" || c.PostForm("recovery_code") != "" || c.PostForm("credential") != "" {
+		_, tr := verifySecondFactor(u, c, userId, false)
+		return tr
+	}
+
+	hasPassword, tr := u.Tx.Queries().HasPassword(userId)
+	if tr != nil {
+		return tr
+	}
+	if !hasPassword {
+		session, tr := u.Tx.Queries().GetSession(userId, util.GetSessionId(c))
+		if tr != nil {
+			return tr
+		}
+		if !session.IsApi && time.Since(session.CreatedAt) < constants.LifetimeReauthentication {
+			return nil
+		}
+	}
+
+	return errors.New().Status(http.StatusUnauthorized).
+		Append(errors.LvlDebug, "User %v did not re-authenticate", userId).
+		Append(errors.LvlPlain, "Please confirm with your password or a second factor")
+}
+
+func startTotpEnrollment(u *util.HandlerUtility, userId types.ID) (*gin.H, *errors.ErrorTrace) {
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	secret, tr := auth.NewTotpSecret()
+	if tr != nil {
+		return nil, tr
+	}
+
+	tr = u.Tx.Queries().SetPendingTotp(userId, secret)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return &gin.H{
+		"secret": auth.EncodeTotpSecret(secret),
+		"url":    auth.TotpUrl(secret, user.Username),
+	}, nil
+}
+
+func LoginSecondFactor(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	preauth, tr := getPreauthSession(u, c)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	enrolled, tr := verifySecondFactor(u, c, preauth.UserId, true)
+	if tr != nil {
+		if tr.GetStatus() == http.StatusUnauthorized {
+			if attemptTr := recordPreauthAttempt(u, preauth); attemptTr != nil {
+				tr = attemptTr
+			}
+		}
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	// The pre-authentication session can only be used once
+	tr = u.Tx.Queries().DeletePreauthSession(preauth.Id)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	token, tr := createSession(u, c, preauth.UserId, preauth.IsShortLived)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	if !enrolled {
+		u.Success(&gin.H{"token": token})
+		return
+	}
+
+	codes, tr := generateRecoveryCodes(u, preauth.UserId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	u.Success(&gin.H{"token": token, "recovery_codes": codes})
+}
+
+// Lets users that are required to use two-factor authentication set it up
+// before their first complete login
+func PutLoginTotp(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	preauth, tr := getPreauthSession(u, c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Every enrollment replaces the pending secret, so it counts as an attempt as well
+	tr = recordPreauthAttempt(u, preauth)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	factors, tr := getSecondFactors(u, preauth.UserId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if factors.Any() {
+		u.Error(errors.New().Status(http.StatusConflict).
+			Append(errors.LvlPlain, "Two-factor authentication is already set up"),
+		)
+		return
+	}
+
+	response, tr := startTotpEnrollment(u, preauth.UserId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlPlain, "Could not set up two-factor authentication"),
+		)
+		return
+	}
+
+	u.Success(response)
+}
+
+func GetMfaStatus(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	factors, tr := getSecondFactors(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	codes, tr := u.Tx.Queries().GetRecoveryCodes(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{
+		"required":       u.Config.Settings.RequireTwoFactor.Enabled,
+		"totp":           factors.totp,
+		"passkeys":       factors.passkeys,
+		"recovery_codes": len(codes),
+	})
+}
+
+func PutTotp(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	response, tr := startTotpEnrollment(u, userId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlPlain, "Could not set up two-factor authentication"),
+		)
+		return
+	}
+
+	u.Success(response)
+}
+
+// Enables the secret from PutTotp once the user proved that their authenticator works
+func ConfirmTotp(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	code := c.PostForm("code")
+	if code == "" {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing code"),
+		)
+		return
+	}
+
+	factors, tr := getSecondFactors(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	enrolled, tr := verifySecondFactor(u, c, userId, true)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if !enrolled {
+		u.Error(errors.New().Status(http.StatusConflict).
+			Append(errors.LvlPlain, "Two-factor authentication is already enabled"),
+		)
+		return
+	}
+
+	// Users who already had passkeys keep their recovery codes
+	if factors.Any() {
+		u.Success(nil)
+		return
+	}
+
+	codes, tr := generateRecoveryCodes(u, userId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlPlain, "Could not generate recovery codes"),
+		)
+		return
+	}
+
+	u.Success(&gin.H{"recovery_codes": codes})
+}
+
+func DeleteTotp(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	tr := verifyReauthentication(u, c, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = u.Tx.Queries().DeleteTotp(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = afterSecondFactorRemoved(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(nil)
+}
+
+func PutRecoveryCodes(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	tr := verifyReauthentication(u, c, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	factors, tr := getSecondFactors(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if !factors.Any() {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Two-factor authentication is not set up"),
+		)
+		return
+	}
+
+	codes, tr := generateRecoveryCodes(u, userId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlPlain, "Could not generate recovery codes"),
+		)
+		return
+	}
+
+	u.Success(&gin.H{"recovery_codes": codes})
+}
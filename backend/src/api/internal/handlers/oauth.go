@@ -51,6 +51,23 @@ func GetOauthClients(c *gin.Context) {
 	})
 }
 
+// Login providers use OpenID Connect and may decide who is an administrator
+func parseLoginProviderOptions(c *gin.Context, client *types.OauthClient) {
+	client.LoginProvider = c.PostForm("login_provider") == "true"
+	client.AutoRegister = c.PostForm("auto_register") == "true"
+	client.GroupClaim = c.DefaultPostForm("group_claim", "groups")
+	client.AdminGroup = c.PostForm("admin_group")
+}
+
+func checkLoginProvider(client *types.OauthClient) *errors.ErrorTrace {
+	if client.LoginProvider && (client.Issuer == "" || client.JwksUrl == nil) {
+		return errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlDebug, "OAuth 2.0 client %v did not announce an issuer or JWKS URL", client.Name).
+			Append(errors.LvlPlain, "The provider does not support OpenID Connect login")
+	}
+	return nil
+}
+
 func PutOauthClient(c *gin.Context) {
 	u := util.GetUtil(c)
 
@@ -118,6 +135,7 @@ func PutOauthClient(c *gin.Context) {
 		BaseUrl:      baseUrl,
 		Scope:        c.Request.FormValue("scope"),
 	}
+	parseLoginProviderOptions(c, client)
 
 	tr := auth.FetchOauthUrls(client, u.Context)
 	if tr != nil {
@@ -127,6 +145,12 @@ func PutOauthClient(c *gin.Context) {
 		)
 	}
 
+	tr = checkLoginProvider(client)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
 	// Insert
 	tr = u.Tx.Queries().InsertOauthClient(client)
 	if tr != nil {
@@ -209,6 +233,7 @@ func PatchOauthClient(c *gin.Context) {
 		BaseUrl:      newBaseUrl,
 		Scope:        c.Request.FormValue("scope"),
 	}
+	parseLoginProviderOptions(c, client)
 
 	tr = auth.FetchOauthUrls(client, u.Context)
 	if tr != nil {
@@ -218,6 +243,12 @@ func PatchOauthClient(c *gin.Context) {
 		)
 	}
 
+	tr = checkLoginProvider(client)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
 	// Update
 	tr = u.Tx.Queries().UpdateOauthClient(client)
 	if tr != nil {
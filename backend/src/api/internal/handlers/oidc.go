@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"luna-backend/api/internal/util"
+	"luna-backend/auth"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logging in with an OpenID Connect provider takes two requests, just like
+// authorizing an OAuth 2.0 client. The first one returns the URL the user has
+// to be redirected to, the second one receives the authorization code that the
+// provider appended to the redirect back to /oauth/login. The state parameter
+// of that redirect is the request ID.
+
+func getLoginProvider(u *util.HandlerUtility, clientId types.ID) (*types.OauthClient, *errors.ErrorTrace) {
+	client, tr := u.Tx.Queries().GetOauthClientById(clientId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	if !client.LoginProvider {
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "OAuth 2.0 client %v is not a login provider", clientId).
+			Append(errors.LvlPlain, "Login provider not found")
+	}
+
+	tr = auth.FetchOauthUrls(client, u.Context)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return client, nil
+}
+
+// Starts a login request, or a request to link an account if the user ID is not empty
+func beginOauthLogin(u *util.HandlerUtility, clientId types.ID, userId types.ID, isShortLived bool) (*gin.H, *errors.ErrorTrace) {
+	client, tr := getLoginProvider(u, clientId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	codeVerifier, tr := auth.NewPkceVerifier()
+	if tr != nil {
+		return nil, tr
+	}
+	nonce, tr := auth.NewOidcNonce()
+	if tr != nil {
+		return nil, tr
+	}
+
+	request := &types.OauthLoginRequest{
+		ClientId:     clientId,
+		UserId:       userId,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		IsShortLived: isShortLived,
+	}
+	tr = u.Tx.Queries().InsertOauthLoginRequest(request)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return &gin.H{
+		"request": request,
+		"url":     auth.GetOauthLoginUrl(client, request, u.Config),
+	}, nil
+}
+
+// Trades the authorization code of a request started by beginOauthLogin for a validated identity
+func finishOauthLogin(u *util.HandlerUtility, c *gin.Context, userId types.ID) (*types.OauthLoginRequest, *types.OauthClient, *auth.OidcIdentity, string, *errors.ErrorTrace) {
+	requestId, tr := util.GetId(c, "request")
+	if tr != nil {
+		return nil, nil, nil, "", tr
+	}
+
+	authCode := c.PostForm("authorization_code")
+	if authCode == "" {
+		return nil, nil, nil, "", errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlWordy, "Missing authorization code")
+	}
+
+	request, tr := u.Tx.Queries().TakeOauthLoginRequest(requestId, userId)
+	if tr != nil {
+		return nil, nil, nil, "", tr
+	}
+
+	client, tr := getLoginProvider(u, request.ClientId)
+	if tr != nil {
+		return nil, nil, nil, "", tr
+	}
+
+	tokens, tr := auth.FetchOauthTokensUsingLoginCode(client, authCode, request.CodeVerifier, u.Context, u.Config)
+	if tr != nil {
+		return nil, nil, nil, "", tr
+	}
+
+	identity, tr := auth.ValidateIdToken(client, tokens.IdToken, request.Nonce, u.Context)
+	if tr != nil {
+		return nil, nil, nil, "", tr
+	}
+
+	accountId, accountName, tr := auth.FetchOauthAccountIdentifier(client, userId, tokens.AccessToken, u.Context)
+	if tr != nil {
+		return nil, nil, nil, "", tr
+	}
+
+	// OpenID Connect Core 1.0 5.3.2
+	if accountId != identity.Subject {
+		return nil, nil, nil, "", errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "The userinfo subject %v does not match the ID token subject %v", accountId, identity.Subject).
+			Append(errors.LvlWordy, "Invalid ID token")
+	}
+
+	return request, client, identity, accountName, nil
+}
+
+func registerOauthUser(u *util.HandlerUtility, client *types.OauthClient, identity *auth.OidcIdentity, accountName string) (types.ID, *errors.ErrorTrace) {
+	if !client.AutoRegister {
+		return types.EmptyId(), errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlDebug, "Subject %v of OAuth 2.0 client %v is not linked to any user", identity.Subject, client.Id).
+			Append(errors.LvlPlain, "This account is not linked to any user")
+	}
+
+	emailErr := util.IsValidEmail(identity.Email)
+	if emailErr != nil {
+		return types.EmptyId(), errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, emailErr).
+			Append(errors.LvlPlain, "The login provider did not share a valid email address")
+	}
+
+	// Fall back to the local part of the email address
+	username := identity.Username
+	if util.IsValidUsername(username) != nil {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+	usernameErr := util.IsValidUsername(username)
+	if usernameErr != nil {
+		return types.EmptyId(), errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, usernameErr).
+			Append(errors.LvlPlain, "The login provider did not share a valid username")
+	}
+
+	// Existing users are never linked automatically, because the provider might
+	// let anyone claim an email address without verifying it
+	taken, tr := u.Tx.Queries().IsUsernameOrEmailTaken(username, identity.Email)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+	if taken {
+		return types.EmptyId(), errors.New().Status(http.StatusConflict).
+			Append(errors.LvlDebug, "Username %v or email %v is already taken", username, identity.Email).
+			Append(errors.LvlPlain, "A user with this username or email address already exists. Log in and link the account in your settings instead.")
+	}
+
+	user := &types.User{
+		Username:           username,
+		Email:              identity.Email,
+		Searchable:         true,
+		ProfilePictureType: "static",
+		ProfilePictureFile: types.EmptyId(),
+		ProfilePictureUrl:  util.GetDefaultProfilePictureUrl(!u.Config.Settings.EnableGravatar.Enabled, identity.Email),
+	}
+
+	userId, tr := u.Tx.Queries().AddUser(user)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	tr = u.Tx.Queries().InitializeUserSettings(userId)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	tr = u.Tx.Queries().InsertOauthIdentity(&types.OauthIdentity{
+		ClientId: client.Id,
+		UserId:   userId,
+		Subject:  identity.Subject,
+		Name:     accountName,
+	})
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	if identity.EmailVerified {
+		tr = u.Tx.Queries().SetUserVerified(userId, identity.Email)
+	} else {
+		tr = sendEmailVerification(u, user)
+	}
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	return userId, nil
+}
+
+func GetLoginProviders(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	clients, tr := u.Tx.Queries().GetOauthLoginProviders()
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	providers := make([]gin.H, len(clients))
+	for i, client := range clients {
+		providers[i] = gin.H{
+			"id":   client.Id,
+			"name": client.Name,
+		}
+	}
+
+	u.Success(&gin.H{
+		"providers": providers,
+	})
+}
+
+func PutOauthLogin(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	clientId, tr := util.GetId(c, "client")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	response, tr := beginOauthLogin(u, clientId, types.EmptyId(), c.PostForm("remember") != "true")
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	u.Success(response)
+}
+
+func OauthLogin(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	request, client, identity, accountName, tr := finishOauthLogin(u, c, types.EmptyId())
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	userId, tr := u.Tx.Queries().GetUserIdFromOauthIdentity(client.Id, identity.Subject)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+	if userId.IsEmpty() {
+		userId, tr = registerOauthUser(u, client, identity, accountName)
+		if tr != nil {
+			u.Error(tr.
+				Append(errors.LvlBroad, "Could not log in"),
+			)
+			return
+		}
+	}
+
+	// The provider decides who is an administrator
+	if client.AdminGroup != "" {
+		tr = u.Tx.Queries().SetUserAdmin(userId, identity.InGroup(client.AdminGroup))
+		if tr != nil {
+			u.Error(tr.
+				Append(errors.LvlWordy, "Database error").
+				Append(errors.LvlBroad, "Could not log in"),
+			)
+			return
+		}
+	}
+
+	enabled, tr := u.Tx.Queries().IsUserEnabled(userId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlDebug, "Could not check if user %v is enabled", userId.String()).
+			Append(errors.LvlWordy, "Database error").
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+	if !enabled {
+		u.Error(errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlPlain, "Your account is disabled."),
+		)
+		return
+	}
+
+	response, tr := completeLogin(u, c, userId, request.IsShortLived)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	u.Success(response)
+}
+
+//
+// Linked accounts
+//
+
+func GetOauthIdentities(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	identities, tr := u.Tx.Queries().GetOauthIdentities(util.GetUserId(c))
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{
+		"identities": identities,
+	})
+}
+
+func PutOauthIdentity(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	clientId, tr := util.GetId(c, "client")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	response, tr := beginOauthLogin(u, clientId, util.GetUserId(c), true)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(response)
+}
+
+// Only the user who started the request can finish it, so that nobody can be
+// tricked into linking someone else's account to their own
+func PostOauthIdentity(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	_, client, identity, accountName, tr := finishOauthLogin(u, c, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	linked := &types.OauthIdentity{
+		ClientId: client.Id,
+		UserId:   userId,
+		Subject:  identity.Subject,
+		Name:     accountName,
+	}
+	tr = u.Tx.Queries().InsertOauthIdentity(linked)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{
+		"identity": linked,
+	})
+}
+
+func DeleteOauthIdentity(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	identityId, tr := util.GetId(c, "identity")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Users without a password must keep some way to log in
+	hasPassword, tr := u.Tx.Queries().HasPassword(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if !hasPassword {
+		identities, tr := u.Tx.Queries().GetOauthIdentities(userId)
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+		factors, tr := getSecondFactors(u, userId)
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+		if len(identities) <= 1 && factors.passkeys == 0 {
+			u.Error(errors.New().Status(http.StatusForbidden).
+				Append(errors.LvlPlain, "You cannot remove the only way to log in to your account"),
+			)
+			return
+		}
+	}
+
+	tr = u.Tx.Queries().DeleteOauthIdentity(identityId, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(nil)
+}
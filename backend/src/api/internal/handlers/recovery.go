@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"luna-backend/api/internal/util"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	"luna-backend/notifications"
+	"luna-backend/types"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Password reset and email verification links carry a random token, of which
+// only a hash is stored. Requests for new links always succeed and the emails
+// are sent after the response, so that neither the response nor its timing
+// reveals whether an account with the email address exists.
+
+func getMailer(u *util.HandlerUtility) (*notifications.Mailer, *errors.ErrorTrace) {
+	mailer := notifications.NewMailer(u.Config.Env)
+	if mailer == nil {
+		return nil, errors.New().Status(http.StatusServiceUnavailable).
+			Append(errors.LvlDebug, "No SMTP server is configured").
+			Append(errors.LvlPlain, "This server cannot send emails")
+	}
+	return mailer, nil
+}
+
+func hashMailToken(u *util.HandlerUtility, token string) ([]byte, *errors.ErrorTrace) {
+	serverSecret, tr := crypto.GetSymmetricKey(u.Config, "tokenHashSecret")
+	if tr != nil {
+		return nil, tr
+	}
+	return crypto.GetSha256Hash(serverSecret, []byte(token)), nil
+}
+
+func newMailToken(u *util.HandlerUtility) (string, []byte, *errors.ErrorTrace) {
+	bytes, tr := crypto.GenerateRandomBytes(32)
+	if tr != nil {
+		return "", nil, tr.
+			Append(errors.LvlWordy, "Could not generate random bytes")
+	}
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+
+	hash, tr := hashMailToken(u, token)
+	if tr != nil {
+		return "", nil, tr
+	}
+	return token, hash, nil
+}
+
+func getMailLink(u *util.HandlerUtility, page string, token string) string {
+	return u.Config.PublicUrl.Subpage(page).SetQuery(&url.Values{"token": {token}}).String()
+}
+
+// Token lifetimes are whole hours or days
+func describeValidity(validity time.Duration) string {
+	if validity%(24*time.Hour) == 0 {
+		days := int(validity.Hours() / 24)
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+
+	hours := int(validity.Hours())
+	if hours == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", hours)
+}
+
+func sendMailInBackground(u *util.HandlerUtility, mailer *notifications.Mailer, recipient string, name string, data *notifications.MailData) {
+	logger := u.Logger
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), constants.MailTimeout)
+		defer cancel()
+
+		tr := mailer.Send(recipient, name, data, ctx)
+		if tr != nil {
+			logger.Warnf("could not send %v email: %v", name, tr.Serialize(errors.LvlDebug))
+		}
+	}()
+}
+
+// Does nothing if no SMTP server is configured
+func sendEmailVerification(u *util.HandlerUtility, user *types.User) *errors.ErrorTrace {
+	mailer := notifications.NewMailer(u.Config.Env)
+	if mailer == nil {
+		return nil
+	}
+
+	token, hash, tr := newMailToken(u)
+	if tr != nil {
+		return tr
+	}
+
+	tr = u.Tx.Queries().InsertEmailVerificationToken(user.Id, user.Email, hash, time.Now().Add(constants.LifetimeEmailVerificationToken))
+	if tr != nil {
+		return tr
+	}
+
+	sendMailInBackground(u, mailer, user.Email, notifications.MailEmailVerification, &notifications.MailData{
+		Username: user.Username,
+		Link:     getMailLink(u, "verify-email", token),
+		ValidFor: describeValidity(constants.LifetimeEmailVerificationToken),
+	})
+
+	return nil
+}
+
+// Administrators are exempt, so that a broken SMTP server cannot lock everyone out
+func isEmailVerificationRequired(u *util.HandlerUtility, user *types.User) bool {
+	return u.Config.Settings.RequireVerifiedEmail.Enabled &&
+		u.Config.Env.SMTP_HOST != "" &&
+		!user.Admin &&
+		!user.Verified
+}
+
+func checkEmailVerified(u *util.HandlerUtility, userId types.ID) *errors.ErrorTrace {
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		return tr
+	}
+
+	if isEmailVerificationRequired(u, user) {
+		return errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlDebug, "User %v has not verified their email address", userId).
+			Append(errors.LvlPlain, "Please verify your email address before logging in")
+	}
+
+	return nil
+}
+
+//
+// Password reset
+//
+
+func PutPasswordReset(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	mailer, tr := getMailer(u)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not reset password"),
+		)
+		return
+	}
+
+	email := c.PostForm("email")
+	if util.IsValidEmail(email) != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Invalid email"),
+		)
+		return
+	}
+
+	userId, tr := u.Tx.Queries().GetUserIdFromEmail(email)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if userId.IsEmpty() {
+		u.Logger.Debugf("no user with email %v requested a password reset", email)
+		u.Success(nil)
+		return
+	}
+
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	// Directory users and users who log in with a provider have no password to reset
+	isLdapUser, tr := u.Tx.Queries().IsLdapUser(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	hasPassword, tr := u.Tx.Queries().HasPassword(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if !user.Enabled || isLdapUser || !hasPassword {
+		u.Logger.Debugf("user %v cannot reset their password", userId)
+		u.Success(nil)
+		return
+	}
+
+	token, hash, tr := newMailToken(u)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = u.Tx.Queries().InsertPasswordResetToken(userId, hash, time.Now().Add(constants.LifetimePasswordResetToken))
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	sendMailInBackground(u, mailer, user.Email, notifications.MailPasswordReset, &notifications.MailData{
+		Username: user.Username,
+		Link:     getMailLink(u, "reset-password", token),
+		ValidFor: describeValidity(constants.LifetimePasswordResetToken),
+	})
+
+	u.Success(nil)
+}
+
+func PostPasswordReset(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	token := c.PostForm("token")
+	if token == "" {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing token"),
+		)
+		return
+	}
+
+	password := c.PostForm("password")
+	passwordErr := util.IsValidPassword(password)
+	if passwordErr != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, passwordErr).
+			Append(errors.LvlPlain, "Invalid password"),
+		)
+		return
+	}
+
+	hash, tr := hashMailToken(u, token)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	userId, tr := u.Tx.Queries().TakePasswordResetToken(hash)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not reset password"),
+		)
+		return
+	}
+
+	enabled, tr := u.Tx.Queries().IsUserEnabled(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if !enabled {
+		u.Error(errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlPlain, "Your account is disabled."),
+		)
+		return
+	}
+
+	securedPassword, tr := auth.SecurePassword(password, u.Config)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlDebug, "Could not hash new password").
+			Append(errors.LvlBroad, "Could not reset password"),
+		)
+		return
+	}
+
+	tr = u.Tx.Queries().UpdatePassword(userId, securedPassword)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlDebug, "Could not update password").
+			Append(errors.LvlBroad, "Could not reset password"),
+		)
+		return
+	}
+
+	// Whoever knew the old password should not stay logged in
+	tr = u.Tx.Queries().DeleteSessions(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(nil)
+}
+
+//
+// Email verification
+//
+
+func PutEmailVerification(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	_, tr := getMailer(u)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	email := c.PostForm("email")
+	if util.IsValidEmail(email) != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Invalid email"),
+		)
+		return
+	}
+
+	userId, tr := u.Tx.Queries().GetUserIdFromEmail(email)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if userId.IsEmpty() {
+		u.Logger.Debugf("no user with email %v requested a verification email", email)
+		u.Success(nil)
+		return
+	}
+
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+	if user.Verified {
+		u.Success(nil)
+		return
+	}
+
+	tr = sendEmailVerification(u, user)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(nil)
+}
+
+func PostEmailVerification(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	token := c.PostForm("token")
+	if token == "" {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing token"),
+		)
+		return
+	}
+
+	hash, tr := hashMailToken(u, token)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	userId, email, tr := u.Tx.Queries().TakeEmailVerificationToken(hash)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not verify email address"),
+		)
+		return
+	}
+
+	tr = u.Tx.Queries().SetUserVerified(userId, email)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not verify email address"),
+		)
+		return
+	}
+
+	u.Success(nil)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"luna-backend/api/internal/util"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	icalProtocol "luna-backend/protocols/ical"
+	"luna-backend/scheduling"
+	"luna-backend/types"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Applies iTIP replies from attendees to the events organized by the user.
+// The body is either an iCalendar object or a complete email, e.g. piped from the mail server.
+func PostSchedulingInbox(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	raw, err := c.GetRawData()
+	if err != nil || len(raw) == 0 {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Missing message"))
+		return
+	}
+
+	cal, err := scheduling.ParseMessage(c.GetHeader("Content-Type"), raw)
+	if err != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed scheduling message"))
+		return
+	}
+
+	replies, err := icalProtocol.ParseItipReplies(cal)
+	if err != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Unsupported scheduling message"))
+		return
+	}
+
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	updated := 0
+	for _, reply := range replies {
+		// Replies to single instances of recurring events cannot be matched reliably, see scheduling.IsOrganizer
+		if reply.RecurrenceId != "" {
+			continue
+		}
+
+		eventIds, tr := u.Tx.Queries().GetEventIdsByUid(userId, reply.Uid)
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+
+		for _, eventId := range eventIds {
+			event, tr := u.Tx.Queries().GetEvent(userId, eventId, u.Context, u.Config)
+			if tr != nil {
+				u.Error(tr)
+				return
+			}
+			if !scheduling.IsOrganizer(user, event) {
+				continue
+			}
+
+			// Replies from people that were never invited are ignored
+			participants := event.GetParticipants().Clone()
+			attendee := participants.Find(reply.Attendee.Email)
+			if attendee == nil {
+				continue
+			}
+
+			attendee.Status = reply.Attendee.Status
+			if attendee.Name == "" {
+				attendee.Name = reply.Attendee.Name
+			}
+
+			_, tr = event.GetCalendar().EditEvent(event, event.GetName(), event.GetDesc(), event.GetColor(), event.GetDate(), event.GetLocation(), participants, event.GetReminders(), false, u.Tx.Queries())
+			if tr != nil {
+				u.Error(tr)
+				return
+			}
+			u.Tx.Queries().NotifyChange(userId, types.NewEventChange(constants.ChangeActionUpdated, eventId, event.GetCalendar().GetId()))
+			updated++
+		}
+	}
+
+	if updated == 0 {
+		u.Error(errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "No event organized by %v matches the replies from %v", user.Email, strings.Join(replyEmails(replies), ", ")).
+			Append(errors.LvlPlain, "No matching event found"))
+		return
+	}
+
+	u.Success(&gin.H{"updated": updated})
+}
+
+func replyEmails(replies []*icalProtocol.ItipReply) []string {
+	emails := make([]string, len(replies))
+	for i, reply := range replies {
+		emails[i] = reply.Attendee.Email
+	}
+	return emails
+}
@@ -13,8 +13,13 @@ import (
 	"luna-backend/errors"
 	"luna-backend/files"
 	"luna-backend/protocols/caldav"
+	"luna-backend/protocols/carddav"
 	"luna-backend/protocols/google"
+	"luna-backend/protocols/holidays"
 	"luna-backend/protocols/ical"
+	"luna-backend/protocols/jmap"
+	"luna-backend/protocols/luna"
+	"luna-backend/protocols/microsoft"
 	"luna-backend/types"
 
 	"github.com/gin-gonic/gin"
@@ -27,13 +32,15 @@ type exposedSource struct {
 }
 
 type exposedDetailedSource struct {
-	Id              types.ID `json:"id"`
-	Name            string   `json:"name"`
-	Type            string   `json:"type"`
-	Settings        any      `json:"settings"`
-	AuthType        string   `json:"auth_type"`
-	Auth            any      `json:"auth"`
-	CanAddCalendars bool     `json:"can_add_calendars"`
+	Id              types.ID            `json:"id"`
+	Name            string              `json:"name"`
+	Type            string              `json:"type"`
+	Settings        any                 `json:"settings"`
+	AuthType        string              `json:"auth_type"`
+	Auth            any                 `json:"auth"`
+	CanAddCalendars bool                `json:"can_add_calendars"`
+	Reminders       bool                `json:"reminders"`
+	Health          *types.SourceHealth `json:"health"`
 }
 
 func getSources(u *util.HandlerUtility, userId types.ID) ([]types.Source, *errors.ErrorTrace) {
@@ -88,6 +95,18 @@ func GetSource(c *gin.Context) {
 
 	u.Config.Cache.Cache(userId, source)
 
+	reminders, err := u.Tx.Queries().GetSourceReminders(userId, sourceId)
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
+	health, err := u.Tx.Queries().GetSourceHealth(sourceId)
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
 	exposedSource := exposedDetailedSource{
 		Id:              source.GetId(),
 		Name:            source.GetName(),
@@ -96,11 +115,25 @@ func GetSource(c *gin.Context) {
 		AuthType:        source.GetAuth().GetType(),
 		Auth:            source.GetAuth(),
 		CanAddCalendars: source.CanAddCalendars(),
+		Reminders:       reminders,
+		Health:          health,
 	}
 
 	u.Success(&gin.H{"source": exposedSource})
 }
 
+func GetUnhealthySources(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	sources, err := u.Tx.Queries().GetUnhealthySources()
+	if err != nil {
+		u.Error(err)
+		return
+	}
+
+	u.Success(&gin.H{"sources": sources})
+}
+
 func parseAuthMethod(c *gin.Context) (types.AuthMethod, *errors.ErrorTrace) {
 	var sourceAuth types.AuthMethod
 
@@ -193,6 +226,26 @@ func parseSource(c *gin.Context, sourceName string, sourceAuth types.AuthMethod,
 		source = caldav.NewCaldavSource(sourceName, sourceUrl, sourceAuth)
 		source.SupplyContext(ctx)
 
+	case constants.SourceCarddav:
+		rawUrl := c.PostForm("url")
+		if rawUrl == "" {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "Missing CardDAV url")
+		}
+		if util.IsValidUrl(rawUrl) != nil {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "Invalid CardDAV url")
+		}
+		sourceUrl, err := types.NewUrl(rawUrl)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlPlain, "Invalid CardDAV url")
+		}
+
+		source = carddav.NewCarddavSource(sourceName, sourceUrl, sourceAuth)
+		source.SupplyContext(ctx)
+
 	case constants.SourceIcal:
 		locationType := c.PostForm("location")
 		if locationType == "" {
@@ -217,7 +270,9 @@ func parseSource(c *gin.Context, sourceName string, sourceAuth types.AuthMethod,
 					AddErr(errors.LvlDebug, err).
 					Append(errors.LvlPlain, "Invalid iCal url")
 			}
-			source, tr = ical.NewRemoteIcalSource(sourceName, sourceUrl, sourceAuth, user, q)
+			// Only relevant for authenticated files, since files without authentication are always refetched
+			backgroundRefetch := c.PostForm("background_refetch") == "true"
+			source, tr = ical.NewRemoteIcalSource(sourceName, sourceUrl, sourceAuth, backgroundRefetch, user, q)
 			if tr != nil {
 				return nil, tr
 			}
@@ -281,6 +336,44 @@ func parseSource(c *gin.Context, sourceName string, sourceAuth types.AuthMethod,
 		// TODO: do we need anything more or is that it?
 		source = google.NewGoogleSource(sourceName, sourceAuth)
 
+	case constants.SourceMicrosoft:
+		source = microsoft.NewMicrosoftSource(sourceName, sourceAuth)
+
+	case constants.SourceJmap:
+		rawUrl := c.PostForm("url")
+		if rawUrl == "" {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "Missing JMAP url")
+		}
+		if util.IsValidUrl(rawUrl) != nil {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "Invalid JMAP url")
+		}
+		sourceUrl, err := types.NewUrl(rawUrl)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlPlain, "Invalid JMAP url")
+		}
+
+		source = jmap.NewJmapSource(sourceName, sourceUrl, sourceAuth)
+		source.SupplyContext(ctx)
+
+	case constants.SourceHolidays:
+		country := c.PostForm("country")
+		if country == "" {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlPlain, "Missing holiday country")
+		}
+
+		source, tr = holidays.NewHolidaysSource(sourceName, country, c.PostForm("region"))
+		if tr != nil {
+			return nil, tr
+		}
+
+	case constants.SourceLuna:
+		source = luna.NewLunaSource(sourceName)
+
 	case "":
 		return nil, errors.New().Status(http.StatusBadRequest).
 			Append(errors.LvlPlain, "Missing source type")
@@ -322,6 +415,14 @@ func PutSource(c *gin.Context) {
 		return
 	}
 
+	if c.PostForm("reminders") == "true" {
+		err = u.Tx.Queries().SetSourceReminders(userId, id, true)
+		if err != nil {
+			u.Error(err)
+			return
+		}
+	}
+
 	u.Success(&gin.H{"id": id.String()})
 }
 
@@ -339,8 +440,9 @@ func PatchSource(c *gin.Context) {
 	newName := c.PostForm("name")
 	newType := c.PostForm("type")
 	newAuthType := c.PostForm("auth_type")
+	newReminders := c.PostForm("reminders")
 
-	if newName == "" && newType == "" && newAuthType == "" {
+	if newName == "" && newType == "" && newAuthType == "" && newReminders == "" {
 		u.Error(errors.New().Status(http.StatusBadRequest).
 			Append(errors.LvlPlain, "Nothing to change"))
 		return
@@ -383,11 +485,32 @@ func PatchSource(c *gin.Context) {
 			return
 		}
 	}
+	if source.GetType() == constants.SourceLuna {
+		// Calendars hosted by Luna would be orphaned once the source is changed to another type
+		if newType != "" && newType != constants.SourceLuna {
+			err = source.Cleanup(u.Tx.Queries())
+		}
+		if err != nil {
+			u.Error(err.
+				Append(errors.LvlWordy, "Could not clean up source before editing"))
+			return
+		}
+	}
 
-	err = u.Tx.Queries().UpdateSource(userId, sourceId, newName, newAuth, newType, newSourceSettings)
-	if err != nil {
-		u.Error(err)
-		return
+	if newName != "" || newAuth != nil || newType != "" {
+		err = u.Tx.Queries().UpdateSource(userId, sourceId, newName, newAuth, newType, newSourceSettings)
+		if err != nil {
+			u.Error(err)
+			return
+		}
+	}
+
+	if newReminders != "" {
+		err = u.Tx.Queries().SetSourceReminders(userId, sourceId, newReminders == "true")
+		if err != nil {
+			u.Error(err)
+			return
+		}
 	}
 
 	u.Success(nil)
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"luna-backend/api/internal/util"
+	"luna-backend/constants"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permission required to be notified about changes of each resource
+var changePermissions = map[string]types.Permission{
+	constants.ChangeResourceSource:   types.PermReadSources,
+	constants.ChangeResourceCalendar: types.PermReadCalendars,
+	constants.ChangeResourceEvent:    types.PermReadEvents,
+	constants.ChangeResourceSettings: types.PermManageUserSettings,
+	constants.ChangeResourceSession:  types.PermManageSessions,
+}
+
+func canSeeChange(permissions *types.TokenPermissions, sessionId types.ID, change *types.Change) bool {
+	// A session is always told that it was revoked, even if it cannot manage sessions
+	if change.Resource == constants.ChangeResourceSession && (change.Id == sessionId || change.Id.IsEmpty()) {
+		return true
+	}
+	return permissions.Has(changePermissions[change.Resource])
+}
+
+func writeChange(w io.Writer, change *types.Change) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: change\ndata: %s\n\n", change.Token, data)
+	return err
+}
+
+// Server-sent events stream of changes to the user's resources.
+// A reconnecting client resumes from the token of the last change it received,
+// which browsers send automatically in the Last-Event-ID header.
+func GetStream(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+	sessionId := util.GetSessionId(c)
+	permissions := util.GetPermissions(c)
+
+	resumeToken := c.GetHeader("Last-Event-ID")
+	if resumeToken == "" {
+		resumeToken = c.Query("resume")
+	}
+
+	u.ResponseWithStream(func(c *gin.Context) {
+		sub, missed, resumable := u.Config.Changes.Subscribe(userId, sessionId, resumeToken)
+		defer sub.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		var err error
+		switch {
+		case !resumable:
+			// The missed changes are unknown, so the client has to refetch everything
+			_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: reset\ndata: {}\n\n", sub.Token())
+		case resumeToken == "":
+			_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: ready\ndata: {}\n\n", sub.Token())
+		default:
+			// The token is left as is until the missed changes have been sent
+			_, err = fmt.Fprint(c.Writer, "event: ready\ndata: {}\n\n")
+		}
+		for _, change := range missed {
+			if err != nil {
+				return
+			}
+			if canSeeChange(permissions, sessionId, change) {
+				err = writeChange(c.Writer, change)
+			}
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(constants.ChangeStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			case change, open := <-sub.Changes():
+				if !open {
+					return
+				}
+				if canSeeChange(permissions, sessionId, change) {
+					err = writeChange(c.Writer, change)
+				}
+			}
+			if err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	})
+}
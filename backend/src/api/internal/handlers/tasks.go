@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"luna-backend/api/internal/util"
+	"luna-backend/cache"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type exposedTask struct {
+	Id       types.ID `json:"id"`
+	Calendar types.ID `json:"calendar"`
+	Uid      string   `json:"uid"`
+	*types.TaskProps
+	CanEdit   bool `json:"can_edit"`
+	CanDelete bool `json:"can_delete"`
+}
+
+func exposeTask(task types.Task) exposedTask {
+	return exposedTask{
+		Id:        task.GetId(),
+		Calendar:  task.GetCalendar().GetId(),
+		Uid:       task.GetUid(),
+		TaskProps: task.GetProps(),
+		CanEdit:   task.CanEdit(),
+		CanDelete: task.CanDelete(),
+	}
+}
+
+func canAddTasks(cal types.Calendar) bool {
+	taskCal, ok := cal.(types.TaskCalendar)
+	return ok && taskCal.CanAddTasks()
+}
+
+// Only some sources can hold tasks besides events
+func getTaskCalendar(u *util.HandlerUtility, userId types.ID, c *gin.Context) (types.TaskCalendar, *errors.ErrorTrace) {
+	calendarId, tr := util.GetId(c, "calendar")
+	if tr != nil {
+		return nil, tr
+	}
+
+	calendar, tr := cache.GetCached(u.Config.Cache, userId, calendarId, u.Context, func() (types.Calendar, *errors.ErrorTrace) {
+		return u.Tx.Queries().GetCalendar(userId, calendarId, u.Context, u.Config)
+	})
+	if tr != nil {
+		return nil, tr
+	}
+
+	taskCal, ok := calendar.(types.TaskCalendar)
+	if !ok {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlDebug, "Calendar %v of type %v does not support tasks", calendarId, calendar.GetSource().GetType()).
+			Append(errors.LvlPlain, "Calendar does not support tasks")
+	}
+
+	return taskCal, nil
+}
+
+func parseTaskTime(c *gin.Context, field string, current *time.Time) (*time.Time, *errors.ErrorTrace) {
+	raw, exists := c.GetPostForm(field)
+	if !exists {
+		return current, nil
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed %v time", field)
+	}
+	return &parsed, nil
+}
+
+func parseTaskInt(c *gin.Context, field string, current int) (int, *errors.ErrorTrace) {
+	raw, exists := c.GetPostForm(field)
+	if !exists {
+		return current, nil
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed %v", field)
+	}
+	return parsed, nil
+}
+
+// Applies the submitted fields to a copy of the current properties.
+// Fields that are not sent keep their current value, while empty fields remove it.
+func parseTaskProps(c *gin.Context, current *types.TaskProps) (*types.TaskProps, *errors.ErrorTrace) {
+	props := current.Clone()
+	if props == nil {
+		props = &types.TaskProps{}
+	}
+
+	if name, exists := c.GetPostForm("name"); exists {
+		props.Name = name
+	}
+	if desc, exists := c.GetPostForm("desc"); exists {
+		props.Desc = desc
+	}
+	if allDay, exists := c.GetPostForm("all_day"); exists {
+		props.AllDay = allDay == "true"
+	}
+	if status, exists := c.GetPostForm("status"); exists {
+		props.Status = status
+	}
+	if parent, exists := c.GetPostForm("parent"); exists {
+		props.Parent = parent
+	}
+
+	var tr *errors.ErrorTrace
+	props.Start, tr = parseTaskTime(c, "start", props.Start)
+	if tr != nil {
+		return nil, tr
+	}
+	props.Due, tr = parseTaskTime(c, "due", props.Due)
+	if tr != nil {
+		return nil, tr
+	}
+	props.Percent, tr = parseTaskInt(c, "percent", props.Percent)
+	if tr != nil {
+		return nil, tr
+	}
+	props.Priority, tr = parseTaskInt(c, "priority", props.Priority)
+	if tr != nil {
+		return nil, tr
+	}
+
+	if props.Name == "" {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing name")
+	}
+
+	err := props.Validate()
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlPlain, "Malformed task")
+	}
+
+	return props, nil
+}
+
+// The parent must be a task in the same calendar, and a task must not become a subtask of its own subtasks
+func checkTaskParent(tasks []types.Task, uid string, parent string) *errors.ErrorTrace {
+	if parent == "" {
+		return nil
+	}
+
+	parents := make(map[string]string, len(tasks))
+	for _, task := range tasks {
+		parents[task.GetUid()] = task.GetProps().Parent
+	}
+
+	if _, exists := parents[parent]; !exists {
+		return errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlDebug, "Parent task %v not found", parent).
+			Append(errors.LvlPlain, "Parent task not found")
+	}
+
+	visited := map[string]bool{}
+	for current := parent; current != "" && !visited[current]; current = parents[current] {
+		if current == uid {
+			return errors.New().Status(http.StatusBadRequest).
+				Append(errors.LvlDebug, "Task %v cannot be a subtask of %v", uid, parent).
+				Append(errors.LvlPlain, "A task cannot be its own subtask")
+		}
+		visited[current] = true
+	}
+
+	return nil
+}
+
+func GetTasks(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	calendar, tr := getTaskCalendar(u, userId, c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tasks, tr := calendar.GetTasks(u.Tx.Queries())
+	u.Tx.Queries().ReportSourceHealth(calendar.GetSource().GetId(), tr)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	convertedTasks := make([]exposedTask, len(tasks))
+	for i, task := range tasks {
+		convertedTasks[i] = exposeTask(task)
+	}
+
+	u.Success(&gin.H{"tasks": convertedTasks})
+}
+
+func GetTask(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	calendar, tr := getTaskCalendar(u, userId, c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	taskId, tr := util.GetId(c, "task")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	task, tr := calendar.GetTask(taskId, u.Tx.Queries())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{"task": exposeTask(task)})
+}
+
+func PutTask(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	calendar, tr := getTaskCalendar(u, userId, c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	props, tr := parseTaskProps(c, nil)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	if props.Parent != "" {
+		tasks, tr := calendar.GetTasks(u.Tx.Queries())
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+		tr = checkTaskParent(tasks, "", props.Parent)
+		if tr != nil {
+			u.Error(tr)
+			return
+		}
+	}
+
+	task, tr := calendar.AddTask(props, u.Tx.Queries())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{"id": task.GetId().String()})
+}
+
+func PatchTask(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	calendar, tr := getTaskCalendar(u, userId, c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	taskId, tr := util.GetId(c, "task")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tasks, tr := calendar.GetTasks(u.Tx.Queries())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	var task types.Task
+	for _, candidate := range tasks {
+		if candidate.GetId() == taskId {
+			task = candidate
+			break
+		}
+	}
+	if task == nil {
+		u.Error(errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Task %v not found in calendar %v", taskId, calendar.GetId()).
+			Append(errors.LvlPlain, "Task not found"))
+		return
+	}
+
+	props, tr := parseTaskProps(c, task.GetProps())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = checkTaskParent(tasks, task.GetUid(), props.Parent)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	newTask, tr := calendar.EditTask(task, props, u.Tx.Queries())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{"task": exposeTask(newTask)})
+}
+
+// Subtasks are kept when their parent is deleted and show up as top-level tasks afterwards
+func DeleteTask(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	calendar, tr := getTaskCalendar(u, userId, c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	taskId, tr := util.GetId(c, "task")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	task, tr := calendar.GetTask(taskId, u.Tx.Queries())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = calendar.DeleteTask(task, u.Tx.Queries())
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(nil)
+}
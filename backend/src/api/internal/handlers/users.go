@@ -329,6 +329,15 @@ func PatchUserData(c *gin.Context) {
 			u.Error(tr)
 			return
 		}
+
+		// Changing the email address resets its verification
+		if newUserStruct.Email != oldUserStruct.Email {
+			tr = sendEmailVerification(u, newUserStruct)
+			if tr != nil {
+				u.Error(tr)
+				return
+			}
+		}
 	}
 
 	// Update the password
@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"luna-backend/api/internal/util"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebAuthn ceremonies take two requests. The first one returns the options
+// for the browser's navigator.credentials API together with a ceremony ID.
+// The second one receives the ceremony ID and the JSON-serialized
+// PublicKeyCredential that the browser produced in the form field credential.
+
+func getWebauthnUser(u *util.HandlerUtility, userId types.ID) (*auth.WebauthnUser, *errors.ErrorTrace) {
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	credentials, tr := u.Tx.Queries().GetWebauthnCredentials(userId)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return auth.NewWebauthnUser(user, credentials), nil
+}
+
+func getCeremonyId(c *gin.Context) (types.ID, *errors.ErrorTrace) {
+	rawId := c.PostForm("ceremony_id")
+	if rawId == "" {
+		return types.EmptyId(), errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Missing ceremony ID")
+	}
+
+	id, err := types.IdFromString(rawId)
+	if err != nil {
+		return types.EmptyId(), errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Received id was: %v", rawId).
+			Append(errors.LvlPlain, "Malformed ceremony ID")
+	}
+
+	return id, nil
+}
+
+func parseAssertion(c *gin.Context) (*protocol.ParsedCredentialAssertionData, *errors.ErrorTrace) {
+	parsed, err := protocol.ParseCredentialRequestResponseBody(strings.NewReader(c.PostForm("credential")))
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse WebAuthn assertion").
+			Append(errors.LvlPlain, "Malformed credential")
+	}
+	return parsed, nil
+}
+
+// Begins a ceremony for a known user, or for an unknown one if the user ID is empty
+func beginWebauthnLogin(u *util.HandlerUtility, userId types.ID, purpose string) (*gin.H, *errors.ErrorTrace) {
+	relyingParty, tr := auth.NewWebauthn(u.Config)
+	if tr != nil {
+		return nil, tr
+	}
+
+	var options *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var err error
+	if userId.IsEmpty() {
+		options, session, err = relyingParty.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	} else {
+		user, tr := getWebauthnUser(u, userId)
+		if tr != nil {
+			return nil, tr
+		}
+		options, session, err = relyingParty.BeginLogin(user)
+	}
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not begin WebAuthn login").
+			Append(errors.LvlPlain, "No passkeys available")
+	}
+
+	ceremonyId, tr := u.Tx.Queries().InsertWebauthnCeremony(userId, purpose, session)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return &gin.H{
+		"ceremony_id": ceremonyId,
+		"options":     options,
+	}, nil
+}
+
+// Checks the assertion for a ceremony started by beginWebauthnLogin and returns the user it belongs to
+func finishWebauthnLogin(u *util.HandlerUtility, c *gin.Context, userId types.ID, purpose string) (types.ID, *errors.ErrorTrace) {
+	ceremonyId, tr := getCeremonyId(c)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	session, tr := u.Tx.Queries().TakeWebauthnCeremony(ceremonyId, userId, purpose)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	assertion, tr := parseAssertion(c)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	relyingParty, tr := auth.NewWebauthn(u.Config)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	var user *auth.WebauthnUser
+	var credential *webauthn.Credential
+	var err error
+	if userId.IsEmpty() {
+		// The authenticator tells us whose passkey it is
+		var handlerTrace *errors.ErrorTrace
+		_, credential, err = relyingParty.ValidatePasskeyLogin(func(rawId, userHandle []byte) (webauthn.User, error) {
+			ownerId, err := types.IdFromBytes(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			user, handlerTrace = getWebauthnUser(u, ownerId)
+			if handlerTrace != nil {
+				return nil, handlerTrace.SerializeError(errors.LvlDebug)
+			}
+			return user, nil
+		}, *session, assertion)
+	} else {
+		user, tr = getWebauthnUser(u, userId)
+		if tr != nil {
+			return types.EmptyId(), tr
+		}
+		credential, err = relyingParty.ValidateLogin(user, *session, assertion)
+	}
+	if err != nil {
+		return types.EmptyId(), errors.New().Status(http.StatusUnauthorized).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not validate WebAuthn assertion").
+			Append(errors.LvlPlain, "Invalid passkey")
+	}
+
+	// Authenticators with a signature counter make it possible to detect cloned keys
+	if credential.Authenticator.CloneWarning {
+		return types.EmptyId(), errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Signature counter of WebAuthn credential did not increase").
+			Append(errors.LvlPlain, "Invalid passkey")
+	}
+
+	stored := user.FindCredential(credential)
+	if stored == nil {
+		return types.EmptyId(), errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Validated WebAuthn credential is not stored").
+			Append(errors.LvlPlain, "Invalid passkey")
+	}
+
+	tr = u.Tx.Queries().UpdateWebauthnCredentialUsage(stored.Id, credential)
+	if tr != nil {
+		return types.EmptyId(), tr
+	}
+
+	return user.User().Id, nil
+}
+
+// Begins a login without a password. The passkey has to verify the user, e.g.
+// through a PIN or biometrics, so that it counts as two factors by itself.
+func PutLoginPasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	response, tr := beginWebauthnLogin(u, types.EmptyId(), constants.WebauthnCeremonyLogin)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	u.Success(response)
+}
+
+func LoginPasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId, tr := finishWebauthnLogin(u, c, types.EmptyId(), constants.WebauthnCeremonyLogin)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	enabled, tr := u.Tx.Queries().IsUserEnabled(userId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlDebug, "Could not check if user %v is enabled", userId.String()).
+			Append(errors.LvlWordy, "Database error").
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+	if !enabled {
+		u.Error(errors.New().Status(http.StatusForbidden).
+			Append(errors.LvlPlain, "Your account is disabled."),
+		)
+		return
+	}
+
+	tr = checkEmailVerified(u, userId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	token, tr := createSession(u, c, userId, c.PostForm("remember") != "true")
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	u.Success(&gin.H{"token": token})
+}
+
+// Begins using a passkey as the second factor after the password was checked
+func PutLoginMfaPasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	preauth, tr := getPreauthSession(u, c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	response, tr := beginWebauthnLogin(u, preauth.UserId, constants.WebauthnCeremonySecondFactor)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlBroad, "Could not log in"),
+		)
+		return
+	}
+
+	u.Success(response)
+}
+
+// Begins using a passkey to confirm changes to the second factors, see verifyReauthentication
+func PutMfaPasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	response, tr := beginWebauthnLogin(u, userId, constants.WebauthnCeremonySecondFactor)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(response)
+}
+
+func GetPasskeys(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	credentials, tr := u.Tx.Queries().GetWebauthnCredentials(userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{"passkeys": credentials})
+}
+
+// Anyone holding a passkey can log in without a password, so adding one requires re-authentication
+func PutPasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	tr := verifyReauthentication(u, c, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	user, tr := getWebauthnUser(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	relyingParty, tr := auth.NewWebauthn(u.Config)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	existing := webauthn.Credentials(user.WebAuthnCredentials())
+	options, session, err := relyingParty.BeginRegistration(user,
+		webauthn.WithExclusions(existing.CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		u.Error(errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not begin WebAuthn registration").
+			Append(errors.LvlPlain, "Could not add passkey"),
+		)
+		return
+	}
+
+	ceremonyId, tr := u.Tx.Queries().InsertWebauthnCeremony(userId, constants.WebauthnCeremonyRegistration, session)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(&gin.H{
+		"ceremony_id": ceremonyId,
+		"options":     options,
+	})
+}
+
+func PostPasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > 255 {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Name is too long"),
+		)
+		return
+	}
+
+	ceremonyId, tr := getCeremonyId(c)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	session, tr := u.Tx.Queries().TakeWebauthnCeremony(ceremonyId, userId, constants.WebauthnCeremonyRegistration)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	creation, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(c.PostForm("credential")))
+	if err != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse WebAuthn attestation").
+			Append(errors.LvlPlain, "Malformed credential"),
+		)
+		return
+	}
+
+	user, tr := getWebauthnUser(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	relyingParty, tr := auth.NewWebauthn(u.Config)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	credential, err := relyingParty.CreateCredential(user, *session, creation)
+	if err != nil {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not validate WebAuthn attestation").
+			Append(errors.LvlPlain, "Could not add passkey"),
+		)
+		return
+	}
+
+	factors, tr := getSecondFactors(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	stored := &types.WebauthnCredential{
+		UserId:     userId,
+		Name:       name,
+		Credential: *credential,
+	}
+	tr = u.Tx.Queries().InsertWebauthnCredential(stored)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlPlain, "Could not add passkey"),
+		)
+		return
+	}
+
+	// The first second factor comes with recovery codes in case it gets lost
+	if factors.Any() {
+		u.Success(&gin.H{"passkey": stored})
+		return
+	}
+
+	codes, tr := generateRecoveryCodes(u, userId)
+	if tr != nil {
+		u.Error(tr.
+			Append(errors.LvlPlain, "Could not generate recovery codes"),
+		)
+		return
+	}
+
+	u.Success(&gin.H{"passkey": stored, "recovery_codes": codes})
+}
+
+func PatchPasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	passkeyId, tr := util.GetId(c, "passkey")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" || len(name) > 255 {
+		u.Error(errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Invalid name"),
+		)
+		return
+	}
+
+	tr = u.Tx.Queries().RenameWebauthnCredential(userId, passkeyId, name)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(nil)
+}
+
+func DeletePasskey(c *gin.Context) {
+	u := util.GetUtil(c)
+
+	userId := util.GetUserId(c)
+
+	passkeyId, tr := util.GetId(c, "passkey")
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = verifyReauthentication(u, c, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = u.Tx.Queries().DeleteWebauthnCredential(userId, passkeyId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	tr = afterSecondFactorRemoved(u, userId)
+	if tr != nil {
+		u.Error(tr)
+		return
+	}
+
+	u.Success(nil)
+}
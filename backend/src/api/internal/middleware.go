@@ -28,6 +28,7 @@ func RequestSetup(timeout time.Duration, database *db.Database, withTransaction
 		var responseMsg *gin.H
 		var responseFileName string
 		var responseFileBody []byte
+		var responseStream func(c *gin.Context)
 		var responseErr *errors.ErrorTrace
 		var responseWarns []*errors.ErrorTrace
 
@@ -53,6 +54,11 @@ func RequestSetup(timeout time.Duration, database *db.Database, withTransaction
 				}
 			}
 
+			if responseStream != nil && responseErr == nil {
+				responseStream(c)
+				return
+			}
+
 			if responseErr != nil {
 				logger.Error(responseErr.Serialize(errors.LvlDebug))
 				c.AbortWithStatusJSON(responseErr.GetStatus(), &gin.H{"error": responseErr.Serialize(config.LoggingVerbosity())})
@@ -114,6 +120,7 @@ func RequestSetup(timeout time.Duration, database *db.Database, withTransaction
 			Config:       config,
 			Logger:       logger,
 			Tx:           tx,
+			Db:           database,
 			Context:      ctx,
 			GinContext:   c,
 			ResponseChan: responseChan,
@@ -146,6 +153,7 @@ func RequestSetup(timeout time.Duration, database *db.Database, withTransaction
 			responseRaw = response.GetRaw()
 			responseRawType = response.GetRawType()
 			responseMsg = response.GetMsg()
+			responseStream = response.GetStream()
 			responseFile := response.GetFile()
 
 			if responseFile != nil {
@@ -329,6 +337,29 @@ func RequireAuth() gin.HandlerFunc {
 	}
 }
 
+// CalDAV clients cannot obtain a token through the login endpoint,
+// so they have to send an API token as the password of HTTP basic authentication.
+// The username is only used by clients to tell accounts apart, the token alone identifies the user.
+func DavAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := util.GetUtil(c)
+
+		c.Header("WWW-Authenticate", `Basic realm="Luna", charset="UTF-8"`)
+
+		_, password, ok := c.Request.BasicAuth()
+		if !ok || password == "" {
+			u.Error(errors.New().Status(http.StatusUnauthorized).
+				Append(errors.LvlWordy, "Missing credentials"))
+			c.Abort()
+			return
+		}
+
+		c.Request.Header.Set("Authorization", "Bearer "+password)
+
+		c.Next()
+	}
+}
+
 func RequireAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		u := util.GetUtil(c)
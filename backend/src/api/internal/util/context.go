@@ -18,6 +18,7 @@ type HandlerUtility struct {
 	Config       *config.CommonConfig
 	Logger       *logrus.Entry
 	Tx           *db.Transaction
+	Db           *db.Database // for work that runs in parallel and needs its own transactions
 	Context      context.Context
 	GinContext   *gin.Context
 	ResponseChan chan *Response
@@ -31,6 +32,7 @@ type Response struct {
 	file     types.File
 	raw      []byte
 	rawType  string
+	stream   func(c *gin.Context)
 }
 
 func (r *Response) GetStatus() int {
@@ -53,20 +55,37 @@ func (r *Response) GetFile() types.File {
 	return r.file
 }
 
+func (r *Response) GetStream() func(c *gin.Context) {
+	return r.stream
+}
+
 func (u *HandlerUtility) Success(msg *gin.H) {
 	u.ResponseWithStatus(http.StatusOK, msg)
 }
 
 func (u *HandlerUtility) SuccessRawJson(rawJson []byte) {
-	u.ResponseChan <- &Response{http.StatusOK, nil, nil, rawJson, "application/json"}
+	u.ResponseChan <- &Response{http.StatusOK, nil, nil, rawJson, "application/json", nil}
+}
+
+func (u *HandlerUtility) ResponseRawWithStatus(httpCode int, raw []byte, rawType string) {
+	if raw == nil {
+		raw = []byte{}
+	}
+	u.ResponseChan <- &Response{httpCode, nil, nil, raw, rawType, nil}
 }
 
 func (u *HandlerUtility) ResponseWithStatus(httpCode int, msg *gin.H) {
-	u.ResponseChan <- &Response{httpCode, msg, nil, nil, ""}
+	u.ResponseChan <- &Response{httpCode, msg, nil, nil, "", nil}
 }
 
 func (u *HandlerUtility) ResponseWithFile(file types.File) {
-	u.ResponseChan <- &Response{http.StatusOK, nil, file, nil, ""}
+	u.ResponseChan <- &Response{http.StatusOK, nil, file, nil, "", nil}
+}
+
+// The stream is written once the transaction has been committed.
+// It is not bound to the request timeout and runs until it returns or the client disconnects.
+func (u *HandlerUtility) ResponseWithStream(stream func(c *gin.Context)) {
+	u.ResponseChan <- &Response{http.StatusOK, nil, nil, nil, "", stream}
 }
 
 func (u *HandlerUtility) Error(err *errors.ErrorTrace) {
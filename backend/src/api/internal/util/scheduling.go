@@ -0,0 +1,81 @@
+package util
+
+import (
+	"luna-backend/config"
+	"luna-backend/errors"
+	"luna-backend/scheduling"
+	"luna-backend/types"
+)
+
+// Returns nil if the user does not want scheduling messages to be sent or no SMTP server is configured
+func getScheduler(u *HandlerUtility, user *types.User) (*scheduling.Scheduler, *errors.ErrorTrace) {
+	scheduler := scheduling.NewScheduler(u.Config.Env)
+	if scheduler == nil {
+		return nil, nil
+	}
+
+	setting := &config.SchedulingEmail{}
+	tr := u.Tx.Queries().GetUserSettingOrDefault(user.Id, setting)
+	if tr != nil {
+		return nil, tr
+	}
+	if !setting.Enabled {
+		return nil, nil
+	}
+
+	return scheduler, nil
+}
+
+// Sends invitations and cancellations after the user changed an event they organize,
+// either through the API or through Luna's CalDAV server.
+// Failures are only reported as warnings, since the event itself has already been saved.
+// Must be called before a deleted event is removed from the database, so that its sequence number can be incremented.
+func ScheduleEventChange(u *HandlerUtility, userId types.ID, before types.Event, after types.Event) {
+	user, tr := u.Tx.Queries().GetUser(userId)
+	if tr != nil {
+		u.Warn(tr)
+		return
+	}
+
+	if !scheduling.IsOrganizer(user, before) && !scheduling.IsOrganizer(user, after) {
+		return
+	}
+
+	scheduler, tr := getScheduler(u, user)
+	if tr != nil || scheduler == nil {
+		if tr != nil {
+			u.Warn(tr)
+		}
+		return
+	}
+
+	sequence := 0
+	if before != nil {
+		sequence, tr = u.Tx.Queries().IncrementEventSequence(userId, before.GetId(), before.GetUid())
+		if tr != nil {
+			u.Warn(tr)
+			return
+		}
+	}
+
+	tr = scheduler.EventChanged(user, before, after, sequence, u.Context)
+	if tr != nil {
+		u.Warn(tr)
+	}
+}
+
+// Sends a reply to the organizer after the user changed their participation status
+func ScheduleParticipationChange(u *HandlerUtility, user *types.User, event types.Event, attendee *types.EventAttendee) {
+	scheduler, tr := getScheduler(u, user)
+	if tr != nil || scheduler == nil {
+		if tr != nil {
+			u.Warn(tr)
+		}
+		return
+	}
+
+	tr = scheduler.ParticipationChanged(user, event, attendee, u.Context)
+	if tr != nil {
+		u.Warn(tr)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"luna-backend/config"
 	"luna-backend/db"
 	"luna-backend/types"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -42,6 +43,17 @@ func run(api *util.Api) {
 
 	authEndpoints.POST("/login", handlers.Login)
 	authEndpoints.POST("/register", handlers.Register)
+	authEndpoints.POST("/login/mfa", handlers.LoginSecondFactor)
+	authEndpoints.PUT("/login/mfa/totp", handlers.PutLoginTotp)
+	authEndpoints.PUT("/login/mfa/passkey", handlers.PutLoginMfaPasskey)
+	authEndpoints.PUT("/login/passkey", handlers.PutLoginPasskey)
+	authEndpoints.POST("/login/passkey", handlers.LoginPasskey)
+	authEndpoints.PUT("/login/oauth/:clientId", handlers.PutOauthLogin)
+	authEndpoints.POST("/login/oauth/:requestId", handlers.OauthLogin)
+	authEndpoints.PUT("/password/reset", handlers.PutPasswordReset)
+	authEndpoints.POST("/password/reset", handlers.PostPasswordReset)
+	authEndpoints.PUT("/email/verification", handlers.PutEmailVerification)
+	authEndpoints.POST("/email/verification", handlers.PostEmailVerification)
 
 	// /api/* the rest
 	endpoints := rawEndpoints.Group("",
@@ -50,6 +62,8 @@ func run(api *util.Api) {
 
 	endpoints.GET("/health", handlers.GetHealth)
 	endpoints.GET("/register/enabled", handlers.RegistrationEnabled)
+	endpoints.GET("/login/oauth", handlers.GetLoginProviders)
+	endpoints.GET("/feeds/:feedId/:token", handlers.GetCalendarFeedContent) // authenticated by the secret in the URL
 
 	// everything past here requires the user to be logged in
 	authenticatedEndpoints := endpoints.Group("", middleware.RequireAuth())
@@ -76,6 +90,27 @@ func run(api *util.Api) {
 	userSettingsEndpoints.DELETE("", handlers.ResetUserSettings)
 	userSettingsEndpoints.DELETE("/:settingKey", handlers.ResetUserSetting)
 
+	// /api/mfa/*
+	mfaEndpoints := authenticatedEndpoints.Group("/mfa", middleware.RequirePermissions(types.PermManageUsers))
+	longRunningMfaEndpoints := longRunningAuthenticatedEndpoints.Group("/mfa", middleware.RequirePermissions(types.PermManageUsers)) // endpoints that hash passwords or recovery codes
+
+	mfaEndpoints.GET("", handlers.GetMfaStatus)
+	mfaEndpoints.PUT("/totp", handlers.PutTotp)
+	mfaEndpoints.PUT("/passkey", handlers.PutMfaPasskey)
+	longRunningMfaEndpoints.POST("/totp", handlers.ConfirmTotp)
+	longRunningMfaEndpoints.DELETE("/totp", handlers.DeleteTotp)
+	longRunningMfaEndpoints.PUT("/recovery", handlers.PutRecoveryCodes)
+
+	// /api/passkeys/*
+	passkeyEndpoints := authenticatedEndpoints.Group("/passkeys", middleware.RequirePermissions(types.PermManageUsers))
+	longRunningPasskeyEndpoints := longRunningAuthenticatedEndpoints.Group("/passkeys", middleware.RequirePermissions(types.PermManageUsers)) // endpoints that verify the password or recovery codes
+
+	passkeyEndpoints.GET("", handlers.GetPasskeys)
+	longRunningPasskeyEndpoints.PUT("", handlers.PutPasskey)
+	longRunningPasskeyEndpoints.POST("", handlers.PostPasskey) // may hash recovery codes
+	passkeyEndpoints.PATCH("/:passkeyId", handlers.PatchPasskey)
+	longRunningPasskeyEndpoints.DELETE("/:passkeyId", handlers.DeletePasskey)
+
 	// /api/sources/*
 	sourcesEndpoints := authenticatedEndpoints.Group("/sources")
 
@@ -88,6 +123,9 @@ func run(api *util.Api) {
 	sourcesEndpoints.PUT("/:sourceId/calendars", middleware.RequirePermissions(types.PermAddCalendars), handlers.PutCalendar)
 	sourcesEndpoints.POST("/:sourceId/order", middleware.RequirePermissions(types.PermEditSources), handlers.ChangeSourceDisplayOrder)
 
+	sourcesAdminEndpoints := administratorEndpoints.Group("/sources")
+	sourcesAdminEndpoints.GET("/unhealthy", handlers.GetUnhealthySources)
+
 	// /api/calendars/*
 	calendarsEndpoints := authenticatedEndpoints.Group("/calendars")
 	calendarsEndpoints.GET("/:calendarId", middleware.RequirePermissions(types.PermReadCalendars), handlers.GetCalendar)
@@ -95,13 +133,30 @@ func run(api *util.Api) {
 	calendarsEndpoints.DELETE("/:calendarId", middleware.RequirePermissions(types.PermDeleteCalendars), handlers.DeleteCalendar)
 	calendarsEndpoints.GET("/:calendarId/events", middleware.RequirePermissions(types.PermReadEvents), handlers.GetEvents)
 	calendarsEndpoints.PUT("/:calendarId/events", middleware.RequirePermissions(types.PermAddEvents), handlers.PutEvent)
+	calendarsEndpoints.GET("/:calendarId/tasks", middleware.RequirePermissions(types.PermReadEvents), handlers.GetTasks)
+	calendarsEndpoints.PUT("/:calendarId/tasks", middleware.RequirePermissions(types.PermAddEvents), handlers.PutTask)
+	calendarsEndpoints.GET("/:calendarId/tasks/:taskId", middleware.RequirePermissions(types.PermReadEvents), handlers.GetTask)
+	calendarsEndpoints.PATCH("/:calendarId/tasks/:taskId", middleware.RequirePermissions(types.PermEditEvents), handlers.PatchTask)
+	calendarsEndpoints.DELETE("/:calendarId/tasks/:taskId", middleware.RequirePermissions(types.PermDeleteEvents), handlers.DeleteTask)
 	calendarsEndpoints.POST("/:calendarId/order", middleware.RequirePermissions(types.PermEditCalendars), handlers.ChangeCalendarDisplayOrder)
+	calendarsEndpoints.PUT("/:calendarId/feeds", middleware.RequirePermissions(types.PermReadCalendars, types.PermReadEvents), handlers.PutCalendarFeed)
+
+	// /api/feeds/*
+	feedEndpoints := authenticatedEndpoints.Group("/feeds", middleware.RequirePermissions(types.PermReadCalendars, types.PermReadEvents))
+	feedEndpoints.GET("", handlers.GetCalendarFeeds)
+	feedEndpoints.DELETE("/:feedId", handlers.DeleteCalendarFeed)
 
 	// /api/events/*
 	eventEndpoints := authenticatedEndpoints.Group("/events")
+	eventEndpoints.GET("", middleware.RequirePermissions(types.PermReadCalendars, types.PermReadEvents), handlers.GetAllEvents)
 	eventEndpoints.GET("/:eventId", middleware.RequirePermissions(types.PermReadEvents), handlers.GetEvent)
 	eventEndpoints.PATCH("/:eventId", middleware.RequirePermissions(types.PermEditEvents), handlers.PatchEvent)
 	eventEndpoints.DELETE("/:eventId", middleware.RequirePermissions(types.PermDeleteEvents), handlers.DeleteEvent)
+	eventEndpoints.POST("/:eventId/participation", middleware.RequirePermissions(types.PermEditEvents), handlers.PostEventParticipation)
+
+	// /api/scheduling/*
+	schedulingEndpoints := authenticatedEndpoints.Group("/scheduling")
+	schedulingEndpoints.POST("/inbox", middleware.RequirePermissions(types.PermEditEvents), handlers.PostSchedulingInbox)
 
 	// /api/files/*
 	fileEndpoints := authenticatedEndpoints.Group("/files")
@@ -163,9 +218,40 @@ func run(api *util.Api) {
 
 	oauthTokensEndpoints.GET("", handlers.GetOauthClientsWithTokens)
 
+	// /api/oauth/identities/*
+	oauthIdentitiesEndpoints := oauthEndpoints.Group("/identities")
+
+	oauthIdentitiesEndpoints.GET("", handlers.GetOauthIdentities)
+	oauthIdentitiesEndpoints.PUT("/:clientId", handlers.PutOauthIdentity)
+	oauthIdentitiesEndpoints.POST("/:requestId", handlers.PostOauthIdentity)
+	oauthIdentitiesEndpoints.DELETE("/:identityId", handlers.DeleteOauthIdentity)
+
+	// /api/stream
+	authenticatedEndpoints.GET("/stream", handlers.GetStream)
+
 	// /api/* the rest
 	authenticatedEndpoints.POST("/url", handlers.CheckUrl)
 
+	// /dav/* (CalDAV server)
+	davEndpoints := router.Group("/dav",
+		middleware.RequestSetup(api.CommonConfig.Env.REQUEST_TIMEOUT_DEFAULT, api.Db, true, api.CommonConfig, api.Logger),
+		middleware.DynamicThrottle(api.Throttle),
+		middleware.DavAuth(),
+		middleware.RequireAuth(),
+	)
+
+	davReadPermissions := middleware.RequirePermissions(types.PermReadCalendars, types.PermReadEvents)
+	for _, method := range []string{http.MethodOptions, http.MethodGet, http.MethodHead, "PROPFIND", "REPORT"} {
+		davEndpoints.Handle(method, "/*path", davReadPermissions, handlers.ServeDav)
+	}
+	davEndpoints.PUT("/*path", middleware.RequirePermissions(types.PermAddEvents, types.PermEditEvents), handlers.ServeDav)
+	davEndpoints.DELETE("/*path", middleware.RequirePermissions(types.PermDeleteEvents), handlers.ServeDav)
+	davEndpoints.Handle("PROPPATCH", "/*path", middleware.RequirePermissions(types.PermEditCalendars), handlers.ServeDav)
+	davEndpoints.Handle("MKCOL", "/*path", middleware.RequirePermissions(types.PermAddCalendars), handlers.ServeDav)
+
+	router.GET("/.well-known/caldav", handlers.RedirectDav)
+	router.Handle("PROPFIND", "/.well-known/caldav", handlers.RedirectDav)
+
 	// Run the server
 	router.Run(fmt.Sprintf(":%d", api.CommonConfig.Env.API_PORT))
 }
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"luna-backend/config"
+	"luna-backend/constants"
+	"luna-backend/errors"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Users are looked up in the directory with the configured filter, either
+// anonymously or as the configured service account. Their password is then
+// checked by binding as the entry that was found.
+
+type LdapUser struct {
+	Dn       string
+	Username string
+	Email    string
+	Groups   []string
+}
+
+func (user *LdapUser) InGroup(group string) bool {
+	groupDn, err := ldap.ParseDN(group)
+	for _, memberOf := range user.Groups {
+		memberOfDn, memberErr := ldap.ParseDN(memberOf)
+		if err == nil && memberErr == nil {
+			if groupDn.EqualFold(memberOfDn) {
+				return true
+			}
+		} else if strings.EqualFold(group, memberOf) {
+			return true
+		}
+	}
+	return false
+}
+
+func getLdapTlsConfig(env *config.Environmental) (*tls.Config, *errors.ErrorTrace) {
+	ldapUrl, err := url.Parse(env.LDAP_URL)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse LDAP URL").
+			Append(errors.LvlWordy, "Could not connect to the directory")
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         ldapUrl.Hostname(),
+		InsecureSkipVerify: env.LDAP_TLS_SKIP_VERIFY,
+	}
+
+	if env.LDAP_TLS_CA_FILE != "" {
+		ca, err := os.ReadFile(env.LDAP_TLS_CA_FILE)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not read LDAP certificate authority %v", env.LDAP_TLS_CA_FILE).
+				Append(errors.LvlWordy, "Could not connect to the directory")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				Append(errors.LvlDebug, "Could not parse LDAP certificate authority %v", env.LDAP_TLS_CA_FILE).
+				Append(errors.LvlWordy, "Could not connect to the directory")
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func connectLdap(env *config.Environmental) (*ldap.Conn, *errors.ErrorTrace) {
+	tlsConfig, tr := getLdapTlsConfig(env)
+	if tr != nil {
+		return nil, tr
+	}
+
+	conn, err := ldap.DialURL(env.LDAP_URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: constants.LdapTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not connect to LDAP server %v", env.LDAP_URL).
+			Append(errors.LvlWordy, "Could not connect to the directory")
+	}
+	conn.SetTimeout(constants.LdapTimeout)
+
+	if env.LDAP_STARTTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, errors.New().Status(http.StatusBadGateway).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not start TLS with LDAP server %v", env.LDAP_URL).
+				Append(errors.LvlWordy, "Could not connect to the directory")
+		}
+	}
+
+	if env.LDAP_BIND_DN != "" {
+		err = conn.Bind(env.LDAP_BIND_DN, env.LDAP_BIND_PASSWORD)
+		if err != nil {
+			conn.Close()
+			return nil, errors.New().Status(http.StatusBadGateway).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not bind to LDAP server as %v", env.LDAP_BIND_DN).
+				Append(errors.LvlWordy, "Could not connect to the directory")
+		}
+	}
+
+	return conn, nil
+}
+
+func AuthenticateLdap(env *config.Environmental, username string, password string) (*LdapUser, *errors.ErrorTrace) {
+	// An empty password would be an unauthenticated bind, which many servers accept (RFC 4513 5.1.2)
+	if password == "" {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Empty password").
+			Append(errors.LvlPlain, "Invalid credentials")
+	}
+
+	conn, tr := connectLdap(env)
+	if tr != nil {
+		return nil, tr
+	}
+	defer conn.Close()
+
+	search := ldap.NewSearchRequest(
+		env.LDAP_SEARCH_BASE,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, // one entry is expected, a second one makes the filter ambiguous
+		int(constants.LdapTimeout.Seconds()),
+		false,
+		strings.ReplaceAll(env.LDAP_USER_FILTER, "{username}", ldap.EscapeFilter(username)),
+		[]string{env.LDAP_USERNAME_ATTRIBUTE, env.LDAP_EMAIL_ATTRIBUTE, env.LDAP_GROUP_ATTRIBUTE},
+		nil,
+	)
+
+	res, err := conn.Search(search)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not search for LDAP user %v", username).
+			Append(errors.LvlWordy, "Could not connect to the directory")
+	}
+	if res == nil || len(res.Entries) != 1 {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Found no unique LDAP entry for user %v", username).
+			Append(errors.LvlPlain, "Invalid credentials")
+	}
+	entry := res.Entries[0]
+
+	err = conn.Bind(entry.DN, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Wrong password for LDAP entry %v", entry.DN).
+			Append(errors.LvlPlain, "Invalid credentials")
+	}
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not bind as LDAP entry %v", entry.DN).
+			Append(errors.LvlWordy, "Could not connect to the directory")
+	}
+
+	return &LdapUser{
+		Dn:       entry.DN,
+		Username: entry.GetAttributeValue(env.LDAP_USERNAME_ATTRIBUTE),
+		Email:    entry.GetAttributeValue(env.LDAP_EMAIL_ATTRIBUTE),
+		Groups:   entry.GetAttributeValues(env.LDAP_GROUP_ATTRIBUTE),
+	}, nil
+}
@@ -15,9 +15,12 @@ import (
 )
 
 type oidcDiscoveryResponse struct {
-	AuthorizationEndpoint string `json:"authorization_endpoint"`
-	TokenEndpoint         string `json:"token_endpoint"`
-	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	Issuer                string   `json:"issuer"`
+	JwksUri               string   `json:"jwks_uri"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	IdTokenAlgs           []string `json:"id_token_signing_alg_values_supported"`
 }
 
 func FetchOauthUrls(oauthClient *types.OauthClient, ctx context.Context) *errors.ErrorTrace {
@@ -62,6 +65,21 @@ func FetchOauthUrls(oauthClient *types.OauthClient, ctx context.Context) *errors
 			AltStr(errors.LvlWordy, "Could not fetch URLs for OAuth 2.0 client")
 	}
 
+	// Only needed to validate ID tokens, so providers that are not used for
+	// logging in do not have to offer it
+	oauthClient.Issuer = res.Issuer
+	oauthClient.IdTokenAlgs = res.IdTokenAlgs
+	if res.JwksUri != "" {
+		oauthClient.JwksUrl, err = types.NewUrl(res.JwksUri)
+		if err != nil {
+			return errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not parse JWKS URL %v", res.JwksUri).
+				Append(errors.LvlDebug, "Could not fetch URLs for OAuth 2.0 client %v", oauthClient.Name).
+				AltStr(errors.LvlWordy, "Could not fetch URLs for OAuth 2.0 client")
+		}
+	}
+
 	return nil
 }
 
@@ -69,6 +87,16 @@ func GetOauthRedirectUrl(config *config.CommonConfig) *types.Url {
 	return config.PublicUrl.Subpage("/oauth")
 }
 
+func grantsScopes(granted string, requested string) bool {
+	grantedSlice := strings.Split(granted, " ")
+	for _, scope := range strings.Split(requested, " ") {
+		if scope != "offline_access" && !slices.Contains(grantedSlice, scope) {
+			return false
+		}
+	}
+	return true
+}
+
 // RFC 6749 5.1
 type oauthTokenResponse struct {
 	AccessToken  string `json:"access_token"`
@@ -76,9 +104,10 @@ type oauthTokenResponse struct {
 	Expires      int    `json:"expires_in"`
 	RefreshToken string `json:"refresh_token"`
 	Scope        string `json:"scope"`
+	IdToken      string `json:"id_token"` // OpenID Connect Core 1.0 3.1.3.3
 }
 
-func fetchOauthTokens(oauthClient *types.OauthClient, expectRefresh bool, form *url.Values, ctx context.Context) (*types.OauthTokens, *errors.ErrorTrace) {
+func fetchOauthTokens(oauthClient *types.OauthClient, scope string, expectRefresh bool, form *url.Values, ctx context.Context) (*types.OauthTokens, *errors.ErrorTrace) {
 	// RFC 6749 4.1.3, 6
 	form.Add("client_id", oauthClient.ClientId)
 	form.Add("client_secret", oauthClient.ClientSecret)
@@ -94,14 +123,12 @@ func fetchOauthTokens(oauthClient *types.OauthClient, expectRefresh bool, form *
 			AltStr(errors.LvlWordy, "Could not fetch tokens for OAuth 2.0 client")
 	}
 
-	// Google OAuth 2.0 returns the scopes in a potentially different order
-	resScopeSlice := strings.Split(res.Scope, " ")
-	clientScopeSlice := strings.Split(oauthClient.Scope, " ")
-	slices.Sort(resScopeSlice)
-	slices.Sort(clientScopeSlice)
-	if res.Scope != "" && strings.Join(resScopeSlice, " ") != strings.Join(clientScopeSlice, " ") {
+	// Google OAuth 2.0 returns the scopes in a potentially different order.
+	// Microsoft also returns scopes that were granted earlier, but never the offline_access scope,
+	// so only the requested scopes are checked.
+	if res.Scope != "" && !grantsScopes(res.Scope, scope) {
 		return nil, errors.New().Status(http.StatusInternalServerError).
-			Append(errors.LvlDebug, "Returned scope %v does not match the requested scope %v", res.Scope, oauthClient.Scope).
+			Append(errors.LvlDebug, "Returned scope %v does not match the requested scope %v", res.Scope, scope).
 			Append(errors.LvlDebug, "Could not fetch tokens for OAuth 2.0 client %v", oauthClient.Name).
 			AltStr(errors.LvlWordy, "Could not fetch tokens for OAuth 2.0 client")
 	}
@@ -141,6 +168,7 @@ func fetchOauthTokens(oauthClient *types.OauthClient, expectRefresh bool, form *
 		AccessToken:  res.AccessToken,
 		RefreshToken: res.RefreshToken,
 		Expires:      timestamp,
+		IdToken:      res.IdToken,
 	}, nil
 }
 
@@ -152,7 +180,7 @@ func FetchOauthTokensUsingAuthorizationCode(oauthClient *types.OauthClient, auth
 	form.Add("code", authCode)
 	form.Add("redirect_uri", GetOauthRedirectUrl(config).String())
 
-	return fetchOauthTokens(oauthClient, true, &form, ctx)
+	return fetchOauthTokens(oauthClient, oauthClient.Scope, true, &form, ctx)
 }
 
 func FetchOauthTokensUsingRefreshToken(oauthClient *types.OauthClient, refreshToken string, ctx context.Context) (*types.OauthTokens, *errors.ErrorTrace) {
@@ -162,7 +190,7 @@ func FetchOauthTokensUsingRefreshToken(oauthClient *types.OauthClient, refreshTo
 	form.Add("grant_type", "refresh_token")
 	form.Add("refresh_token", refreshToken)
 
-	return fetchOauthTokens(oauthClient, false, &form, ctx)
+	return fetchOauthTokens(oauthClient, oauthClient.Scope, false, &form, ctx)
 }
 
 type oidcUserinfoResponse struct {
@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"luna-backend/config"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	"luna-backend/net"
+	"luna-backend/types"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OAuth 2.0 clients that are marked as login providers can be used to sign in
+// using the OpenID Connect authorization code flow. The flow is protected by
+// PKCE (RFC 7636) and a nonce, and the ID token returned by the provider is
+// validated before the user is looked up by its subject identifier.
+
+func GetOauthLoginRedirectUrl(config *config.CommonConfig) *types.Url {
+	return config.PublicUrl.Subpage("oauth", "login")
+}
+
+func newRandomString() (string, *errors.ErrorTrace) {
+	bytes, tr := crypto.GenerateRandomBytes(32)
+	if tr != nil {
+		return "", tr
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// RFC 7636 4.1
+func NewPkceVerifier() (string, *errors.ErrorTrace) {
+	return newRandomString()
+}
+
+// RFC 7636 4.2, using the S256 method
+func PkceChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func NewOidcNonce() (string, *errors.ErrorTrace) {
+	return newRandomString()
+}
+
+func GetOauthLoginUrl(oauthClient *types.OauthClient, request *types.OauthLoginRequest, config *config.CommonConfig) string {
+	consentUrl := *oauthClient.AuthorizationUrl.URL()
+	queryParams := consentUrl.Query()
+
+	// OpenID Connect Core 1.0 3.1.2.1
+	queryParams.Add("response_type", "code")
+	queryParams.Add("client_id", oauthClient.ClientId)
+	queryParams.Add("redirect_uri", GetOauthLoginRedirectUrl(config).String())
+	queryParams.Add("scope", constants.OidcLoginScope)
+	queryParams.Add("state", request.Id.String())
+	queryParams.Add("nonce", request.Nonce)
+	queryParams.Add("code_challenge", PkceChallenge(request.CodeVerifier))
+	queryParams.Add("code_challenge_method", "S256")
+
+	consentUrl.RawQuery = queryParams.Encode()
+	return consentUrl.String()
+}
+
+func FetchOauthTokensUsingLoginCode(oauthClient *types.OauthClient, authCode string, codeVerifier string, ctx context.Context, config *config.CommonConfig) (*types.OauthTokens, *errors.ErrorTrace) {
+	form := make(url.Values)
+
+	// RFC 6749 4.1.3, RFC 7636 4.5
+	form.Add("grant_type", "authorization_code")
+	form.Add("code", authCode)
+	form.Add("redirect_uri", GetOauthLoginRedirectUrl(config).String())
+	form.Add("code_verifier", codeVerifier)
+
+	tokens, tr := fetchOauthTokens(oauthClient, constants.OidcLoginScope, false, &form, ctx)
+	if tr != nil {
+		return nil, tr
+	}
+
+	if tokens.IdToken == "" {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			Append(errors.LvlDebug, "Received no ID token").
+			Append(errors.LvlDebug, "Could not fetch tokens for OAuth 2.0 client %v", oauthClient.Name).
+			AltStr(errors.LvlWordy, "Could not fetch tokens for OAuth 2.0 client")
+	}
+
+	return tokens, nil
+}
+
+// RFC 7517 4, RFC 7518 6
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func decodeBigInt(raw string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func (key *jsonWebKey) publicKey() (any, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %v", key.Crv)
+		}
+		x, err := decodeBigInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if key.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %v", key.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", key.Kty)
+	}
+}
+
+func fetchOidcSigningKey(oauthClient *types.OauthClient, keyId string, ctx context.Context) (any, error) {
+	if oauthClient.JwksUrl == nil {
+		return nil, fmt.Errorf("the provider does not publish its signing keys")
+	}
+
+	res := &jsonWebKeySet{}
+	tr := net.FetchJson(oauthClient.JwksUrl, "GET", NewNoAuth(), nil, "", ctx, res)
+	if tr != nil {
+		return nil, tr.SerializeError(errors.LvlDebug)
+	}
+
+	for _, key := range res.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		// The key ID may only be omitted if there is no ambiguity
+		if key.Kid != keyId && (keyId != "" || len(res.Keys) != 1) {
+			continue
+		}
+		return key.publicKey()
+	}
+
+	return nil, fmt.Errorf("no signing key with ID %v", keyId)
+}
+
+type OidcIdentity struct {
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// Groups are usually sent as an array, but some providers send a single string
+func parseGroupClaim(claim any) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		groups := make([]string, 0, len(value))
+		for _, group := range value {
+			if name, ok := group.(string); ok {
+				groups = append(groups, name)
+			}
+		}
+		return groups
+	default:
+		return []string{}
+	}
+}
+
+var idTokenAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA", "HS256", "HS384", "HS512"}
+
+// Only the algorithms that the provider announced are accepted, and RS256 if it announced none.
+// Symmetric signatures use the client secret as the key, so they are never accepted without one.
+func idTokenMethods(oauthClient *types.OauthClient) []string {
+	announced := oauthClient.IdTokenAlgs
+	if len(announced) == 0 {
+		announced = []string{"RS256"}
+	}
+
+	methods := []string{}
+	for _, alg := range announced {
+		if !slices.Contains(idTokenAlgs, alg) {
+			continue
+		}
+		if strings.HasPrefix(alg, "HS") && oauthClient.ClientSecret == "" {
+			continue
+		}
+		methods = append(methods, alg)
+	}
+	return methods
+}
+
+// OpenID Connect Core 1.0 3.1.3.7
+func ValidateIdToken(oauthClient *types.OauthClient, rawIdToken string, nonce string, ctx context.Context) (*OidcIdentity, *errors.ErrorTrace) {
+	if oauthClient.Issuer == "" {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			Append(errors.LvlDebug, "The provider did not announce its issuer identifier").
+			Append(errors.LvlDebug, "Could not validate ID token of OAuth 2.0 client %v", oauthClient.Name).
+			AltStr(errors.LvlWordy, "Could not validate ID token")
+	}
+
+	methods := idTokenMethods(oauthClient)
+	if len(methods) == 0 {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			Append(errors.LvlDebug, "None of the signing algorithms %v announced by the provider can be used", strings.Join(oauthClient.IdTokenAlgs, ", ")).
+			Append(errors.LvlDebug, "Could not validate ID token of OAuth 2.0 client %v", oauthClient.Name).
+			AltStr(errors.LvlWordy, "Could not validate ID token")
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(rawIdToken, claims, func(token *jwt.Token) (any, error) {
+		// Symmetric signatures use the client secret as the key
+		if strings.HasPrefix(token.Method.Alg(), "HS") {
+			if oauthClient.ClientSecret == "" {
+				return nil, fmt.Errorf("symmetric signatures cannot be checked without a client secret")
+			}
+			return []byte(oauthClient.ClientSecret), nil
+		}
+		keyId, _ := token.Header["kid"].(string)
+		return fetchOidcSigningKey(oauthClient, keyId, ctx)
+	},
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(oauthClient.Issuer),
+		jwt.WithAudience(oauthClient.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not validate ID token of OAuth 2.0 client %v", oauthClient.Name).
+			AltStr(errors.LvlWordy, "Invalid ID token")
+	}
+
+	audience, _ := claims.GetAudience()
+	authorizedParty, _ := claims["azp"].(string)
+	if len(audience) > 1 && authorizedParty != oauthClient.ClientId {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "The ID token was issued to %v", authorizedParty).
+			Append(errors.LvlDebug, "Could not validate ID token of OAuth 2.0 client %v", oauthClient.Name).
+			AltStr(errors.LvlWordy, "Invalid ID token")
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if tokenNonce != nonce {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "The nonce does not match").
+			Append(errors.LvlDebug, "Could not validate ID token of OAuth 2.0 client %v", oauthClient.Name).
+			AltStr(errors.LvlWordy, "Invalid ID token")
+	}
+
+	identity := &OidcIdentity{}
+	identity.Subject, _ = claims.GetSubject()
+	identity.Username, _ = claims["preferred_username"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	if oauthClient.GroupClaim != "" {
+		identity.Groups = parseGroupClaim(claims[oauthClient.GroupClaim])
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "The ID token contains no subject identifier").
+			Append(errors.LvlDebug, "Could not validate ID token of OAuth 2.0 client %v", oauthClient.Name).
+			AltStr(errors.LvlWordy, "Invalid ID token")
+	}
+
+	return identity, nil
+}
+
+func (identity *OidcIdentity) InGroup(group string) bool {
+	return slices.Contains(identity.Groups, group)
+}
@@ -3,11 +3,13 @@ package auth
 import (
 	"encoding/base64"
 	"luna-backend/config"
+	"luna-backend/constants"
 	"luna-backend/crypto"
 	"luna-backend/db"
 	"luna-backend/errors"
 	"luna-backend/types"
 	"net/http"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -62,3 +64,61 @@ func ParseToken(commonConfig *config.CommonConfig, tokenString string) (*JsonWeb
 
 	return token, nil
 }
+
+// Proves that the password was correct, but can only be traded in for a real
+// token together with a second factor. It is signed with a different key, so
+// that it can never be mistaken for a real token.
+type PreauthToken struct {
+	PreauthId types.ID `json:"preauth_id"`
+	UserId    types.ID `json:"user_id"`
+	Secret    string   `json:"secret"`
+	jwt.RegisteredClaims
+}
+
+func NewPreauthToken(commonConfig *config.CommonConfig, userId types.ID, preauthId types.ID, secret []byte) (string, *errors.ErrorTrace) {
+	token := PreauthToken{
+		UserId:    userId,
+		PreauthId: preauthId,
+		Secret:    base64.StdEncoding.EncodeToString(secret),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(constants.LifetimePreauthSession)),
+		},
+	}
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS512, token)
+
+	key, tr := crypto.GetSymmetricKey(commonConfig, "preauth")
+	if tr != nil {
+		return "", tr
+	}
+
+	signedToken, err := jwtToken.SignedString(key)
+	if err != nil {
+		return "", errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not sign pre-authentication token")
+	}
+
+	return signedToken, nil
+}
+
+func ParsePreauthToken(commonConfig *config.CommonConfig, tokenString string) (*PreauthToken, *errors.ErrorTrace) {
+	token := &PreauthToken{}
+
+	_, err := jwt.ParseWithClaims(tokenString, token, func(token *jwt.Token) (interface{}, error) {
+		key, tr := crypto.GetSymmetricKey(commonConfig, "preauth")
+		if tr != nil {
+			return nil, tr.SerializeError(commonConfig.LoggingVerbosity())
+		}
+		return key, nil
+	})
+
+	if err != nil {
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse pre-authentication token").
+			Append(errors.LvlPlain, "Login expired, please log in again")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Time-based one-time passwords as described in RFC 6238, using the defaults
+// that every common authenticator app supports: SHA-1, 6 digits, 30 seconds.
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func NewTotpSecret() ([]byte, *errors.ErrorTrace) {
+	secret, tr := crypto.GenerateRandomBytes(20)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not generate TOTP secret")
+	}
+	return secret, nil
+}
+
+func EncodeTotpSecret(secret []byte) string {
+	return totpEncoding.EncodeToString(secret)
+}
+
+// URL that authenticator apps can import, usually by scanning it as a QR code
+func TotpUrl(secret []byte, username string) string {
+	label := url.PathEscape("Luna:" + username)
+	query := url.Values{}
+	query.Set("secret", EncodeTotpSecret(secret))
+	query.Set("issuer", "Luna")
+	query.Set("digits", fmt.Sprint(constants.TotpDigits))
+	query.Set("period", fmt.Sprint(int(constants.TotpPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func totpCode(secret []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for range constants.TotpDigits {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", constants.TotpDigits, value%modulo)
+}
+
+// Returns the time step the code belongs to, so that the caller can prevent it
+// from being used again. Codes of neighbouring steps are accepted to allow for
+// clock drift.
+func VerifyTotp(secret []byte, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != constants.TotpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(constants.TotpPeriod.Seconds())
+	for step := current - constants.TotpSkew; step <= current+constants.TotpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Recovery codes are shown to the user once and only stored hashed
+func NewRecoveryCode() (string, *errors.ErrorTrace) {
+	raw, tr := crypto.GenerateRandomBytes(10)
+	if tr != nil {
+		return "", tr.
+			Append(errors.LvlWordy, "Could not generate recovery code")
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(raw))
+	return code[:8] + "-" + code[8:], nil
+}
+
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 16 {
+		code = code[:8] + "-" + code[8:]
+	}
+	return code
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"luna-backend/config"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// The relying party is the frontend, since that is where the browser runs the
+// ceremonies. Passkeys are therefore bound to the host of PUBLIC_URL.
+func NewWebauthn(commonConfig *config.CommonConfig) (*webauthn.WebAuthn, *errors.ErrorTrace) {
+	publicUrl := commonConfig.PublicUrl.URL()
+
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:          publicUrl.Hostname(),
+		RPDisplayName: "Luna",
+		RPOrigins:     []string{publicUrl.Scheme + "://" + publicUrl.Host},
+	})
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not configure WebAuthn")
+	}
+
+	return relyingParty, nil
+}
+
+// Adapts a user and their credentials to what the WebAuthn library expects.
+// The user handle stored on the authenticator is the user ID.
+type WebauthnUser struct {
+	user        *types.User
+	credentials []*types.WebauthnCredential
+}
+
+func NewWebauthnUser(user *types.User, credentials []*types.WebauthnCredential) *WebauthnUser {
+	return &WebauthnUser{
+		user:        user,
+		credentials: credentials,
+	}
+}
+
+// The raw 16 bytes rather than ID.Bytes(), which returns the string representation
+func (u *WebauthnUser) WebAuthnID() []byte {
+	id := u.user.Id.UUID()
+	return id[:]
+}
+
+func (u *WebauthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *WebauthnUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *WebauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.credentials))
+	for i, credential := range u.credentials {
+		credentials[i] = credential.Credential
+	}
+	return credentials
+}
+
+func (u *WebauthnUser) User() *types.User {
+	return u.user
+}
+
+// Finds the stored credential that the authenticator used
+func (u *WebauthnUser) FindCredential(credential *webauthn.Credential) *types.WebauthnCredential {
+	for _, candidate := range u.credentials {
+		if string(candidate.Credential.ID) == string(credential.ID) {
+			return candidate
+		}
+	}
+	return nil
+}
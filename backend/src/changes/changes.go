@@ -0,0 +1,186 @@
+package changes
+
+import (
+	"fmt"
+	"luna-backend/constants"
+	"luna-backend/types"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// This module delivers change notifications to the open change streams of a
+// user, so that clients do not have to poll for new data.
+//
+// Changes are only published once the transaction that caused them has been
+// committed. The most recent changes of every user are kept in memory, which
+// allows a client that lost its connection to resume where it left off by
+// sending the token of the last change it received. If the changes since then
+// are no longer known, for example because the server restarted in the
+// meantime, the client is told to reset and refetch everything instead.
+
+type entry struct {
+	seq    uint64
+	change *types.Change
+}
+
+type userLog struct {
+	entries     []entry
+	dropped     uint64 // sequence number of the newest change that is no longer kept
+	subscribers map[*Subscription]bool
+}
+
+type Hub struct {
+	epoch string // distinguishes tokens issued before a restart
+	seq   uint64
+	users map[types.ID]*userLog
+	lock  sync.Mutex
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		epoch: types.RandomId().String()[:8],
+		users: map[types.ID]*userLog{},
+	}
+}
+
+type Subscription struct {
+	hub       *Hub
+	userId    types.ID
+	sessionId types.ID
+	token     string
+	changes   chan *types.Change
+	closed    bool
+}
+
+// Changes are received until the subscription is closed, either by the client
+// disconnecting, by the session being revoked, or by the client falling behind.
+func (s *Subscription) Changes() <-chan *types.Change {
+	return s.changes
+}
+
+// Token of the newest change at the time of subscribing
+func (s *Subscription) Token() string {
+	return s.token
+}
+
+func (s *Subscription) Close() {
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+
+	s.hub.unsubscribe(s)
+}
+
+func (hub *Hub) token(seq uint64) string {
+	return fmt.Sprintf("%s-%d", hub.epoch, seq)
+}
+
+func (hub *Hub) parseToken(token string) (uint64, bool) {
+	epoch, rawSeq, found := strings.Cut(token, "-")
+	if !found || epoch != hub.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil || seq > hub.seq {
+		return 0, false
+	}
+	return seq, true
+}
+
+func (hub *Hub) getLog(userId types.ID) *userLog {
+	log, exists := hub.users[userId]
+	if !exists {
+		log = &userLog{subscribers: map[*Subscription]bool{}}
+		hub.users[userId] = log
+	}
+	return log
+}
+
+// Registers a new change stream. If a resume token is given, the changes the
+// client missed since are returned as well. If they cannot be determined,
+// resumable is false and the client has to refetch everything.
+func (hub *Hub) Subscribe(userId types.ID, sessionId types.ID, resumeToken string) (sub *Subscription, missed []*types.Change, resumable bool) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	log := hub.getLog(userId)
+
+	sub = &Subscription{
+		hub:       hub,
+		userId:    userId,
+		sessionId: sessionId,
+		token:     hub.token(hub.seq),
+		changes:   make(chan *types.Change, constants.ChangeStreamBufferSize),
+	}
+	log.subscribers[sub] = true
+
+	if resumeToken == "" {
+		return sub, nil, true
+	}
+
+	seq, valid := hub.parseToken(resumeToken)
+	if !valid || seq < log.dropped {
+		return sub, nil, false
+	}
+
+	for _, entry := range log.entries {
+		if entry.seq > seq {
+			missed = append(missed, entry.change)
+		}
+	}
+
+	return sub, missed, true
+}
+
+func (hub *Hub) unsubscribe(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.changes)
+
+	log := hub.users[sub.userId]
+	delete(log.subscribers, sub)
+}
+
+// Never blocks the caller. Streams that cannot keep up are closed, so that the
+// client reconnects and resumes from the last change it received.
+func (hub *Hub) Publish(userId types.ID, changes []*types.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	log := hub.getLog(userId)
+
+	for _, change := range changes {
+		hub.seq++
+		published := *change
+		published.Token = hub.token(hub.seq)
+
+		log.entries = append(log.entries, entry{seq: hub.seq, change: &published})
+		if len(log.entries) > constants.ChangeStreamBufferSize {
+			log.dropped = log.entries[0].seq
+			log.entries = log.entries[1:]
+		}
+
+		for sub := range log.subscribers {
+			select {
+			case sub.changes <- &published:
+			default:
+				hub.unsubscribe(sub)
+			}
+		}
+
+		// Streams of revoked sessions end after being told about it
+		if published.Resource == constants.ChangeResourceSession && (published.Action == constants.ChangeActionDeleted || published.Action == constants.ChangeActionRevoked) {
+			for sub := range log.subscribers {
+				if published.Id.IsEmpty() || sub.sessionId == published.Id {
+					hub.unsubscribe(sub)
+				}
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"luna-backend/cache"
+	"luna-backend/changes"
 	"luna-backend/errors"
 	"luna-backend/types"
 )
@@ -10,10 +11,12 @@ type CommonConfig struct {
 	Version                  types.Version
 	Env                      *Environmental
 	Cache                    *cache.Cache
+	Changes                  *changes.Hub
 	PublicUrl                *types.Url
 	Settings                 *GlobalSettings
 	TokenInvalidationChannel chan *types.Session
 	OauthInvalidationChannel chan types.ID
+	SourceHealthChannel      chan *types.SourceHealthReport
 }
 
 func (c *CommonConfig) LoggingVerbosity() int {
@@ -23,3 +26,17 @@ func (c *CommonConfig) LoggingVerbosity() int {
 		return c.Settings.LoggingVerbosity.Verbosity
 	}
 }
+
+// Never blocks the caller, since a lost report only delays the health record until the next one.
+// Returns false if the report was dropped because the source health service is busy.
+func (c *CommonConfig) ReportSourceHealth(sourceId types.ID, tr *errors.ErrorTrace) bool {
+	if c.SourceHealthChannel == nil {
+		return true
+	}
+	select {
+	case c.SourceHealthChannel <- &types.SourceHealthReport{SourceId: sourceId, Error: tr}:
+		return true
+	default:
+		return false
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
@@ -27,6 +28,34 @@ type Environmental struct {
 	REQUEST_TIMEOUT_DEFAULT        time.Duration `env:"REQUEST_TIMEOUT_DEFAULT" envDefault:"15s"`
 	REQUEST_TIMEOUT_AUTHENTICATION time.Duration `env:"REQUEST_TIMEOUT_AUTHENTICATION" envDefault:"15s"`
 
+	EVENTS_FETCH_WORKERS        int           `env:"EVENTS_FETCH_WORKERS" envDefault:"3"`
+	EVENTS_FETCH_SOURCE_TIMEOUT time.Duration `env:"EVENTS_FETCH_SOURCE_TIMEOUT" envDefault:"10s"`
+
+	GOOGLE_API_URL    url.URL `env:"GOOGLE_API_URL" envDefault:"https://www.googleapis.com/calendar/v3"`
+	MICROSOFT_API_URL url.URL `env:"MICROSOFT_API_URL" envDefault:"https://graph.microsoft.com/v1.0"`
+
+	SMTP_HOST     string `env:"SMTP_HOST"`
+	SMTP_PORT     uint16 `env:"SMTP_PORT" envDefault:"587"`
+	SMTP_USERNAME string `env:"SMTP_USERNAME"`
+	SMTP_PASSWORD string `env:"SMTP_PASSWORD"`
+	SMTP_FROM     string `env:"SMTP_FROM"`
+
+	LDAP_URL                string `env:"LDAP_URL"`
+	LDAP_BIND_DN            string `env:"LDAP_BIND_DN"`
+	LDAP_BIND_PASSWORD      string `env:"LDAP_BIND_PASSWORD"`
+	LDAP_SEARCH_BASE        string `env:"LDAP_SEARCH_BASE"`
+	LDAP_USER_FILTER        string `env:"LDAP_USER_FILTER" envDefault:"(uid={username})"`
+	LDAP_USERNAME_ATTRIBUTE string `env:"LDAP_USERNAME_ATTRIBUTE" envDefault:"uid"`
+	LDAP_EMAIL_ATTRIBUTE    string `env:"LDAP_EMAIL_ATTRIBUTE" envDefault:"mail"`
+	LDAP_GROUP_ATTRIBUTE    string `env:"LDAP_GROUP_ATTRIBUTE" envDefault:"memberOf"`
+	LDAP_ADMIN_GROUP        string `env:"LDAP_ADMIN_GROUP"`
+	LDAP_STARTTLS           bool   `env:"LDAP_STARTTLS" envDefault:"false"`
+	LDAP_TLS_CA_FILE        string `env:"LDAP_TLS_CA_FILE"`
+	LDAP_TLS_SKIP_VERIFY    bool   `env:"LDAP_TLS_SKIP_VERIFY" envDefault:"false"`
+
+	REMINDER_SCAN_INTERVAL time.Duration `env:"REMINDER_SCAN_INTERVAL" envDefault:"5m"`
+	REMINDER_MAX_DELAY     time.Duration `env:"REMINDER_MAX_DELAY" envDefault:"1h"`
+
 	DEVELOPMENT bool `env:"DEVELOPMENT" envDefault:"false"`
 }
 
@@ -65,9 +94,45 @@ func (env *Environmental) Validate() error {
 		}
 	}
 
+	if env.EVENTS_FETCH_WORKERS < 1 {
+		return fmt.Errorf("EVENTS_FETCH_WORKERS must be at least 1")
+	}
+	// Otherwise the whole request times out before any source does, and no partial results are returned
+	if env.EVENTS_FETCH_SOURCE_TIMEOUT <= 0 || env.EVENTS_FETCH_SOURCE_TIMEOUT >= env.REQUEST_TIMEOUT_DEFAULT {
+		return fmt.Errorf("EVENTS_FETCH_SOURCE_TIMEOUT must be positive and shorter than REQUEST_TIMEOUT_DEFAULT")
+	}
+
+	if env.SMTP_HOST != "" && env.SMTP_FROM == "" {
+		return fmt.Errorf("SMTP_FROM is required if SMTP_HOST is set")
+	}
+
+	if env.LDAP_URL != "" {
+		if env.LDAP_SEARCH_BASE == "" {
+			return fmt.Errorf("LDAP_SEARCH_BASE is required if LDAP_URL is set")
+		}
+		if !strings.Contains(env.LDAP_USER_FILTER, "{username}") {
+			return fmt.Errorf("LDAP_USER_FILTER must contain the {username} placeholder")
+		}
+		if env.LDAP_BIND_DN != "" && env.LDAP_BIND_PASSWORD == "" {
+			return fmt.Errorf("LDAP_BIND_PASSWORD is required if LDAP_BIND_DN is set")
+		}
+	}
+
+	if env.REMINDER_SCAN_INTERVAL < time.Minute {
+		return fmt.Errorf("REMINDER_SCAN_INTERVAL must be at least one minute")
+	}
+	// Deliveries are only remembered for a week, see tasks.DeleteStaleReminderDeliveries
+	if env.REMINDER_MAX_DELAY < 0 || env.REMINDER_MAX_DELAY > 24*time.Hour {
+		return fmt.Errorf("REMINDER_MAX_DELAY must be between zero and 24 hours")
+	}
+
 	return nil
 }
 
+func (env *Environmental) LdapEnabled() bool {
+	return env.LDAP_URL != ""
+}
+
 func (env *Environmental) getBasePath() string {
 	return env.DATA_PATH
 }
@@ -16,6 +16,8 @@ type GlobalSettings struct {
 	EnableGravatar              EnableGravatar              `json:"enable_gravatar"`
 	CacheProfilePictures        CacheProfilePictures        `json:"cache_profile_pictures"`
 	EnableProfilePicturesUpload EnableProfilePicturesUpload `json:"enable_profile_pictures_upload"`
+	RequireTwoFactor            RequireTwoFactor            `json:"require_two_factor"`
+	RequireVerifiedEmail        RequireVerifiedEmail        `json:"require_verified_email"`
 }
 
 func (s *GlobalSettings) UpdateSetting(entry SettingsEntry) {
@@ -32,6 +34,10 @@ func (s *GlobalSettings) UpdateSetting(entry SettingsEntry) {
 		s.EnableGravatar.Enabled = entry.(*EnableGravatar).Enabled
 	case KeyCacheProfilePictures:
 		s.CacheProfilePictures.Enabled = entry.(*CacheProfilePictures).Enabled
+	case KeyRequireTwoFactor:
+		s.RequireTwoFactor.Enabled = entry.(*RequireTwoFactor).Enabled
+	case KeyRequireVerifiedEmail:
+		s.RequireVerifiedEmail.Enabled = entry.(*RequireVerifiedEmail).Enabled
 	default:
 		// TODO: warning
 	}
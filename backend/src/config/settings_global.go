@@ -15,6 +15,8 @@ const (
 	KeyEnableGravatar              = "enable_gravatar"
 	KeyCacheProfilePictures        = "cache_profile_pictures"
 	KeyEnableProfilePicturesUpload = "enable_profile_pictures_upload"
+	KeyRequireTwoFactor            = "require_two_factor"
+	KeyRequireVerifiedEmail        = "require_verified_email"
 )
 
 func AllDefaultGlobalSettings() []SettingsEntry {
@@ -26,6 +28,8 @@ func AllDefaultGlobalSettings() []SettingsEntry {
 		&EnableGravatar{},
 		&CacheProfilePictures{},
 		&EnableProfilePicturesUpload{},
+		&RequireTwoFactor{},
+		&RequireVerifiedEmail{},
 	}
 
 	for _, setting := range settings {
@@ -51,6 +55,10 @@ func GetMatchingGlobalSettingStruct(key string) (SettingsEntry, *errors.ErrorTra
 		return &CacheProfilePictures{}, nil
 	case KeyEnableProfilePicturesUpload:
 		return &EnableProfilePicturesUpload{}, nil
+	case KeyRequireTwoFactor:
+		return &RequireTwoFactor{}, nil
+	case KeyRequireVerifiedEmail:
+		return &RequireVerifiedEmail{}, nil
 	default:
 		return nil, errors.New().Status(http.StatusBadRequest).
 			Append(errors.LvlWordy, "Invalid setting key: %s", key).
@@ -233,3 +241,43 @@ func (entry *EnableProfilePicturesUpload) UnmarshalJSON(data []byte) (err error)
 	entry.Enabled, err = common.UnmarshalBool(data)
 	return err
 }
+
+// Whether every user has to set up a second factor before they can log in
+// Should default to false
+type RequireTwoFactor struct {
+	Enabled bool `json:"value"`
+}
+
+func (entry *RequireTwoFactor) Key() string {
+	return KeyRequireTwoFactor
+}
+func (entry *RequireTwoFactor) Default() {
+	entry.Enabled = false
+}
+func (entry *RequireTwoFactor) MarshalJSON() ([]byte, error) {
+	return common.MarshalBool(entry.Enabled), nil
+}
+func (entry *RequireTwoFactor) UnmarshalJSON(data []byte) (err error) {
+	entry.Enabled, err = common.UnmarshalBool(data)
+	return err
+}
+
+// Whether users have to verify their email address before they can log in
+// Should default to false
+type RequireVerifiedEmail struct {
+	Enabled bool `json:"value"`
+}
+
+func (entry *RequireVerifiedEmail) Key() string {
+	return KeyRequireVerifiedEmail
+}
+func (entry *RequireVerifiedEmail) Default() {
+	entry.Enabled = false
+}
+func (entry *RequireVerifiedEmail) MarshalJSON() ([]byte, error) {
+	return common.MarshalBool(entry.Enabled), nil
+}
+func (entry *RequireVerifiedEmail) UnmarshalJSON(data []byte) (err error) {
+	entry.Enabled, err = common.UnmarshalBool(data)
+	return err
+}
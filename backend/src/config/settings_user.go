@@ -1,10 +1,12 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"luna-backend/common"
 	"luna-backend/errors"
 	"net/http"
+	"net/url"
 )
 
 const (
@@ -28,6 +30,10 @@ const (
 	KeyAnimateMonthSelectionSwipe   = "animate_month_selection_swipe"
 	KeyAppearanceFrostedGlass       = "appearance_frosted_glass"
 	KeyAnimationDuration            = "animation_duration"
+	KeyNotificationEmail            = "notification_email"
+	KeyNotificationWebhook          = "notification_webhook"
+	KeyNotificationPush             = "notification_push"
+	KeySchedulingEmail              = "scheduling_email"
 )
 
 func AllDefaultUserSettings() []SettingsEntry {
@@ -52,6 +58,10 @@ func AllDefaultUserSettings() []SettingsEntry {
 		&AnimateMonthSelectionSwipe{},
 		&AppearenceFrostedGlass{},
 		&AnimationDuration{},
+		&NotificationEmail{},
+		&NotificationWebhook{},
+		&NotificationPush{},
+		&SchedulingEmail{},
 	}
 
 	for _, setting := range settings {
@@ -103,6 +113,14 @@ func GetMatchingUserSettingStruct(key string) (SettingsEntry, *errors.ErrorTrace
 		return &AppearenceFrostedGlass{}, nil
 	case KeyAnimationDuration:
 		return &AnimationDuration{}, nil
+	case KeyNotificationEmail:
+		return &NotificationEmail{}, nil
+	case KeyNotificationWebhook:
+		return &NotificationWebhook{}, nil
+	case KeyNotificationPush:
+		return &NotificationPush{}, nil
+	case KeySchedulingEmail:
+		return &SchedulingEmail{}, nil
 	default:
 		return nil, errors.New().Status(http.StatusBadRequest).
 			Append(errors.LvlWordy, "Invalid setting key %s", key).
@@ -554,3 +572,137 @@ func (entry *AnimationDuration) UnmarshalJSON(data []byte) (err error) {
 	}
 	return nil
 }
+
+// Whether reminders with the email action are sent to the user's email address
+// Should default to true
+type NotificationEmail struct {
+	Enabled bool `json:"value"`
+}
+
+func (entry *NotificationEmail) Key() string {
+	return KeyNotificationEmail
+}
+func (entry *NotificationEmail) Default() {
+	entry.Enabled = true
+}
+func (entry *NotificationEmail) MarshalJSON() ([]byte, error) {
+	return common.MarshalBool(entry.Enabled), nil
+}
+func (entry *NotificationEmail) UnmarshalJSON(data []byte) (err error) {
+	entry.Enabled, err = common.UnmarshalBool(data)
+	return err
+}
+
+// URL that reminders with the display action are posted to as JSON, or empty to disable
+// Should default to ""
+type NotificationWebhook struct {
+	Url string `json:"value"`
+}
+
+func (entry *NotificationWebhook) Key() string {
+	return KeyNotificationWebhook
+}
+func (entry *NotificationWebhook) Default() {
+	entry.Url = ""
+}
+func (entry *NotificationWebhook) MarshalJSON() ([]byte, error) {
+	return common.MarshalString(entry.Url), nil
+}
+func (entry *NotificationWebhook) UnmarshalJSON(data []byte) error {
+	rawUrl, err := common.UnmarshalString(data)
+	if err != nil {
+		return err
+	}
+	if rawUrl != "" {
+		err = validateNotificationUrl(rawUrl)
+		if err != nil {
+			return err
+		}
+	}
+	entry.Url = rawUrl
+	return nil
+}
+
+const (
+	PushServiceNtfy   = "ntfy"
+	PushServiceGotify = "gotify"
+)
+
+// Push service that reminders with the display action are sent to, or an empty service to disable
+// The URL is the topic URL for ntfy and the server URL for Gotify
+// Should default to an empty service
+type NotificationPush struct {
+	Service string `json:"service"`
+	Url     string `json:"url"`
+	Token   string `json:"token"`
+}
+
+func (entry *NotificationPush) Key() string {
+	return KeyNotificationPush
+}
+func (entry *NotificationPush) Default() {
+	entry.Service = ""
+	entry.Url = ""
+	entry.Token = ""
+}
+func (entry *NotificationPush) MarshalJSON() ([]byte, error) {
+	type plain NotificationPush
+	return json.Marshal((*plain)(entry))
+}
+func (entry *NotificationPush) UnmarshalJSON(data []byte) error {
+	type plain NotificationPush
+	parsed := &plain{}
+	err := json.Unmarshal(data, parsed)
+	if err != nil {
+		return err
+	}
+
+	switch parsed.Service {
+	case "":
+	case PushServiceNtfy, PushServiceGotify:
+		err = validateNotificationUrl(parsed.Url)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid push service: %s", parsed.Service)
+	}
+
+	*entry = NotificationPush(*parsed)
+	return nil
+}
+
+func validateNotificationUrl(rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("could not parse url: %v", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid url scheme: %s", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("url is missing a host")
+	}
+	return nil
+}
+
+// Whether invitations, cancellations and replies for events with attendees are sent by email.
+// Should be disabled if the CalDAV server already delivers them itself.
+// Should default to true
+type SchedulingEmail struct {
+	Enabled bool `json:"value"`
+}
+
+func (entry *SchedulingEmail) Key() string {
+	return KeySchedulingEmail
+}
+func (entry *SchedulingEmail) Default() {
+	entry.Enabled = true
+}
+func (entry *SchedulingEmail) MarshalJSON() ([]byte, error) {
+	return common.MarshalBool(entry.Enabled), nil
+}
+func (entry *SchedulingEmail) UnmarshalJSON(data []byte) (err error) {
+	entry.Enabled, err = common.UnmarshalBool(data)
+	return err
+}
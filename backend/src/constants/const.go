@@ -9,4 +9,28 @@ const LifetimeFileCacheHard = 24 * time.Hour
 
 const MaxInviteDuration = 7 * 24 * time.Hour // 7 days
 
+const DefaultFeedDaysPast = 30    // 1 month
+const DefaultFeedDaysFuture = 365 // 1 year
+const MaxFeedDays = 5 * 365       // 5 years in either direction
+
 const MaxFormBytes = 50 * 1024 * 1024 // 50MB
+
+const ChangeStreamBufferSize = 256 // changes kept per user for resuming streams
+const ChangeStreamHeartbeat = 30 * time.Second
+
+const TotpDigits = 6
+const TotpPeriod = 30 * time.Second
+const TotpSkew = 1 // accepted time steps before and after the current one
+const RecoveryCodeCount = 10
+const LifetimePreauthSession = 5 * time.Minute
+const MaxPreauthAttempts = 5 // wrong second factors and restarted enrollments before the login has to start over
+const LifetimeWebauthnCeremony = 5 * time.Minute
+const LifetimeReauthentication = 10 * time.Minute // how long users without a password count as re-authenticated after logging in
+const LifetimePasswordResetToken = 1 * time.Hour
+const LifetimeEmailVerificationToken = 48 * time.Hour
+
+const OidcLoginScope = "openid profile email"
+
+const LdapTimeout = 10 * time.Second
+
+const MailTimeout = 30 * time.Second
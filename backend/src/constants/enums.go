@@ -1,10 +1,15 @@
 package constants
 
 const (
-	SourceUnknown = "unknown"
-	SourceCaldav  = "caldav"
-	SourceIcal    = "ical"
-	SourceGoogle  = "google"
+	SourceUnknown   = "unknown"
+	SourceCaldav    = "caldav"
+	SourceCarddav   = "carddav"
+	SourceIcal      = "ical"
+	SourceGoogle    = "google"
+	SourceMicrosoft = "microsoft"
+	SourceHolidays  = "holidays"
+	SourceJmap      = "jmap"
+	SourceLuna      = "luna"
 )
 
 const (
@@ -26,3 +31,67 @@ const (
 	HashArgon2         = "argon2"
 	HashArgon2Peppered = "argon2pepper"
 )
+
+const (
+	SyncMethodCollection = "sync-collection"
+	SyncMethodCtag       = "ctag"
+	SyncMethodSyncToken  = "sync-token"
+)
+
+const (
+	SyncStatusOk     = "ok"
+	SyncStatusFailed = "failed"
+)
+
+const (
+	ReminderActionDisplay = "display"
+	ReminderActionEmail   = "email"
+)
+
+const (
+	ReminderRelatedStart = "start"
+	ReminderRelatedEnd   = "end"
+)
+
+const (
+	AttendeeRoleChair          = "chair"
+	AttendeeRoleRequired       = "required"
+	AttendeeRoleOptional       = "optional"
+	AttendeeRoleNonParticipant = "non-participant"
+)
+
+const (
+	ParticipationNeedsAction = "needs-action"
+	ParticipationAccepted    = "accepted"
+	ParticipationDeclined    = "declined"
+	ParticipationTentative   = "tentative"
+	ParticipationDelegated   = "delegated"
+)
+
+const (
+	TaskStatusNeedsAction = "needs-action"
+	TaskStatusInProcess   = "in-process"
+	TaskStatusCompleted   = "completed"
+	TaskStatusCancelled   = "cancelled"
+)
+
+const (
+	ChangeResourceSource   = "source"
+	ChangeResourceCalendar = "calendar"
+	ChangeResourceEvent    = "event"
+	ChangeResourceSettings = "settings"
+	ChangeResourceSession  = "session"
+)
+
+const (
+	ChangeActionCreated = "created"
+	ChangeActionUpdated = "updated"
+	ChangeActionDeleted = "deleted"
+	ChangeActionRevoked = "revoked"
+)
+
+const (
+	WebauthnCeremonyRegistration = "registration"
+	WebauthnCeremonyLogin        = "login"
+	WebauthnCeremonySecondFactor = "mfa"
+)
@@ -43,7 +43,12 @@ func init() {
 			CREATE TYPE SOURCE_TYPE_ENUM AS ENUM (
 				'caldav',
 				'ical',
-				'google'
+				'google',
+				'luna',
+				'microsoft',
+				'jmap',
+				'carddav',
+				'holidays'
 			);
 			`,
 		)
@@ -119,6 +124,13 @@ func init() {
 				Append(errors.LvlDebug, "Could not initialize sources table")
 		}
 
+		err = q.Tables.InitializeSourceHealthTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize source health table")
+		}
+
 		err = q.Tables.InitializeCalendarsTable()
 		if err != nil {
 			return errors.New().
@@ -147,6 +159,27 @@ func init() {
 				Append(errors.LvlDebug, "Could not initialize event overrides table")
 		}
 
+		err = q.Tables.InitializeDavObjectsTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize dav objects table")
+		}
+
+		err = q.Tables.InitializeLunaCalendarsTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize luna calendars table")
+		}
+
+		err = q.Tables.InitializeLunaEventsTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize luna events table")
+		}
+
 		err = q.Tables.InitializeFilecacheTable()
 		if err != nil {
 			return errors.New().
@@ -180,6 +213,41 @@ func init() {
 				Append(errors.LvlDebug, "Could not initialize sessions table")
 		}
 
+		err = q.Tables.InitializeTotpTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize totp table")
+		}
+
+		err = q.Tables.InitializeRecoveryCodesTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize recovery codes table")
+		}
+
+		err = q.Tables.InitializePreauthSessionsTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize pre-authentication sessions table")
+		}
+
+		err = q.Tables.InitializeWebauthnCredentialsTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize webauthn credentials table")
+		}
+
+		err = q.Tables.InitializeWebauthnCeremoniesTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize webauthn ceremonies table")
+		}
+
 		err = q.Tables.InitializeInvitesTable()
 		if err != nil {
 			return errors.New().
@@ -187,6 +255,13 @@ func init() {
 				Append(errors.LvlDebug, "Could not initialize invites table")
 		}
 
+		err = q.Tables.InitializeCalendarFeedsTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize calendar feeds table")
+		}
+
 		err = q.Tables.InitializeTokenPermissionsTable()
 		if err != nil {
 			return errors.New().
@@ -215,6 +290,48 @@ func init() {
 				Append(errors.LvlDebug, "Could not initialize oauth tokens table")
 		}
 
+		err = q.Tables.InitializeOauthLoginRequestsTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize oauth login requests table")
+		}
+
+		err = q.Tables.InitializeOauthIdentitiesTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize oauth identities table")
+		}
+
+		err = q.Tables.InitializeLdapUsersTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize ldap users table")
+		}
+
+		err = q.Tables.InitializePasswordResetTokensTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize password reset tokens table")
+		}
+
+		err = q.Tables.InitializeEmailVerificationTokensTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize email verification tokens table")
+		}
+
+		err = q.Tables.InitializeReminderDeliveriesTable()
+		if err != nil {
+			return errors.New().
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not initialize reminder deliveries table")
+		}
+
 		return nil
 	})
 }
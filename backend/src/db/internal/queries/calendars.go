@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"luna-backend/config"
+	"luna-backend/constants"
 
 	"luna-backend/db/internal/parsing"
 	"luna-backend/db/internal/util"
@@ -12,6 +13,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -357,6 +359,7 @@ func (q *Queries) UpdateCalendarDisplayOrder(userId types.ID, calendarId types.I
 
 	switch err {
 	case nil:
+		q.NotifyChange(userId, types.NewCalendarChange(constants.ChangeActionUpdated, calendarId, types.EmptyId()))
 		return nil
 	case pgx.ErrNoRows:
 		return errors.New().Status(http.StatusNotFound).
@@ -428,9 +431,40 @@ func (q *Queries) DeleteCalendar(userId types.ID, calendarId types.ID) *errors.E
 			AltStr(errors.LvlBroad, "Could not delete calendar")
 	}
 
+	q.NotifyChange(userId, types.NewCalendarChange(constants.ChangeActionDeleted, calendarId, deletedCalendarSourceId))
+
 	return nil
 }
 
+func (q *Queries) GetCalendarOwner(calendarId types.ID) (types.ID, *errors.ErrorTrace) {
+	var userId uuid.UUID
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT sources.userid
+		FROM calendars
+		JOIN sources ON calendars.source = sources.id
+		WHERE calendars.id = $1;
+		`,
+		calendarId.UUID(),
+	).Scan(&userId)
+
+	switch err {
+	case nil:
+		return types.IdFromUuid(userId), nil
+	case pgx.ErrNoRows:
+		return types.EmptyId(), errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Calendar %v not found", calendarId).
+			AltStr(errors.LvlPlain, "Calendar not found").
+			AltStr(errors.LvlBroad, "Could not get calendar owner")
+	default:
+		return types.EmptyId(), errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get calendar %v", calendarId).
+			AltStr(errors.LvlBroad, "Could not get calendar owner")
+	}
+}
+
 func (q *Queries) SetCalendarOverrides(calendarId types.ID, name string, desc string, color *types.Color) *errors.ErrorTrace {
 	columns := []string{}
 	params := []any{calendarId.UUID()}
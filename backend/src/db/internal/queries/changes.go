@@ -0,0 +1,54 @@
+package queries
+
+import (
+	"luna-backend/types"
+)
+
+type pendingChange struct {
+	userId types.ID
+	change *types.Change
+}
+
+// Changes are only published once the transaction is committed.
+// Repeated changes of the same resource are merged, keeping the latest action.
+func (q *Queries) NotifyChange(userId types.ID, change *types.Change) {
+	q.changesMutex.Lock()
+	defer q.changesMutex.Unlock()
+
+	for _, pending := range q.pendingChanges {
+		if pending.userId == userId && pending.change.Resource == change.Resource && pending.change.Id == change.Id && pending.change.Key == change.Key {
+			pending.change.Action = change.Action
+			return
+		}
+	}
+
+	q.pendingChanges = append(q.pendingChanges, pendingChange{userId: userId, change: change})
+}
+
+func (q *Queries) PublishChanges() {
+	q.changesMutex.Lock()
+	defer q.changesMutex.Unlock()
+
+	if q.CommonConfig.Changes != nil {
+		byUser := map[types.ID][]*types.Change{}
+		order := []types.ID{}
+		for _, pending := range q.pendingChanges {
+			if _, exists := byUser[pending.userId]; !exists {
+				order = append(order, pending.userId)
+			}
+			byUser[pending.userId] = append(byUser[pending.userId], pending.change)
+		}
+		for _, userId := range order {
+			q.CommonConfig.Changes.Publish(userId, byUser[userId])
+		}
+	}
+
+	q.pendingChanges = nil
+}
+
+func (q *Queries) DiscardChanges() {
+	q.changesMutex.Lock()
+	defer q.changesMutex.Unlock()
+
+	q.pendingChanges = nil
+}
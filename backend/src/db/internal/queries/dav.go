@@ -0,0 +1,132 @@
+package queries
+
+import (
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Returns the objects of all events in the calendar that a CalDAV client created, keyed by event ID
+func (q *Queries) GetDavObjects(calendarId types.ID) (map[types.ID]*types.DavObject, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT event_id, file_name, uid
+		FROM dav_objects
+		WHERE calendar = $1;
+		`,
+		calendarId.UUID(),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get dav objects of calendar %v", calendarId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+	defer rows.Close()
+
+	objects := map[types.ID]*types.DavObject{}
+	for rows.Next() {
+		obj := &types.DavObject{}
+		err = rows.Scan(&obj.EventId, &obj.FileName, &obj.Uid)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan dav object").
+				AltStr(errors.LvlWordy, "Database error")
+		}
+		objects[obj.EventId] = obj
+	}
+
+	return objects, nil
+}
+
+func (q *Queries) getDavObject(query string, calendarId types.ID, key string) (*types.DavObject, *errors.ErrorTrace) {
+	obj := &types.DavObject{}
+
+	err := q.Tx.QueryRow(q.Context, query, calendarId.UUID(), key).Scan(&obj.EventId, &obj.FileName, &obj.Uid)
+
+	switch err {
+	case nil:
+		return obj, nil
+	case pgx.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get dav object %v of calendar %v", key, calendarId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+}
+
+// Returns nil if no client created an object with this name
+func (q *Queries) GetDavObjectByFileName(calendarId types.ID, fileName string) (*types.DavObject, *errors.ErrorTrace) {
+	return q.getDavObject(
+		`
+		SELECT event_id, file_name, uid
+		FROM dav_objects
+		WHERE calendar = $1
+		AND file_name = $2;
+		`,
+		calendarId,
+		fileName,
+	)
+}
+
+// RFC 4791 5.3.2.1: a UID may only be used once per calendar. Events that no client
+// created are served with their own ID as UID, unless their source gave them one.
+func (q *Queries) IsUidUsedInCalendar(calendarId types.ID, uid string) (bool, *errors.ErrorTrace) {
+	var used bool
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT EXISTS (
+			SELECT 1
+			FROM events
+			WHERE calendar = $1
+			AND (uid = $2 OR id::TEXT = $2)
+		) OR EXISTS (
+			SELECT 1
+			FROM dav_objects
+			WHERE calendar = $1
+			AND uid = $2
+		);
+		`,
+		calendarId.UUID(),
+		uid,
+	).Scan(&used)
+
+	if err != nil {
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not check if uid %v is used in calendar %v", uid, calendarId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	return used, nil
+}
+
+func (q *Queries) InsertDavObject(calendarId types.ID, obj *types.DavObject) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO dav_objects (event_id, calendar, file_name, uid)
+		VALUES ($1, $2, $3, $4);
+		`,
+		obj.EventId.UUID(),
+		calendarId.UUID(),
+		obj.FileName,
+		obj.Uid,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not insert dav object %v for event %v", obj.FileName, obj.EventId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"luna-backend/config"
+	"luna-backend/constants"
 	"luna-backend/db/internal/parsing"
 	"luna-backend/db/internal/util"
 	"luna-backend/errors"
@@ -22,6 +23,7 @@ func (q *Queries) insertEvents(events []types.Event) *errors.ErrorTrace {
 			event.GetId(),
 			event.GetCalendar().GetId(),
 			event.GetSettings().Bytes(),
+			event.GetUid(),
 		}
 
 		rows = append(rows, row)
@@ -32,8 +34,8 @@ func (q *Queries) insertEvents(events []types.Event) *errors.ErrorTrace {
 		q.Context,
 		"events",
 		"id",
-		[]string{"id", "calendar", "settings"},
-		[]string{"settings"},
+		[]string{"id", "calendar", "settings", "uid"},
+		[]string{"settings", "uid"},
 		rows,
 		false,
 		"",
@@ -211,12 +213,13 @@ func (q *Queries) InsertEvent(event types.Event) *errors.ErrorTrace {
 	_, err := q.Tx.Exec(
 		q.Context,
 		`
-		INSERT INTO events (id, calendar, settings)
-		VALUES ($1, $2, $3);
+		INSERT INTO events (id, calendar, settings, uid)
+		VALUES ($1, $2, $3, $4);
 		`,
 		event.GetId().UUID(),
 		event.GetCalendar().GetId().UUID(),
 		event.GetSettings().Bytes(),
+		event.GetUid(),
 	)
 
 	if err != nil {
@@ -234,11 +237,12 @@ func (q *Queries) UpdateEvent(event types.Event) *errors.ErrorTrace {
 		q.Context,
 		`
 		UPDATE events
-		SET settings = $2
+		SET settings = $2, uid = $3
 		WHERE id = $1;
 		`,
 		event.GetId().UUID(),
 		event.GetSettings().Bytes(),
+		event.GetUid(),
 	)
 
 	switch err {
@@ -278,6 +282,7 @@ func (q *Queries) DeleteEvent(userId types.ID, eventId types.ID) *errors.ErrorTr
 
 	switch err {
 	case nil:
+		q.NotifyChange(userId, types.NewEventChange(constants.ChangeActionDeleted, eventId, types.EmptyId()))
 		return nil
 	case pgx.ErrNoRows:
 		return errors.New().Status(http.StatusNotFound).
@@ -293,6 +298,90 @@ func (q *Queries) DeleteEvent(userId types.ID, eventId types.ID) *errors.ErrorTr
 	}
 }
 
+// Finds the events of a user that belong to the given iCalendar UID, including known instances of recurring events
+func (q *Queries) GetEventIdsByUid(userId types.ID, uid string) ([]types.ID, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT events.id
+		FROM events
+		JOIN calendars ON events.calendar = calendars.id
+		JOIN sources ON calendars.source = sources.id
+		WHERE events.uid = $1
+		AND sources.userid = $2;
+		`,
+		uid,
+		userId.UUID(),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get events with uid %v", uid).
+			Append(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	ids := []types.ID{}
+	for rows.Next() {
+		var id types.ID
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan event id").
+				Append(errors.LvlWordy, "Could not get events with uid %v", uid).
+				Append(errors.LvlPlain, "Database error")
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// Increments the scheduling sequence number of an event and returns the new value.
+// The sequence is shared by all events with the same uid, so that instances and the series stay in order.
+// Events cached before their uid was known are matched by their id.
+func (q *Queries) IncrementEventSequence(userId types.ID, eventId types.ID, uid string) (int, *errors.ErrorTrace) {
+	var sequence int
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		WITH user_events AS (
+			SELECT events.id, events.sequence
+			FROM events
+			JOIN calendars ON events.calendar = calendars.id
+			JOIN sources ON calendars.source = sources.id
+			WHERE (events.uid = $1 OR events.id = $3)
+			AND sources.userid = $2
+		)
+		UPDATE events
+		SET uid = $1, sequence = (SELECT MAX(sequence) + 1 FROM user_events)
+		WHERE id IN (SELECT id FROM user_events)
+		RETURNING sequence;
+		`,
+		uid,
+		userId.UUID(),
+		eventId.UUID(),
+	).Scan(&sequence)
+
+	switch err {
+	case nil:
+		return sequence, nil
+	case pgx.ErrNoRows:
+		return 0, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Event %v with uid %v for user %v not found", eventId, uid, userId).
+			AltStr(errors.LvlPlain, "Event not found").
+			Append(errors.LvlWordy, "Could not increment event sequence")
+	default:
+		return 0, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not increment event sequence").
+			Append(errors.LvlPlain, "Database error")
+	}
+}
+
 func (q *Queries) SetEventOverrides(eventId types.ID, name string, desc string, color *types.Color) *errors.ErrorTrace {
 	columns := []string{}
 	params := []any{eventId.UUID()}
@@ -0,0 +1,159 @@
+package queries
+
+import (
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *Queries) InsertCalendarFeed(feed *types.CalendarFeed) *errors.ErrorTrace {
+	// Feed object does not have an ID or timestamp yet
+	// These are generated by the database and updated in the feed object
+
+	query := `
+		INSERT INTO calendar_feeds (userid, calendar, name, days_past, days_future, hash)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING feedid, created_at;
+	`
+
+	err := q.Tx.
+		QueryRow(
+			q.Context,
+			query,
+			feed.UserId.UUID(),
+			feed.CalendarId.UUID(),
+			feed.Name,
+			feed.DaysPast,
+			feed.DaysFuture,
+			feed.SecretHash,
+		).Scan(&feed.FeedId, &feed.CreatedAt)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not insert calendar feed").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) UpdateCalendarFeedHash(feedId types.ID, hash []byte) *errors.ErrorTrace {
+	query := `
+		UPDATE calendar_feeds
+		SET hash = $1
+		WHERE feedid = $2;
+	`
+
+	_, err := q.Tx.Exec(q.Context, query, hash, feedId.UUID())
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not update calendar feed hash").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
+
+// Unlike other getters, this one does not require the user ID, since feeds are accessed without logging in.
+// The caller is responsible for verifying the secret.
+func (q *Queries) GetCalendarFeed(feedId types.ID) (*types.CalendarFeed, *errors.ErrorTrace) {
+	query := `
+		SELECT *
+		FROM calendar_feeds
+		WHERE feedid = $1;
+	`
+
+	rows, err := q.Tx.Query(q.Context, query, feedId.UUID())
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get calendar feed").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	feed, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[types.CalendarFeed])
+	switch err {
+	case nil:
+		break
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlPlain, "Feed not found")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not scan calendar feed").
+			Append(errors.LvlWordy, "Could not get calendar feed").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return &feed, nil
+}
+
+func (q *Queries) UpdateCalendarFeedLastUsed(feedId types.ID) *errors.ErrorTrace {
+	query := `
+		UPDATE calendar_feeds
+		SET last_used = NOW()
+		WHERE feedid = $1;
+	`
+
+	_, err := q.Tx.Exec(q.Context, query, feedId.UUID())
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not update calendar feed").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
+
+func (q *Queries) GetCalendarFeeds(userId types.ID) ([]types.CalendarFeed, *errors.ErrorTrace) {
+	query := `
+		SELECT *
+		FROM calendar_feeds
+		WHERE userid = $1
+		ORDER BY created_at DESC;
+	`
+
+	rows, err := q.Tx.Query(q.Context, query, userId.UUID())
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get calendar feeds").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	feeds, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.CalendarFeed])
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not scan calendar feeds").
+			Append(errors.LvlWordy, "Could not get calendar feeds").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return feeds, nil
+}
+
+func (q *Queries) DeleteCalendarFeed(userId types.ID, feedId types.ID) (bool, *errors.ErrorTrace) {
+	query := `
+		DELETE FROM calendar_feeds
+		WHERE userid = $1 AND feedid = $2;
+	`
+
+	tag, err := q.Tx.Exec(q.Context, query, userId.UUID(), feedId.UUID())
+	if err != nil {
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not delete calendar feed").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return tag.RowsAffected() > 0, nil
+}
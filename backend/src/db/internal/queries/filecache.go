@@ -118,17 +118,91 @@ func (q *Queries) UpdateFileCache(file types.File, content io.Reader) *errors.Er
 		q.Context,
 		`
 		UPDATE filecache
-		SET file = $1
+		SET file = $1, date = NOW()
 		WHERE id = $2;
 		`,
 		buf,
 		file.GetId().UUID(),
 	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not save file cache").
+			Append(errors.LvlPlain, "Database error")
+	}
 
-	return errors.New().Status(http.StatusInternalServerError).
-		AddErr(errors.LvlDebug, err).
-		Append(errors.LvlWordy, "Could not save file cache").
-		Append(errors.LvlPlain, "Database error")
+	return nil
+}
+
+// Returns nil if the file is not cached or the server did not send any validators
+func (q *Queries) GetFilecacheValidators(file types.File) (*types.FileValidators, *errors.ErrorTrace) {
+	var etag, lastModified *string
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT etag, last_modified
+		FROM filecache
+		WHERE id = $1;
+		`,
+		file.GetId().UUID(),
+	).Scan(&etag, &lastModified)
+
+	switch err {
+	case nil:
+		break
+	case pgx.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not get file cache validators").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	if etag == nil && lastModified == nil {
+		return nil, nil
+	}
+
+	validators := &types.FileValidators{}
+	if etag != nil {
+		validators.ETag = *etag
+	}
+	if lastModified != nil {
+		validators.LastModified = *lastModified
+	}
+	return validators, nil
+}
+
+// Marks the cached file as up to date, either after downloading it or after the server confirmed that it did not change
+func (q *Queries) TouchFilecache(file types.File, validators *types.FileValidators) *errors.ErrorTrace {
+	var etag, lastModified *string
+	if validators != nil && validators.ETag != "" {
+		etag = &validators.ETag
+	}
+	if validators != nil && validators.LastModified != "" {
+		lastModified = &validators.LastModified
+	}
+
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		UPDATE filecache
+		SET date = NOW(), etag = $2, last_modified = $3
+		WHERE id = $1;
+		`,
+		file.GetId().UUID(),
+		etag,
+		lastModified,
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not update file cache").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
 }
 
 func (q *Queries) DeleteFilecache(file types.File, user types.ID) *errors.ErrorTrace {
@@ -0,0 +1,82 @@
+package queries
+
+import (
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Returns an empty ID if no user was provisioned from the entry
+func (q *Queries) GetUserIdFromLdapDn(dn string) (types.ID, *errors.ErrorTrace) {
+	var userId types.ID
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT user_id
+		FROM ldap_users
+		WHERE LOWER(dn) = LOWER($1);
+		`,
+		dn,
+	).Scan(&userId)
+
+	switch err {
+	case nil:
+		return userId, nil
+	case pgx.ErrNoRows:
+		return types.EmptyId(), nil
+	default:
+		return types.EmptyId(), errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get user provisioned from LDAP entry %v", dn).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+}
+
+func (q *Queries) IsLdapUser(userId types.ID) (bool, *errors.ErrorTrace) {
+	var exists bool
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT EXISTS (
+			SELECT 1
+			FROM ldap_users
+			WHERE user_id = $1
+		);
+		`,
+		userId.UUID(),
+	).Scan(&exists)
+
+	if err != nil {
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not check if user %v was provisioned from LDAP", userId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	return exists, nil
+}
+
+func (q *Queries) InsertLdapUser(userId types.ID, dn string) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO ldap_users (user_id, dn)
+		VALUES ($1, $2);
+		`,
+		userId.UUID(),
+		dn,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not link user %v to LDAP entry %v", userId, dn).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	return nil
+}
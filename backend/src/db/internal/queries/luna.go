@@ -0,0 +1,482 @@
+package queries
+
+import (
+	"encoding/json"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const lunaEventColumns = `
+	id, calendar, COALESCE(master, '00000000-0000-0000-0000-000000000000'::UUID), COALESCE(recurrence_id, ''), recurrence_start,
+	name, description, color,
+	date_start, date_end, specify_duration, all_day, timezone,
+	COALESCE(recurrence, ''), recurrence_exceptions, recurrence_additional,
+	reminders, location, participants,
+	ARRAY(SELECT modified.recurrence_start FROM luna_events AS modified WHERE modified.master = luna_events.id)
+`
+
+func lunaColorToBytes(color *types.Color) []byte {
+	if color.IsEmpty() {
+		return nil
+	}
+	return color.Bytes()
+}
+
+func lunaColorFromBytes(bytes []byte) *types.Color {
+	color := types.ColorFromBytes(bytes)
+	if color.IsEmpty() {
+		return nil
+	}
+	return color
+}
+
+// The timezone is needed to expand recurrence rules in the correct location.
+// Not all event dates carry a timezone name that can be loaded again, so we fall back to UTC.
+func lunaTimezoneName(date *types.EventDate) string {
+	candidates := []string{date.Timezone(), date.Start().Location().String()}
+	for _, candidate := range candidates {
+		if candidate == "" || candidate == "Local" {
+			continue
+		}
+		if _, err := time.LoadLocation(candidate); err == nil {
+			return candidate
+		}
+	}
+	return "UTC"
+}
+
+func scanLunaEvent(row types.PgxScannable) (*types.LunaEventDatabaseEntry, error) {
+	entry := &types.LunaEventDatabaseEntry{}
+
+	var color []byte
+	var start, end time.Time
+	var specifyDuration, allDay bool
+	var timezone, rule string
+	var exceptions, additional, modified []time.Time
+	var reminders, eventLocation, participants []byte
+
+	err := row.Scan(
+		&entry.Id, &entry.Calendar, &entry.Master, &entry.RecurrenceId, &entry.RecurrenceStart,
+		&entry.Name, &entry.Description, &color,
+		&start, &end, &specifyDuration, &allDay, &timezone,
+		&rule, &exceptions, &additional,
+		&reminders, &eventLocation, &participants,
+		&modified,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Color = lunaColorFromBytes(color)
+
+	err = json.Unmarshal(reminders, &entry.Reminders)
+	if err != nil {
+		return nil, err
+	}
+
+	if eventLocation != nil {
+		err = json.Unmarshal(eventLocation, &entry.Location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if participants != nil {
+		err = json.Unmarshal(participants, &entry.Participants)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	if allDay {
+		start = start.UTC()
+		end = end.UTC()
+	} else {
+		start = start.In(location)
+		end = end.In(location)
+	}
+
+	recurrence := types.EmptyEventRecurrence()
+	if rule != "" {
+		recurrence, err = types.EventRecurrenceFromLines([]string{rule})
+		if err != nil {
+			return nil, err
+		}
+		for _, exception := range exceptions {
+			exception = exception.In(location)
+			recurrence.AddException(&exception)
+		}
+		for _, addition := range additional {
+			addition = addition.In(location)
+			recurrence.AddAdditional(&addition)
+		}
+		for _, instance := range modified {
+			instance = instance.In(location)
+			recurrence.AddModifiedInstance(&instance)
+		}
+	}
+
+	if specifyDuration {
+		duration := end.Sub(start)
+		entry.Date = types.NewEventDateFromDuration(&start, &duration, allDay, recurrence)
+	} else {
+		entry.Date = types.NewEventDateFromEndTime(&start, &end, allDay, recurrence)
+	}
+	entry.Date.SetTimezone(location)
+
+	return entry, nil
+}
+
+func (q *Queries) GetLunaCalendars(sourceId types.ID) ([]*types.LunaCalendarDatabaseEntry, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT id, source, name, description, color
+		FROM luna_calendars
+		WHERE source = $1;
+		`,
+		sourceId.UUID(),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get calendars").
+			Append(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	entries := []*types.LunaCalendarDatabaseEntry{}
+	for rows.Next() {
+		entry := &types.LunaCalendarDatabaseEntry{}
+		var color []byte
+
+		err := rows.Scan(&entry.Id, &entry.Source, &entry.Name, &entry.Description, &color)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan calendar row").
+				Append(errors.LvlWordy, "Could not get calendars").
+				Append(errors.LvlPlain, "Database error")
+		}
+		entry.Color = lunaColorFromBytes(color)
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (q *Queries) GetLunaCalendar(calendarId types.ID) (*types.LunaCalendarDatabaseEntry, *errors.ErrorTrace) {
+	entry := &types.LunaCalendarDatabaseEntry{}
+	var color []byte
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT id, source, name, description, color
+		FROM luna_calendars
+		WHERE id = $1;
+		`,
+		calendarId.UUID(),
+	).Scan(&entry.Id, &entry.Source, &entry.Name, &entry.Description, &color)
+
+	switch err {
+	case nil:
+		entry.Color = lunaColorFromBytes(color)
+		return entry, nil
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Luna calendar %v not found", calendarId).
+			Append(errors.LvlPlain, "Calendar not found")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get calendar").
+			Append(errors.LvlPlain, "Database error")
+	}
+}
+
+func (q *Queries) InsertLunaCalendar(entry *types.LunaCalendarDatabaseEntry) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO luna_calendars (id, source, name, description, color)
+		VALUES ($1, $2, $3, $4, $5);
+		`,
+		entry.Id.UUID(),
+		entry.Source.UUID(),
+		entry.Name,
+		entry.Description,
+		lunaColorToBytes(entry.Color),
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not insert calendar").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
+
+func (q *Queries) UpdateLunaCalendar(entry *types.LunaCalendarDatabaseEntry) *errors.ErrorTrace {
+	tag, err := q.Tx.Exec(
+		q.Context,
+		`
+		UPDATE luna_calendars
+		SET name = $2, description = $3, color = $4
+		WHERE id = $1;
+		`,
+		entry.Id.UUID(),
+		entry.Name,
+		entry.Description,
+		lunaColorToBytes(entry.Color),
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not update calendar").
+			Append(errors.LvlPlain, "Database error")
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Luna calendar %v not found", entry.Id).
+			Append(errors.LvlPlain, "Calendar not found")
+	}
+	return nil
+}
+
+func (q *Queries) DeleteLunaCalendar(calendarId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM luna_calendars
+		WHERE id = $1;
+		`,
+		calendarId.UUID(),
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not delete calendar").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
+
+// Returns all recurring events of the calendar along with all other events that overlap with the given time frame.
+// Recurring events are expanded later on.
+func (q *Queries) GetLunaEvents(calendarId types.ID, start time.Time, end time.Time) ([]*types.LunaEventDatabaseEntry, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT `+lunaEventColumns+`
+		FROM luna_events
+		WHERE calendar = $1
+		AND (
+			(master IS NULL AND recurrence IS NOT NULL)
+			OR (date_start < $3 AND date_end >= $2)
+		);
+		`,
+		calendarId.UUID(),
+		start,
+		end,
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get events").
+			Append(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	entries := []*types.LunaEventDatabaseEntry{}
+	for rows.Next() {
+		entry, err := scanLunaEvent(rows)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan event row").
+				Append(errors.LvlWordy, "Could not get events").
+				Append(errors.LvlPlain, "Database error")
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (q *Queries) GetLunaEvent(eventId types.ID) (*types.LunaEventDatabaseEntry, *errors.ErrorTrace) {
+	row := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT `+lunaEventColumns+`
+		FROM luna_events
+		WHERE id = $1;
+		`,
+		eventId.UUID(),
+	)
+
+	entry, err := scanLunaEvent(row)
+	switch err {
+	case nil:
+		return entry, nil
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Luna event %v not found", eventId).
+			Append(errors.LvlPlain, "Event not found")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not scan event row").
+			Append(errors.LvlWordy, "Could not get event").
+			Append(errors.LvlPlain, "Database error")
+	}
+}
+
+// Inserts the event or replaces all of its values if it already exists
+func (q *Queries) SetLunaEvent(entry *types.LunaEventDatabaseEntry) *errors.ErrorTrace {
+	var master any = nil
+	if !entry.Master.IsEmpty() {
+		master = entry.Master.UUID()
+	}
+
+	var recurrenceId any = nil
+	if entry.RecurrenceId != "" {
+		recurrenceId = entry.RecurrenceId
+	}
+
+	var rule any = nil
+	exceptions := []time.Time{}
+	additional := []time.Time{}
+	recurrence := entry.Date.Recurrence()
+	if recurrence.Repeats() && recurrence.Rule() != nil {
+		rule = recurrence.Rule().RRuleString()
+		exceptions = append(exceptions, recurrence.Except()...)
+		additional = append(additional, recurrence.Additional()...)
+	}
+
+	reminders := entry.Reminders
+	if reminders == nil {
+		reminders = []*types.EventReminder{}
+	}
+	remindersJson, err := json.Marshal(reminders)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not marshal reminders").
+			Append(errors.LvlWordy, "Could not save event")
+	}
+
+	var locationJson []byte = nil
+	if !entry.Location.IsEmpty() {
+		locationJson, err = json.Marshal(entry.Location)
+		if err != nil {
+			return errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not marshal location").
+				Append(errors.LvlWordy, "Could not save event")
+		}
+	}
+
+	var participantsJson []byte = nil
+	if !entry.Participants.IsEmpty() {
+		participantsJson, err = json.Marshal(entry.Participants)
+		if err != nil {
+			return errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not marshal participants").
+				Append(errors.LvlWordy, "Could not save event")
+		}
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO luna_events (
+			id, calendar, master, recurrence_id, recurrence_start,
+			name, description, color,
+			date_start, date_end, specify_duration, all_day, timezone,
+			recurrence, recurrence_exceptions, recurrence_additional,
+			reminders, location, participants
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		ON CONFLICT (id) DO UPDATE
+		SET
+			name = EXCLUDED.name,
+			description = EXCLUDED.description,
+			color = EXCLUDED.color,
+			date_start = EXCLUDED.date_start,
+			date_end = EXCLUDED.date_end,
+			specify_duration = EXCLUDED.specify_duration,
+			all_day = EXCLUDED.all_day,
+			timezone = EXCLUDED.timezone,
+			recurrence = EXCLUDED.recurrence,
+			recurrence_exceptions = EXCLUDED.recurrence_exceptions,
+			recurrence_additional = EXCLUDED.recurrence_additional,
+			reminders = EXCLUDED.reminders,
+			location = EXCLUDED.location,
+			participants = EXCLUDED.participants;
+		`,
+		entry.Id.UUID(),
+		entry.Calendar.UUID(),
+		master,
+		recurrenceId,
+		entry.RecurrenceStart,
+		entry.Name,
+		entry.Description,
+		lunaColorToBytes(entry.Color),
+		*entry.Date.Start(),
+		*entry.Date.End(),
+		entry.Date.SpecifyDuration(),
+		entry.Date.AllDay(),
+		lunaTimezoneName(entry.Date),
+		rule,
+		exceptions,
+		additional,
+		remindersJson,
+		locationJson,
+		participantsJson,
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not save event").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
+
+func (q *Queries) DeleteLunaEvent(eventId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM luna_events
+		WHERE id = $1;
+		`,
+		eventId.UUID(),
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not delete event").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package queries
+
+import (
+	"luna-backend/constants"
+	"luna-backend/db/internal/util"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Returns nil if the user never started setting up TOTP
+func (q *Queries) GetTotp(userId types.ID) (*types.TotpEntry, *errors.ErrorTrace) {
+	decryptionKey, tr := util.GetTotpEncryptionKey(q.CommonConfig, userId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not get TOTP secret of user %v", userId)
+	}
+
+	entry := &types.TotpEntry{}
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT PGP_SYM_DECRYPT_BYTEA(secret, $2), enabled, last_step
+		FROM totp
+		WHERE userid = $1;
+		`,
+		userId.UUID(),
+		decryptionKey,
+	).Scan(&entry.Secret, &entry.Enabled, &entry.LastStep)
+
+	switch err {
+	case nil:
+		return entry, nil
+	case pgx.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get TOTP secret of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+}
+
+// Replaces a secret that was not confirmed yet, but never an enabled one
+func (q *Queries) SetPendingTotp(userId types.ID, secret []byte) *errors.ErrorTrace {
+	encryptionKey, tr := util.GetTotpEncryptionKey(q.CommonConfig, userId)
+	if tr != nil {
+		return tr.
+			Append(errors.LvlDebug, "Could not set TOTP secret of user %v", userId)
+	}
+
+	tag, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO totp (userid, secret)
+		VALUES ($1, PGP_SYM_ENCRYPT_BYTEA($2, $3))
+		ON CONFLICT (userid) DO UPDATE
+		SET secret = EXCLUDED.secret, last_step = 0, created_at = NOW()
+		WHERE totp.enabled = FALSE;
+		`,
+		userId.UUID(),
+		secret,
+		encryptionKey,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not set TOTP secret of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New().Status(http.StatusConflict).
+			Append(errors.LvlPlain, "Two-factor authentication is already enabled")
+	}
+
+	return nil
+}
+
+// Marks the time step as used, so that the same code cannot be used again.
+// Returns false if the step or a later one was already used.
+func (q *Queries) UseTotpStep(userId types.ID, step int64, enable bool) (bool, *errors.ErrorTrace) {
+	tag, err := q.Tx.Exec(
+		q.Context,
+		`
+		UPDATE totp
+		SET last_step = $2, enabled = enabled OR $3
+		WHERE userid = $1 AND last_step < $2;
+		`,
+		userId.UUID(),
+		step,
+		enable,
+	)
+
+	if err != nil {
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not update TOTP state of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (q *Queries) DeleteTotp(userId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM totp
+		WHERE userid = $1;
+		`,
+		userId.UUID(),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete TOTP secret of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) GetRecoveryCodes(userId types.ID) ([]*types.RecoveryCode, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT id, hash, salt, algorithm, parameters
+		FROM recovery_codes
+		WHERE userid = $1;
+		`,
+		userId.UUID(),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get recovery codes of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	codes := []*types.RecoveryCode{}
+	for rows.Next() {
+		var id uuid.UUID
+		entry := &types.PasswordEntry{}
+
+		err = rows.Scan(&id, &entry.Hash, &entry.Salt, &entry.Algorithm, &entry.Parameters)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan recovery code of user %v", userId).
+				AltStr(errors.LvlPlain, "Database error")
+		}
+
+		codes = append(codes, &types.RecoveryCode{Id: types.IdFromUuid(id), PasswordEntry: entry})
+	}
+
+	return codes, nil
+}
+
+// Replaces all previous recovery codes of the user
+func (q *Queries) SetRecoveryCodes(userId types.ID, entries []*types.PasswordEntry) *errors.ErrorTrace {
+	tr := q.DeleteRecoveryCodes(userId)
+	if tr != nil {
+		return tr
+	}
+
+	rows := make([][]any, len(entries))
+	for i, entry := range entries {
+		rows[i] = []any{userId.UUID(), entry.Hash, entry.Salt, entry.Algorithm, entry.Parameters}
+	}
+
+	_, err := q.Tx.CopyFrom(
+		q.Context,
+		pgx.Identifier{"recovery_codes"},
+		[]string{"userid", "hash", "salt", "algorithm", "parameters"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not insert recovery codes of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) DeleteRecoveryCode(codeId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM recovery_codes
+		WHERE id = $1;
+		`,
+		codeId.UUID(),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete recovery code %v", codeId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) DeleteRecoveryCodes(userId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM recovery_codes
+		WHERE userid = $1;
+		`,
+		userId.UUID(),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete recovery codes of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) InsertPreauthSession(session *types.PreauthSession) *errors.ErrorTrace {
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		INSERT INTO preauth_sessions (userid, is_short_lived, hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at;
+		`,
+		session.UserId.UUID(),
+		session.IsShortLived,
+		session.SecretHash,
+	).Scan(&session.Id, &session.CreatedAt)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not insert pre-authentication session").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) UpdatePreauthSessionHash(sessionId types.ID, hash []byte) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		UPDATE preauth_sessions
+		SET hash = $2
+		WHERE id = $1;
+		`,
+		sessionId.UUID(),
+		hash,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not update hash of pre-authentication session %v", sessionId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+// Expired sessions are treated as if they did not exist
+func (q *Queries) GetPreauthSession(userId types.ID, sessionId types.ID) (*types.PreauthSession, *errors.ErrorTrace) {
+	session := &types.PreauthSession{}
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT id, userid, created_at, is_short_lived, hash
+		FROM preauth_sessions
+		WHERE userid = $1 AND id = $2 AND created_at > $3 AND attempts < $4;
+		`,
+		userId.UUID(),
+		sessionId.UUID(),
+		time.Now().Add(-constants.LifetimePreauthSession),
+		constants.MaxPreauthAttempts,
+	).Scan(&session.Id, &session.UserId, &session.CreatedAt, &session.IsShortLived, &session.SecretHash)
+
+	switch err {
+	case nil:
+		return session, nil
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusUnauthorized).
+			Append(errors.LvlDebug, "Pre-authentication session %v of user %v not found", sessionId, userId).
+			Append(errors.LvlPlain, "Login expired, please log in again")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get pre-authentication session %v", sessionId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+}
+
+// Returns whether the session was deleted because it reached the maximum number of attempts
+func (q *Queries) RecordPreauthAttempt(sessionId types.ID) (bool, *errors.ErrorTrace) {
+	var attempts int
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		UPDATE preauth_sessions
+		SET attempts = attempts + 1
+		WHERE id = $1
+		RETURNING attempts;
+		`,
+		sessionId.UUID(),
+	).Scan(&attempts)
+
+	switch err {
+	case nil:
+		break
+	case pgx.ErrNoRows:
+		return true, nil
+	default:
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not record attempt for pre-authentication session %v", sessionId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	if attempts < constants.MaxPreauthAttempts {
+		return false, nil
+	}
+	return true, q.DeletePreauthSession(sessionId)
+}
+
+func (q *Queries) DeletePreauthSession(sessionId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM preauth_sessions
+		WHERE id = $1;
+		`,
+		sessionId.UUID(),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete pre-authentication session %v", sessionId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) DeleteExpiredPreauthSessions() *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM preauth_sessions
+		WHERE created_at <= $1;
+		`,
+		time.Now().Add(-constants.LifetimePreauthSession),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not delete expired pre-authentication sessions").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
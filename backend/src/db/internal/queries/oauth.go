@@ -6,6 +6,7 @@ import (
 	"luna-backend/types"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -21,18 +22,22 @@ func (q *Queries) InsertOauthClient(client *types.OauthClient) *errors.ErrorTrac
 	}
 
 	query := `
-		INSERT INTO oauth_clients (name, client_id, client_secret, base_url, scope)
-		VALUES ($1, $2, PGP_SYM_ENCRYPT($3, $6), $4, $5)
+		INSERT INTO oauth_clients (name, client_id, client_secret, base_url, scope, login_provider, auto_register, group_claim, admin_group)
+		VALUES ($1, $2, PGP_SYM_ENCRYPT($3, $6), $4, $5, $7, $8, $9, $10)
 		RETURNING id;
 	`
 
-	params := make([]any, 6)
+	params := make([]any, 10)
 	params[0] = client.Name
 	params[1] = client.ClientId
 	params[2] = client.ClientSecret
 	params[3] = client.BaseUrl
 	params[4] = client.Scope
 	params[5] = encryptionKey
+	params[6] = client.LoginProvider
+	params[7] = client.AutoRegister
+	params[8] = client.GroupClaim
+	params[9] = client.AdminGroup
 
 	err := q.Tx.
 		QueryRow(
@@ -59,7 +64,7 @@ func (q *Queries) GetOauthClientById(id types.ID) (*types.OauthClient, *errors.E
 	}
 
 	query := `
-		SELECT id, name, client_id, PGP_SYM_DECRYPT(client_secret, $2), base_url, scope
+		SELECT id, name, client_id, PGP_SYM_DECRYPT(client_secret, $2), base_url, scope, login_provider, auto_register, group_claim, admin_group
 		FROM oauth_clients
 		WHERE id = $1;
 	`
@@ -72,7 +77,7 @@ func (q *Queries) GetOauthClientById(id types.ID) (*types.OauthClient, *errors.E
 		query,
 		id.UUID(),
 		decryptionKey,
-	).Scan(&client.Id, &client.Name, &client.ClientId, &client.ClientSecret, &rawBaseUrl, &client.Scope)
+	).Scan(&client.Id, &client.Name, &client.ClientId, &client.ClientSecret, &rawBaseUrl, &client.Scope, &client.LoginProvider, &client.AutoRegister, &client.GroupClaim, &client.AdminGroup)
 
 	switch err {
 	case nil:
@@ -113,7 +118,7 @@ func (q *Queries) GetOauthClientById(id types.ID) (*types.OauthClient, *errors.E
 
 func (q *Queries) GetOauthClients() ([]*types.OauthClient, *errors.ErrorTrace) {
 	query := `
-		SELECT id, name, client_id, base_url, scope
+		SELECT id, name, client_id, base_url, scope, login_provider, auto_register, group_claim, admin_group
 		FROM oauth_clients;
 	`
 
@@ -130,7 +135,7 @@ func (q *Queries) GetOauthClients() ([]*types.OauthClient, *errors.ErrorTrace) {
 	for rows.Next() {
 		client := &types.OauthClient{}
 		var rawBaseUrl string
-		err = rows.Scan(&client.Id, &client.Name, &client.ClientId, &rawBaseUrl, &client.Scope)
+		err = rows.Scan(&client.Id, &client.Name, &client.ClientId, &rawBaseUrl, &client.Scope, &client.LoginProvider, &client.AutoRegister, &client.GroupClaim, &client.AdminGroup)
 		if err != nil {
 			return nil, errors.New().Status(http.StatusInternalServerError).
 				AddErr(errors.LvlDebug, err).
@@ -166,17 +171,17 @@ func (q *Queries) UpdateOauthClient(client *types.OauthClient) *errors.ErrorTrac
 	if client.ClientSecret == "" {
 		query = `
 			UPDATE oauth_clients
-			SET name = $1, client_id = $2, base_url = $3, scope = $4
+			SET name = $1, client_id = $2, base_url = $3, scope = $4, login_provider = $6, auto_register = $7, group_claim = $8, admin_group = $9
 			WHERE id = $5;
 		`
-		params = make([]any, 5)
+		params = make([]any, 9)
 	} else {
 		query = `
 			UPDATE oauth_clients
-			SET name = $1, client_id = $2, client_secret = PGP_SYM_ENCRYPT($6, $7), base_url = $3, scope = $4
+			SET name = $1, client_id = $2, client_secret = PGP_SYM_ENCRYPT($10, $11), base_url = $3, scope = $4, login_provider = $6, auto_register = $7, group_claim = $8, admin_group = $9
 			WHERE id = $5;
 		`
-		params = make([]any, 7)
+		params = make([]any, 11)
 	}
 
 	params[0] = client.Name
@@ -184,6 +189,10 @@ func (q *Queries) UpdateOauthClient(client *types.OauthClient) *errors.ErrorTrac
 	params[2] = client.BaseUrl
 	params[3] = client.Scope
 	params[4] = client.Id.UUID()
+	params[5] = client.LoginProvider
+	params[6] = client.AutoRegister
+	params[7] = client.GroupClaim
+	params[8] = client.AdminGroup
 	if client.ClientSecret != "" {
 		encryptionKey, tr := util.GetGlobalEncryptionKey(q.CommonConfig)
 		if tr != nil {
@@ -192,8 +201,8 @@ func (q *Queries) UpdateOauthClient(client *types.OauthClient) *errors.ErrorTrac
 				AltStr(errors.LvlWordy, "Could not update oauth client")
 		}
 
-		params[5] = client.ClientSecret
-		params[6] = encryptionKey
+		params[9] = client.ClientSecret
+		params[10] = encryptionKey
 	}
 
 	_, err := q.Tx.Exec(q.Context, query, params...)
@@ -207,6 +216,40 @@ func (q *Queries) UpdateOauthClient(client *types.OauthClient) *errors.ErrorTrac
 	return nil
 }
 
+// Only the IDs and names are returned, because anyone may see the login providers
+func (q *Queries) GetOauthLoginProviders() ([]*types.OauthClient, *errors.ErrorTrace) {
+	query := `
+		SELECT id, name
+		FROM oauth_clients
+		WHERE login_provider = TRUE
+		ORDER BY name;
+	`
+
+	rows, err := q.Tx.Query(q.Context, query)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not get login providers").
+			Append(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	clients := make([]*types.OauthClient, 0)
+	for rows.Next() {
+		client := &types.OauthClient{LoginProvider: true}
+		err = rows.Scan(&client.Id, &client.Name)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlWordy, "Could not scan login provider").
+				Append(errors.LvlPlain, "Database error")
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
 func (q *Queries) DeleteOauthClient(id types.ID) *errors.ErrorTrace {
 	query := `
 		DELETE FROM oauth_clients
@@ -546,3 +589,228 @@ func (q *Queries) DeleteOauthTokens(tokensId types.ID) *errors.ErrorTrace {
 
 	return nil
 }
+
+//
+// OpenID Connect Login Requests
+//
+
+func (q *Queries) InsertOauthLoginRequest(request *types.OauthLoginRequest) *errors.ErrorTrace {
+	query := `
+		INSERT INTO oauth_login_requests (client_id, user_id, code_verifier, nonce, is_short_lived)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING request_id, expires_at;
+	`
+
+	var owner *uuid.UUID
+	if !request.UserId.IsEmpty() {
+		ownerId := request.UserId.UUID()
+		owner = &ownerId
+	}
+
+	err := q.Tx.
+		QueryRow(
+			q.Context,
+			query,
+			request.ClientId.UUID(),
+			owner,
+			request.CodeVerifier,
+			request.Nonce,
+			request.IsShortLived,
+		).Scan(&request.Id, &request.Expires)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not insert OpenID Connect login request").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+// Login requests can only be used once, so they are deleted when taken. Requests
+// for linking an account can only be taken by the user that started them, and
+// requests for logging in only by someone who is not logged in.
+func (q *Queries) TakeOauthLoginRequest(id types.ID, userId types.ID) (*types.OauthLoginRequest, *errors.ErrorTrace) {
+	query := `
+		DELETE FROM oauth_login_requests
+		WHERE request_id = $1
+		AND expires_at > NOW()
+		RETURNING client_id, user_id, code_verifier, nonce, is_short_lived, expires_at;
+	`
+
+	request := &types.OauthLoginRequest{
+		Id: id,
+	}
+	var owner *uuid.UUID
+
+	err := q.Tx.
+		QueryRow(
+			q.Context,
+			query,
+			id.UUID(),
+		).Scan(&request.ClientId, &owner, &request.CodeVerifier, &request.Nonce, &request.IsShortLived, &request.Expires)
+
+	switch err {
+	case nil:
+		break
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "OpenID Connect login request %v not found", id).
+			AltStr(errors.LvlPlain, "Login request not found or expired")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not get OpenID Connect login request").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	request.UserId = types.EmptyId()
+	if owner != nil {
+		request.UserId = types.IdFromUuid(*owner)
+	}
+
+	if request.UserId != userId {
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "OpenID Connect login request %v belongs to user %v instead of %v", id, request.UserId, userId).
+			AltStr(errors.LvlPlain, "Login request not found or expired")
+	}
+
+	return request, nil
+}
+
+func (q *Queries) DeleteExpiredOauthLoginRequests() *errors.ErrorTrace {
+	query := `
+		DELETE FROM oauth_login_requests
+		WHERE expires_at <= NOW();
+	`
+
+	_, err := q.Tx.Exec(q.Context, query)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not delete expired OpenID Connect login requests").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+//
+// OpenID Connect Identities
+//
+
+// Returns an empty ID if no user is linked to the subject
+func (q *Queries) GetUserIdFromOauthIdentity(clientId types.ID, subject string) (types.ID, *errors.ErrorTrace) {
+	query := `
+		SELECT user_id
+		FROM oauth_identities
+		WHERE client_id = $1 AND subject = $2;
+	`
+
+	var userId types.ID
+	err := q.Tx.QueryRow(q.Context, query, clientId.UUID(), subject).Scan(&userId)
+
+	switch err {
+	case nil:
+		return userId, nil
+	case pgx.ErrNoRows:
+		return types.EmptyId(), nil
+	default:
+		return types.EmptyId(), errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get user linked to subject %v of OAuth 2.0 client %v", subject, clientId).
+			AltStr(errors.LvlWordy, "Could not get linked user").
+			Append(errors.LvlPlain, "Database error")
+	}
+}
+
+func (q *Queries) GetOauthIdentities(userId types.ID) ([]*types.OauthIdentity, *errors.ErrorTrace) {
+	query := `
+		SELECT id, client_id, subject, name, created_at
+		FROM oauth_identities
+		WHERE user_id = $1
+		ORDER BY created_at;
+	`
+
+	rows, err := q.Tx.Query(q.Context, query, userId.UUID())
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not get linked accounts").
+			Append(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	identities := make([]*types.OauthIdentity, 0)
+	for rows.Next() {
+		identity := &types.OauthIdentity{
+			UserId: userId,
+		}
+		err = rows.Scan(&identity.Id, &identity.ClientId, &identity.Subject, &identity.Name, &identity.CreatedAt)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlWordy, "Could not scan linked account").
+				Append(errors.LvlPlain, "Database error")
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+func (q *Queries) InsertOauthIdentity(identity *types.OauthIdentity) *errors.ErrorTrace {
+	query := `
+		INSERT INTO oauth_identities (client_id, user_id, subject, name)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (client_id, subject) DO NOTHING
+		RETURNING id, created_at;
+	`
+
+	err := q.Tx.
+		QueryRow(
+			q.Context,
+			query,
+			identity.ClientId.UUID(),
+			identity.UserId.UUID(),
+			identity.Subject,
+			identity.Name,
+		).Scan(&identity.Id, &identity.CreatedAt)
+
+	switch err {
+	case nil:
+		return nil
+	case pgx.ErrNoRows:
+		return errors.New().Status(http.StatusConflict).
+			Append(errors.LvlDebug, "Subject %v of OAuth 2.0 client %v is already linked", identity.Subject, identity.ClientId).
+			AltStr(errors.LvlPlain, "This account is already linked to a user")
+	default:
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not link account").
+			Append(errors.LvlPlain, "Database error")
+	}
+}
+
+func (q *Queries) DeleteOauthIdentity(id types.ID, userId types.ID) *errors.ErrorTrace {
+	query := `
+		DELETE FROM oauth_identities
+		WHERE id = $1 AND user_id = $2;
+	`
+
+	result, err := q.Tx.Exec(q.Context, query, id.UUID(), userId.UUID())
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete linked account %v", id).
+			AltStr(errors.LvlWordy, "Could not delete linked account").
+			Append(errors.LvlPlain, "Database error")
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Linked account %v of user %v not found", id, userId).
+			AltStr(errors.LvlPlain, "Linked account not found")
+	}
+
+	return nil
+}
@@ -36,6 +36,32 @@ func (q *Queries) GetPassword(userId types.ID) (*types.PasswordEntry, *errors.Er
 	}
 }
 
+// Users that signed up using a login provider do not have a password
+func (q *Queries) HasPassword(userId types.ID) (bool, *errors.ErrorTrace) {
+	var err error
+
+	var exists bool
+
+	err = q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT EXISTS (
+			SELECT 1
+			FROM passwords
+			WHERE userid = $1
+		);
+		`, userId.UUID(),
+	).Scan(&exists)
+
+	if err != nil {
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not check if user %v has a password", userId)
+	}
+
+	return exists, nil
+}
+
 func (q *Queries) InsertPassword(userId types.ID, entry *types.PasswordEntry) *errors.ErrorTrace {
 	var err error
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"luna-backend/config"
 	"luna-backend/db/internal/parsing"
+	"luna-backend/types"
+	"sync"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
@@ -15,8 +17,18 @@ type Queries struct {
 	Logger           *logrus.Entry
 	CommonConfig     *config.CommonConfig
 	PrimitivesParser *parsing.PrimitivesParser
+
+	healthMutex   sync.Mutex
+	failedSources map[types.ID]bool
+
+	changesMutex   sync.Mutex
+	pendingChanges []pendingChange
 }
 
 func (q *Queries) GetContext() context.Context {
 	return q.Context
 }
+
+func (q *Queries) GetLogger() *logrus.Entry {
+	return q.Logger
+}
@@ -0,0 +1,198 @@
+package queries
+
+import (
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Tokens are looked up by their hash and deleted in the same query, so that
+// each one can only be used once.
+
+// Replaces all earlier password reset tokens of the user
+func (q *Queries) InsertPasswordResetToken(userId types.ID, tokenHash []byte, expiresAt time.Time) *errors.ErrorTrace {
+	tr := q.DeletePasswordResetTokens(userId)
+	if tr != nil {
+		return tr
+	}
+
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3);
+		`,
+		userId.UUID(),
+		tokenHash,
+		expiresAt,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not insert password reset token for user %v", userId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) TakePasswordResetToken(tokenHash []byte) (types.ID, *errors.ErrorTrace) {
+	var userId types.ID
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		DELETE FROM password_reset_tokens
+		WHERE token_hash = $1
+		AND expires_at > NOW()
+		RETURNING user_id;
+		`,
+		tokenHash,
+	).Scan(&userId)
+
+	switch err {
+	case nil:
+		return userId, nil
+	case pgx.ErrNoRows:
+		return types.EmptyId(), errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Password reset token not found").
+			Append(errors.LvlPlain, "This link is invalid or has expired")
+	default:
+		return types.EmptyId(), errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get password reset token").
+			AltStr(errors.LvlWordy, "Database error")
+	}
+}
+
+func (q *Queries) DeletePasswordResetTokens(userId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM password_reset_tokens
+		WHERE user_id = $1;
+		`,
+		userId.UUID(),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete password reset tokens of user %v", userId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) DeleteExpiredPasswordResetTokens() *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM password_reset_tokens
+		WHERE expires_at <= NOW();
+		`,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete expired password reset tokens")
+	}
+
+	return nil
+}
+
+// Replaces all earlier verification tokens of the user
+func (q *Queries) InsertEmailVerificationToken(userId types.ID, email string, tokenHash []byte, expiresAt time.Time) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM email_verification_tokens
+		WHERE user_id = $1;
+		`,
+		userId.UUID(),
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete email verification tokens of user %v", userId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4);
+		`,
+		userId.UUID(),
+		email,
+		tokenHash,
+		expiresAt,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not insert email verification token for user %v", userId).
+			AltStr(errors.LvlWordy, "Database error")
+	}
+
+	return nil
+}
+
+// Returns the user and the email address the token was sent to
+func (q *Queries) TakeEmailVerificationToken(tokenHash []byte) (types.ID, string, *errors.ErrorTrace) {
+	var userId types.ID
+	var email string
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		DELETE FROM email_verification_tokens
+		WHERE token_hash = $1
+		AND expires_at > NOW()
+		RETURNING user_id, email;
+		`,
+		tokenHash,
+	).Scan(&userId, &email)
+
+	switch err {
+	case nil:
+		return userId, email, nil
+	case pgx.ErrNoRows:
+		return types.EmptyId(), "", errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Email verification token not found").
+			Append(errors.LvlPlain, "This link is invalid or has expired")
+	default:
+		return types.EmptyId(), "", errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get email verification token").
+			AltStr(errors.LvlWordy, "Database error")
+	}
+}
+
+func (q *Queries) DeleteExpiredEmailVerificationTokens() *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM email_verification_tokens
+		WHERE expires_at <= NOW();
+		`,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete expired email verification tokens")
+	}
+
+	return nil
+}
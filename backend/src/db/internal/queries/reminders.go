@@ -0,0 +1,53 @@
+package queries
+
+import (
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+// Records that a reminder is about to be delivered.
+// Returns false if the reminder has already been delivered before, in which case it must not be sent again.
+func (q *Queries) ClaimReminderDelivery(deliveryId types.ID, userId types.ID, eventId types.ID, triggerTime time.Time) (bool, *errors.ErrorTrace) {
+	tag, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO reminder_deliveries (id, userid, event, trigger_time)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING;
+		`,
+		deliveryId.UUID(),
+		userId.UUID(),
+		eventId.UUID(),
+		triggerTime,
+	)
+	if err != nil {
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not record reminder delivery").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (q *Queries) DeleteStaleReminderDeliveries(deleteBefore time.Time) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM reminder_deliveries
+		WHERE delivered_at < $1;
+		`,
+		deleteBefore,
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not delete stale reminder deliveries").
+			Append(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
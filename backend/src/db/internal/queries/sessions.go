@@ -1,12 +1,14 @@
 package queries
 
 import (
+	"luna-backend/constants"
 	"luna-backend/errors"
 	"luna-backend/types"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -40,6 +42,8 @@ func (q *Queries) InsertSession(session *types.Session) *errors.ErrorTrace {
 			Append(errors.LvlPlain, "Database error")
 	}
 
+	q.NotifyChange(session.UserId, types.NewSessionChange(constants.ChangeActionCreated, session.SessionId))
+
 	return nil
 }
 
@@ -71,6 +75,7 @@ func (q *Queries) UpdateSession(session *types.Session) *errors.ErrorTrace {
 	_, err := q.Tx.Exec(q.Context, query, session.UserAgent, session.SessionId)
 	switch err {
 	case nil:
+		q.NotifyChange(session.UserId, types.NewSessionChange(constants.ChangeActionUpdated, session.SessionId))
 		return nil
 	case pgx.ErrNoRows:
 		return errors.New().Status(http.StatusNotFound).
@@ -196,16 +201,20 @@ func (q *Queries) DeleteSession(userId types.ID, sessionId types.ID) *errors.Err
 			Append(errors.LvlWordy, "Could not delete session").
 			Append(errors.LvlPlain, "Database error")
 	}
+
+	q.NotifyChange(userId, types.NewSessionChange(constants.ChangeActionDeleted, sessionId))
+
 	return nil
 }
 
 func (q *Queries) DeleteSessions(userid types.ID) *errors.ErrorTrace {
 	query := `
 		DELETE FROM sessions
-		WHERE userid = $1;
+		WHERE userid = $1
+		RETURNING sessionid;
 	`
 
-	_, err := q.Tx.Exec(q.Context, query, userid)
+	rows, err := q.Tx.Query(q.Context, query, userid)
 	if err != nil {
 		return errors.New().Status(http.StatusInternalServerError).
 			AddErr(errors.LvlDebug, err).
@@ -213,17 +222,18 @@ func (q *Queries) DeleteSessions(userid types.ID) *errors.ErrorTrace {
 			Append(errors.LvlWordy, "Could not delete sessions").
 			Append(errors.LvlPlain, "Database error")
 	}
-	return nil
+	return q.notifyDeletedSessions(userid, rows)
 }
 
 func (q *Queries) DeleteUserSessions(userid types.ID) *errors.ErrorTrace {
 	query := `
 		DELETE FROM sessions
 		WHERE userid = $1
-		AND is_api = false;
+		AND is_api = false
+		RETURNING sessionid;
 	`
 
-	_, err := q.Tx.Exec(q.Context, query, userid)
+	rows, err := q.Tx.Query(q.Context, query, userid)
 	if err != nil {
 		return errors.New().Status(http.StatusInternalServerError).
 			AddErr(errors.LvlDebug, err).
@@ -231,17 +241,18 @@ func (q *Queries) DeleteUserSessions(userid types.ID) *errors.ErrorTrace {
 			Append(errors.LvlWordy, "Could not delete sessions").
 			Append(errors.LvlPlain, "Database error")
 	}
-	return nil
+	return q.notifyDeletedSessions(userid, rows)
 }
 
 func (q *Queries) DeleteApiSessions(userid types.ID) *errors.ErrorTrace {
 	query := `
 		DELETE FROM sessions
 		WHERE userid = $1
-		AND is_api = true;
+		AND is_api = true
+		RETURNING sessionid;
 	`
 
-	_, err := q.Tx.Exec(q.Context, query, userid)
+	rows, err := q.Tx.Query(q.Context, query, userid)
 	if err != nil {
 		return errors.New().Status(http.StatusInternalServerError).
 			AddErr(errors.LvlDebug, err).
@@ -249,7 +260,7 @@ func (q *Queries) DeleteApiSessions(userid types.ID) *errors.ErrorTrace {
 			Append(errors.LvlWordy, "Could not delete sessions").
 			Append(errors.LvlPlain, "Database error")
 	}
-	return nil
+	return q.notifyDeletedSessions(userid, rows)
 }
 
 func (q *Queries) DeleteExpiredSessions(deleteBefore time.Time, shortLived bool) *errors.ErrorTrace {
@@ -270,3 +281,29 @@ func (q *Queries) DeleteExpiredSessions(deleteBefore time.Time, shortLived bool)
 	}
 	return nil
 }
+
+func (q *Queries) notifyDeletedSessions(userId types.ID, rows pgx.Rows) *errors.ErrorTrace {
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionId uuid.UUID
+		err := rows.Scan(&sessionId)
+		if err != nil {
+			return errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan deleted session").
+				Append(errors.LvlWordy, "Could not delete sessions").
+				Append(errors.LvlPlain, "Database error")
+		}
+		q.NotifyChange(userId, types.NewSessionChange(constants.ChangeActionDeleted, types.IdFromUuid(sessionId)))
+	}
+
+	if rows.Err() != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, rows.Err()).
+			Append(errors.LvlWordy, "Could not delete sessions").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package queries
 import (
 	"fmt"
 	"luna-backend/config"
+	"luna-backend/constants"
 	"luna-backend/errors"
 	"luna-backend/types"
 	"net/http"
@@ -137,6 +138,26 @@ func (q *Queries) GetUserSetting(userId types.ID, key string) (config.SettingsEn
 	return setting, nil
 }
 
+// Loads a setting into the given struct, falling back to its default.
+// Users that registered before a setting was introduced do not have it stored yet.
+func (q *Queries) GetUserSettingOrDefault(userId types.ID, setting config.SettingsEntry) *errors.ErrorTrace {
+	setting.Default()
+
+	raw, tr := q.GetRawUserSetting(userId, setting.Key())
+	if tr != nil {
+		if tr.GetStatus() == http.StatusNotFound {
+			return nil
+		}
+		return tr
+	}
+
+	err := setting.UnmarshalJSON(raw)
+	if err != nil {
+		setting.Default()
+	}
+	return nil
+}
+
 func (q *Queries) UpdateUserSetting(userId types.ID, setting config.SettingsEntry) *errors.ErrorTrace {
 	_, err := q.Tx.Exec(
 		q.Context,
@@ -158,5 +179,7 @@ func (q *Queries) UpdateUserSetting(userId types.ID, setting config.SettingsEntr
 			AltStr(errors.LvlPlain, "Database error")
 	}
 
+	q.NotifyChange(userId, types.NewSettingsChange(constants.ChangeActionUpdated, setting.Key()))
+
 	return nil
 }
@@ -0,0 +1,146 @@
+package queries
+
+import (
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// The report is recorded by the source health service in its own transaction,
+// and a report that cannot be handed over is logged instead.
+// Failures take precedence within a transaction, so a request that fell back to
+// stored data after its source failed does not report the source as healthy.
+func (q *Queries) ReportSourceHealth(sourceId types.ID, tr *errors.ErrorTrace) {
+	q.healthMutex.Lock()
+	defer q.healthMutex.Unlock()
+
+	if tr == nil && q.failedSources[sourceId] {
+		return
+	}
+	if tr != nil {
+		if q.failedSources == nil {
+			q.failedSources = map[types.ID]bool{}
+		}
+		q.failedSources[sourceId] = true
+	}
+
+	if !q.CommonConfig.ReportSourceHealth(sourceId, tr) {
+		q.Logger.Warnf("dropped health report of source %v", sourceId)
+	}
+}
+
+func (q *Queries) RecordSourceSuccess(sourceId types.ID) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO source_health (sourceid, last_success, consecutive_failures)
+		VALUES ($1, NOW(), 0)
+		ON CONFLICT (sourceid) DO UPDATE
+		SET last_success = NOW(), consecutive_failures = 0, next_retry = NULL;
+		`,
+		sourceId.UUID(),
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not record success of source %v", sourceId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
+
+// The time until the next retry doubles with every failure, from one minute up to one day
+func (q *Queries) RecordSourceFailure(sourceId types.ID, trace string) *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		INSERT INTO source_health (sourceid, last_failure, last_error, consecutive_failures, next_retry)
+		VALUES ($1, NOW(), $2, 1, NOW() + INTERVAL '1 minute')
+		ON CONFLICT (sourceid) DO UPDATE
+		SET last_failure = NOW(),
+			last_error = $2,
+			consecutive_failures = source_health.consecutive_failures + 1,
+			next_retry = NOW() + LEAST(INTERVAL '1 minute' * POWER(2, LEAST(source_health.consecutive_failures, 11)), INTERVAL '1 day');
+		`,
+		sourceId.UUID(),
+		trace,
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not record failure of source %v", sourceId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	return nil
+}
+
+// Sources that were never fetched are reported as healthy
+func (q *Queries) GetSourceHealth(sourceId types.ID) (*types.SourceHealth, *errors.ErrorTrace) {
+	health := &types.SourceHealth{}
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT last_success, last_failure, last_error, consecutive_failures, next_retry
+		FROM source_health
+		WHERE sourceid = $1;
+		`,
+		sourceId.UUID(),
+	).Scan(&health.LastSuccess, &health.LastFailure, &health.LastError, &health.ConsecutiveFailures, &health.NextRetry)
+
+	switch err {
+	case nil, pgx.ErrNoRows:
+		return health, nil
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get health of source %v", sourceId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+}
+
+func (q *Queries) GetUnhealthySources() ([]*types.UnhealthySource, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT sources.id, sources.userid, sources.name, sources.type::TEXT,
+			source_health.last_success, source_health.last_failure, source_health.last_error,
+			source_health.consecutive_failures, source_health.next_retry
+		FROM source_health
+		JOIN sources ON sources.id = source_health.sourceid
+		WHERE source_health.consecutive_failures > 0
+		ORDER BY source_health.last_failure DESC;
+		`,
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get unhealthy sources").
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	sources := []*types.UnhealthySource{}
+	for rows.Next() {
+		var id, userId uuid.UUID
+		source := &types.UnhealthySource{Health: &types.SourceHealth{}}
+		health := source.Health
+
+		err = rows.Scan(&id, &userId, &source.Name, &source.Type, &health.LastSuccess, &health.LastFailure, &health.LastError, &health.ConsecutiveFailures, &health.NextRetry)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan unhealthy source").
+				AltStr(errors.LvlPlain, "Database error")
+		}
+
+		source.Id = types.IdFromUuid(id)
+		source.UserId = types.IdFromUuid(userId)
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"luna-backend/config"
+	"luna-backend/constants"
 	"luna-backend/db/internal/parsing"
 	"luna-backend/db/internal/util"
 	"luna-backend/errors"
@@ -133,13 +134,13 @@ func (q *Queries) GetSourcesByUser(userId types.ID, ctx context.Context, config
 // This is only used to refetch iCal files cache periodically.
 // The information about file URL could be stored in another table instead,
 // so we don't have to query the more sensitive sources table.
-func (q *Queries) GetSourceSettingsByType(sourceType string) ([][]byte, *errors.ErrorTrace) {
+func (q *Queries) GetSourceSettingsByType(sourceType string) (map[types.ID][]byte, *errors.ErrorTrace) {
 	var err error
 
 	rows, err := q.Tx.Query(
 		q.Context,
 		`
-		SELECT settings
+		SELECT id, settings
 		FROM sources
 		WHERE type = $1;
 		`,
@@ -161,21 +162,264 @@ func (q *Queries) GetSourceSettingsByType(sourceType string) ([][]byte, *errors.
 	}
 	defer rows.Close()
 
-	settings := [][]byte{}
+	settings := map[types.ID][]byte{}
 	for rows.Next() {
+		var id uuid.UUID
 		var setting []byte
-		err = rows.Scan(&setting)
+		err = rows.Scan(&id, &setting)
 		if err != nil {
 			return nil, errors.New().Status(http.StatusInternalServerError).
 				AddErr(errors.LvlDebug, err).
 				AltStr(errors.LvlBroad, "Could not get sources")
 		}
-		settings = append(settings, setting)
+		settings[types.IdFromUuid(id)] = setting
 	}
 
 	return settings, nil
 }
 
+// This and GetSourcesForReminders are the only places where a user's key is derived without a request by that user.
+// The opt-in of the source's owner is checked before the key is derived,
+// and every use is logged so that administrators can audit it.
+func (q *Queries) GetSourceForBackgroundRefetch(sourceId types.ID, ctx context.Context) (types.Source, *errors.ErrorTrace) {
+	var ownerId uuid.UUID
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT userid
+		FROM sources
+		WHERE id = $1 AND (settings->>'background_refetch')::BOOLEAN IS TRUE;
+		`,
+		sourceId.UUID(),
+	).Scan(&ownerId)
+
+	switch err {
+	case nil:
+		break
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Source %v does not allow background refetching", sourceId).
+			AltStr(errors.LvlPlain, "Source not found").
+			AltStr(errors.LvlBroad, "Could not get source")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get owner of source %v for background refetch", sourceId).
+			AltStr(errors.LvlBroad, "Could not get source")
+	}
+	userId := types.IdFromUuid(ownerId)
+
+	q.Logger.WithField("user", userId.String()).WithField("source", sourceId.String()).
+		Infof("decrypting credentials of source %v of user %v for background refetch", sourceId, userId)
+
+	decryptionKey, tr := util.GetUserDecryptionKey(q.CommonConfig, userId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not get source %v for background refetch", sourceId).
+			AltStr(errors.LvlBroad, "Could not get source")
+	}
+
+	scanner := parsing.NewPgxScanner(q.PrimitivesParser, q)
+	scanner.ScheduleSource()
+	cols, params := scanner.Variables(3)
+
+	query := fmt.Sprintf(
+		`
+		SELECT %s
+		FROM sources
+		WHERE id = $1 AND userid = $2;
+		`,
+		cols,
+	)
+
+	err = q.Tx.QueryRow(
+		q.Context,
+		query,
+		sourceId.UUID(),
+		userId.UUID(),
+		decryptionKey,
+	).Scan(params...)
+
+	switch err {
+	case nil:
+		break
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Source %v of user %v not found", sourceId, userId).
+			AltStr(errors.LvlPlain, "Source not found").
+			AltStr(errors.LvlBroad, "Could not get source")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get source %v for background refetch", sourceId).
+			AltStr(errors.LvlBroad, "Could not get source")
+	}
+
+	source, tr := scanner.GetSource(ctx)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not parse source %v for background refetch", sourceId).
+			AltStr(errors.LvlWordy, "Could not parse source").
+			AltStr(errors.LvlBroad, "Could not get source")
+	}
+
+	return source, nil
+}
+
+// Returns the sources of the user that opted in to reminders being collected in the background.
+// Like GetSourceForBackgroundRefetch, the opt-in is checked before the user's key is derived,
+// and every use is logged so that administrators can audit it.
+func (q *Queries) GetSourcesForReminders(userId types.ID, ctx context.Context) ([]types.Source, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT id
+		FROM sources
+		WHERE userid = $1 AND reminders;
+		`,
+		userId.UUID(),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get sources of user %v with reminders", userId).
+			AltStr(errors.LvlBroad, "Could not get sources")
+	}
+	sourceIds := []string{}
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan source of user %v with reminders", userId).
+				AltStr(errors.LvlBroad, "Could not get sources")
+		}
+		sourceIds = append(sourceIds, types.IdFromUuid(id).String())
+	}
+	rows.Close()
+
+	if len(sourceIds) == 0 {
+		return []types.Source{}, nil
+	}
+
+	q.Logger.WithField("user", userId.String()).WithField("sources", sourceIds).
+		Infof("decrypting credentials of sources %v of user %v for reminders", strings.Join(sourceIds, ", "), userId)
+
+	decryptionKey, tr := util.GetUserDecryptionKey(q.CommonConfig, userId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not get sources of user %v for reminders", userId).
+			AltStr(errors.LvlBroad, "Could not get sources")
+	}
+
+	scanner := parsing.NewPgxScanner(q.PrimitivesParser, q)
+	scanner.ScheduleSource()
+	cols, params := scanner.Variables(2)
+
+	query := fmt.Sprintf(
+		`
+		SELECT %s
+		FROM sources
+		WHERE userid = $1 AND reminders
+		ORDER BY display_order;
+		`,
+		cols,
+	)
+
+	rows, err = q.Tx.Query(
+		q.Context,
+		query,
+		userId.UUID(),
+		decryptionKey,
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get sources of user %v for reminders", userId).
+			AltStr(errors.LvlBroad, "Could not get sources")
+	}
+	defer rows.Close()
+
+	sources := []types.Source{}
+	for rows.Next() {
+		err = rows.Scan(params...)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan source of user %v for reminders", userId).
+				AltStr(errors.LvlBroad, "Could not get sources")
+		}
+		source, tr := scanner.GetSource(ctx)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlDebug, "Could not parse sources of user %v for reminders", userId).
+				AltStr(errors.LvlWordy, "Could not parse sources").
+				AltStr(errors.LvlBroad, "Could not get sources")
+		}
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
+
+func (q *Queries) GetSourceReminders(userId types.ID, sourceId types.ID) (bool, *errors.ErrorTrace) {
+	var reminders bool
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT reminders
+		FROM sources
+		WHERE id = $1 AND userid = $2;
+		`,
+		sourceId.UUID(),
+		userId.UUID(),
+	).Scan(&reminders)
+
+	switch err {
+	case nil:
+		return reminders, nil
+	case pgx.ErrNoRows:
+		return false, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Source %v for user %v not found", sourceId, userId).
+			AltStr(errors.LvlPlain, "Source not found")
+	default:
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get reminder setting of source %v", sourceId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+}
+
+func (q *Queries) SetSourceReminders(userId types.ID, sourceId types.ID, reminders bool) *errors.ErrorTrace {
+	tag, err := q.Tx.Exec(
+		q.Context,
+		`
+		UPDATE sources
+		SET reminders = $3
+		WHERE id = $1 AND userid = $2;
+		`,
+		sourceId.UUID(),
+		userId.UUID(),
+		reminders,
+	)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not set reminder setting of source %v", sourceId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Source %v for user %v not found", sourceId, userId).
+			AltStr(errors.LvlPlain, "Source not found")
+	}
+
+	q.NotifyChange(userId, types.NewSourceChange(constants.ChangeActionUpdated, sourceId))
+	return nil
+}
+
 func (q *Queries) InsertSource(userId types.ID, source types.Source) (types.ID, *errors.ErrorTrace) {
 	encryptionKey, tr := util.GetUserEncryptionKey(q.CommonConfig, userId)
 	if tr != nil {
@@ -217,6 +461,8 @@ func (q *Queries) InsertSource(userId types.ID, source types.Source) (types.ID,
 			AltStr(errors.LvlBroad, "Could not add source")
 	}
 
+	q.NotifyChange(userId, types.NewSourceChange(constants.ChangeActionCreated, types.IdFromUuid(id)))
+
 	return types.IdFromUuid(id), nil
 }
 
@@ -275,6 +521,7 @@ func (q *Queries) UpdateSource(userId types.ID, sourceId types.ID, newName strin
 
 	switch err {
 	case nil:
+		q.NotifyChange(userId, types.NewSourceChange(constants.ChangeActionUpdated, sourceId))
 		return nil
 	case pgx.ErrNoRows:
 		return errors.New().Status(http.StatusNotFound).
@@ -317,6 +564,7 @@ func (q *Queries) UpdateSourceDisplayOrder(userId types.ID, sourceId types.ID, n
 
 	switch err {
 	case nil:
+		q.NotifyChange(userId, types.NewSourceChange(constants.ChangeActionUpdated, sourceId))
 		return nil
 	case pgx.ErrNoRows:
 		return errors.New().Status(http.StatusNotFound).
@@ -382,6 +630,8 @@ func (q *Queries) DeleteSource(userId types.ID, sourceId types.ID) (bool, *error
 			AltStr(errors.LvlBroad, "Could not delete source")
 	}
 
+	q.NotifyChange(userId, types.NewSourceChange(constants.ChangeActionDeleted, sourceId))
+
 	return true, nil
 }
 
@@ -0,0 +1,259 @@
+package queries
+
+import (
+	"fmt"
+	"luna-backend/constants"
+	"luna-backend/db/internal/util"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Larger batches, such as the initial synchronization, are reported as a change of the whole calendar
+const maxEventChangesPerSync = 50
+
+func (q *Queries) GetCalendarSyncState(calendarId types.ID) (*types.CalendarSyncState, *errors.ErrorTrace) {
+	query := `
+		SELECT COALESCE(sync_token, ''), COALESCE(sync_method, ''), COALESCE(sync_status, ''), last_sync
+		FROM calendars
+		WHERE id = $1;
+	`
+
+	state := &types.CalendarSyncState{}
+	err := q.Tx.QueryRow(q.Context, query, calendarId.UUID()).Scan(&state.Token, &state.Method, &state.Status, &state.LastSync)
+	switch err {
+	case nil:
+		return state, nil
+	case pgx.ErrNoRows:
+		// The calendar has not been stored yet, so it has never been synchronized either
+		return state, nil
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get calendar sync state").
+			Append(errors.LvlPlain, "Database error")
+	}
+}
+
+// Returns the sync states of all calendars that have been synchronized at least once
+func (q *Queries) GetCalendarSyncStates(calendarIds []types.ID) (map[types.ID]*types.CalendarSyncState, *errors.ErrorTrace) {
+	states := map[types.ID]*types.CalendarSyncState{}
+	if len(calendarIds) == 0 {
+		return states, nil
+	}
+
+	query := fmt.Sprintf(
+		`
+		SELECT id, COALESCE(sync_token, ''), sync_method, COALESCE(sync_status, ''), last_sync
+		FROM calendars
+		WHERE sync_method IS NOT NULL
+		AND id IN (
+			%s
+		);
+		`,
+		util.GenerateArgList(1, len(calendarIds)),
+	)
+
+	rows, err := q.Tx.Query(
+		q.Context,
+		query,
+		util.JoinIds(calendarIds, func(id types.ID) types.ID { return id })...,
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get calendar sync states").
+			Append(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id types.ID
+		state := &types.CalendarSyncState{}
+
+		err := rows.Scan(&id, &state.Token, &state.Method, &state.Status, &state.LastSync)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan calendar sync state").
+				Append(errors.LvlWordy, "Could not get calendar sync states").
+				Append(errors.LvlPlain, "Database error")
+		}
+
+		states[id] = state
+	}
+
+	return states, nil
+}
+
+func (q *Queries) SetCalendarSyncState(calendarId types.ID, state *types.CalendarSyncState) *errors.ErrorTrace {
+	query := `
+		UPDATE calendars
+		SET sync_token = $1, sync_method = $2, sync_status = $3, last_sync = $4
+		WHERE id = $5;
+	`
+
+	_, err := q.Tx.Exec(q.Context, query, state.Token, state.Method, state.Status, state.LastSync, calendarId.UUID())
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not set calendar sync state").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) GetRemoteObjects(calendarId types.ID) ([]*types.RemoteObjectDatabaseEntry, *errors.ErrorTrace) {
+	query := `
+		SELECT id, calendar, settings, remote_href, COALESCE(remote_etag, '') AS remote_etag, COALESCE(remote_data, '') AS remote_data
+		FROM events
+		WHERE calendar = $1
+		AND remote_href IS NOT NULL;
+	`
+
+	rows, err := q.Tx.Query(q.Context, query, calendarId.UUID())
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not get remote objects").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	objects, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[types.RemoteObjectDatabaseEntry])
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not scan remote objects").
+			Append(errors.LvlWordy, "Could not get remote objects").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return objects, nil
+}
+
+func (q *Queries) SetRemoteObjects(objects []*types.RemoteObjectDatabaseEntry) *errors.ErrorTrace {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	rows := make([][]any, len(objects))
+	for i, object := range objects {
+		rows[i] = []any{
+			object.Id,
+			object.Calendar,
+			object.Settings,
+			object.Href,
+			object.Etag,
+			object.Data,
+		}
+	}
+
+	tr := util.CopyAndUpdate(
+		q.Tx,
+		q.Context,
+		"events",
+		"id",
+		[]string{"id", "calendar", "settings", "remote_href", "remote_etag", "remote_data"},
+		[]string{"settings", "remote_href", "remote_etag", "remote_data"},
+		rows,
+		false,
+		"",
+		"",
+		false,
+		"",
+		"",
+	)
+	if tr != nil {
+		return tr.
+			Append(errors.LvlWordy, "Could not set remote objects")
+	}
+
+	byCalendar := map[types.ID][]types.ID{}
+	for _, object := range objects {
+		byCalendar[object.Calendar] = append(byCalendar[object.Calendar], object.Id)
+	}
+	for calendarId, eventIds := range byCalendar {
+		tr = q.notifySyncedEvents(calendarId, eventIds, constants.ChangeActionUpdated)
+		if tr != nil {
+			return tr.
+				Append(errors.LvlWordy, "Could not set remote objects")
+		}
+	}
+
+	return nil
+}
+
+func (q *Queries) DeleteRemoteObjects(calendarId types.ID, hrefs []string) *errors.ErrorTrace {
+	if len(hrefs) == 0 {
+		return nil
+	}
+
+	query := `
+		DELETE FROM events
+		WHERE calendar = $1
+		AND remote_href = ANY($2)
+		RETURNING id;
+	`
+
+	rows, err := q.Tx.Query(q.Context, query, calendarId.UUID(), hrefs)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not execute query").
+			Append(errors.LvlWordy, "Could not delete remote objects").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	deletedIds, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not scan deleted remote objects").
+			Append(errors.LvlWordy, "Could not delete remote objects").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	eventIds := make([]types.ID, len(deletedIds))
+	for i, id := range deletedIds {
+		eventIds[i] = types.IdFromUuid(id)
+	}
+
+	tr := q.notifySyncedEvents(calendarId, eventIds, constants.ChangeActionDeleted)
+	if tr != nil {
+		return tr.
+			Append(errors.LvlWordy, "Could not delete remote objects")
+	}
+
+	return nil
+}
+
+// Changes found by synchronizing are published like any other change once the transaction is committed
+func (q *Queries) notifySyncedEvents(calendarId types.ID, eventIds []types.ID, action string) *errors.ErrorTrace {
+	if len(eventIds) == 0 {
+		return nil
+	}
+
+	userId, tr := q.GetCalendarOwner(calendarId)
+	if tr != nil {
+		return tr
+	}
+
+	if len(eventIds) > maxEventChangesPerSync {
+		q.NotifyChange(userId, types.NewCalendarChange(constants.ChangeActionUpdated, calendarId, types.EmptyId()))
+		return nil
+	}
+
+	for _, eventId := range eventIds {
+		q.NotifyChange(userId, types.NewEventChange(action, eventId, calendarId))
+	}
+
+	return nil
+}
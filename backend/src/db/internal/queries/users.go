@@ -35,6 +35,7 @@ func (q *Queries) AddUser(user *types.User) (types.ID, *errors.ErrorTrace) {
 	return id, nil
 }
 
+// Returns an empty ID if no user has the email address
 func (q *Queries) GetUserIdFromEmail(email string) (types.ID, *errors.ErrorTrace) {
 	var err error
 
@@ -50,13 +51,16 @@ func (q *Queries) GetUserIdFromEmail(email string) (types.ID, *errors.ErrorTrace
 		email,
 	).Scan(&id)
 
-	if err != nil {
+	switch err {
+	case nil:
+		return types.IdFromUuid(id), nil
+	case pgx.ErrNoRows:
+		return types.EmptyId(), nil
+	default:
 		return types.EmptyId(), errors.New().Status(http.StatusInternalServerError).
 			AddErr(errors.LvlDebug, err).
 			Append(errors.LvlDebug, "Could not get user with email %v", email)
 	}
-
-	return types.IdFromUuid(id), nil
 }
 
 func (q *Queries) GetUserIdFromUsername(username string) (types.ID, *errors.ErrorTrace) {
@@ -133,6 +137,33 @@ func (q *Queries) AnyUsersExist() (bool, *errors.ErrorTrace) {
 	return exists, nil
 }
 
+func (q *Queries) IsUsernameOrEmailTaken(username string, email string) (bool, *errors.ErrorTrace) {
+	var err error
+
+	var taken bool
+
+	err = q.Tx.QueryRow(
+		q.Context,
+		`
+		SELECT EXISTS (
+			SELECT 1
+			FROM users
+			WHERE username = $1 OR email = $2
+		);
+		`,
+		username,
+		email,
+	).Scan(&taken)
+
+	if err != nil {
+		return false, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not check if username %v or email %v are taken", username, email)
+	}
+
+	return taken, nil
+}
+
 func (q *Queries) GetUser(userId types.ID) (*types.User, *errors.ErrorTrace) {
 	var err error
 
@@ -248,7 +279,8 @@ func (q *Queries) UpdateUserData(user *types.User) *errors.ErrorTrace {
 
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, searchable = $3, profile_picture_type = $4, profile_picture_file = $5, profile_picture_url = $6
+		SET username = $1, email = $2, searchable = $3, profile_picture_type = $4, profile_picture_file = $5, profile_picture_url = $6,
+		verified = verified AND email = $2
 		WHERE id = $7;
 	`
 
@@ -347,3 +379,61 @@ func (q *Queries) SetUserEnabled(userId types.ID, enabled bool) *errors.ErrorTra
 
 	return nil
 }
+
+func (q *Queries) SetUserAdmin(userId types.ID, admin bool) *errors.ErrorTrace {
+	var err error
+
+	query := `
+		UPDATE users
+		SET admin = $1
+		WHERE id = $2;
+	`
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		query,
+		admin,
+		userId.UUID(),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not set user %v admin to %v", userId, admin)
+	}
+
+	return nil
+}
+
+// Only marks the user as verified if they still have the email address that was verified
+func (q *Queries) SetUserVerified(userId types.ID, email string) *errors.ErrorTrace {
+	var err error
+
+	query := `
+		UPDATE users
+		SET verified = TRUE
+		WHERE id = $1
+		AND email = $2;
+	`
+
+	res, err := q.Tx.Exec(
+		q.Context,
+		query,
+		userId.UUID(),
+		email,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not verify email of user %v", userId)
+	}
+
+	if res.RowsAffected() == 0 {
+		return errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlDebug, "User %v no longer has email %v", userId, email).
+			Append(errors.LvlPlain, "This link is invalid or has expired")
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package queries
+
+import (
+	"encoding/json"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *Queries) GetWebauthnCredentials(userId types.ID) ([]*types.WebauthnCredential, *errors.ErrorTrace) {
+	rows, err := q.Tx.Query(
+		q.Context,
+		`
+		SELECT id, name, credential, created_at, last_used
+		FROM webauthn_credentials
+		WHERE userid = $1
+		ORDER BY created_at;
+		`,
+		userId.UUID(),
+	)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get WebAuthn credentials of user %v", userId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	defer rows.Close()
+
+	credentials := []*types.WebauthnCredential{}
+	for rows.Next() {
+		var id uuid.UUID
+		var rawCredential []byte
+		credential := &types.WebauthnCredential{UserId: userId}
+
+		err = rows.Scan(&id, &credential.Name, &rawCredential, &credential.CreatedAt, &credential.LastUsed)
+		if err == nil {
+			err = json.Unmarshal(rawCredential, &credential.Credential)
+		}
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not scan WebAuthn credential of user %v", userId).
+				AltStr(errors.LvlPlain, "Database error")
+		}
+
+		credential.Id = types.IdFromUuid(id)
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, nil
+}
+
+func (q *Queries) InsertWebauthnCredential(credential *types.WebauthnCredential) *errors.ErrorTrace {
+	rawCredential, err := json.Marshal(credential.Credential)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not marshal WebAuthn credential")
+	}
+
+	var id uuid.UUID
+	err = q.Tx.QueryRow(
+		q.Context,
+		`
+		INSERT INTO webauthn_credentials (userid, credential_id, name, credential)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;
+		`,
+		credential.UserId.UUID(),
+		credential.Credential.ID,
+		credential.Name,
+		rawCredential,
+	).Scan(&id, &credential.CreatedAt)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not insert WebAuthn credential of user %v", credential.UserId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	credential.Id = types.IdFromUuid(id)
+	return nil
+}
+
+// Stores the signature counter and flags reported by the authenticator during a login
+func (q *Queries) UpdateWebauthnCredentialUsage(credentialId types.ID, credential *webauthn.Credential) *errors.ErrorTrace {
+	rawCredential, err := json.Marshal(credential)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not marshal WebAuthn credential")
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		UPDATE webauthn_credentials
+		SET credential = $2, last_used = NOW()
+		WHERE id = $1;
+		`,
+		credentialId.UUID(),
+		rawCredential,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not update WebAuthn credential %v", credentialId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
+
+func (q *Queries) RenameWebauthnCredential(userId types.ID, credentialId types.ID, name string) *errors.ErrorTrace {
+	tag, err := q.Tx.Exec(
+		q.Context,
+		`
+		UPDATE webauthn_credentials
+		SET name = $3
+		WHERE userid = $1 AND id = $2;
+		`,
+		userId.UUID(),
+		credentialId.UUID(),
+		name,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not rename WebAuthn credential %v", credentialId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "WebAuthn credential %v of user %v not found", credentialId, userId).
+			Append(errors.LvlPlain, "Passkey not found")
+	}
+
+	return nil
+}
+
+func (q *Queries) DeleteWebauthnCredential(userId types.ID, credentialId types.ID) *errors.ErrorTrace {
+	tag, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM webauthn_credentials
+		WHERE userid = $1 AND id = $2;
+		`,
+		userId.UUID(),
+		credentialId.UUID(),
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not delete WebAuthn credential %v", credentialId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "WebAuthn credential %v of user %v not found", credentialId, userId).
+			Append(errors.LvlPlain, "Passkey not found")
+	}
+
+	return nil
+}
+
+// The user may be empty if it is not known yet, i.e. when logging in with a passkey alone
+func (q *Queries) InsertWebauthnCeremony(userId types.ID, purpose string, data *webauthn.SessionData) (types.ID, *errors.ErrorTrace) {
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return types.EmptyId(), errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not marshal WebAuthn session data")
+	}
+
+	var owner *uuid.UUID
+	if !userId.IsEmpty() {
+		ownerId := userId.UUID()
+		owner = &ownerId
+	}
+
+	var id uuid.UUID
+	err = q.Tx.QueryRow(
+		q.Context,
+		`
+		INSERT INTO webauthn_ceremonies (userid, purpose, data, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+		`,
+		owner,
+		purpose,
+		rawData,
+		time.Now().Add(constants.LifetimeWebauthnCeremony),
+	).Scan(&id)
+
+	if err != nil {
+		return types.EmptyId(), errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not insert WebAuthn ceremony").
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	return types.IdFromUuid(id), nil
+}
+
+// Every ceremony can only be completed once, so it is deleted when it is retrieved
+func (q *Queries) TakeWebauthnCeremony(ceremonyId types.ID, userId types.ID, purpose string) (*webauthn.SessionData, *errors.ErrorTrace) {
+	var owner *uuid.UUID
+	var rawData []byte
+
+	err := q.Tx.QueryRow(
+		q.Context,
+		`
+		DELETE FROM webauthn_ceremonies
+		WHERE id = $1 AND purpose = $2 AND expires_at > NOW()
+		RETURNING userid, data;
+		`,
+		ceremonyId.UUID(),
+		purpose,
+	).Scan(&owner, &rawData)
+
+	switch err {
+	case nil:
+		break
+	case pgx.ErrNoRows:
+		return nil, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlDebug, "WebAuthn ceremony %v not found", ceremonyId).
+			Append(errors.LvlPlain, "Request expired, please try again")
+	default:
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not get WebAuthn ceremony %v", ceremonyId).
+			AltStr(errors.LvlPlain, "Database error")
+	}
+
+	// Ceremonies of one user must not be completed by another one
+	if (owner == nil) != userId.IsEmpty() || (owner != nil && types.IdFromUuid(*owner) != userId) {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlDebug, "WebAuthn ceremony %v does not belong to user %v", ceremonyId, userId).
+			Append(errors.LvlPlain, "Request expired, please try again")
+	}
+
+	data := &webauthn.SessionData{}
+	err = json.Unmarshal(rawData, data)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not unmarshal WebAuthn session data")
+	}
+
+	return data, nil
+}
+
+func (q *Queries) DeleteExpiredWebauthnCeremonies() *errors.ErrorTrace {
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		DELETE FROM webauthn_ceremonies
+		WHERE expires_at <= NOW();
+		`,
+	)
+
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not delete expired WebAuthn ceremonies").
+			Append(errors.LvlPlain, "Database error")
+	}
+
+	return nil
+}
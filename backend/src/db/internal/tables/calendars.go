@@ -7,7 +7,7 @@ import (
 func (q *Tables) InitializeCalendarsTable() error {
 	var err error
 	// Calendars table:
-	// id source settings
+	// id source settings display_order sync_token sync_method sync_status last_sync
 	_, err = q.Tx.Exec(
 		q.Context,
 		`
@@ -15,7 +15,11 @@ func (q *Tables) InitializeCalendarsTable() error {
 			id UUID PRIMARY KEY,
 			source UUID REFERENCES sources(id) ON DELETE CASCADE,
 			settings JSONB NOT NULL,
-			display_order SMALLINT NOT NULL
+			display_order SMALLINT NOT NULL,
+			sync_token TEXT,
+			sync_method TEXT,
+			sync_status TEXT,
+			last_sync TIMESTAMPTZ
 		);
 	`)
 	if err != nil {
@@ -0,0 +1,29 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializeDavObjectsTable() error {
+	// DAV objects table:
+	// event_id calendar file_name uid
+	//
+	// CalDAV clients choose the resource name and UID of the events they
+	// create and expect to find them under the same ones again.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE dav_objects (
+			event_id UUID PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+			calendar UUID NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+			file_name TEXT NOT NULL,
+			uid TEXT NOT NULL,
+			UNIQUE (calendar, file_name),
+			UNIQUE (calendar, uid)
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create dav objects table: %v", err)
+	}
+
+	return nil
+}
@@ -7,14 +7,21 @@ import (
 func (q *Tables) InitializeEventsTable() error {
 	var err error
 	// Events table:
-	// id calendar settings
+	// id calendar settings uid sequence remote_href remote_etag remote_data
+	// The uid and sequence identify the event in scheduling messages (RFC 5546)
+	// The remote columns hold a local mirror of the calendar object for protocols that support incremental synchronization
 	_, err = q.Tx.Exec(
 		q.Context,
 		`
 		CREATE TABLE events (
 			id UUID PRIMARY KEY,
 			calendar UUID REFERENCES calendars(id) ON DELETE CASCADE,
-			settings JSONB NOT NULL
+			settings JSONB NOT NULL,
+			uid TEXT,
+			sequence INT NOT NULL DEFAULT 0,
+			remote_href TEXT,
+			remote_etag TEXT,
+			remote_data TEXT
 		);
 	`)
 	if err != nil {
@@ -30,6 +37,15 @@ func (q *Tables) InitializeEventsTable() error {
 		return fmt.Errorf("could not create secondary index on events table: %v", err)
 	}
 
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_events_uid ON events (uid);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create uid index on events table: %v", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,41 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializeCalendarFeedsTable() error {
+	// Calendar feeds table:
+	// feedid userid calendar name created_at last_used days_past days_future hash
+	//
+	// Feeds expose a single calendar as a public iCal file.
+	// The secret part of the feed URL is only stored as a hash, just like for sessions.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE calendar_feeds (
+			feedid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			userid UUID REFERENCES users(id) ON DELETE CASCADE,
+			calendar UUID REFERENCES calendars(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_used TIMESTAMPTZ,
+			days_past INT NOT NULL,
+			days_future INT NOT NULL,
+			hash BYTEA NOT NULL
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create calendar feeds table: %v", err)
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_calendar_feeds_userid ON calendar_feeds (userid);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create secondary index on calendar feeds table: %v", err)
+	}
+
+	return nil
+}
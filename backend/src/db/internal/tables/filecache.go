@@ -7,7 +7,7 @@ import (
 func (q *Tables) InitializeFilecacheTable() error {
 	var err error
 	// Filecache table:
-	// id date file
+	// id date name file owner etag last_modified
 	_, err = q.Tx.Exec(
 		q.Context,
 		`
@@ -16,7 +16,9 @@ func (q *Tables) InitializeFilecacheTable() error {
 			date TIMESTAMPTZ NOT NULL,
 			name TEXT NOT NULL,
 			file BYTEA,
-			owner UUID REFERENCES users(id) ON DELETE CASCADE
+			owner UUID REFERENCES users(id) ON DELETE CASCADE,
+			etag TEXT,
+			last_modified TEXT
 		);
 	`)
 	if err != nil {
@@ -0,0 +1,26 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializeLdapUsersTable() error {
+	// LDAP users table:
+	// user_id dn created_at
+	//
+	// Users provisioned from the directory have no password of their own and
+	// are always authenticated by the LDAP server.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE ldap_users (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			dn VARCHAR(1024) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create ldap users table: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package tables
+
+import (
+	"fmt"
+)
+
+// Calendars and events hosted by Luna itself (constants.SourceLuna).
+// The ids are the same ones that are used in the calendars and events tables.
+
+func (q *Tables) InitializeLunaCalendarsTable() error {
+	var err error
+	// Luna calendars table:
+	// id source name description color
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE luna_calendars (
+			id UUID PRIMARY KEY,
+			source UUID REFERENCES sources(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL,
+			color BYTEA
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create luna calendars table: %v", err)
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_luna_calendars_source ON luna_calendars (source);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create secondary index on luna calendars table: %v", err)
+	}
+
+	return nil
+}
+
+func (q *Tables) InitializeLunaEventsTable() error {
+	var err error
+	// Luna events table:
+	// id calendar master recurrence_id recurrence_start name description color
+	// date_start date_end specify_duration all_day timezone
+	// recurrence recurrence_exceptions recurrence_additional
+	// reminders location participants
+	//
+	// Rows with a master are modified instances of a recurring event.
+	// They are identified by the recurrence id of the instance they replace.
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE luna_events (
+			id UUID PRIMARY KEY,
+			calendar UUID REFERENCES luna_calendars(id) ON DELETE CASCADE,
+			master UUID REFERENCES luna_events(id) ON DELETE CASCADE,
+			recurrence_id TEXT,
+			recurrence_start TIMESTAMPTZ,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL,
+			color BYTEA,
+			date_start TIMESTAMPTZ NOT NULL,
+			date_end TIMESTAMPTZ NOT NULL,
+			specify_duration BOOLEAN NOT NULL,
+			all_day BOOLEAN NOT NULL,
+			timezone TEXT NOT NULL,
+			recurrence TEXT,
+			recurrence_exceptions TIMESTAMPTZ[] NOT NULL DEFAULT '{}',
+			recurrence_additional TIMESTAMPTZ[] NOT NULL DEFAULT '{}',
+			reminders JSONB NOT NULL DEFAULT '[]',
+			location JSONB,
+			participants JSONB,
+			UNIQUE (master, recurrence_id)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create luna events table: %v", err)
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_luna_events_calendar ON luna_events (calendar);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create secondary index on luna events table: %v", err)
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_luna_events_master ON luna_events (master);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create secondary index on luna events table: %v", err)
+	}
+
+	return nil
+}
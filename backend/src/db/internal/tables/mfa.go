@@ -0,0 +1,93 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializeTotpTable() error {
+	// TOTP table:
+	// userid secret enabled last_step created_at
+	//
+	// The secret is encrypted with a key derived for each user. The last used
+	// time step is kept so that a code cannot be used twice. Secrets are
+	// stored disabled until the user proved that their authenticator works.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE totp (
+			userid UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret BYTEA NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			last_step BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create totp table: %v", err)
+	}
+
+	return nil
+}
+
+func (q *Tables) InitializeRecoveryCodesTable() error {
+	// Recovery codes table:
+	// id userid hash salt algorithm parameters
+	//
+	// Each code can be used once instead of a second factor. They are hashed
+	// just like passwords.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE recovery_codes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			userid UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			hash BYTEA NOT NULL,
+			salt BYTEA NOT NULL,
+			algorithm VARCHAR(32) NOT NULL,
+			parameters JSONB NOT NULL
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create recovery codes table: %v", err)
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_recovery_codes_userid ON recovery_codes (userid);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create secondary index on recovery codes table: %v", err)
+	}
+
+	return nil
+}
+
+func (q *Tables) InitializePreauthSessionsTable() error {
+	// Pre-authentication sessions table:
+	// id userid created_at is_short_lived hash
+	//
+	// A user that requires a second factor gets a pre-authentication session
+	// after entering the correct password. It can only be used to complete the
+	// login and expires after a few minutes. Like for sessions, only a hash of
+	// the secret in the pre-authentication token is stored. Attempts that do not
+	// complete the login are counted, and the session is deleted after too many.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE preauth_sessions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			userid UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			is_short_lived BOOLEAN NOT NULL DEFAULT TRUE,
+			hash BYTEA NOT NULL,
+			attempts SMALLINT NOT NULL DEFAULT 0
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create pre-authentication sessions table: %v", err)
+	}
+
+	return nil
+}
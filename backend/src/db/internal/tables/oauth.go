@@ -4,7 +4,10 @@ import "fmt"
 
 func (q *Tables) InitializeOauthClientsTable() error {
 	// Oauth tokens table:
-	// id name client_id client_secret base_url scope
+	// id name client_id client_secret base_url scope login_provider auto_register group_claim admin_group
+	//
+	// Clients marked as login providers can be used to sign in via OpenID
+	// Connect. Members of the admin group are made administrators on login.
 	_, err := q.Tx.Exec(
 		q.Context,
 		`
@@ -15,7 +18,12 @@ func (q *Tables) InitializeOauthClientsTable() error {
 			client_id VARCHAR(1024) NOT NULL,
 			client_secret BYTEA NOT NULL,
 			base_url VARCHAR(2048) NOT NULL,
-			scope VARCHAR(1024) NOT NULL
+			scope VARCHAR(1024) NOT NULL,
+
+			login_provider BOOLEAN NOT NULL DEFAULT FALSE,
+			auto_register BOOLEAN NOT NULL DEFAULT FALSE,
+			group_claim VARCHAR(255) NOT NULL DEFAULT 'groups',
+			admin_group VARCHAR(255) NOT NULL DEFAULT ''
 		);
 		`,
 	)
@@ -78,3 +86,58 @@ func (q *Tables) InitializeOauthTokensTable() error {
 
 	return nil
 }
+
+func (q *Tables) InitializeOauthLoginRequestsTable() error {
+	// Oauth login requests table:
+	// request_id client_id user_id code_verifier nonce is_short_lived expires_at
+	//
+	// Unlike authorization requests, these are started before the user is
+	// known. The user ID is only set when linking a provider account to an
+	// existing user.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE oauth_login_requests (
+			request_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			code_verifier VARCHAR(128) NOT NULL,
+			nonce VARCHAR(128) NOT NULL,
+			is_short_lived BOOLEAN NOT NULL DEFAULT TRUE,
+			expires_at TIMESTAMPTZ NOT NULL DEFAULT NOW() + INTERVAL '10 minutes'
+		)
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create oauth login requests table: %v", err)
+	}
+
+	return nil
+}
+
+func (q *Tables) InitializeOauthIdentitiesTable() error {
+	// Oauth identities table:
+	// id client_id user_id subject name created_at
+	//
+	// Links the subject identifier of an account at a login provider to a user.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE oauth_identities (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			subject VARCHAR(255) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+			UNIQUE(client_id, subject)
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create oauth identities table: %v", err)
+	}
+
+	return nil
+}
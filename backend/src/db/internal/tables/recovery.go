@@ -0,0 +1,52 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializePasswordResetTokensTable() error {
+	// Password reset tokens table:
+	// id user_id token_hash expires_at
+	//
+	// Only a hash of the token is stored, the token itself is sent to the user
+	// by email.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE password_reset_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash BYTEA NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create password reset tokens table: %v", err)
+	}
+
+	return nil
+}
+
+func (q *Tables) InitializeEmailVerificationTokensTable() error {
+	// Email verification tokens table:
+	// id user_id email token_hash expires_at
+	//
+	// The email address is stored so that a token becomes useless once the
+	// user changes their address.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE email_verification_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			email VARCHAR(255) NOT NULL,
+			token_hash BYTEA NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create email verification tokens table: %v", err)
+	}
+
+	return nil
+}
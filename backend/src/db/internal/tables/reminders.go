@@ -0,0 +1,38 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializeReminderDeliveriesTable() error {
+	// Reminder deliveries table:
+	// id userid event trigger_time delivered_at
+	//
+	// The id is derived from the event instance and the reminder, so that each reminder is only delivered once,
+	// even if the reminder service is restarted in between.
+	// Events are not referenced, since reminders may fire for events that were never stored in the events table.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE reminder_deliveries (
+			id UUID PRIMARY KEY,
+			userid UUID REFERENCES users(id) ON DELETE CASCADE,
+			event UUID NOT NULL,
+			trigger_time TIMESTAMPTZ NOT NULL,
+			delivered_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create reminder deliveries table: %v", err)
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_reminder_deliveries_delivered_at ON reminder_deliveries (delivered_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create secondary index on reminder deliveries table: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializeSourceHealthTable() error {
+	// Source health table:
+	// sourceid last_success last_failure last_error consecutive_failures next_retry
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE source_health (
+			sourceid UUID PRIMARY KEY REFERENCES sources(id) ON DELETE CASCADE,
+			last_success TIMESTAMPTZ,
+			last_failure TIMESTAMPTZ,
+			last_error TEXT,
+			consecutive_failures INT NOT NULL DEFAULT 0,
+			next_retry TIMESTAMPTZ
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create source health table: %v", err)
+	}
+
+	return nil
+}
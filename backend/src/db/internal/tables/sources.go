@@ -7,7 +7,9 @@ import (
 func (q *Tables) InitializeSourcesTable() error {
 	var err error
 	// Sources table:
-	// id user name type settings auth
+	// id user name type settings auth reminders
+	//
+	// With reminders, the owner allows the reminder service to decrypt the credentials in the background.
 	_, err = q.Tx.Exec(
 		q.Context,
 		`
@@ -20,6 +22,7 @@ func (q *Tables) InitializeSourcesTable() error {
 			auth_type BYTEA NOT NULL,
 			auth BYTEA NOT NULL,
 			display_order SMALLINT NOT NULL,
+			reminders BOOLEAN NOT NULL DEFAULT FALSE,
 			UNIQUE (userid, name),
 			UNIQUE (userid, display_order) DEFERRABLE INITIALLY IMMEDIATE
 		);
@@ -0,0 +1,65 @@
+package tables
+
+import "fmt"
+
+func (q *Tables) InitializeWebauthnCredentialsTable() error {
+	// WebAuthn credentials table:
+	// id userid credential_id name credential created_at last_used
+	//
+	// The credential column holds the public key, the signature counter and
+	// the flags of the authenticator as returned by the WebAuthn library.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE webauthn_credentials (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			userid UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			credential_id BYTEA NOT NULL UNIQUE,
+			name VARCHAR(255) NOT NULL,
+			credential JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_used TIMESTAMPTZ
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create webauthn credentials table: %v", err)
+	}
+
+	_, err = q.Tx.Exec(
+		q.Context,
+		`
+		CREATE INDEX index_webauthn_credentials_userid ON webauthn_credentials (userid);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create secondary index on webauthn credentials table: %v", err)
+	}
+
+	return nil
+}
+
+func (q *Tables) InitializeWebauthnCeremoniesTable() error {
+	// WebAuthn ceremonies table:
+	// id userid purpose data expires_at
+	//
+	// Holds the challenge of a registration or login between the request for
+	// options and the response of the authenticator. The user is unknown when
+	// logging in with a passkey alone, so userid may be null.
+	_, err := q.Tx.Exec(
+		q.Context,
+		`
+		CREATE TABLE webauthn_ceremonies (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			userid UUID REFERENCES users(id) ON DELETE CASCADE,
+			purpose VARCHAR(16) NOT NULL,
+			data JSONB NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		);
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create webauthn ceremonies table: %v", err)
+	}
+
+	return nil
+}
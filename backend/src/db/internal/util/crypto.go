@@ -49,3 +49,21 @@ func GetUserEncryptionKey(commonConfig *config.CommonConfig, userId types.ID) (s
 func GetUserDecryptionKey(commonConfig *config.CommonConfig, userId types.ID) (string, *errors.ErrorTrace) {
 	return GetUserEncryptionKey(commonConfig, userId)
 }
+
+// TOTP secrets use their own master key, so that they stay protected even if the database key leaks
+func GetTotpEncryptionKey(commonConfig *config.CommonConfig, userId types.ID) (string, *errors.ErrorTrace) {
+	masterKey, tr := crypto.GetSymmetricKey(commonConfig, "totp")
+	if tr != nil {
+		return "", tr.
+			Append(errors.LvlWordy, "Could not get TOTP master key")
+	}
+	userKey, err := crypto.DeriveKey(masterKey, userId.Bytes())
+	if err != nil {
+		return "", errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not derive TOTP key for %v", userId).
+			AltStr(errors.LvlWordy, "Could not derive TOTP key")
+	}
+	encodedKey := base64.StdEncoding.EncodeToString(userKey)
+	return encodedKey, nil
+}
@@ -69,10 +69,18 @@ func (tx *Transaction) Commit(logger *logrus.Entry) *errors.ErrorTrace {
 			AltStr(errors.LvlPlain, "Database error")
 	}
 
+	if tx.queries != nil {
+		tx.queries.PublishChanges()
+	}
+
 	return nil
 }
 
 func (tx *Transaction) Rollback(logger *logrus.Entry) *errors.ErrorTrace {
+	if tx.queries != nil {
+		tx.queries.DiscardChanges()
+	}
+
 	err := tx.tx.Rollback(tx.context)
 
 	if err != nil && err != pgx.ErrTxClosed && !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
@@ -29,7 +29,7 @@ func GetRemoteFile(url *types.Url, accept string, auth types.AuthMethod) *Remote
 func NewRemoteFile(url *types.Url, accept string, auth types.AuthMethod, user types.ID, q types.DatabaseQueries) (*RemoteFile, *errors.ErrorTrace) {
 	file := &RemoteFile{url: url, accept: accept, auth: auth}
 
-	content, err := net.FetchFile(file.url, file.auth, file.accept, q.GetContext())
+	content, validators, _, err := net.FetchFile(file.url, file.auth, file.accept, nil, q.GetContext())
 	if err != nil {
 		return nil, err.
 			Append(errors.LvlDebug, "Could not read from remote").
@@ -37,6 +37,7 @@ func NewRemoteFile(url *types.Url, accept string, auth types.AuthMethod, user ty
 	}
 
 	q.SetFilecache(file, content, user)
+	q.TouchFilecache(file, validators)
 
 	return file, nil
 }
@@ -54,13 +55,30 @@ func (file *RemoteFile) GetName(_ types.DatabaseQueries) string {
 }
 
 func (file *RemoteFile) fetchContentFromRemote(q types.DatabaseQueries) (io.Reader, *errors.ErrorTrace) {
-	content, err := net.FetchFile(file.url, file.auth, file.accept, q.GetContext())
+	// Only ask the server whether the file changed if we still have its content
+	validators, _ := q.GetFilecacheValidators(file)
+
+	content, newValidators, notModified, err := net.FetchFile(file.url, file.auth, file.accept, validators, q.GetContext())
 	if err != nil {
 		return nil, err.
 			Append(errors.LvlDebug, "Could not read from remote").
 			Append(errors.LvlPlain, "Could not read contents of file")
 	}
 
+	if notModified {
+		q.TouchFilecache(file, newValidators)
+		if file.content != nil {
+			return bytes.NewReader(file.content), nil
+		}
+		cached, _, err := file.fetchContentFromDatabase(q)
+		if err != nil {
+			return nil, err.
+				Append(errors.LvlDebug, "Remote file %v did not change, but its cached content is missing", file.GetId()).
+				Append(errors.LvlPlain, "Could not read contents of file")
+		}
+		return cached, nil
+	}
+
 	// TODO: don't use local buffer, instead run the database query in a
 	// separate goroutine and return a reader directly without buffering the
 	// whole file first
@@ -69,6 +87,8 @@ func (file *RemoteFile) fetchContentFromRemote(q types.DatabaseQueries) (io.Read
 	err = q.UpdateFileCache(file, cache)
 	if err != nil {
 		// TODO: Logger.Warnf("could not set remote file cache in database: %v", err)
+	} else {
+		q.TouchFilecache(file, newValidators)
 	}
 
 	return &buf, nil
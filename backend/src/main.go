@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"luna-backend/api"
 	"luna-backend/cache"
+	"luna-backend/changes"
 	"luna-backend/config"
 	"luna-backend/db"
 	"luna-backend/errors"
 	"luna-backend/log"
 	"luna-backend/parsing"
+	"luna-backend/protocols/google"
+	"luna-backend/protocols/microsoft"
 	"luna-backend/services"
 	"luna-backend/tasks"
 	"luna-backend/types"
@@ -41,8 +44,9 @@ func setupConfig() (*logrus.Logger, *logrus.Entry, *config.CommonConfig, *errors
 	}
 
 	commonConfig := &config.CommonConfig{
-		Env:   &env,
-		Cache: cache.NewCache(),
+		Env:     &env,
+		Cache:   cache.NewCache(),
+		Changes: changes.NewHub(),
 	}
 	commonConfig.Version, err = types.ParseVersion(version)
 	if err != nil {
@@ -52,6 +56,9 @@ func setupConfig() (*logrus.Logger, *logrus.Entry, *config.CommonConfig, *errors
 	}
 	commonConfig.PublicUrl = (*types.Url)(&env.PUBLIC_URL)
 
+	google.SetApiUrl((*types.Url)(&env.GOOGLE_API_URL))
+	microsoft.SetApiUrl((*types.Url)(&env.MICROSOFT_API_URL))
+
 	return logger, mainLogger, commonConfig, nil
 }
 
@@ -192,10 +199,16 @@ func main() {
 	c.AddFunc("*/15 * * * *", createTask("RefetchProfilePictures", tasks.RefetchProfilePictures, db, cronLogger, commonConfig))
 	c.AddFunc("0 * * * *", createTask("DeleteExpiredShortLivedSessions", tasks.DeleteStaleShortLivedSessions, db, cronLogger, commonConfig))
 	c.AddFunc("0 0 * * *", createTask("DeleteExpiredLongLivedSessions", tasks.DeleteStaleLongLivedSessions, db, cronLogger, commonConfig))
+	c.AddFunc("*/15 * * * *", createTask("DeleteExpiredPreauthSessions", tasks.DeleteExpiredPreauthSessions, db, cronLogger, commonConfig))
+	c.AddFunc("*/15 * * * *", createTask("DeleteExpiredWebauthnCeremonies", tasks.DeleteExpiredWebauthnCeremonies, db, cronLogger, commonConfig))
 	c.AddFunc("0 * * * *", createTask("DeleteExpiredRegistrationInvites", tasks.DeleteExpiredRegistrationInvites, db, cronLogger, commonConfig))
 	c.AddFunc("0 * * * *", createTask("DeleteExpiredOauthAuthorizationRequests", tasks.DeleteExpiredOauthAuthorizationRequests, db, cronLogger, commonConfig))
+	c.AddFunc("*/15 * * * *", createTask("DeleteExpiredOauthLoginRequests", tasks.DeleteExpiredOauthLoginRequests, db, cronLogger, commonConfig))
+	c.AddFunc("0 * * * *", createTask("DeleteExpiredPasswordResetTokens", tasks.DeleteExpiredPasswordResetTokens, db, cronLogger, commonConfig))
+	c.AddFunc("0 * * * *", createTask("DeleteExpiredEmailVerificationTokens", tasks.DeleteExpiredEmailVerificationTokens, db, cronLogger, commonConfig))
 	c.AddFunc("*/10 * * * *", createTask("DeleteStaleRequestThrottleEntries", tasks.DeleteStaleRequestThrottleEntries(api.Throttle), db, cronLogger, commonConfig))
 	c.AddFunc("*/10 * * * *", createTask("DeleteStaleMemoryCacheEntries", tasks.ClearStaleCache, db, cronLogger, commonConfig))
+	c.AddFunc("0 0 * * *", createTask("DeleteStaleReminderDeliveries", tasks.DeleteStaleReminderDeliveries, db, cronLogger, commonConfig))
 
 	// Token invalidation service
 	tokenInvalidationLogger := logger.WithField("module", "token_invalidation")
@@ -205,10 +218,20 @@ func main() {
 	oauthInvalidationLogger := logger.WithField("module", "oauth_invalidation")
 	oauthInvalidationService := services.NewOauthInvalidationService(db, commonConfig, oauthInvalidationLogger)
 
+	// Reminder delivery service
+	reminderLogger := logger.WithField("module", "reminders")
+	reminderService := services.NewReminderService(db, commonConfig, reminderLogger)
+
+	// Source health service
+	sourceHealthLogger := logger.WithField("module", "source_health")
+	sourceHealthService := services.NewSourceHealthService(db, commonConfig, sourceHealthLogger)
+
 	// Wait for goroutines to finish
 	var wg sync.WaitGroup
 	startGoroutine(tokenInvalidationService.Start, &wg)
 	startGoroutine(oauthInvalidationService.Start, &wg)
+	startGoroutine(reminderService.Start, &wg)
+	startGoroutine(sourceHealthService.Start, &wg)
 	startGoroutine(c.Start, &wg)
 	startGoroutine(api.Start, &wg)
 	wg.Wait()
@@ -2,6 +2,7 @@ package net
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"encoding/xml"
@@ -11,12 +12,16 @@ import (
 	"luna-backend/types"
 	"net/http"
 	"net/url"
+	"strings"
+
+	"github.com/andybalholm/brotli"
 )
 
-func FetchFile(url *types.Url, auth types.AuthMethod, accept string, ctx context.Context) (io.Reader, *errors.ErrorTrace) {
+// If validators are given and the server confirms that the file did not change, no content is returned and the third return value is true
+func FetchFile(url *types.Url, auth types.AuthMethod, accept string, validators *types.FileValidators, ctx context.Context) (io.Reader, *types.FileValidators, bool, *errors.ErrorTrace) {
 	req, err := http.NewRequest("GET", url.String(), nil)
 	if err != nil {
-		return nil, errors.New().Status(http.StatusInternalServerError).
+		return nil, nil, false, errors.New().Status(http.StatusInternalServerError).
 			AddErr(errors.LvlDebug, err).
 			Append(errors.LvlDebug, "Could not create request").
 			Append(errors.LvlWordy, "Could not fetch resource from %v", url).
@@ -24,16 +29,41 @@ func FetchFile(url *types.Url, auth types.AuthMethod, accept string, ctx context
 	}
 
 	req.Header.Set("Accept", accept)
+	// Setting this ourselves turns off the transparent decompression of the HTTP client, so both are decoded in decodeBody
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	if validators != nil && validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators != nil && validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
 	req = req.WithContext(ctx)
 
 	res, tr := auth.Do(req)
 	if tr != nil {
-		return nil, errors.InterpretRemoteError(tr, "file", "remote file").
+		return nil, nil, false, errors.InterpretRemoteError(tr, "file", "remote file").
 			Append(errors.LvlDebug, "Could not fulfill request").
 			Append(errors.LvlWordy, "Could not fetch resource from %v", url).
 			AltStr(errors.LvlPlain, "Could not fetch resource")
 	}
 
+	newValidators := &types.FileValidators{
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}
+
+	if res.StatusCode == http.StatusNotModified && validators != nil {
+		res.Body.Close()
+		// A 304 response may leave out validators that did not change
+		if newValidators.ETag == "" {
+			newValidators.ETag = validators.ETag
+		}
+		if newValidators.LastModified == "" {
+			newValidators.LastModified = validators.LastModified
+		}
+		return nil, newValidators, true, nil
+	}
+
 	if res.StatusCode != http.StatusOK {
 		tr := errors.New().Status(res.StatusCode)
 
@@ -42,7 +72,7 @@ func FetchFile(url *types.Url, auth types.AuthMethod, accept string, ctx context
 			tr.Append(errors.LvlDebug, string(body))
 		}
 
-		return nil, tr.
+		return nil, nil, false, tr.
 			Append(errors.LvlPlain, "%v", res.Status).
 			Append(errors.LvlWordy, "Error %v", res.StatusCode).
 			Append(errors.LvlDebug, "Server returned an error code").
@@ -50,7 +80,34 @@ func FetchFile(url *types.Url, auth types.AuthMethod, accept string, ctx context
 			AltStr(errors.LvlPlain, "Could not fetch resource")
 	}
 
-	return res.Body, nil
+	body, tr := decodeBody(res)
+	if tr != nil {
+		return nil, nil, false, tr.
+			Append(errors.LvlWordy, "Could not fetch resource from %v", url).
+			AltStr(errors.LvlPlain, "Could not fetch resource")
+	}
+
+	return body, newValidators, false, nil
+}
+
+func decodeBody(res *http.Response) (io.Reader, *errors.ErrorTrace) {
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return res.Body, nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusBadGateway).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not decompress gzip response")
+		}
+		return reader, nil
+	case "br":
+		return brotli.NewReader(res.Body), nil
+	default:
+		return nil, errors.New().Status(http.StatusBadGateway).
+			Append(errors.LvlDebug, "Unsupported content encoding %v", res.Header.Get("Content-Encoding"))
+	}
 }
 
 func FetchBytes(
@@ -61,6 +118,19 @@ func FetchBytes(
 	bodyType string,
 	accept string,
 	ctx context.Context,
+) ([]byte, *errors.ErrorTrace) {
+	return fetchBytes(reqUrl, httpMethod, auth, body, bodyType, accept, nil, ctx)
+}
+
+func fetchBytes(
+	reqUrl *types.Url,
+	httpMethod string,
+	auth types.AuthMethod,
+	body any,
+	bodyType string,
+	accept string,
+	headers http.Header,
+	ctx context.Context,
 ) ([]byte, *errors.ErrorTrace) {
 	// Serialize body
 	var payload io.Reader = nil
@@ -102,6 +172,11 @@ func FetchBytes(
 	}
 
 	// Set headers
+	for name, values := range headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
 	if payload != nil && bodyType != "" {
 		req.Header.Set("Content-Type", bodyType)
 	}
@@ -159,7 +234,21 @@ func FetchJson(
 	ctx context.Context,
 	target any,
 ) *errors.ErrorTrace {
-	data, tr := FetchBytes(url, httpMethod, auth, body, bodyType, "application/json", ctx)
+	return FetchJsonWithHeaders(url, httpMethod, auth, body, bodyType, nil, ctx, target)
+}
+
+// Some APIs, like Microsoft Graph, are configured through additional request headers
+func FetchJsonWithHeaders(
+	url *types.Url,
+	httpMethod string,
+	auth types.AuthMethod,
+	body any,
+	bodyType string,
+	headers http.Header,
+	ctx context.Context,
+	target any,
+) *errors.ErrorTrace {
+	data, tr := fetchBytes(url, httpMethod, auth, body, bodyType, "application/json", headers, ctx)
 	if tr != nil {
 		return tr
 	}
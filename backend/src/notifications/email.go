@@ -0,0 +1,50 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"luna-backend/errors"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// Sends notifications as plain text emails to the user's email address
+type EmailNotifier struct {
+	server *SmtpServer
+}
+
+func NewEmailNotifier(server *SmtpServer) *EmailNotifier {
+	return &EmailNotifier{
+		server: server,
+	}
+}
+
+func (notifier *EmailNotifier) Notify(notification *Notification, ctx context.Context) *errors.ErrorTrace {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", notifier.server.From())
+	fmt.Fprintf(&msg, "To: %s\r\n", notification.User.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+notification.Title()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(notification.Message())
+	msg.WriteString("\r\n")
+
+	err := notifier.server.Send([]string{notification.User.Email}, msg.Bytes(), ctx)
+	if err != nil && err == ctx.Err() {
+		return errors.New().Status(http.StatusGatewayTimeout).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Timed out sending email to %v", notification.User.Email).
+			Append(errors.LvlWordy, "Could not send email notification")
+	}
+	if err != nil {
+		return errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not send email to %v", notification.User.Email).
+			Append(errors.LvlWordy, "Could not send email notification")
+	}
+	return nil
+}
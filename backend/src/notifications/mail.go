@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"luna-backend/config"
+	"luna-backend/errors"
+	"mime"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// Every template defines a "subject" and a "body", so each one is parsed on its own
+//
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+const (
+	MailPasswordReset     = "password_reset"
+	MailEmailVerification = "email_verification"
+)
+
+var mailTemplates = map[string]*template.Template{
+	MailPasswordReset:     parseMailTemplate(MailPasswordReset),
+	MailEmailVerification: parseMailTemplate(MailEmailVerification),
+}
+
+func parseMailTemplate(name string) *template.Template {
+	return template.Must(template.ParseFS(templateFiles, "templates/"+name+".tmpl"))
+}
+
+// The values available to mail templates
+type MailData struct {
+	Username string
+	Link     string
+	ValidFor string
+}
+
+// Sends templated account emails, like password reset links
+type Mailer struct {
+	server *SmtpServer
+}
+
+// Returns nil if no SMTP server is configured
+func NewMailer(env *config.Environmental) *Mailer {
+	if env.SMTP_HOST == "" {
+		return nil
+	}
+
+	return &Mailer{
+		server: NewSmtpServer(env.SMTP_HOST, env.SMTP_PORT, env.SMTP_USERNAME, env.SMTP_PASSWORD, env.SMTP_FROM),
+	}
+}
+
+func (mailer *Mailer) Send(recipient string, name string, data *MailData, ctx context.Context) *errors.ErrorTrace {
+	msg, err := mailer.buildMessage(recipient, name, data)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not assemble %v email", name).
+			Append(errors.LvlWordy, "Could not send email")
+	}
+
+	err = mailer.server.Send([]string{recipient}, msg, ctx)
+	if err != nil && err == ctx.Err() {
+		return errors.New().Status(http.StatusGatewayTimeout).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Timed out sending %v email to %v", name, recipient).
+			Append(errors.LvlWordy, "Could not send email")
+	}
+	if err != nil {
+		return errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not send %v email to %v", name, recipient).
+			Append(errors.LvlWordy, "Could not send email")
+	}
+	return nil
+}
+
+func (mailer *Mailer) buildMessage(recipient string, name string, data *MailData) ([]byte, error) {
+	tmpl, ok := mailTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template %v", name)
+	}
+
+	var subject, body bytes.Buffer
+	err := tmpl.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return nil, fmt.Errorf("could not render subject: %v", err)
+	}
+	err = tmpl.ExecuteTemplate(&body, "body", data)
+	if err != nil {
+		return nil, fmt.Errorf("could not render body: %v", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", mailer.server.From())
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject.String()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.Write(bytes.ReplaceAll(body.Bytes(), []byte("\n"), []byte("\r\n")))
+	msg.WriteString("\r\n")
+
+	return msg.Bytes(), nil
+}
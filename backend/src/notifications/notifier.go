@@ -0,0 +1,44 @@
+package notifications
+
+import (
+	"context"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"time"
+)
+
+// A single reminder for one occurrence of an event
+type Notification struct {
+	User     *types.User
+	Event    types.Event
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+	Trigger  time.Time
+	Reminder *types.EventReminder
+}
+
+// Delivers notifications to one destination, e.g. an email address or a push service
+type Notifier interface {
+	Notify(notification *Notification, ctx context.Context) *errors.ErrorTrace
+}
+
+func (notification *Notification) Title() string {
+	return notification.Event.GetName()
+}
+
+// Short human-readable description of when the event takes place
+func (notification *Notification) Message() string {
+	var message string
+	if notification.AllDay {
+		message = "All day on " + notification.Start.Format("Monday, January 2")
+	} else {
+		message = "Starts at " + notification.Start.Format("Monday, January 2, 15:04 MST")
+	}
+
+	if desc := notification.Event.GetDesc(); desc != "" {
+		message += "\n\n" + desc
+	}
+
+	return message
+}
@@ -0,0 +1,116 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"luna-backend/auth"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+)
+
+// Publishes notifications to an ntfy topic, see https://docs.ntfy.sh/publish/
+type NtfyNotifier struct {
+	topicUrl *types.Url
+	auth     types.AuthMethod
+}
+
+func NewNtfyNotifier(topicUrl *types.Url, token string) *NtfyNotifier {
+	return &NtfyNotifier{
+		topicUrl: topicUrl,
+		auth:     pushAuth(token),
+	}
+}
+
+func (notifier *NtfyNotifier) Notify(notification *Notification, ctx context.Context) *errors.ErrorTrace {
+	req, err := http.NewRequestWithContext(ctx, "POST", notifier.topicUrl.String(), bytes.NewBufferString(notification.Message()))
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not create request").
+			Append(errors.LvlWordy, "Could not send push notification")
+	}
+	req.Header.Set("Title", notification.Title())
+	req.Header.Set("Tags", "calendar")
+
+	return sendPush(req, notifier.auth)
+}
+
+// Sends notifications as messages to a Gotify server, see https://gotify.net/docs/pushmsg
+type GotifyNotifier struct {
+	messageUrl *types.Url
+	auth       types.AuthMethod
+}
+
+func NewGotifyNotifier(serverUrl *types.Url, token string) *GotifyNotifier {
+	return &GotifyNotifier{
+		messageUrl: serverUrl.Subpage("message"),
+		auth:       pushAuth(token),
+	}
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+func (notifier *GotifyNotifier) Notify(notification *Notification, ctx context.Context) *errors.ErrorTrace {
+	body, err := json.Marshal(&gotifyMessage{
+		Title:    notification.Title(),
+		Message:  notification.Message(),
+		Priority: 5,
+	})
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not marshal JSON body").
+			Append(errors.LvlWordy, "Could not send push notification")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", notifier.messageUrl.String(), bytes.NewBuffer(body))
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not create request").
+			Append(errors.LvlWordy, "Could not send push notification")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return sendPush(req, notifier.auth)
+}
+
+// Both ntfy and Gotify accept access tokens as bearer tokens
+func pushAuth(token string) types.AuthMethod {
+	if token == "" {
+		return auth.NewNoAuth()
+	}
+	return auth.NewBearerAuth(token)
+}
+
+func sendPush(req *http.Request, auth types.AuthMethod) *errors.ErrorTrace {
+	res, tr := auth.Do(req)
+	if tr != nil {
+		return errors.InterpretRemoteError(tr, "push service", "push service").
+			Append(errors.LvlWordy, "Could not send push notification")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		tr := errors.New().Status(res.StatusCode)
+
+		body, err := io.ReadAll(res.Body)
+		if err == nil {
+			tr.Append(errors.LvlDebug, string(body))
+		}
+
+		return tr.
+			Append(errors.LvlWordy, "Error %v", res.StatusCode).
+			Append(errors.LvlDebug, "Push service returned an error code").
+			Append(errors.LvlWordy, "Could not send push notification")
+	}
+
+	return nil
+}
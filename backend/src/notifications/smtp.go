@@ -0,0 +1,54 @@
+package notifications
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// The SMTP server used for all outgoing emails
+type SmtpServer struct {
+	host     string
+	port     uint16
+	username string
+	password string
+	from     string
+}
+
+func NewSmtpServer(host string, port uint16, username string, password string, from string) *SmtpServer {
+	return &SmtpServer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// The address that emails are sent from
+func (server *SmtpServer) From() string {
+	return server.from
+}
+
+// Sends a complete message including its headers.
+// net/smtp does not support contexts, so the request is abandoned once the context is done.
+func (server *SmtpServer) Send(recipients []string, msg []byte, ctx context.Context) error {
+	var auth smtp.Auth
+	if server.username != "" {
+		auth = smtp.PlainAuth("", server.username, server.password, server.host)
+	}
+
+	addr := net.JoinHostPort(server.host, strconv.Itoa(int(server.port)))
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, server.from, recipients, msg)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
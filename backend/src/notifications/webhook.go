@@ -0,0 +1,67 @@
+package notifications
+
+import (
+	"context"
+	"luna-backend/auth"
+	"luna-backend/errors"
+	"luna-backend/net"
+	"luna-backend/types"
+	"time"
+)
+
+// Posts notifications as JSON to an arbitrary URL
+type WebhookNotifier struct {
+	url *types.Url
+}
+
+func NewWebhookNotifier(url *types.Url) *WebhookNotifier {
+	return &WebhookNotifier{
+		url: url,
+	}
+}
+
+type webhookEvent struct {
+	Id       types.ID `json:"id"`
+	Calendar types.ID `json:"calendar"`
+	Name     string   `json:"name"`
+	Desc     string   `json:"desc"`
+}
+
+type webhookPayload struct {
+	Type     string               `json:"type"`
+	Event    webhookEvent         `json:"event"`
+	Start    time.Time            `json:"start"`
+	End      time.Time            `json:"end"`
+	AllDay   bool                 `json:"all_day"`
+	Trigger  time.Time            `json:"trigger"`
+	Reminder *types.EventReminder `json:"reminder"`
+	Title    string               `json:"title"`
+	Message  string               `json:"message"`
+}
+
+func (notifier *WebhookNotifier) Notify(notification *Notification, ctx context.Context) *errors.ErrorTrace {
+	payload := &webhookPayload{
+		Type: "reminder",
+		Event: webhookEvent{
+			Id:       notification.Event.GetId(),
+			Calendar: notification.Event.GetCalendar().GetId(),
+			Name:     notification.Event.GetName(),
+			Desc:     notification.Event.GetDesc(),
+		},
+		Start:    notification.Start,
+		End:      notification.End,
+		AllDay:   notification.AllDay,
+		Trigger:  notification.Trigger,
+		Reminder: notification.Reminder,
+		Title:    notification.Title(),
+		Message:  notification.Message(),
+	}
+
+	_, tr := net.FetchBytes(notifier.url, "POST", auth.NewNoAuth(), payload, "application/json", "", ctx)
+	if tr != nil {
+		return tr.
+			Append(errors.LvlWordy, "Could not send webhook notification")
+	}
+
+	return nil
+}
@@ -7,8 +7,13 @@ import (
 	"luna-backend/constants"
 	"luna-backend/errors"
 	"luna-backend/protocols/caldav"
+	"luna-backend/protocols/carddav"
 	"luna-backend/protocols/google"
+	"luna-backend/protocols/holidays"
 	"luna-backend/protocols/ical"
+	"luna-backend/protocols/jmap"
+	"luna-backend/protocols/luna"
+	"luna-backend/protocols/microsoft"
 	"luna-backend/types"
 	"net/http"
 )
@@ -80,6 +85,23 @@ func (PrimitivesParser) ParseSource(entry *types.SourceDatabaseEntry, ctx contex
 		)
 		caldavSource.SupplyContext(ctx)
 		return caldavSource, nil
+	case constants.SourceCarddav:
+		settings := &carddav.CarddavSourceSettings{}
+		err = json.Unmarshal(entry.Settings, settings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal CardDAV settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		carddavSource := carddav.PackCarddavSource(
+			entry.Id,
+			entry.Name,
+			settings,
+			authMethod,
+		)
+		carddavSource.SupplyContext(ctx)
+		return carddavSource, nil
 	case constants.SourceIcal:
 		settings := &ical.IcalSourceSettings{}
 		err = json.Unmarshal(entry.Settings, settings)
@@ -117,6 +139,72 @@ func (PrimitivesParser) ParseSource(entry *types.SourceDatabaseEntry, ctx contex
 		)
 		googleSource.SupplyContext(ctx)
 		return googleSource, nil
+	case constants.SourceMicrosoft:
+		settings := &microsoft.MicrosoftSourceSettings{}
+		err = json.Unmarshal(entry.Settings, settings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal Microsoft settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		microsoftSource := microsoft.PackMicrosoftSource(
+			entry.Id,
+			entry.Name,
+			settings,
+			authMethod,
+		)
+		microsoftSource.SupplyContext(ctx)
+		return microsoftSource, nil
+	case constants.SourceJmap:
+		settings := &jmap.JmapSourceSettings{}
+		err = json.Unmarshal(entry.Settings, settings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal JMAP settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		jmapSource := jmap.PackJmapSource(
+			entry.Id,
+			entry.Name,
+			settings,
+			authMethod,
+		)
+		jmapSource.SupplyContext(ctx)
+		return jmapSource, nil
+	case constants.SourceHolidays:
+		settings := &holidays.HolidaysSourceSettings{}
+		err = json.Unmarshal(entry.Settings, settings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal holiday settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return holidays.PackHolidaysSource(
+			entry.Id,
+			entry.Name,
+			settings,
+			authMethod,
+		), nil
+	case constants.SourceLuna:
+		settings := &luna.LunaSourceSettings{}
+		err = json.Unmarshal(entry.Settings, settings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal Luna settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		lunaSource := luna.PackLunaSource(
+			entry.Id,
+			entry.Name,
+			settings,
+			authMethod,
+		)
+		lunaSource.SupplyContext(ctx)
+		return lunaSource, nil
 	default:
 		return nil, errors.New().Status(http.StatusInternalServerError).
 			Append(errors.LvlWordy, "Unknown source type: %v", entry.Type)
@@ -135,6 +223,16 @@ func (PrimitivesParser) ParseCalendarSettings(sourceType string, settings []byte
 				Append(errors.LvlWordy, "Could not unmarshal settings")
 		}
 		return parsedSettings, nil
+	case constants.SourceCarddav:
+		parsedSettings := &carddav.CarddavCalendarSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal CardDAV settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
 	case constants.SourceIcal:
 		parsedSettings := &ical.IcalCalendarSettings{}
 		err := json.Unmarshal(settings, parsedSettings)
@@ -155,6 +253,46 @@ func (PrimitivesParser) ParseCalendarSettings(sourceType string, settings []byte
 				Append(errors.LvlWordy, "Could not unmarshal settings")
 		}
 		return parsedSettings, nil
+	case constants.SourceMicrosoft:
+		parsedSettings := &microsoft.MicrosoftCalendarSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal Microsoft settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
+	case constants.SourceJmap:
+		parsedSettings := &jmap.JmapCalendarSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal JMAP settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
+	case constants.SourceHolidays:
+		parsedSettings := &holidays.HolidaysCalendarSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal holiday settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
+	case constants.SourceLuna:
+		parsedSettings := &luna.LunaCalendarSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal Luna settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
 	default:
 		return nil, errors.New().Status(http.StatusInternalServerError).
 			Append(errors.LvlWordy, "Unknown source type: %v", sourceType)
@@ -173,6 +311,16 @@ func (PrimitivesParser) ParseEventSettings(sourceType string, settings []byte) (
 				Append(errors.LvlWordy, "Could not unmarshal settings")
 		}
 		return parsedSettings, nil
+	case constants.SourceCarddav:
+		parsedSettings := &carddav.CarddavEventSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal CardDAV settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
 	case constants.SourceIcal:
 		parsedSettings := &ical.IcalEventSettings{}
 		err := json.Unmarshal(settings, parsedSettings)
@@ -193,6 +341,46 @@ func (PrimitivesParser) ParseEventSettings(sourceType string, settings []byte) (
 				Append(errors.LvlWordy, "Could not unmarshal settings")
 		}
 		return parsedSettings, nil
+	case constants.SourceMicrosoft:
+		parsedSettings := &microsoft.MicrosoftEventSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal Microsoft settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
+	case constants.SourceJmap:
+		parsedSettings := &jmap.JmapEventSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal JMAP settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
+	case constants.SourceHolidays:
+		parsedSettings := &holidays.HolidaysEventSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal holiday settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
+	case constants.SourceLuna:
+		parsedSettings := &luna.LunaEventSettings{}
+		err := json.Unmarshal(settings, parsedSettings)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal Luna settings").
+				Append(errors.LvlWordy, "Could not unmarshal settings")
+		}
+		return parsedSettings, nil
 	default:
 		return nil, errors.New().Status(http.StatusInternalServerError).
 			Append(errors.LvlWordy, "Unknown source type: %v", sourceType)
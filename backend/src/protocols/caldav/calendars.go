@@ -161,36 +161,47 @@ func (calendar *CaldavCalendar) convertEvent(event *caldav.CalendarObject, q typ
 	return castedEvent, nil
 }
 
-func (calendar *CaldavCalendar) getEvents(query *caldav.CalendarQuery, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
-	client, tr := calendar.source.getClient()
+func (calendar *CaldavCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
+	objects, tr := calendar.sync(q)
 	if tr != nil {
 		return nil, tr.
 			Append(errors.LvlBroad, "Could not get events")
 	}
 
-	events, err := client.QueryCalendar(q.GetContext(), calendar.settings.Url.String(), query)
-	if err != nil {
-		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "calendar", "CalDAV calendar").
-			Append(errors.LvlBroad, "Could not get events")
-	}
-
 	masterEvents := make(map[string]int)
 	masterEventIndices := make(map[int]bool)
 
-	convertedEvents := make([]types.Event, len(events))
-	for i, event := range events {
-		convertedEvents[i], tr = calendar.convertEvent(&event, q)
+	convertedEvents := []types.Event{}
+	for _, object := range objects {
+		obj, tr := objectFromRemoteObject(object)
 		if tr != nil {
 			return nil, tr.
 				Append(errors.LvlBroad, "Could not get events")
 		}
 
-		eventSettings := convertedEvents[i].GetSettings().(*CaldavEventSettings)
+		// Calendars may also contain other components like tasks
+		if !containsEvent(obj) {
+			continue
+		}
+
+		convertedEvent, tr := calendar.convertEvent(obj, q)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlBroad, "Could not get events")
+		}
+
+		date := convertedEvent.GetDate()
+		if !date.Recurrence().Repeats() && (!date.Start().Before(end) || date.End().Before(start)) {
+			continue
+		}
 
+		eventSettings := convertedEvent.GetSettings().(*CaldavEventSettings)
 		if eventSettings.RecurrenceId == "" {
-			masterEvents[eventSettings.Uid] = i
-			masterEventIndices[i] = true
+			masterEvents[eventSettings.Uid] = len(convertedEvents)
+			masterEventIndices[len(convertedEvents)] = true
 		}
+
+		convertedEvents = append(convertedEvents, convertedEvent)
 	}
 
 	// Internally note all the modified recurrence instances for each master event so that we don't expand these later
@@ -207,30 +218,13 @@ func (calendar *CaldavCalendar) getEvents(query *caldav.CalendarQuery, q types.D
 	return convertedEvents, nil
 }
 
-func (calendar *CaldavCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
-	return calendar.getEvents(&caldav.CalendarQuery{
-		CompRequest: caldav.CalendarCompRequest{
-			Name: "VCALENDAR",
-			Comps: []caldav.CalendarCompRequest{{
-				Name: "VEVENT",
-				Props: []string{
-					"SUMMARY",
-					"UID",
-					"DTSTART",
-					"DTEND",
-					"DURATION",
-				},
-			}},
-		},
-		CompFilter: caldav.CompFilter{
-			Name: "VCALENDAR",
-			Comps: []caldav.CompFilter{{
-				Name:  "VEVENT",
-				Start: start,
-				End:   end,
-			}},
-		},
-	}, q)
+func containsEvent(obj *caldav.CalendarObject) bool {
+	for _, child := range obj.Data.Children {
+		if child.Name == "VEVENT" {
+			return true
+		}
+	}
+	return false
 }
 
 func (calendar *CaldavCalendar) GetEvent(settings types.EventSettings, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
@@ -251,7 +245,7 @@ func (calendar *CaldavCalendar) GetEvent(settings types.EventSettings, q types.D
 	return cal, nil
 }
 
-func setEventProps(cal *ical.Calendar, id string, name string, desc string, color *types.Color, date *types.EventDate) *errors.ErrorTrace {
+func setEventProps(cal *ical.Calendar, id string, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder) *errors.ErrorTrace {
 	var event *ical.Event = nil
 	for _, child := range cal.Children {
 		if child.Name == "VEVENT" {
@@ -316,6 +310,11 @@ func setEventProps(cal *ical.Calendar, id string, name string, desc string, colo
 		event.Props.Del(ical.PropDuration)
 	}
 
+	common.SetIcalLocation(&event.Props, location)
+	common.SetIcalParticipants(&event.Props, participants)
+
+	setEventReminders(event.Component, name, reminders)
+
 	timestamp := time.Now()
 	event.Props.SetDateTime(ical.PropDateTimeStamp, timestamp)
 	//event.Props.SetDateTime(util.PropTimestamp, timestamp)
@@ -326,11 +325,34 @@ func setEventProps(cal *ical.Calendar, id string, name string, desc string, colo
 	return nil
 }
 
-func (calendar *CaldavCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+// Alarms are only rewritten if the reminders changed, so that properties unknown to Luna are preserved.
+// Alarms with actions that Luna does not support are always kept.
+func setEventReminders(event *ical.Component, name string, reminders []*types.EventReminder) {
+	if types.RemindersEqual(types.EventRemindersFromIcal(event), reminders) {
+		return
+	}
+
+	children := []*ical.Component{}
+	for _, child := range event.Children {
+		if child.Name == ical.CompAlarm {
+			action := child.Props.Get(ical.PropAction)
+			if action == nil || action.Value == "DISPLAY" || action.Value == "EMAIL" {
+				continue
+			}
+		}
+		children = append(children, child)
+	}
+	for _, reminder := range reminders {
+		children = append(children, reminder.ToIcal(common.EscapeIcalString(name)))
+	}
+	event.Children = children
+}
+
+func (calendar *CaldavCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
 	id := types.RandomId()
 	cal := ical.NewCalendar()
 
-	tr := setEventProps(cal, id.String(), name, desc, color, date)
+	tr := setEventProps(cal, id.String(), name, desc, color, date, location, participants, reminders)
 	if tr != nil {
 		return nil, tr.Status(http.StatusBadRequest).
 			Append(errors.LvlWordy, "Could not set iCal properties").
@@ -363,13 +385,13 @@ func (calendar *CaldavCalendar) AddEvent(name string, desc string, color *types.
 	return finishedEvent, nil
 }
 
-func (calendar *CaldavCalendar) EditEvent(originalEvent types.Event, name string, desc string, color *types.Color, date *types.EventDate, _ bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+func (calendar *CaldavCalendar) EditEvent(originalEvent types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, _ bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
 	originalCaldavEvent := originalEvent.(*CaldavEvent)
 	uid := originalCaldavEvent.GetSettings().(*CaldavEventSettings).Uid
 	originalRawEvent := originalCaldavEvent.settings.rawEvent
 	cal := originalRawEvent.Data
 
-	tr := setEventProps(cal, uid, name, desc, color, date)
+	tr := setEventProps(cal, uid, name, desc, color, date, location, participants, reminders)
 	if tr != nil {
 		return nil, tr.Status(http.StatusBadRequest).
 			Append(errors.LvlWordy, "Could not set iCal properties").
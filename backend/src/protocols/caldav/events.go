@@ -13,13 +13,16 @@ import (
 )
 
 type CaldavEvent struct {
-	name       string
-	desc       string
-	color      *types.Color
-	overridden bool
-	settings   *CaldavEventSettings
-	calendar   *CaldavCalendar
-	eventDate  *types.EventDate
+	name         string
+	desc         string
+	color        *types.Color
+	overridden   bool
+	settings     *CaldavEventSettings
+	calendar     *CaldavCalendar
+	eventDate    *types.EventDate
+	reminders    []*types.EventReminder
+	location     *types.EventLocation
+	participants *types.EventParticipants
 }
 
 type CaldavEventSettings struct {
@@ -55,7 +58,7 @@ func (calendar *CaldavCalendar) eventFromCaldav(obj *caldav.CalendarObject, q ty
 			Append(errors.LvlDebug, "could not find VEVENT in calendar object %v", obj.Path)
 	}
 
-	parsedProps, mustUpdate, err := common.ParseIcalEvent(&obj.Data.Children[eventIndex].Props)
+	parsedProps, mustUpdate, err := common.ParseIcalEvent(obj.Data.Children[eventIndex])
 	if err != nil {
 		uid := "unknown"
 		if uidProp := obj.Data.Children[eventIndex].Props.Get(ical.PropUID); uidProp != nil {
@@ -85,12 +88,15 @@ func (calendar *CaldavCalendar) eventFromCaldav(obj *caldav.CalendarObject, q ty
 			IsFirstRecurrence: parsedProps.RecurrenceId == "",
 			rawEvent:          obj,
 		},
-		calendar:  calendar,
-		eventDate: parsedProps.EventDate,
+		calendar:     calendar,
+		eventDate:    parsedProps.EventDate,
+		reminders:    parsedProps.Reminders,
+		location:     parsedProps.Location,
+		participants: parsedProps.Participants,
 	}
 
 	if mustUpdate {
-		calendar.EditEvent(event, parsedProps.Name, parsedProps.Desc, parsedProps.Color, parsedProps.EventDate, false, q)
+		calendar.EditEvent(event, parsedProps.Name, parsedProps.Desc, parsedProps.Color, parsedProps.EventDate, parsedProps.Location, parsedProps.Participants, parsedProps.Reminders, false, q)
 		// TODO: we might want to catch errors and display them as notifications here
 	}
 
@@ -163,15 +169,30 @@ func (event *CaldavEvent) GetDate() *types.EventDate {
 	return event.eventDate
 }
 
+func (event *CaldavEvent) GetReminders() []*types.EventReminder {
+	return event.reminders
+}
+
+func (event *CaldavEvent) GetLocation() *types.EventLocation {
+	return event.location
+}
+
+func (event *CaldavEvent) GetParticipants() *types.EventParticipants {
+	return event.participants
+}
+
 func (event *CaldavEvent) Clone() types.Event {
 	return &CaldavEvent{
-		name:       event.name,
-		desc:       event.desc,
-		color:      event.color.Clone(),
-		overridden: event.overridden,
-		settings:   event.settings.Clone(),
-		calendar:   event.calendar,
-		eventDate:  event.eventDate.Clone(),
+		name:         event.name,
+		desc:         event.desc,
+		color:        event.color.Clone(),
+		overridden:   event.overridden,
+		settings:     event.settings.Clone(),
+		calendar:     event.calendar,
+		eventDate:    event.eventDate.Clone(),
+		reminders:    types.CloneReminders(event.reminders),
+		location:     event.location.Clone(),
+		participants: event.participants.Clone(),
 	}
 }
 
@@ -180,6 +201,10 @@ func (event *CaldavEvent) SupplyMasterEvent(masterEvent types.Event) {
 	event.settings.IsFirstRecurrence = masterEvent.GetDate().Start().Equal(*event.eventDate.Start())
 }
 
+func (event *CaldavEvent) GetUid() string {
+	return event.settings.Uid
+}
+
 func (event *CaldavEvent) GetRecurrenceId() string {
 	return event.settings.RecurrenceId
 }
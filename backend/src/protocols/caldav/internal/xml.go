@@ -7,6 +7,8 @@ import (
 	"luna-backend/errors"
 	"luna-backend/net"
 	"luna-backend/types"
+	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -15,9 +17,10 @@ type prop struct {
 }
 
 type propfind struct {
-	C string `xml:"xmlns:C,attr"`
-	D string `xml:"xmlns,attr"`
-	I string `xml:"xmlns:I,attr"`
+	C  string `xml:"xmlns:C,attr"`
+	D  string `xml:"xmlns,attr"`
+	I  string `xml:"xmlns:I,attr"`
+	CS string `xml:"xmlns:CS,attr"`
 
 	XMLName xml.Name `xml:"propfind"`
 
@@ -39,6 +42,7 @@ func PropFind(baseUrl *types.Url, resourceUrl *types.Url, props []string, auth t
 		C:     "urn:ietf:params:xml:ns:caldav",
 		D:     "DAV:",
 		I:     "http://apple.com/ns/ical/",
+		CS:    "http://calendarserver.org/ns/",
 		Props: xmlProps,
 	}
 
@@ -47,15 +51,18 @@ func PropFind(baseUrl *types.Url, resourceUrl *types.Url, props []string, auth t
 
 	res := struct {
 		XMLName   xml.Name `xml:"multistatus"`
-		Propstats []struct {
-			Props []struct {
-				Field struct {
-					XMLName xml.Name
-					Value   string `xml:",innerxml"`
-				} `xml:",any"`
-			} `xml:"prop"`
-			Status string `xml:"status"`
-		} `xml:"response>propstat"`
+		Responses []struct {
+			Href      string `xml:"href"`
+			Propstats []struct {
+				Props []struct {
+					Field struct {
+						XMLName xml.Name
+						Value   string `xml:",innerxml"`
+					} `xml:",any"`
+				} `xml:"prop"`
+				Status string `xml:"status"`
+			} `xml:"propstat"`
+		} `xml:"response"`
 	}{}
 
 	tr := net.FetchXml(&fullUrl, "PROPFIND", auth, body, "application/xml", ctx, &res)
@@ -68,31 +75,47 @@ func PropFind(baseUrl *types.Url, resourceUrl *types.Url, props []string, auth t
 
 	result := make(map[string]propresult)
 
-	for _, x := range res.Propstats {
-		for _, y := range x.Props {
-			var res propresult
+	// Without a depth header, some servers also list the members of a collection.
+	// Only the properties of the requested resource itself are of interest.
+	responses := res.Responses[:0]
+	for _, response := range res.Responses {
+		if samePath(response.Href, resourceUrl.Path) {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		responses = res.Responses
+	}
 
-			if strings.Contains(x.Status, "200 OK") {
-				res = propresult{
-					Found: true,
-					Value: y.Field.Value,
-				}
-			} else {
-				res = propresult{
-					Found: true,
-					Value: "",
+	for _, response := range responses {
+		for _, x := range response.Propstats {
+			for _, y := range x.Props {
+				var res propresult
+
+				if strings.Contains(x.Status, "200 OK") {
+					res = propresult{
+						Found: true,
+						Value: y.Field.Value,
+					}
+				} else {
+					res = propresult{
+						Found: true,
+						Value: "",
+					}
 				}
-			}
 
-			result[y.Field.XMLName.Local] = res
-			result[fmt.Sprintf("%v:%v", y.Field.XMLName.Space, y.Field.XMLName.Local)] = res
-			switch y.Field.XMLName.Space {
-			case "urn:ietf:params:xml:ns:caldav":
-				result[fmt.Sprintf("C:%v", y.Field.XMLName.Local)] = res
-			case "DAV:":
-				result[fmt.Sprintf("D:%v", y.Field.XMLName.Local)] = res
-			case "http://apple.com/ns/ical/":
-				result[fmt.Sprintf("I:%v", y.Field.XMLName.Local)] = res
+				result[y.Field.XMLName.Local] = res
+				result[fmt.Sprintf("%v:%v", y.Field.XMLName.Space, y.Field.XMLName.Local)] = res
+				switch y.Field.XMLName.Space {
+				case "urn:ietf:params:xml:ns:caldav":
+					result[fmt.Sprintf("C:%v", y.Field.XMLName.Local)] = res
+				case "DAV:":
+					result[fmt.Sprintf("D:%v", y.Field.XMLName.Local)] = res
+				case "http://apple.com/ns/ical/":
+					result[fmt.Sprintf("I:%v", y.Field.XMLName.Local)] = res
+				case "http://calendarserver.org/ns/":
+					result[fmt.Sprintf("CS:%v", y.Field.XMLName.Local)] = res
+				}
 			}
 		}
 	}
@@ -100,6 +123,19 @@ func PropFind(baseUrl *types.Url, resourceUrl *types.Url, props []string, auth t
 	return result, nil
 }
 
+// Hrefs may be absolute URLs or percent-encoded paths
+func hrefPath(href string) string {
+	parsed, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return href
+	}
+	return parsed.Path
+}
+
+func samePath(href string, path string) bool {
+	return strings.TrimSuffix(hrefPath(href), "/") == strings.TrimSuffix(path, "/")
+}
+
 type comp struct {
 	XMLName xml.Name `xml:"C:comp"`
 	Name    string   `xml:"name,attr"`
@@ -203,3 +239,87 @@ func PropPatch(baseUrl *types.Url, resourceUrl *types.Url, name string, desc str
 
 	return nil
 }
+
+type synccollection struct {
+	D string `xml:"xmlns,attr"`
+
+	XMLName xml.Name `xml:"sync-collection"`
+
+	SyncToken string   `xml:"sync-token"`
+	SyncLevel string   `xml:"sync-level"`
+	Etag      struct{} `xml:"prop>getetag"`
+}
+
+type SyncResult struct {
+	Token   string
+	Changed map[string]string // href -> etag
+	Deleted []string
+}
+
+// Reports the members of a collection that changed since the given sync token, as described in RFC 6578.
+// An empty token requests the initial synchronization, which lists all members.
+func SyncCollection(baseUrl *types.Url, resourceUrl *types.Url, token string, auth types.AuthMethod, ctx context.Context) (*SyncResult, *errors.ErrorTrace) {
+	body := synccollection{
+		D:         "DAV:",
+		SyncToken: token,
+		SyncLevel: "1",
+	}
+
+	fullUrl := *baseUrl
+	fullUrl.Path = resourceUrl.Path
+
+	res := struct {
+		XMLName   xml.Name `xml:"multistatus"`
+		Responses []struct {
+			Href      string `xml:"href"`
+			Status    string `xml:"status"`
+			Propstats []struct {
+				Etag   string `xml:"prop>getetag"`
+				Status string `xml:"status"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+		SyncToken string `xml:"sync-token"`
+	}{}
+
+	tr := net.FetchXml(&fullUrl, "REPORT", auth, body, "application/xml", ctx, &res)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize collection %v at %v", resourceUrl.String(), baseUrl.String()).
+			Append(errors.LvlWordy, "Could not synchronize collection")
+	}
+
+	if res.SyncToken == "" {
+		return nil, errors.New().Status(http.StatusBadGateway).
+			Append(errors.LvlDebug, "Server did not return a new sync token").
+			Append(errors.LvlWordy, "Could not synchronize collection %v at %v", resourceUrl.String(), baseUrl.String()).
+			Append(errors.LvlWordy, "Could not synchronize collection")
+	}
+
+	result := &SyncResult{
+		Token:   res.SyncToken,
+		Changed: map[string]string{},
+		Deleted: []string{},
+	}
+
+	for _, response := range res.Responses {
+		path := hrefPath(response.Href)
+
+		// The collection itself may be reported as well
+		if samePath(path, resourceUrl.Path) {
+			continue
+		}
+
+		if strings.Contains(response.Status, "404") {
+			result.Deleted = append(result.Deleted, path)
+			continue
+		}
+
+		for _, propstat := range response.Propstats {
+			if strings.Contains(propstat.Status, "200") {
+				result.Changed[path] = propstat.Etag
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,371 @@
+package caldav
+
+import (
+	"bytes"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	supplementary_caldav "luna-backend/protocols/caldav/internal"
+	"luna-backend/types"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+// Number of objects requested per calendar-multiget report
+const multiGetBatchSize = 100
+
+// Changes between the local mirror and the remote calendar
+type syncChanges struct {
+	state   *types.CalendarSyncState
+	changed map[string]string // href -> etag
+	deleted []string
+}
+
+// Brings the local mirror of the calendar objects up to date and returns it.
+// RFC 6578 sync-collection is preferred. Servers that do not support it are compared by ctag and getetag instead.
+// If the server cannot be reached, the last mirrored state is returned.
+func (calendar *CaldavCalendar) sync(q types.DatabaseQueries) ([]*types.RemoteObjectDatabaseEntry, *errors.ErrorTrace) {
+	calendarId := calendar.GetId()
+
+	state, tr := q.GetCalendarSyncState(calendarId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	objects, tr := q.GetRemoteObjects(calendarId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	mirror := make(map[string]*types.RemoteObjectDatabaseEntry, len(objects))
+	for _, object := range objects {
+		mirror[object.Href] = object
+	}
+
+	changes, syncTr := calendar.findChanges(state, mirror)
+	var fetched []*types.RemoteObjectDatabaseEntry
+	if syncTr == nil {
+		fetched, syncTr = calendar.fetchObjects(changes.changed, q)
+	}
+
+	if syncTr != nil {
+		// Without a previous synchronization, there is nothing that we could fall back to
+		if state.LastSync == nil {
+			return nil, syncTr.
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+
+		// The stored events are still returned, but the source is not healthy
+		q.ReportSourceHealth(calendar.GetSource().GetId(), syncTr)
+		state.Status = constants.SyncStatusFailed
+		tr = q.SetCalendarSyncState(calendarId, state)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+
+		return objects, nil
+	}
+
+	for _, href := range changes.deleted {
+		delete(mirror, href)
+	}
+	stale := calendar.assignObjectIds(fetched, mirror, q)
+
+	tr = q.DeleteRemoteObjects(calendarId, append(changes.deleted, stale...))
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	tr = q.SetRemoteObjects(fetched)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	now := time.Now()
+	changes.state.Status = constants.SyncStatusOk
+	changes.state.LastSync = &now
+	tr = q.SetCalendarSyncState(calendarId, changes.state)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	for _, object := range fetched {
+		mirror[object.Href] = object
+	}
+
+	objects = make([]*types.RemoteObjectDatabaseEntry, 0, len(mirror))
+	for _, object := range mirror {
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+// The mirror holds one entry per href. Objects are stored under the ID of the event they describe,
+// so an object that moved to a different path keeps its ID. If another path already holds an object
+// with the same UID, the object is stored under its own path instead, so that neither of them is lost.
+// Returns the hrefs whose mirrored entries were stored under a different ID and have to be replaced.
+func (calendar *CaldavCalendar) assignObjectIds(fetched []*types.RemoteObjectDatabaseEntry, mirror map[string]*types.RemoteObjectDatabaseEntry, q types.DatabaseQueries) []string {
+	owners := make(map[types.ID]string, len(mirror))
+	for href, object := range mirror {
+		owners[object.Id] = href
+	}
+
+	stale := []string{}
+	for _, object := range fetched {
+		if owner, taken := owners[object.Id]; taken && owner != object.Href {
+			q.GetLogger().Warnf("calendar objects %v and %v of calendar %v share a UID, storing the latter under its path", owner, object.Href, calendar.GetId())
+			object.Id = crypto.DeriveID(calendar.GetId(), object.Href)
+		}
+
+		if previous, exists := mirror[object.Href]; exists && previous.Id != object.Id {
+			stale = append(stale, object.Href)
+			delete(owners, previous.Id)
+		}
+		owners[object.Id] = object.Href
+	}
+
+	return stale
+}
+
+func (calendar *CaldavCalendar) findChanges(state *types.CalendarSyncState, mirror map[string]*types.RemoteObjectDatabaseEntry) (*syncChanges, *errors.ErrorTrace) {
+	if state.Method != constants.SyncMethodCtag {
+		if state.Method == constants.SyncMethodCollection && state.Token != "" {
+			changes, tr := calendar.syncCollection(state.Token, mirror)
+			if tr == nil {
+				return changes, nil
+			}
+			// The token might have expired, in which case we start over
+		}
+
+		changes, tr := calendar.syncCollection("", mirror)
+		if tr == nil {
+			return changes, nil
+		}
+		// The server likely does not support sync-collection
+	}
+
+	changes, tr := calendar.compareEtags(state, mirror)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not find changed events")
+	}
+
+	return changes, nil
+}
+
+func (calendar *CaldavCalendar) syncCollection(token string, mirror map[string]*types.RemoteObjectDatabaseEntry) (*syncChanges, *errors.ErrorTrace) {
+	source := calendar.source
+
+	result, tr := supplementary_caldav.SyncCollection(source.settings.Url, calendar.settings.Url, token, source.auth, source.ctx)
+	if tr != nil {
+		return nil, tr
+	}
+
+	changes := &syncChanges{
+		state: &types.CalendarSyncState{
+			Token:  result.Token,
+			Method: constants.SyncMethodCollection,
+		},
+		changed: map[string]string{},
+		deleted: result.Deleted,
+	}
+
+	for href, etag := range result.Changed {
+		if object, exists := mirror[href]; !exists || etag == "" || object.Etag != etag {
+			changes.changed[href] = etag
+		}
+	}
+
+	// The initial synchronization lists all objects, so everything else must have been deleted
+	if token == "" {
+		for href := range mirror {
+			if _, exists := result.Changed[href]; !exists {
+				changes.deleted = append(changes.deleted, href)
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+func (calendar *CaldavCalendar) compareEtags(state *types.CalendarSyncState, mirror map[string]*types.RemoteObjectDatabaseEntry) (*syncChanges, *errors.ErrorTrace) {
+	source := calendar.source
+
+	props, tr := supplementary_caldav.PropFind(source.settings.Url, calendar.settings.Url, []string{"CS:getctag"}, source.auth, source.ctx)
+	if tr != nil {
+		return nil, tr
+	}
+
+	ctag := ""
+	if ctagProp, exists := props["CS:getctag"]; exists && ctagProp.Found {
+		ctag = strings.TrimSpace(ctagProp.Value)
+	}
+
+	changes := &syncChanges{
+		state: &types.CalendarSyncState{
+			Token:  ctag,
+			Method: constants.SyncMethodCtag,
+		},
+		changed: map[string]string{},
+		deleted: []string{},
+	}
+
+	// Nothing changed since the last synchronization
+	if ctag != "" && state.Method == constants.SyncMethodCtag && state.Token == ctag && state.LastSync != nil {
+		return changes, nil
+	}
+
+	client, tr := source.getClient()
+	if tr != nil {
+		return nil, tr
+	}
+
+	files, err := client.ReadDir(source.ctx, calendar.settings.Url.Path, false)
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "calendar", "CalDAV calendar").
+			Append(errors.LvlWordy, "Could not list calendar objects")
+	}
+
+	listed := make(map[string]bool, len(files))
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+
+		listed[file.Path] = true
+		if object, exists := mirror[file.Path]; !exists || file.ETag == "" || object.Etag != file.ETag {
+			changes.changed[file.Path] = file.ETag
+		}
+	}
+
+	for href := range mirror {
+		if !listed[href] {
+			changes.deleted = append(changes.deleted, href)
+		}
+	}
+
+	return changes, nil
+}
+
+func (calendar *CaldavCalendar) fetchObjects(changed map[string]string, q types.DatabaseQueries) ([]*types.RemoteObjectDatabaseEntry, *errors.ErrorTrace) {
+	entries := []*types.RemoteObjectDatabaseEntry{}
+	if len(changed) == 0 {
+		return entries, nil
+	}
+
+	client, tr := calendar.source.getClient()
+	if tr != nil {
+		return nil, tr
+	}
+
+	hrefs := make([]string, 0, len(changed))
+	for href := range changed {
+		hrefs = append(hrefs, href)
+	}
+
+	for i := 0; i < len(hrefs); i += multiGetBatchSize {
+		batch := hrefs[i:min(i+multiGetBatchSize, len(hrefs))]
+
+		objects, err := client.MultiGetCalendar(calendar.source.ctx, calendar.settings.Url.Path, &caldav.CalendarMultiGet{
+			Paths: batch,
+			CompRequest: caldav.CalendarCompRequest{
+				Name:     "VCALENDAR",
+				AllProps: true,
+				AllComps: true,
+			},
+		})
+		if err != nil {
+			return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "calendar", "CalDAV calendar").
+				Append(errors.LvlWordy, "Could not fetch changed calendar objects")
+		}
+
+		for _, object := range objects {
+			entry, tr := calendar.remoteObjectFromCaldav(&object, changed[object.Path])
+			if tr != nil {
+				return nil, tr.
+					Append(errors.LvlWordy, "Could not fetch changed calendar objects")
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (calendar *CaldavCalendar) remoteObjectFromCaldav(object *caldav.CalendarObject, listedEtag string) (*types.RemoteObjectDatabaseEntry, *errors.ErrorTrace) {
+	var buf bytes.Buffer
+	err := ical.NewEncoder(&buf).Encode(object.Data)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not encode calendar object %v", object.Path)
+	}
+
+	url, err := types.NewUrl(object.Path)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse URL %v", object.Path)
+	}
+
+	// Objects are stored under the same ID as the event they describe, or under their path if they have no UID
+	uid := ""
+	for _, child := range object.Data.Children {
+		if uidProp := child.Props.Get(ical.PropUID); uidProp != nil {
+			uid = uidProp.Value
+			break
+		}
+	}
+	var id types.ID
+	if uid == "" {
+		id = crypto.DeriveID(calendar.GetId(), object.Path)
+	} else {
+		id = crypto.DeriveID(calendar.GetId(), uid)
+	}
+
+	settings := &CaldavEventSettings{
+		Url: url,
+		Uid: uid,
+	}
+
+	etag := object.ETag
+	if etag == "" {
+		etag = listedEtag
+	}
+
+	return &types.RemoteObjectDatabaseEntry{
+		Id:       id,
+		Calendar: calendar.GetId(),
+		Settings: settings.Bytes(),
+		Href:     object.Path,
+		Etag:     etag,
+		Data:     buf.String(),
+	}, nil
+}
+
+func objectFromRemoteObject(entry *types.RemoteObjectDatabaseEntry) (*caldav.CalendarObject, *errors.ErrorTrace) {
+	data, err := ical.NewDecoder(strings.NewReader(entry.Data)).Decode()
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not decode mirrored calendar object %v", entry.Href)
+	}
+
+	return &caldav.CalendarObject{
+		Path: entry.Href,
+		ETag: entry.Etag,
+		Data: data,
+	}, nil
+}
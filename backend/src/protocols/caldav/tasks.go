@@ -0,0 +1,210 @@
+package caldav
+
+import (
+	"fmt"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	common "luna-backend/protocols/internal"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+type CaldavTask struct {
+	uid      string
+	props    *types.TaskProps
+	calendar *CaldavCalendar
+	rawTask  *caldav.CalendarObject
+}
+
+// Recurring tasks are rare, so modified instances (VTODOs with a RECURRENCE-ID) are not supported
+func findTaskComponent(obj *caldav.CalendarObject) *ical.Component {
+	for _, child := range obj.Data.Children {
+		if child.Name == ical.CompToDo && child.Props.Get(ical.PropRecurrenceID) == nil {
+			return child
+		}
+	}
+	return nil
+}
+
+func (calendar *CaldavCalendar) taskFromCaldav(obj *caldav.CalendarObject) (*CaldavTask, *errors.ErrorTrace) {
+	component := findTaskComponent(obj)
+	if component == nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			Append(errors.LvlDebug, "Calendar object %v does not contain a task", obj.Path).
+			Append(errors.LvlWordy, "Could not parse task")
+	}
+
+	uid, props, err := common.ParseIcalTask(component)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse iCal task %v", obj.Path).
+			Append(errors.LvlWordy, "Could not parse task")
+	}
+
+	return &CaldavTask{
+		uid:      uid,
+		props:    props,
+		calendar: calendar,
+		rawTask:  obj,
+	}, nil
+}
+
+func (task *CaldavTask) GetId() types.ID {
+	return crypto.DeriveID(task.calendar.GetId(), task.uid)
+}
+
+func (task *CaldavTask) GetCalendar() types.Calendar {
+	return task.calendar
+}
+
+func (task *CaldavTask) GetUid() string {
+	return task.uid
+}
+
+func (task *CaldavTask) CanEdit() bool {
+	return true
+}
+
+func (task *CaldavTask) CanDelete() bool {
+	return true
+}
+
+func (task *CaldavTask) GetProps() *types.TaskProps {
+	return task.props
+}
+
+func (calendar *CaldavCalendar) CanAddTasks() bool {
+	return true
+}
+
+func (calendar *CaldavCalendar) GetTasks(q types.DatabaseQueries) ([]types.Task, *errors.ErrorTrace) {
+	objects, err := calendar.client.QueryCalendar(q.GetContext(), calendar.settings.Url.Path, &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{
+			Name:     "VCALENDAR",
+			AllProps: true,
+			AllComps: true,
+		},
+		CompFilter: caldav.CompFilter{
+			Name: "VCALENDAR",
+			Comps: []caldav.CompFilter{
+				{Name: ical.CompToDo},
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "calendar", "CalDAV calendar").
+			Append(errors.LvlBroad, "Could not get tasks")
+	}
+
+	tasks := []types.Task{}
+	for i := range objects {
+		// Some servers ignore the filter and return events as well
+		if findTaskComponent(&objects[i]) == nil {
+			continue
+		}
+
+		task, tr := calendar.taskFromCaldav(&objects[i])
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlBroad, "Could not get tasks")
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+func (calendar *CaldavCalendar) GetTask(taskId types.ID, q types.DatabaseQueries) (types.Task, *errors.ErrorTrace) {
+	tasks, tr := calendar.GetTasks(q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get task")
+	}
+
+	for _, task := range tasks {
+		if task.GetId() == taskId {
+			return task, nil
+		}
+	}
+
+	return nil, errors.New().Status(http.StatusNotFound).
+		Append(errors.LvlDebug, "Task %v not found in calendar %v", taskId, calendar.GetId()).
+		AltStr(errors.LvlPlain, "Task not found").
+		Append(errors.LvlBroad, "Could not get task")
+}
+
+// Writes the calendar object and reads it back, so that changes made by the server are reflected
+func (calendar *CaldavCalendar) putTask(path string, cal *ical.Calendar, q types.DatabaseQueries) (types.Task, *errors.ErrorTrace) {
+	_, err := calendar.client.PutCalendarObject(q.GetContext(), path, cal)
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "calendar", "CalDAV calendar").
+			Append(errors.LvlWordy, "Could not upload task")
+	}
+
+	obj, err := calendar.client.GetCalendarObject(q.GetContext(), path)
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "calendar", "CalDAV calendar").
+			Append(errors.LvlWordy, "Could not get finished task")
+	}
+
+	task, tr := calendar.taskFromCaldav(obj)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not parse finished task")
+	}
+
+	return task, nil
+}
+
+func (calendar *CaldavCalendar) AddTask(props *types.TaskProps, q types.DatabaseQueries) (types.Task, *errors.ErrorTrace) {
+	id := types.RandomId()
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, common.IcalProductId)
+	cal.Props.SetText(ical.PropVersion, "2.0")
+
+	component := ical.NewComponent(ical.CompToDo)
+	common.SetIcalTask(component, id.String(), props)
+	cal.Children = append(cal.Children, component)
+
+	path := fmt.Sprintf("%v%v.ics", calendar.settings.Url.Path, id.String())
+
+	task, tr := calendar.putTask(path, cal, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not add task")
+	}
+
+	return task, nil
+}
+
+func (calendar *CaldavCalendar) EditTask(task types.Task, props *types.TaskProps, q types.DatabaseQueries) (types.Task, *errors.ErrorTrace) {
+	caldavTask := task.(*CaldavTask)
+	cal := caldavTask.rawTask.Data
+
+	common.SetIcalTask(findTaskComponent(caldavTask.rawTask), caldavTask.uid, props)
+
+	editedTask, tr := calendar.putTask(caldavTask.rawTask.Path, cal, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not edit task")
+	}
+
+	return editedTask, nil
+}
+
+func (calendar *CaldavCalendar) DeleteTask(task types.Task, q types.DatabaseQueries) *errors.ErrorTrace {
+	caldavTask := task.(*CaldavTask)
+
+	err := calendar.client.RemoveAll(q.GetContext(), caldavTask.rawTask.Path)
+	if err != nil {
+		return errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "task", "CalDAV task").
+			Append(errors.LvlBroad, "Could not delete task")
+	}
+
+	return nil
+}
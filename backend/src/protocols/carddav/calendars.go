@@ -0,0 +1,220 @@
+package carddav
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+)
+
+type CarddavCalendar struct {
+	name       string
+	desc       string
+	color      *types.Color
+	overridden bool
+	settings   *CarddavCalendarSettings
+	source     *CarddavSource
+}
+
+type CarddavCalendarSettings struct {
+	Path string `json:"path"`
+}
+
+// Only the properties needed for the events are requested
+var contactDataRequest = carddav.AddressDataRequest{
+	Props: []string{
+		vcard.FieldVersion,
+		vcard.FieldUID,
+		vcard.FieldFormattedName,
+		vcard.FieldName,
+		vcard.FieldOrganization,
+		vcard.FieldBirthday,
+		vcard.FieldAnniversary,
+	},
+}
+
+func (source *CarddavSource) calendarFromAddressBook(addressBook carddav.AddressBook) *CarddavCalendar {
+	return &CarddavCalendar{
+		name:       addressBook.Name,
+		desc:       addressBook.Description,
+		color:      nil,
+		overridden: false,
+		settings: &CarddavCalendarSettings{
+			Path: addressBook.Path,
+		},
+		source: source,
+	}
+}
+
+func (settings *CarddavCalendarSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (calendar *CarddavCalendar) GetId() types.ID {
+	return crypto.DeriveID(calendar.source.id, calendar.settings.Path)
+}
+
+func (calendar *CarddavCalendar) GetName() string {
+	return calendar.name
+}
+
+func (calendar *CarddavCalendar) SetName(name string) {
+	calendar.name = name
+}
+
+func (calendar *CarddavCalendar) GetDesc() string {
+	return calendar.desc
+}
+
+func (calendar *CarddavCalendar) SetDesc(desc string) {
+	calendar.desc = desc
+}
+
+func (calendar *CarddavCalendar) GetSource() types.Source {
+	return calendar.source
+}
+
+func (calendar *CarddavCalendar) GetSettings() types.CalendarSettings {
+	return calendar.settings
+}
+
+func (calendar *CarddavCalendar) GetColor() *types.Color {
+	if calendar.color == nil {
+		return types.ColorEmpty
+	} else {
+		return calendar.color
+	}
+}
+
+func (calendar *CarddavCalendar) SetColor(color *types.Color) {
+	calendar.color = color
+}
+
+func (calendar *CarddavCalendar) GetOverridden() bool {
+	return calendar.overridden
+}
+
+func (calendar *CarddavCalendar) SetOverridden(overridden bool) {
+	calendar.overridden = overridden
+}
+
+func (calendar *CarddavCalendar) CanEdit() bool {
+	return false
+}
+
+func (calendar *CarddavCalendar) CanDelete() bool {
+	return false
+}
+
+func (calendar *CarddavCalendar) CanAddEvents() bool {
+	return false
+}
+
+// Every event repeats yearly, so the window does not narrow down the contacts
+func (calendar *CarddavCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
+	client, tr := calendar.source.getClient()
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get events")
+	}
+
+	contacts, err := client.QueryAddressBook(q.GetContext(), calendar.settings.Path, &carddav.AddressBookQuery{
+		DataRequest: contactDataRequest,
+		PropFilters: []carddav.PropFilter{
+			{Name: vcard.FieldBirthday},
+			{Name: vcard.FieldAnniversary},
+		},
+		FilterTest: carddav.FilterAnyOf,
+	})
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "calendar", "CardDAV address book").
+			Append(errors.LvlBroad, "Could not get events")
+	}
+
+	result := []types.Event{}
+	for _, contact := range contacts {
+		for _, event := range calendar.eventsFromContact(&contact) {
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
+
+func (calendar *CarddavCalendar) GetEvent(settings types.EventSettings, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	carddavSettings := settings.(*CarddavEventSettings)
+
+	client, tr := calendar.source.getClient()
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get event")
+	}
+
+	contact, err := client.GetAddressObject(q.GetContext(), carddavSettings.Path)
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "event", "CardDAV contact").
+			Append(errors.LvlBroad, "Could not get event")
+	}
+
+	for _, event := range calendar.eventsFromContact(contact) {
+		if event.settings.Kind == carddavSettings.Kind {
+			return event, nil
+		}
+	}
+
+	return nil, errors.New().Status(http.StatusNotFound).
+		Append(errors.LvlWordy, "Event %v of contact %v not found", carddavSettings.Kind, carddavSettings.Path).
+		AltStr(errors.LvlPlain, "Event not found")
+}
+
+/* CardDAV calendar is read-only */
+
+func (calendar *CarddavCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	return nil, errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (calendar *CarddavCalendar) EditEvent(event types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, override bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	if override {
+		anyOverrides := false
+		if name != "" {
+			event.SetName(name)
+			anyOverrides = true
+		}
+		if desc != "" {
+			event.SetDesc(desc)
+			anyOverrides = true
+		}
+		if color != nil && !color.IsEmpty() {
+			event.SetColor(color)
+			anyOverrides = true
+		}
+
+		if anyOverrides {
+			q.SetEventOverrides(event.GetId(), name, desc, color)
+			return event, nil
+		} else {
+			q.DeleteEventOverrides(event.GetId())
+			return calendar.GetEvent(event.GetSettings(), q)
+		}
+	} else {
+		return nil, errors.New().Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (calendar *CarddavCalendar) DeleteEvent(event types.Event, q types.DatabaseQueries) *errors.ErrorTrace {
+	return errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (calendar *CarddavCalendar) SupplyContext(ctx context.Context) {
+	calendar.source.SupplyContext(ctx)
+}
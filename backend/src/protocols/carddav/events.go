@@ -0,0 +1,219 @@
+package carddav
+
+import (
+	"encoding/json"
+	"fmt"
+	"luna-backend/crypto"
+	common "luna-backend/protocols/internal"
+	"luna-backend/types"
+
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+)
+
+const (
+	kindBirthday    = "birthday"
+	kindAnniversary = "anniversary"
+)
+
+var contactDateFields = map[string]string{
+	kindBirthday:    vcard.FieldBirthday,
+	kindAnniversary: vcard.FieldAnniversary,
+}
+
+type CarddavEvent struct {
+	name       string
+	desc       string
+	color      *types.Color
+	overridden bool
+	settings   *CarddavEventSettings
+	calendar   *CarddavCalendar
+	eventDate  *types.EventDate
+}
+
+type CarddavEventSettings struct {
+	Path              string `json:"path"`
+	Uid               string `json:"uid"`
+	Kind              string `json:"kind"`
+	RecurrenceId      string `json:"recurrence_id"`
+	IsFirstRecurrence bool   `json:"is_first_recurrence"`
+}
+
+func (settings *CarddavEventSettings) Clone() *CarddavEventSettings {
+	return &CarddavEventSettings{
+		Path:              settings.Path,
+		Uid:               settings.Uid,
+		Kind:              settings.Kind,
+		RecurrenceId:      settings.RecurrenceId,
+		IsFirstRecurrence: settings.IsFirstRecurrence,
+	}
+}
+
+func (settings *CarddavEventSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// Returns a yearly all-day event for the birthday and the anniversary of the contact.
+// Dates that cannot be parsed are skipped, since vCards may contain free-form text instead of a date.
+func (calendar *CarddavCalendar) eventsFromContact(contact *carddav.AddressObject) []*CarddavEvent {
+	name := contactName(contact.Card)
+
+	uid := contact.Card.Value(vcard.FieldUID)
+	if uid == "" {
+		uid = contact.Path
+	}
+
+	events := []*CarddavEvent{}
+	for _, kind := range []string{kindBirthday, kindAnniversary} {
+		field := contact.Card.Get(contactDateFields[kind])
+		if field == nil {
+			continue
+		}
+
+		date, err := parseContactDate(field)
+		if err != nil {
+			continue
+		}
+
+		start := date.start()
+		recurrence, err := types.EventRecurrenceFromLines([]string{"RRULE:FREQ=YEARLY"})
+		if err != nil {
+			panic(err)
+		}
+
+		event := &CarddavEvent{
+			overridden: false,
+			settings: &CarddavEventSettings{
+				Path: contact.Path,
+				Uid:  uid + "-" + kind,
+				Kind: kind,
+			},
+			calendar:  calendar,
+			eventDate: types.NewEventDateFromSingleDay(&start, recurrence),
+		}
+
+		switch kind {
+		case kindBirthday:
+			event.name = fmt.Sprintf("Birthday of %v", name)
+			if date.year != 0 {
+				event.desc = fmt.Sprintf("Born in %v", date.year)
+			}
+		case kindAnniversary:
+			event.name = fmt.Sprintf("Anniversary of %v", name)
+			if date.year != 0 {
+				event.desc = fmt.Sprintf("Since %v", date.year)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func (event *CarddavEvent) GetId() types.ID {
+	masterEventId := crypto.DeriveID(event.calendar.GetId(), event.settings.Uid)
+
+	if event.settings.RecurrenceId == "" || event.settings.IsFirstRecurrence {
+		return masterEventId
+	}
+
+	return crypto.DeriveID(masterEventId, event.settings.RecurrenceId)
+}
+
+func (event *CarddavEvent) GetName() string {
+	return event.name
+}
+
+func (event *CarddavEvent) SetName(name string) {
+	event.name = name
+}
+
+func (event *CarddavEvent) GetDesc() string {
+	return event.desc
+}
+
+func (event *CarddavEvent) SetDesc(desc string) {
+	event.desc = desc
+}
+
+func (event *CarddavEvent) GetCalendar() types.Calendar {
+	return event.calendar
+}
+
+func (event *CarddavEvent) GetSettings() types.EventSettings {
+	return event.settings
+}
+
+func (event *CarddavEvent) GetColor() *types.Color {
+	if event.color == nil {
+		return event.calendar.GetColor()
+	} else {
+		return event.color
+	}
+}
+
+func (event *CarddavEvent) SetColor(color *types.Color) {
+	event.color = color
+}
+
+func (event *CarddavEvent) GetOverridden() bool {
+	return event.overridden
+}
+
+func (event *CarddavEvent) SetOverridden(overridden bool) {
+	event.overridden = overridden
+}
+
+func (event *CarddavEvent) GetDate() *types.EventDate {
+	return event.eventDate
+}
+
+func (event *CarddavEvent) GetReminders() []*types.EventReminder {
+	return []*types.EventReminder{}
+}
+
+func (event *CarddavEvent) GetLocation() *types.EventLocation {
+	return nil
+}
+
+func (event *CarddavEvent) GetParticipants() *types.EventParticipants {
+	return nil
+}
+
+func (event *CarddavEvent) Clone() types.Event {
+	return &CarddavEvent{
+		name:       event.name,
+		desc:       event.desc,
+		color:      event.color.Clone(),
+		overridden: event.overridden,
+		settings:   event.settings.Clone(),
+		calendar:   event.calendar,
+		eventDate:  event.eventDate.Clone(),
+	}
+}
+
+func (event *CarddavEvent) SupplyMasterEvent(masterEvent types.Event) {
+	event.settings.RecurrenceId = common.CalculateRecurrenceId(event.eventDate.Start(), event.eventDate.AllDay())
+	event.settings.IsFirstRecurrence = masterEvent.GetDate().Start().Equal(*event.eventDate.Start())
+}
+
+func (event *CarddavEvent) GetUid() string {
+	return event.settings.Uid
+}
+
+func (event *CarddavEvent) GetRecurrenceId() string {
+	return event.settings.RecurrenceId
+}
+
+func (event *CarddavEvent) CanEdit() bool {
+	return false
+}
+
+func (event *CarddavEvent) CanDelete() bool {
+	return false
+}
@@ -0,0 +1,187 @@
+package carddav
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/emersion/go-webdav/carddav"
+)
+
+// Shows the birthdays and anniversaries of contacts as read-only calendars, one for each address book
+type CarddavSource struct {
+	id       types.ID
+	name     string
+	settings *CarddavSourceSettings
+	auth     types.AuthMethod
+	client   *carddav.Client `json:"-"`
+}
+
+type CarddavSourceSettings struct {
+	Url *types.Url `json:"url"`
+}
+
+func (settings *CarddavSourceSettings) GetBytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (source *CarddavSource) GetType() string {
+	return constants.SourceCarddav
+}
+
+func (source *CarddavSource) GetId() types.ID {
+	return source.id
+}
+
+func (source *CarddavSource) GetName() string {
+	return source.name
+}
+
+func (source *CarddavSource) GetAuth() types.AuthMethod {
+	return source.auth
+}
+
+func (source *CarddavSource) GetSettings() types.SourceSettings {
+	return source.settings
+}
+
+func (source *CarddavSource) CanAddCalendars() bool {
+	return false
+}
+
+func NewCarddavSource(name string, url *types.Url, auth types.AuthMethod) *CarddavSource {
+	return &CarddavSource{
+		id:   types.EmptyId(), // Placeholder until the database assigns an ID
+		name: name,
+		auth: auth,
+		settings: &CarddavSourceSettings{
+			Url: url,
+		},
+	}
+}
+
+func PackCarddavSource(id types.ID, name string, settings *CarddavSourceSettings, auth types.AuthMethod) *CarddavSource {
+	return &CarddavSource{
+		id:       id,
+		name:     name,
+		settings: settings,
+		auth:     auth,
+	}
+}
+
+func (source *CarddavSource) getClient() (*carddav.Client, *errors.ErrorTrace) {
+	if source.client == nil {
+		var err error
+		source.client, err = carddav.NewClient(
+			source.auth.HttpClient(),
+			source.settings.Url.URL().String(),
+		)
+
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlWordy, "Could not create CardDAV client")
+		}
+	}
+	return source.client, nil
+}
+
+// The URL may point to an address book home set or to a single address book
+func (source *CarddavSource) GetCalendars(q types.DatabaseQueries) ([]types.Calendar, *errors.ErrorTrace) {
+	client, tr := source.getClient()
+	if tr != nil {
+		return nil, tr
+	}
+
+	addressBooks, err := client.FindAddressBooks(q.GetContext(), "")
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "source", "CardDAV source").
+			Append(errors.LvlBroad, "Could not get calendars")
+	}
+
+	result := make([]types.Calendar, len(addressBooks))
+	for i, addressBook := range addressBooks {
+		result[i] = source.calendarFromAddressBook(addressBook)
+	}
+
+	return result, nil
+}
+
+func (source *CarddavSource) GetCalendar(settings types.CalendarSettings, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	carddavSettings := settings.(*CarddavCalendarSettings)
+
+	client, tr := source.getClient()
+	if tr != nil {
+		return nil, tr
+	}
+
+	addressBooks, err := client.FindAddressBooks(q.GetContext(), carddavSettings.Path)
+	if err != nil {
+		return nil, errors.InterpretRemoteError(errors.New().AddErr(errors.LvlDebug, err), "source", "CardDAV source").
+			Append(errors.LvlBroad, "Could not get calendar")
+	}
+
+	for _, addressBook := range addressBooks {
+		if addressBook.Path == carddavSettings.Path {
+			return source.calendarFromAddressBook(addressBook), nil
+		}
+	}
+
+	return nil, errors.New().Status(http.StatusNotFound).
+		Append(errors.LvlBroad, "Calendar not found").
+		AltStr(errors.LvlBroad, "Could not get calendar")
+}
+
+/* CardDAV source is read-only */
+
+func (source *CarddavSource) AddCalendar(name string, desc string, color *types.Color, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	return nil, errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (source *CarddavSource) EditCalendar(calendar types.Calendar, name string, desc string, color *types.Color, override bool, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	if override {
+		anyOverrides := false
+		if name != "" {
+			calendar.SetName(name)
+			anyOverrides = true
+		}
+		if desc != "" {
+			calendar.SetDesc(desc)
+			anyOverrides = true
+		}
+		if color != nil && !color.IsEmpty() {
+			calendar.SetColor(color)
+			anyOverrides = true
+		}
+
+		if anyOverrides {
+			q.SetCalendarOverrides(calendar.GetId(), name, desc, color)
+			return calendar, nil
+		} else {
+			q.DeleteCalendarOverrides(calendar.GetId())
+			return source.GetCalendar(calendar.GetSettings(), q)
+		}
+	} else {
+		return nil, errors.New().Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (source *CarddavSource) DeleteCalendar(calendar types.Calendar, q types.DatabaseQueries) *errors.ErrorTrace {
+	return errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (source *CarddavSource) Cleanup(_ types.DatabaseQueries) *errors.ErrorTrace { return nil }
+
+func (source *CarddavSource) SupplyContext(ctx context.Context) {
+	if source.auth.GetType() == constants.AuthOauth {
+		source.auth.(*auth.OauthAuth).SupplyContext(ctx)
+	}
+}
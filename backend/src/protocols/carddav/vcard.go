@@ -0,0 +1,100 @@
+package carddav
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
+)
+
+// Year that dates without a year are placed in, chosen as a leap year so that birthdays on February 29 still exist
+const yearlessYear = 2000
+
+type contactDate struct {
+	year  int // 0 if unknown
+	month time.Month
+	day   int
+}
+
+// Parses the dates that vCard 3 and 4 allow for BDAY and ANNIVERSARY, e.g. "1985-04-12", "19850412" and "--0412".
+// Apple stores dates without a year as "1604-04-12;X-APPLE-OMIT-YEAR=1604".
+// Dates given as text, e.g. "circa 1800", cannot be shown and return an error.
+func parseContactDate(field *vcard.Field) (*contactDate, error) {
+	if strings.EqualFold(field.Params.Get(vcard.ParamValue), "text") {
+		return nil, fmt.Errorf("date %v is given as text", field.Value)
+	}
+
+	value, _, _ := strings.Cut(strings.TrimSpace(field.Value), "T")
+
+	yearless := strings.HasPrefix(value, "--")
+	digits := strings.ReplaceAll(strings.TrimPrefix(value, "--"), "-", "")
+
+	date := &contactDate{}
+	var err error
+	switch {
+	case yearless && len(digits) == 4:
+		date.month, date.day, err = parseMonthDay(digits)
+	case !yearless && len(digits) == 8:
+		date.year, err = strconv.Atoi(digits[:4])
+		if err == nil {
+			date.month, date.day, err = parseMonthDay(digits[4:])
+		}
+	default:
+		err = fmt.Errorf("unsupported date format")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse date %v: %v", field.Value, err)
+	}
+
+	if omitYear := field.Params.Get("X-APPLE-OMIT-YEAR"); omitYear != "" && omitYear == strconv.Itoa(date.year) {
+		date.year = 0
+	}
+
+	return date, nil
+}
+
+func parseMonthDay(digits string) (time.Month, int, error) {
+	month, err := strconv.Atoi(digits[:2])
+	if err != nil {
+		return 0, 0, err
+	}
+	day, err := strconv.Atoi(digits[2:])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// time.Date normalizes invalid dates, which we can detect by comparing the result
+	normalized := time.Date(yearlessYear, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if normalized.Month() != time.Month(month) || normalized.Day() != day {
+		return 0, 0, fmt.Errorf("invalid month %v or day %v", month, day)
+	}
+
+	return time.Month(month), day, nil
+}
+
+// The first occurrence of the yearly event, which is the original date if the year is known
+func (date *contactDate) start() time.Time {
+	year := date.year
+	if year == 0 {
+		year = yearlessYear
+	}
+	return time.Date(year, date.month, date.day, 0, 0, 0, 0, time.UTC)
+}
+
+func contactName(card vcard.Card) string {
+	if name := card.PreferredValue(vcard.FieldFormattedName); name != "" {
+		return name
+	}
+	if name := card.Name(); name != nil {
+		full := strings.TrimSpace(name.GivenName + " " + name.FamilyName)
+		if full != "" {
+			return full
+		}
+	}
+	if organization := card.PreferredValue(vcard.FieldOrganization); organization != "" {
+		return organization
+	}
+	return "Unknown contact"
+}
@@ -20,6 +20,8 @@ type GoogleCalendar struct {
 	settings   *GoogleCalendarSettings
 	source     *GoogleSource
 	primary    bool
+
+	defaultReminders []google.ReminderOverride
 }
 
 type GoogleCalendarSettings struct {
@@ -63,6 +65,8 @@ func (source *GoogleSource) calendarFromGoogle(calListEntry *google.CalendarList
 		settings:   settings,
 		source:     source,
 		primary:    calListEntry.Primary,
+
+		defaultReminders: calListEntry.DefaultReminders,
 	}
 
 	return calendar, nil
@@ -141,27 +145,18 @@ func (calendar *GoogleCalendar) CanAddEvents() bool {
 }
 
 func (calendar *GoogleCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
-	var res struct {
-		Items []*google.Event `json:"items"`
-	}
-
-	url := google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events")
-	query := url.Query()
-	query.Set("timeMin", start.Format(time.RFC3339))
-	query.Set("timeMax", end.Format(time.RFC3339))
-	url.SetQuery(query)
-
-	tr := net.FetchJson(url, "GET", calendar.source.auth, nil, "", q.GetContext(), &res)
+	items, tr := calendar.sync(q)
 	if tr != nil {
-		return nil, tr
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get events")
 	}
 
 	cancelledInstances := make(map[string][]*google.Event)
 	modifiedInstances := make(map[string][]*google.Event)
 
-	result := make([]types.Event, len(res.Items))
+	result := make([]types.Event, len(items))
 	eventCount := 0
-	for _, event := range res.Items {
+	for _, event := range items {
 		// Instead of adding an exception to the RRULE,
 		// Google calendar returns an additional "copy" of the event with status set to "cancelled".
 		// To get actual instances, we would either have to call the instances endpoint for every recurring event,
@@ -193,6 +188,12 @@ func (calendar *GoogleCalendar) GetEvents(start time.Time, end time.Time, q type
 				Append(errors.LvlBroad, "Could not get events")
 		}
 
+		// Events are stored regardless of the requested window, so we filter them here
+		date := converted.GetDate()
+		if !date.Recurrence().Repeats() && (!date.Start().Before(end) || date.End().Before(start)) {
+			continue
+		}
+
 		casted := (types.Event)(converted)
 
 		result[eventCount] = casted
@@ -253,7 +254,7 @@ func (calendar *GoogleCalendar) GetEvent(settings types.EventSettings, q types.D
 	return casted, nil
 }
 
-func (calendar *GoogleCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+func (calendar *GoogleCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
 	var tr *errors.ErrorTrace
 
 	var colId string
@@ -302,9 +303,14 @@ func (calendar *GoogleCalendar) AddEvent(name string, desc string, color *types.
 		Start:       start,
 		End:         end,
 		Recurrence:  recurrence,
+		Location:    locationToGoogle(location),
+		Reminders:   remindersToGoogle(reminders, date),
+	}
+	if !participants.IsEmpty() {
+		event.Attendees = participantsToGoogle(participants)
 	}
 
-	url := google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events")
+	url := withSendUpdates(google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events"))
 
 	var res google.Event
 
@@ -327,7 +333,7 @@ func (calendar *GoogleCalendar) AddEvent(name string, desc string, color *types.
 	return casted, nil
 }
 
-func (calendar *GoogleCalendar) EditEvent(originalEvent types.Event, name string, desc string, color *types.Color, date *types.EventDate, _ bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+func (calendar *GoogleCalendar) EditEvent(originalEvent types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, _ bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
 	var tr *errors.ErrorTrace
 
 	var colId string
@@ -376,9 +382,21 @@ func (calendar *GoogleCalendar) EditEvent(originalEvent types.Event, name string
 		Start:       start,
 		End:         end,
 		Recurrence:  recurrence,
+		Location:    locationToGoogle(location),
+	}
+
+	// Events that use the calendar's default reminders keep doing so unless the reminders were changed
+	originalGoogleEvent := originalEvent.(*GoogleEvent)
+	if !originalGoogleEvent.defaultReminders || !types.RemindersEqual(reminders, originalGoogleEvent.reminders) {
+		event.Reminders = remindersToGoogle(reminders, date)
 	}
 
-	url := google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events", originalEvent.GetSettings().(*GoogleEventSettings).GoogleId)
+	// Attendees are only sent if they changed, since Google keeps details like comments that Luna does not know about
+	if !participants.Equal(originalGoogleEvent.participants) {
+		event.Attendees = participantsToGoogle(participants)
+	}
+
+	url := withSendUpdates(google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events", originalEvent.GetSettings().(*GoogleEventSettings).GoogleId))
 
 	var res google.Event
 
@@ -404,7 +422,7 @@ func (calendar *GoogleCalendar) EditEvent(originalEvent types.Event, name string
 func (calendar *GoogleCalendar) DeleteEvent(event types.Event, q types.DatabaseQueries) *errors.ErrorTrace {
 	googleSettings := event.GetSettings().(*GoogleEventSettings)
 
-	url := google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events", googleSettings.GoogleId)
+	url := withSendUpdates(google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events", googleSettings.GoogleId))
 
 	_, tr := net.FetchBytes(url, "DELETE", calendar.source.auth, nil, "", "", q.GetContext())
 	if tr != nil {
@@ -417,3 +435,19 @@ func (calendar *GoogleCalendar) DeleteEvent(event types.Event, q types.DatabaseQ
 func (calendar *GoogleCalendar) SupplyContext(ctx context.Context) {
 	calendar.source.SupplyContext(ctx)
 }
+
+// Google delivers invitations, cancellations and replies itself, but only if asked to
+func withSendUpdates(url *types.Url) *types.Url {
+	query := url.Query()
+	query.Set("sendUpdates", "all")
+	return url.SetQuery(query)
+}
+
+// Google only stores the name of a location.
+// Links to video conferences are created by Google itself and coordinates are not supported at all.
+func locationToGoogle(location *types.EventLocation) string {
+	if location == nil {
+		return ""
+	}
+	return location.Name
+}
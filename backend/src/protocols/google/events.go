@@ -12,13 +12,18 @@ import (
 )
 
 type GoogleEvent struct {
-	name       string
-	desc       string
-	color      *types.Color
-	overridden bool
-	settings   *GoogleEventSettings
-	calendar   *GoogleCalendar
-	eventDate  *types.EventDate
+	name         string
+	desc         string
+	color        *types.Color
+	overridden   bool
+	settings     *GoogleEventSettings
+	calendar     *GoogleCalendar
+	eventDate    *types.EventDate
+	reminders    []*types.EventReminder
+	location     *types.EventLocation
+	participants *types.EventParticipants
+
+	defaultReminders bool
 }
 
 type GoogleEventSettings struct {
@@ -98,14 +103,34 @@ func (calendar *GoogleCalendar) eventFromGoogle(googleEvent *google.Event, q typ
 	eventDate := types.NewEventDateFromEndTime(startTime, endTime, allDay, recurrence)
 	eventDate.SetTimezone(timezone)
 
+	reminders, defaultReminders := calendar.remindersFromGoogle(googleEvent.Reminders)
+
+	location := &types.EventLocation{
+		Name: googleEvent.Location,
+	}
+	if conferenceUri := googleEvent.ConferenceUri(); conferenceUri != "" {
+		url, err := types.NewUrl(conferenceUri)
+		if err == nil {
+			location.Url = url
+		}
+	}
+	if location.IsEmpty() {
+		location = nil
+	}
+
 	event := &GoogleEvent{
-		name:       googleEvent.Name,
-		desc:       googleEvent.Description,
-		color:      col,
-		overridden: false,
-		settings:   settings.Clone(),
-		calendar:   calendar,
-		eventDate:  eventDate,
+		name:         googleEvent.Name,
+		desc:         googleEvent.Description,
+		color:        col,
+		overridden:   false,
+		settings:     settings.Clone(),
+		calendar:     calendar,
+		eventDate:    eventDate,
+		reminders:    reminders,
+		location:     location,
+		participants: participantsFromGoogle(googleEvent.Organizer, googleEvent.Attendees),
+
+		defaultReminders: defaultReminders,
 	}
 
 	return event, nil
@@ -181,15 +206,32 @@ func (event *GoogleEvent) GetDate() *types.EventDate {
 	return event.eventDate
 }
 
+func (event *GoogleEvent) GetReminders() []*types.EventReminder {
+	return event.reminders
+}
+
+func (event *GoogleEvent) GetLocation() *types.EventLocation {
+	return event.location
+}
+
+func (event *GoogleEvent) GetParticipants() *types.EventParticipants {
+	return event.participants
+}
+
 func (event *GoogleEvent) Clone() types.Event {
 	return &GoogleEvent{
-		name:       event.name,
-		desc:       event.desc,
-		color:      event.color.Clone(),
-		overridden: event.overridden,
-		settings:   event.settings.Clone(),
-		calendar:   event.calendar,
-		eventDate:  event.eventDate.Clone(),
+		name:         event.name,
+		desc:         event.desc,
+		color:        event.color.Clone(),
+		overridden:   event.overridden,
+		settings:     event.settings.Clone(),
+		calendar:     event.calendar,
+		eventDate:    event.eventDate.Clone(),
+		reminders:    types.CloneReminders(event.reminders),
+		location:     event.location.Clone(),
+		participants: event.participants.Clone(),
+
+		defaultReminders: event.defaultReminders,
 	}
 }
 
@@ -200,6 +242,10 @@ func (event *GoogleEvent) SupplyMasterEvent(masterEvent types.Event) {
 	event.settings.GoogleId = ""
 }
 
+func (event *GoogleEvent) GetUid() string {
+	return event.settings.Uid
+}
+
 func (event *GoogleEvent) GetRecurrenceId() string {
 	return event.settings.RecurrenceId
 }
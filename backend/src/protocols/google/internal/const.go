@@ -2,6 +2,12 @@ package google
 
 import "luna-backend/types"
 
+var apiUrl = "https://www.googleapis.com/calendar/v3"
+
 func ApiUrl() *types.Url {
-	return types.NewUrlSafe("https://www.googleapis.com/calendar/v3")
+	return types.NewUrlSafe(apiUrl)
+}
+
+func SetApiUrl(url *types.Url) {
+	apiUrl = url.String()
 }
@@ -22,6 +22,16 @@ type CalendarListEntry struct {
 	BackgroundColor string `json:"backgroundColor,omitempty"`
 	ForegroundColor string `json:"foregroundColor,omitempty"`
 	Primary         bool   `json:"primary,omitempty"`
+
+	DefaultReminders []ReminderOverride `json:"defaultReminders,omitempty"`
+}
+
+// Single page of the events list.
+// The sync token is only included on the last page.
+type Events struct {
+	Items         []*Event `json:"items"`
+	NextPageToken string   `json:"nextPageToken,omitempty"`
+	NextSyncToken string   `json:"nextSyncToken,omitempty"`
 }
 
 type ColorDefinition struct {
@@ -49,17 +59,62 @@ func (timeDef *TimeDefinition) String() string {
 }
 
 type Event struct {
-	Id                string         `json:"id,omitempty"`
-	Name              string         `json:"summary"`
-	Description       string         `json:"description,omitempty"`
-	ColorId           string         `json:"colorId,omitempty"`
-	Start             TimeDefinition `json:"start"`
-	End               TimeDefinition `json:"end"`
-	Recurrence        []string       `json:"recurrence,omitempty"`
-	IcalUid           string         `json:"icalUid,omitempty"`
-	RecurringEventId  string         `json:"recurringEventId,omitempty"`
-	Status            string         `json:"status,omitempty"`
-	OriginalStartTime TimeDefinition `json:"originalStartTime,omitempty"`
+	Id                string          `json:"id,omitempty"`
+	Etag              string          `json:"etag,omitempty"`
+	Name              string          `json:"summary"`
+	Description       string          `json:"description,omitempty"`
+	ColorId           string          `json:"colorId,omitempty"`
+	Start             TimeDefinition  `json:"start"`
+	End               TimeDefinition  `json:"end"`
+	Recurrence        []string        `json:"recurrence,omitempty"`
+	IcalUid           string          `json:"icalUid,omitempty"`
+	RecurringEventId  string          `json:"recurringEventId,omitempty"`
+	Status            string          `json:"status,omitempty"`
+	OriginalStartTime TimeDefinition  `json:"originalStartTime,omitempty"`
+	Reminders         *Reminders      `json:"reminders,omitempty"`
+	Location          string          `json:"location"` // always sent, since an omitted location would be left unchanged by a PATCH
+	HangoutLink       string          `json:"hangoutLink,omitempty"`
+	ConferenceData    *ConferenceData `json:"conferenceData,omitempty"`
+	Organizer         *Organizer      `json:"organizer,omitempty"`
+	Attendees         *[]Attendee     `json:"attendees,omitempty"` // a pointer, so that an empty list can be sent to remove all attendees
+}
+
+// The organizer can only be changed by moving the event to another calendar, so it is never sent back
+type Organizer struct {
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	Self        bool   `json:"self,omitempty"`
+}
+
+type Attendee struct {
+	Email          string `json:"email"`
+	DisplayName    string `json:"displayName,omitempty"`
+	Optional       bool   `json:"optional,omitempty"`
+	ResponseStatus string `json:"responseStatus,omitempty"` // "needsAction", "declined", "tentative" or "accepted"
+	Organizer      bool   `json:"organizer,omitempty"`
+	Self           bool   `json:"self,omitempty"`
+}
+
+// Conferences can only be created through Google, so they are never sent back
+type ConferenceData struct {
+	EntryPoints []ConferenceEntryPoint `json:"entryPoints,omitempty"`
+}
+
+type ConferenceEntryPoint struct {
+	EntryPointType string `json:"entryPointType"` // "video", "phone", "sip" or "more"
+	Uri            string `json:"uri"`
+}
+
+// If the default reminders are used, the overrides must be empty.
+// The overrides are always sent, since an omitted list would be left unchanged by a PATCH.
+type Reminders struct {
+	UseDefault bool               `json:"useDefault"`
+	Overrides  []ReminderOverride `json:"overrides"`
+}
+
+type ReminderOverride struct {
+	Method  string `json:"method"` // "email" or "popup"
+	Minutes int    `json:"minutes"`
 }
 
 func (timeDefinition *TimeDefinition) ParseTimeDefinition() (*time.Time, *time.Location, bool, *errors.ErrorTrace) {
@@ -95,3 +150,15 @@ func (timeDefinition *TimeDefinition) ParseTimeDefinition() (*time.Time, *time.L
 
 	return &parsedTime, timezone, allDay, nil
 }
+
+// The link to the video conference of the event, if any
+func (event *Event) ConferenceUri() string {
+	if event.ConferenceData != nil {
+		for _, entryPoint := range event.ConferenceData.EntryPoints {
+			if entryPoint.EntryPointType == "video" && entryPoint.Uri != "" {
+				return entryPoint.Uri
+			}
+		}
+	}
+	return event.HangoutLink
+}
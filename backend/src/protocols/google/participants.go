@@ -0,0 +1,86 @@
+package google
+
+import (
+	"luna-backend/constants"
+	google "luna-backend/protocols/google/internal"
+	"luna-backend/types"
+)
+
+var googleResponseStatuses = map[string]string{
+	"needsAction": constants.ParticipationNeedsAction,
+	"accepted":    constants.ParticipationAccepted,
+	"declined":    constants.ParticipationDeclined,
+	"tentative":   constants.ParticipationTentative,
+}
+
+// Google only distinguishes between required and optional attendees and always expects a response,
+// so every attendee except the organizer is asked to reply.
+func participantsFromGoogle(organizer *google.Organizer, attendees *[]google.Attendee) *types.EventParticipants {
+	participants := &types.EventParticipants{
+		Attendees: []*types.EventAttendee{},
+	}
+
+	if organizer != nil && organizer.Email != "" {
+		participants.Organizer = &types.EventOrganizer{
+			Email: organizer.Email,
+			Name:  organizer.DisplayName,
+		}
+	}
+
+	if attendees != nil {
+		for _, attendee := range *attendees {
+			if attendee.Email == "" {
+				continue
+			}
+
+			role := constants.AttendeeRoleRequired
+			if attendee.Optional {
+				role = constants.AttendeeRoleOptional
+			}
+			status, known := googleResponseStatuses[attendee.ResponseStatus]
+			if !known {
+				status = constants.ParticipationNeedsAction
+			}
+
+			participants.Attendees = append(participants.Attendees, &types.EventAttendee{
+				Email:  attendee.Email,
+				Name:   attendee.DisplayName,
+				Role:   role,
+				Status: status,
+				Rsvp:   !attendee.Organizer,
+				Self:   attendee.Self,
+			})
+		}
+	}
+
+	if participants.IsEmpty() {
+		return nil
+	}
+	return participants
+}
+
+// Delegation is not supported by Google, so delegated attendees are reset to needing an action.
+// The organizer and self flags are read-only and therefore not sent.
+func participantsToGoogle(participants *types.EventParticipants) *[]google.Attendee {
+	attendees := []google.Attendee{}
+	if participants == nil {
+		return &attendees
+	}
+
+	for _, attendee := range participants.Attendees {
+		responseStatus := "needsAction"
+		for googleStatus, status := range googleResponseStatuses {
+			if status == attendee.Status {
+				responseStatus = googleStatus
+			}
+		}
+
+		attendees = append(attendees, google.Attendee{
+			Email:          attendee.Email,
+			DisplayName:    attendee.Name,
+			Optional:       attendee.Role == constants.AttendeeRoleOptional || attendee.Role == constants.AttendeeRoleNonParticipant,
+			ResponseStatus: responseStatus,
+		})
+	}
+	return &attendees
+}
@@ -0,0 +1,60 @@
+package google
+
+import (
+	"luna-backend/constants"
+	google "luna-backend/protocols/google/internal"
+	"luna-backend/types"
+	"time"
+)
+
+// Google does not accept reminders more than four weeks before the event
+const maxReminderMinutes = 40320
+
+// Converts the reminders of an event, resolving the calendar's default reminders if the event uses them.
+// The second return value reports whether the defaults were used.
+func (calendar *GoogleCalendar) remindersFromGoogle(reminders *google.Reminders) ([]*types.EventReminder, bool) {
+	overrides := calendar.defaultReminders
+	useDefault := reminders == nil || reminders.UseDefault
+	if !useDefault {
+		overrides = reminders.Overrides
+	}
+
+	converted := make([]*types.EventReminder, 0, len(overrides))
+	for _, override := range overrides {
+		action := constants.ReminderActionDisplay
+		if override.Method == "email" {
+			action = constants.ReminderActionEmail
+		}
+		converted = append(converted, types.NewRelativeReminder(action, -time.Duration(override.Minutes)*time.Minute, false))
+	}
+
+	return converted, useDefault
+}
+
+// Google only supports reminders given in minutes before the start of the event.
+// Other reminders are converted relative to the start, and reminders that would fire after the start are dropped.
+func remindersToGoogle(reminders []*types.EventReminder, date *types.EventDate) *google.Reminders {
+	overrides := []google.ReminderOverride{}
+
+	for _, reminder := range reminders {
+		minutes := int(date.Start().Sub(reminder.TriggerTime(date)) / time.Minute)
+		if minutes < 0 || minutes > maxReminderMinutes {
+			continue
+		}
+
+		method := "popup"
+		if reminder.Action() == constants.ReminderActionEmail {
+			method = "email"
+		}
+
+		overrides = append(overrides, google.ReminderOverride{
+			Method:  method,
+			Minutes: minutes,
+		})
+	}
+
+	return &google.Reminders{
+		UseDefault: false,
+		Overrides:  overrides,
+	}
+}
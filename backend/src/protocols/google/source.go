@@ -31,6 +31,11 @@ func (settings *GoogleSourceSettings) GetBytes() []byte {
 	return bytes
 }
 
+// Points all requests at a different implementation of the Calendar v3 API, e.g. a local stand-in for testing
+func SetApiUrl(url *types.Url) {
+	google.SetApiUrl(url)
+}
+
 func (source *GoogleSource) fetchColors(q types.DatabaseQueries) *errors.ErrorTrace {
 	if source.colors == nil {
 		var res google.Colors
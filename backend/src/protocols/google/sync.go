@@ -0,0 +1,212 @@
+package google
+
+import (
+	"encoding/json"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/net"
+	google "luna-backend/protocols/google/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+// Largest page size that the events list accepts
+const eventsPageSize = "2500"
+
+// Brings the locally stored events up to date and returns them.
+// After the first full synchronization, only the changes since the last sync token are requested.
+// If Google cannot be reached or is rate-limiting us, the stored events are returned.
+func (calendar *GoogleCalendar) sync(q types.DatabaseQueries) ([]*google.Event, *errors.ErrorTrace) {
+	calendarId := calendar.GetId()
+
+	state, tr := q.GetCalendarSyncState(calendarId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	objects, tr := q.GetRemoteObjects(calendarId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	stored := make(map[string]*google.Event, len(objects))
+	for _, object := range objects {
+		event := &google.Event{}
+		err := json.Unmarshal([]byte(object.Data), event)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal stored event %v", object.Href).
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+		stored[object.Href] = event
+	}
+
+	fullSync := state.Method != constants.SyncMethodSyncToken || state.Token == ""
+
+	var changed []*google.Event
+	var syncToken string
+	if !fullSync {
+		changed, syncToken, tr = calendar.listEvents(state.Token, q)
+		// The sync token expired, so Google asks us to start over
+		if tr != nil && tr.GetStatus() == http.StatusGone {
+			fullSync = true
+		}
+	}
+	if fullSync {
+		changed, syncToken, tr = calendar.listEvents("", q)
+	}
+
+	if tr != nil {
+		// Without a previous synchronization, there is nothing that we could fall back to
+		if state.LastSync == nil {
+			return nil, tr.
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+
+		// The stored events are still returned, but the source is not healthy
+		q.ReportSourceHealth(calendar.GetSource().GetId(), tr)
+		state.Status = constants.SyncStatusFailed
+		tr = q.SetCalendarSyncState(calendarId, state)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+
+		return mapValues(stored), nil
+	}
+
+	deleted := []string{}
+	updated := map[string]*types.RemoteObjectDatabaseEntry{}
+
+	if fullSync {
+		seen := make(map[string]bool, len(changed))
+		for _, event := range changed {
+			seen[event.Id] = true
+		}
+		for id := range stored {
+			if !seen[id] {
+				deleted = append(deleted, id)
+				delete(stored, id)
+			}
+		}
+	}
+
+	for _, event := range changed {
+		// Deleted events are returned as cancelled.
+		// Cancelled instances of recurring events are kept, since they are the recurrence exceptions.
+		if event.Status == "cancelled" && event.RecurringEventId == "" {
+			deleted = append(deleted, event.Id)
+			delete(stored, event.Id)
+			delete(updated, event.Id)
+			continue
+		}
+
+		entry, tr := calendar.remoteObjectFromGoogle(event)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+
+		updated[event.Id] = entry
+		stored[event.Id] = event
+	}
+
+	tr = q.DeleteRemoteObjects(calendarId, deleted)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	tr = q.SetRemoteObjects(mapValues(updated))
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	now := time.Now()
+	tr = q.SetCalendarSyncState(calendarId, &types.CalendarSyncState{
+		Token:    syncToken,
+		Method:   constants.SyncMethodSyncToken,
+		Status:   constants.SyncStatusOk,
+		LastSync: &now,
+	})
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	return mapValues(stored), nil
+}
+
+// Lists all events that changed since the sync token, or all events if no token is given
+func (calendar *GoogleCalendar) listEvents(syncToken string, q types.DatabaseQueries) ([]*google.Event, string, *errors.ErrorTrace) {
+	events := []*google.Event{}
+
+	pageToken := ""
+	for {
+		url := google.ApiUrl().Subpage("calendars", calendar.settings.GoogleId, "events")
+		query := url.Query()
+		query.Set("maxResults", eventsPageSize)
+		if syncToken != "" {
+			query.Set("syncToken", syncToken)
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		url.SetQuery(query)
+
+		var res google.Events
+		tr := net.FetchJson(url, "GET", calendar.source.auth, nil, "", q.GetContext(), &res)
+		if tr != nil {
+			return nil, "", tr.
+				Append(errors.LvlWordy, "Could not list events")
+		}
+
+		events = append(events, res.Items...)
+
+		if res.NextPageToken == "" {
+			if res.NextSyncToken == "" {
+				return nil, "", errors.New().Status(http.StatusBadGateway).
+					Append(errors.LvlDebug, "Google did not return a sync token").
+					Append(errors.LvlWordy, "Could not list events")
+			}
+			return events, res.NextSyncToken, nil
+		}
+		pageToken = res.NextPageToken
+	}
+}
+
+func (calendar *GoogleCalendar) remoteObjectFromGoogle(event *google.Event) (*types.RemoteObjectDatabaseEntry, *errors.ErrorTrace) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not marshal event %v", event.Id)
+	}
+
+	settings := &GoogleEventSettings{
+		GoogleId: event.Id,
+		Uid:      event.IcalUid,
+	}
+
+	return &types.RemoteObjectDatabaseEntry{
+		Id:       genEventId(calendar.GetId(), event.Id),
+		Calendar: calendar.GetId(),
+		Settings: settings.Bytes(),
+		Href:     event.Id,
+		Etag:     event.Etag,
+		Data:     string(data),
+	}, nil
+}
+
+func mapValues[K comparable, V any](m map[K]V) []V {
+	values := make([]V, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,207 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	google "luna-backend/protocols/google/internal"
+	"luna-backend/types"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Keeps the synchronization state in memory instead of the database.
+// Queries that the synchronization does not need are left unimplemented.
+type syncTestQueries struct {
+	types.DatabaseQueries
+
+	state   *types.CalendarSyncState
+	objects map[string]*types.RemoteObjectDatabaseEntry
+	reports []*errors.ErrorTrace
+}
+
+func (q *syncTestQueries) GetContext() context.Context {
+	return context.Background()
+}
+
+func (q *syncTestQueries) GetLogger() *logrus.Entry {
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+func (q *syncTestQueries) ReportSourceHealth(sourceId types.ID, tr *errors.ErrorTrace) {
+	q.reports = append(q.reports, tr)
+}
+
+func (q *syncTestQueries) GetCalendarSyncState(calendarId types.ID) (*types.CalendarSyncState, *errors.ErrorTrace) {
+	state := *q.state
+	return &state, nil
+}
+
+func (q *syncTestQueries) SetCalendarSyncState(calendarId types.ID, state *types.CalendarSyncState) *errors.ErrorTrace {
+	q.state = state
+	return nil
+}
+
+func (q *syncTestQueries) GetRemoteObjects(calendarId types.ID) ([]*types.RemoteObjectDatabaseEntry, *errors.ErrorTrace) {
+	objects := make([]*types.RemoteObjectDatabaseEntry, 0, len(q.objects))
+	for _, object := range q.objects {
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+func (q *syncTestQueries) SetRemoteObjects(objects []*types.RemoteObjectDatabaseEntry) *errors.ErrorTrace {
+	for _, object := range objects {
+		q.objects[object.Href] = object
+	}
+	return nil
+}
+
+func (q *syncTestQueries) DeleteRemoteObjects(calendarId types.ID, hrefs []string) *errors.ErrorTrace {
+	for _, href := range hrefs {
+		delete(q.objects, href)
+	}
+	return nil
+}
+
+func newSyncTestCalendar(t *testing.T, handler http.HandlerFunc) *GoogleCalendar {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	SetApiUrl(types.NewUrlSafe(server.URL))
+
+	return &GoogleCalendar{
+		settings: &GoogleCalendarSettings{GoogleId: "primary"},
+		source: &GoogleSource{
+			id:       types.RandomId(),
+			settings: &GoogleSourceSettings{},
+			auth:     auth.NewNoAuth(),
+		},
+	}
+}
+
+// Stores the given events as if they had been synchronized before
+func newSyncTestQueries(t *testing.T, calendar *GoogleCalendar, token string, events ...*google.Event) *syncTestQueries {
+	lastSync := time.Now().Add(-time.Hour)
+	q := &syncTestQueries{
+		state: &types.CalendarSyncState{
+			Token:    token,
+			Method:   constants.SyncMethodSyncToken,
+			Status:   constants.SyncStatusOk,
+			LastSync: &lastSync,
+		},
+		objects: map[string]*types.RemoteObjectDatabaseEntry{},
+	}
+
+	for _, event := range events {
+		entry, tr := calendar.remoteObjectFromGoogle(event)
+		if tr != nil {
+			t.Fatalf("could not store event %v: %v", event.Id, tr.Serialize(errors.LvlDebug))
+		}
+		q.objects[entry.Href] = entry
+	}
+
+	return q
+}
+
+func eventIds(events []*google.Event) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.Id
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSyncStartsOverWhenTokenExpired(t *testing.T) {
+	var requestedTokens []string
+	calendar := newSyncTestCalendar(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/calendars/primary/events" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token := r.URL.Query().Get("syncToken")
+		requestedTokens = append(requestedTokens, token)
+		if token != "" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+
+		json.NewEncoder(w).Encode(&google.Events{
+			Items: []*google.Event{
+				{Id: "kept", Etag: "2", Name: "Kept"},
+				{Id: "added", Etag: "1", Name: "Added"},
+			},
+			NextSyncToken: "fresh",
+		})
+	})
+	q := newSyncTestQueries(t, calendar, "expired",
+		&google.Event{Id: "kept", Etag: "1", Name: "Kept"},
+		&google.Event{Id: "removed", Etag: "1", Name: "Removed"},
+	)
+
+	events, tr := calendar.sync(q)
+	if tr != nil {
+		t.Fatalf("sync failed: %v", tr.Serialize(errors.LvlDebug))
+	}
+
+	if len(requestedTokens) != 2 || requestedTokens[0] != "expired" || requestedTokens[1] != "" {
+		t.Errorf("expected a request with the expired token followed by a full sync, got tokens %q", requestedTokens)
+	}
+	if ids := eventIds(events); len(ids) != 2 || ids[0] != "added" || ids[1] != "kept" {
+		t.Errorf("expected events [added kept], got %v", ids)
+	}
+	if _, exists := q.objects["removed"]; exists {
+		t.Errorf("event missing from the full sync was not deleted")
+	}
+	if q.objects["kept"].Etag != "2" {
+		t.Errorf("expected the stored event to be updated, got etag %v", q.objects["kept"].Etag)
+	}
+	if q.state.Token != "fresh" || q.state.Status != constants.SyncStatusOk {
+		t.Errorf("expected token fresh with status ok, got %v with status %v", q.state.Token, q.state.Status)
+	}
+}
+
+func TestSyncFallsBackToStoredEvents(t *testing.T) {
+	calendar := newSyncTestCalendar(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	q := newSyncTestQueries(t, calendar, "valid",
+		&google.Event{Id: "stored", Etag: "1", Name: "Stored"},
+	)
+
+	events, tr := calendar.sync(q)
+	if tr != nil {
+		t.Fatalf("expected the stored events instead of an error, got %v", tr.Serialize(errors.LvlDebug))
+	}
+
+	if ids := eventIds(events); len(ids) != 1 || ids[0] != "stored" {
+		t.Errorf("expected events [stored], got %v", ids)
+	}
+	if q.state.Status != constants.SyncStatusFailed || q.state.Token != "valid" {
+		t.Errorf("expected token valid with status failed, got %v with status %v", q.state.Token, q.state.Status)
+	}
+	if len(q.reports) != 1 || q.reports[0] == nil || q.reports[0].GetStatus() != http.StatusServiceUnavailable {
+		t.Errorf("expected the failure to be reported to the source health")
+	}
+}
+
+func TestSyncFailsWithoutStoredEvents(t *testing.T) {
+	calendar := newSyncTestCalendar(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	q := newSyncTestQueries(t, calendar, "")
+	q.state.LastSync = nil
+
+	_, tr := calendar.sync(q)
+	if tr == nil {
+		t.Fatalf("expected an error without a previous synchronization")
+	}
+}
@@ -0,0 +1,199 @@
+package holidays
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	holidays "luna-backend/protocols/holidays/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+type HolidaysCalendar struct {
+	name       string
+	desc       string
+	color      *types.Color
+	overridden bool
+	settings   *HolidaysCalendarSettings
+	source     *HolidaysSource
+	country    *holidays.Country
+}
+
+// The source only has one calendar, so nothing needs to be stored to find it again
+type HolidaysCalendarSettings struct{}
+
+func (source *HolidaysSource) getCalendar() (*HolidaysCalendar, *errors.ErrorTrace) {
+	country, tr := source.getCountry()
+	if tr != nil {
+		return nil, tr
+	}
+
+	name := fmt.Sprintf("Holidays in %v", country.Name)
+	if region, exists := country.Regions[source.settings.Region]; exists {
+		name = fmt.Sprintf("Holidays in %v, %v", region, country.Name)
+	}
+
+	return &HolidaysCalendar{
+		name:       name,
+		desc:       "",
+		color:      nil,
+		overridden: false,
+		settings:   &HolidaysCalendarSettings{},
+		source:     source,
+		country:    country,
+	}, nil
+}
+
+func (settings *HolidaysCalendarSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (calendar *HolidaysCalendar) GetId() types.ID {
+	return crypto.DeriveID(calendar.source.id, "holidays")
+}
+
+func (calendar *HolidaysCalendar) GetName() string {
+	return calendar.name
+}
+
+func (calendar *HolidaysCalendar) SetName(name string) {
+	calendar.name = name
+}
+
+func (calendar *HolidaysCalendar) GetDesc() string {
+	return calendar.desc
+}
+
+func (calendar *HolidaysCalendar) SetDesc(desc string) {
+	calendar.desc = desc
+}
+
+func (calendar *HolidaysCalendar) GetSource() types.Source {
+	return calendar.source
+}
+
+func (calendar *HolidaysCalendar) GetSettings() types.CalendarSettings {
+	return calendar.settings
+}
+
+func (calendar *HolidaysCalendar) GetColor() *types.Color {
+	if calendar.color == nil {
+		return types.ColorEmpty
+	} else {
+		return calendar.color
+	}
+}
+
+func (calendar *HolidaysCalendar) SetColor(color *types.Color) {
+	calendar.color = color
+}
+
+func (calendar *HolidaysCalendar) GetOverridden() bool {
+	return calendar.overridden
+}
+
+func (calendar *HolidaysCalendar) SetOverridden(overridden bool) {
+	calendar.overridden = overridden
+}
+
+func (calendar *HolidaysCalendar) CanEdit() bool {
+	return false
+}
+
+func (calendar *HolidaysCalendar) CanDelete() bool {
+	return false
+}
+
+func (calendar *HolidaysCalendar) CanAddEvents() bool {
+	return false
+}
+
+// Holidays can move from year to year, so every year within the window is generated separately instead of using recurrence rules
+func (calendar *HolidaysCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
+	result := []types.Event{}
+	for year := start.Year() - 1; year <= end.Year(); year++ {
+		for _, event := range calendar.eventsInYear(year) {
+			// The generated events end when they start, even though they last the whole day
+			dayStart := *event.GetDate().Start()
+			if !dayStart.Before(end) || !dayStart.AddDate(0, 0, 1).After(start) {
+				continue
+			}
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (calendar *HolidaysCalendar) GetEvent(settings types.EventSettings, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	holidaysSettings := settings.(*HolidaysEventSettings)
+
+	date, err := time.Parse("2006-01-02", holidaysSettings.Date)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse holiday date %v", holidaysSettings.Date).
+			Append(errors.LvlBroad, "Could not get event")
+	}
+
+	// Days off that were moved because of a weekend may fall into the next year
+	for _, year := range []int{date.Year(), date.Year() - 1} {
+		for _, event := range calendar.eventsInYear(year) {
+			if event.settings.key() == holidaysSettings.key() {
+				return event, nil
+			}
+		}
+	}
+
+	return nil, errors.New().Status(http.StatusNotFound).
+		Append(errors.LvlWordy, "Holiday %v on %v not found", holidaysSettings.Name, holidaysSettings.Date).
+		AltStr(errors.LvlPlain, "Event not found")
+}
+
+/* Holidays calendar is read-only */
+
+func (calendar *HolidaysCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	return nil, errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (calendar *HolidaysCalendar) EditEvent(event types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, override bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	if override {
+		anyOverrides := false
+		if name != "" {
+			event.SetName(name)
+			anyOverrides = true
+		}
+		if desc != "" {
+			event.SetDesc(desc)
+			anyOverrides = true
+		}
+		if color != nil && !color.IsEmpty() {
+			event.SetColor(color)
+			anyOverrides = true
+		}
+
+		if anyOverrides {
+			q.SetEventOverrides(event.GetId(), name, desc, color)
+			return event, nil
+		} else {
+			q.DeleteEventOverrides(event.GetId())
+			return calendar.GetEvent(event.GetSettings(), q)
+		}
+	} else {
+		return nil, errors.New().Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (calendar *HolidaysCalendar) DeleteEvent(event types.Event, q types.DatabaseQueries) *errors.ErrorTrace {
+	return errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (calendar *HolidaysCalendar) SupplyContext(ctx context.Context) {
+	calendar.source.SupplyContext(ctx)
+}
@@ -0,0 +1,198 @@
+package holidays
+
+import (
+	"encoding/json"
+	"fmt"
+	"luna-backend/crypto"
+	"luna-backend/types"
+	"time"
+)
+
+type HolidaysEvent struct {
+	name       string
+	desc       string
+	color      *types.Color
+	overridden bool
+	settings   *HolidaysEventSettings
+	calendar   *HolidaysCalendar
+	eventDate  *types.EventDate
+}
+
+type HolidaysEventSettings struct {
+	Name     string `json:"name"`
+	Date     string `json:"date"`
+	Observed bool   `json:"observed"` // the day off that replaces a holiday on a weekend
+}
+
+func (settings *HolidaysEventSettings) key() string {
+	return fmt.Sprintf("%v %v %v", settings.Date, settings.Name, settings.Observed)
+}
+
+func (settings *HolidaysEventSettings) Clone() *HolidaysEventSettings {
+	return &HolidaysEventSettings{
+		Name:     settings.Name,
+		Date:     settings.Date,
+		Observed: settings.Observed,
+	}
+}
+
+func (settings *HolidaysEventSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// Rules that overlap, e.g. a holiday that is regional in most years but nationwide in one, only create a single event
+func (calendar *HolidaysCalendar) eventsInYear(year int) []*HolidaysEvent {
+	region := calendar.source.settings.Region
+
+	events := []*HolidaysEvent{}
+	seen := map[string]bool{}
+	add := func(name string, date string, observed bool) {
+		settings := &HolidaysEventSettings{
+			Name:     name,
+			Date:     date,
+			Observed: observed,
+		}
+		if seen[settings.key()] {
+			return
+		}
+		seen[settings.key()] = true
+		events = append(events, calendar.eventFromSettings(settings))
+	}
+
+	for _, rule := range calendar.country.Holidays {
+		if !rule.AppliesTo(region, year) {
+			continue
+		}
+
+		// The rules are checked when they are loaded
+		date, _ := rule.DateIn(year)
+		add(rule.Name, date.Format("2006-01-02"), false)
+
+		if observed, moved := rule.ObservedDate(date); moved {
+			add(rule.Name, observed.Format("2006-01-02"), true)
+		}
+	}
+
+	return events
+}
+
+func (calendar *HolidaysCalendar) eventFromSettings(settings *HolidaysEventSettings) *HolidaysEvent {
+	// The date was formatted by us, so it always parses
+	start, _ := time.Parse("2006-01-02", settings.Date)
+
+	name := settings.Name
+	desc := ""
+	if settings.Observed {
+		name = fmt.Sprintf("%v (observed)", settings.Name)
+		desc = fmt.Sprintf("Day off for %v, which falls on a weekend", settings.Name)
+	}
+
+	return &HolidaysEvent{
+		name:       name,
+		desc:       desc,
+		color:      nil,
+		overridden: false,
+		settings:   settings,
+		calendar:   calendar,
+		eventDate:  types.NewEventDateFromSingleDay(&start, types.EmptyEventRecurrence()),
+	}
+}
+
+func (event *HolidaysEvent) GetId() types.ID {
+	return crypto.DeriveID(event.calendar.GetId(), event.settings.key())
+}
+
+func (event *HolidaysEvent) GetName() string {
+	return event.name
+}
+
+func (event *HolidaysEvent) SetName(name string) {
+	event.name = name
+}
+
+func (event *HolidaysEvent) GetDesc() string {
+	return event.desc
+}
+
+func (event *HolidaysEvent) SetDesc(desc string) {
+	event.desc = desc
+}
+
+func (event *HolidaysEvent) GetCalendar() types.Calendar {
+	return event.calendar
+}
+
+func (event *HolidaysEvent) GetSettings() types.EventSettings {
+	return event.settings
+}
+
+func (event *HolidaysEvent) GetColor() *types.Color {
+	if event.color == nil {
+		return event.calendar.GetColor()
+	} else {
+		return event.color
+	}
+}
+
+func (event *HolidaysEvent) SetColor(color *types.Color) {
+	event.color = color
+}
+
+func (event *HolidaysEvent) GetOverridden() bool {
+	return event.overridden
+}
+
+func (event *HolidaysEvent) SetOverridden(overridden bool) {
+	event.overridden = overridden
+}
+
+func (event *HolidaysEvent) GetDate() *types.EventDate {
+	return event.eventDate
+}
+
+func (event *HolidaysEvent) GetReminders() []*types.EventReminder {
+	return []*types.EventReminder{}
+}
+
+func (event *HolidaysEvent) GetLocation() *types.EventLocation {
+	return nil
+}
+
+func (event *HolidaysEvent) GetParticipants() *types.EventParticipants {
+	return nil
+}
+
+func (event *HolidaysEvent) Clone() types.Event {
+	return &HolidaysEvent{
+		name:       event.name,
+		desc:       event.desc,
+		color:      event.color.Clone(),
+		overridden: event.overridden,
+		settings:   event.settings.Clone(),
+		calendar:   event.calendar,
+		eventDate:  event.eventDate.Clone(),
+	}
+}
+
+// Holidays never recur, since every year is generated separately
+func (event *HolidaysEvent) SupplyMasterEvent(_ types.Event) {}
+
+func (event *HolidaysEvent) GetUid() string {
+	return fmt.Sprintf("%v@holidays", event.GetId())
+}
+
+func (event *HolidaysEvent) GetRecurrenceId() string {
+	return ""
+}
+
+func (event *HolidaysEvent) CanEdit() bool {
+	return false
+}
+
+func (event *HolidaysEvent) CanDelete() bool {
+	return false
+}
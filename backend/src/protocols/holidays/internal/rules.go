@@ -0,0 +1,199 @@
+package holidays
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Public holidays by ISO 3166-1 country code, with regions by the subdivision part of their ISO 3166-2 code
+//
+//go:embed rules.json
+var rulesJson []byte
+
+var (
+	countries     map[string]*Country
+	countriesOnce sync.Once
+)
+
+type Country struct {
+	Name     string            `json:"name"`
+	Regions  map[string]string `json:"regions"`
+	Holidays []*Rule           `json:"holidays"`
+}
+
+// Exactly one way of calculating the date is set:
+//   - Date: a fixed date as "MM-DD"
+//   - Easter: the number of days after Easter Sunday
+//   - Month, Weekday and Nth: the nth weekday of the month, counted from the end if negative
+//   - Weekday and Before or After: the closest weekday before or after a fixed date, excluding the date itself
+type Rule struct {
+	Name    string   `json:"name"`
+	Regions []string `json:"regions"` // applies to the whole country if empty
+	Since   int      `json:"since"`
+	Until   int      `json:"until"`
+
+	Date    string `json:"date"`
+	Easter  *int   `json:"easter"`
+	Month   int    `json:"month"`
+	Weekday string `json:"weekday"`
+	Nth     int    `json:"nth"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+
+	// Days by which the day off moves if the holiday falls on a weekend, e.g. {"sunday": 1}
+	Observed map[string]int `json:"observed"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// The rules are embedded into the binary, so errors in them are programming errors
+func Countries() map[string]*Country {
+	countriesOnce.Do(func() {
+		err := json.Unmarshal(rulesJson, &countries)
+		if err != nil {
+			panic(fmt.Sprintf("could not parse holiday rules: %v", err))
+		}
+
+		for code, country := range countries {
+			for _, rule := range country.Holidays {
+				_, err := rule.DateIn(2000)
+				if err != nil {
+					panic(fmt.Sprintf("invalid holiday rule %v in %v: %v", rule.Name, code, err))
+				}
+				for day := range rule.Observed {
+					if _, known := weekdays[day]; !known {
+						panic(fmt.Sprintf("invalid holiday rule %v in %v: unknown weekday %v", rule.Name, code, day))
+					}
+				}
+			}
+		}
+	})
+	return countries
+}
+
+func (rule *Rule) AppliesTo(region string, year int) bool {
+	if (rule.Since != 0 && year < rule.Since) || (rule.Until != 0 && year > rule.Until) {
+		return false
+	}
+	if len(rule.Regions) == 0 {
+		return true
+	}
+	for _, ruleRegion := range rule.Regions {
+		if strings.EqualFold(ruleRegion, region) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *Rule) DateIn(year int) (time.Time, error) {
+	switch {
+	case rule.Date != "":
+		return fixedDate(year, rule.Date)
+
+	case rule.Easter != nil:
+		return Easter(year).AddDate(0, 0, *rule.Easter), nil
+
+	case rule.Weekday != "" && rule.Month != 0 && rule.Nth != 0:
+		weekday, known := weekdays[rule.Weekday]
+		if !known {
+			return time.Time{}, fmt.Errorf("unknown weekday %v", rule.Weekday)
+		}
+		return nthWeekday(year, time.Month(rule.Month), weekday, rule.Nth)
+
+	case rule.Weekday != "" && (rule.Before != "" || rule.After != ""):
+		weekday, known := weekdays[rule.Weekday]
+		if !known {
+			return time.Time{}, fmt.Errorf("unknown weekday %v", rule.Weekday)
+		}
+
+		step := -1
+		anchor := rule.Before
+		if anchor == "" {
+			step = 1
+			anchor = rule.After
+		}
+		date, err := fixedDate(year, anchor)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		date = date.AddDate(0, 0, step)
+		for date.Weekday() != weekday {
+			date = date.AddDate(0, 0, step)
+		}
+		return date, nil
+
+	default:
+		return time.Time{}, fmt.Errorf("rule has no date")
+	}
+}
+
+// Returns the day off if it differs from the date of the holiday
+func (rule *Rule) ObservedDate(date time.Time) (time.Time, bool) {
+	for day, offset := range rule.Observed {
+		if weekdays[day] == date.Weekday() && offset != 0 {
+			return date.AddDate(0, 0, offset), true
+		}
+	}
+	return date, false
+}
+
+func fixedDate(year int, monthDay string) (time.Time, error) {
+	parsed, err := time.Parse("01-02", monthDay)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse date %v: %v", monthDay, err)
+	}
+	return time.Date(year, parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func nthWeekday(year int, month time.Month, weekday time.Weekday, nth int) (time.Time, error) {
+	if nth > 0 {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+		date := first.AddDate(0, 0, offset+(nth-1)*7)
+		if date.Month() != month {
+			return time.Time{}, fmt.Errorf("%v has no weekday number %v", month, nth)
+		}
+		return date, nil
+	}
+
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+	date := last.AddDate(0, 0, -offset+(nth+1)*7)
+	if date.Month() != month {
+		return time.Time{}, fmt.Errorf("%v has no weekday number %v", month, nth)
+	}
+	return date, nil
+}
+
+// Easter Sunday in the Gregorian calendar, using the anonymous Gregorian algorithm
+func Easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,163 @@
+package holidays
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	holidays "luna-backend/protocols/holidays/internal"
+	"luna-backend/types"
+	"net/http"
+	"strings"
+)
+
+// Public holidays generated from the rules that are built into Luna, so no remote server is required.
+// The source has a single calendar for the chosen country and region.
+type HolidaysSource struct {
+	id       types.ID
+	name     string
+	settings *HolidaysSourceSettings
+	auth     types.AuthMethod
+}
+
+type HolidaysSourceSettings struct {
+	Country string `json:"country"` // ISO 3166-1 alpha-2, e.g. "DE"
+	Region  string `json:"region"`  // subdivision part of ISO 3166-2, e.g. "BY", or empty for nationwide holidays only
+}
+
+func (settings *HolidaysSourceSettings) GetBytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (source *HolidaysSource) GetType() string {
+	return constants.SourceHolidays
+}
+
+func (source *HolidaysSource) GetId() types.ID {
+	return source.id
+}
+
+func (source *HolidaysSource) GetName() string {
+	return source.name
+}
+
+func (source *HolidaysSource) GetAuth() types.AuthMethod {
+	return source.auth
+}
+
+func (source *HolidaysSource) GetSettings() types.SourceSettings {
+	return source.settings
+}
+
+func (source *HolidaysSource) CanAddCalendars() bool {
+	return false
+}
+
+func NewHolidaysSource(name string, country string, region string) (*HolidaysSource, *errors.ErrorTrace) {
+	country = strings.ToUpper(country)
+	region = strings.ToUpper(region)
+
+	rules, exists := holidays.Countries()[country]
+	if !exists {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Unsupported holiday country: %v", country)
+	}
+	if _, exists := rules.Regions[region]; region != "" && !exists {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "Unsupported holiday region: %v", region)
+	}
+
+	return &HolidaysSource{
+		id:   types.EmptyId(), // Placeholder until the database assigns an ID
+		name: name,
+		settings: &HolidaysSourceSettings{
+			Country: country,
+			Region:  region,
+		},
+		auth: auth.NewNoAuth(),
+	}, nil
+}
+
+func PackHolidaysSource(id types.ID, name string, settings *HolidaysSourceSettings, auth types.AuthMethod) *HolidaysSource {
+	return &HolidaysSource{
+		id:       id,
+		name:     name,
+		settings: settings,
+		auth:     auth,
+	}
+}
+
+func (source *HolidaysSource) getCountry() (*holidays.Country, *errors.ErrorTrace) {
+	country, exists := holidays.Countries()[source.settings.Country]
+	if !exists {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			Append(errors.LvlDebug, "No holiday rules for country %v", source.settings.Country).
+			Append(errors.LvlWordy, "Could not get holidays")
+	}
+	return country, nil
+}
+
+func (source *HolidaysSource) GetCalendars(q types.DatabaseQueries) ([]types.Calendar, *errors.ErrorTrace) {
+	calendar, tr := source.getCalendar()
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get calendars")
+	}
+	return []types.Calendar{calendar}, nil
+}
+
+func (source *HolidaysSource) GetCalendar(settings types.CalendarSettings, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	calendar, tr := source.getCalendar()
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get calendar")
+	}
+	return calendar, nil
+}
+
+/* Holidays source is read-only */
+
+func (source *HolidaysSource) AddCalendar(name string, desc string, color *types.Color, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	return nil, errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (source *HolidaysSource) EditCalendar(calendar types.Calendar, name string, desc string, color *types.Color, override bool, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	if override {
+		anyOverrides := false
+		if name != "" {
+			calendar.SetName(name)
+			anyOverrides = true
+		}
+		if desc != "" {
+			calendar.SetDesc(desc)
+			anyOverrides = true
+		}
+		if color != nil && !color.IsEmpty() {
+			calendar.SetColor(color)
+			anyOverrides = true
+		}
+
+		if anyOverrides {
+			q.SetCalendarOverrides(calendar.GetId(), name, desc, color)
+			return calendar, nil
+		} else {
+			q.DeleteCalendarOverrides(calendar.GetId())
+			return source.GetCalendar(calendar.GetSettings(), q)
+		}
+	} else {
+		return nil, errors.New().Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (source *HolidaysSource) DeleteCalendar(calendar types.Calendar, q types.DatabaseQueries) *errors.ErrorTrace {
+	return errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (source *HolidaysSource) Cleanup(_ types.DatabaseQueries) *errors.ErrorTrace { return nil }
+
+func (source *HolidaysSource) SupplyContext(_ context.Context) {}
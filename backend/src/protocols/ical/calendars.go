@@ -155,7 +155,7 @@ func (calendar *IcalCalendar) GetEvents(start time.Time, end time.Time, q types.
 			continue
 		}
 
-		event, err := calendar.eventFromIcal(&comp.Props)
+		event, err := calendar.eventFromIcal(comp)
 		if err != nil {
 			return nil, err.
 				Append(errors.LvlDebug, "Could not parse event from calendar %v (%v)", calendar.GetName(), calendar.GetId()).
@@ -200,7 +200,7 @@ func (calendar *IcalCalendar) GetEvent(settings types.EventSettings, q types.Dat
 			continue
 		}
 
-		event, err := calendar.eventFromIcal(&comp.Props)
+		event, err := calendar.eventFromIcal(comp)
 		if err != nil {
 			return nil, err.
 				Append(errors.LvlDebug, "Could not parse event %v in calendar %v (%v)", icalSettings.Uid, calendar.GetName(), calendar.GetId()).
@@ -221,11 +221,11 @@ func (calendar *IcalCalendar) GetEvent(settings types.EventSettings, q types.Dat
 
 /* Ical calendar is read-only */
 
-func (calendar *IcalCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+func (calendar *IcalCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
 	return nil, errors.New().Status(http.StatusMethodNotAllowed)
 }
 
-func (calendar *IcalCalendar) EditEvent(event types.Event, name string, desc string, color *types.Color, date *types.EventDate, override bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+func (calendar *IcalCalendar) EditEvent(event types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, override bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
 	if override {
 		anyOverrides := false
 		if name != "" {
@@ -12,13 +12,16 @@ import (
 )
 
 type IcalEvent struct {
-	name       string
-	desc       string
-	color      *types.Color
-	overridden bool
-	settings   *IcalEventSettings
-	calendar   *IcalCalendar
-	eventDate  *types.EventDate
+	name         string
+	desc         string
+	color        *types.Color
+	overridden   bool
+	settings     *IcalEventSettings
+	calendar     *IcalCalendar
+	eventDate    *types.EventDate
+	reminders    []*types.EventReminder
+	location     *types.EventLocation
+	participants *types.EventParticipants
 }
 
 type IcalEventSettings struct {
@@ -36,11 +39,11 @@ func (settings *IcalEventSettings) Clone() *IcalEventSettings {
 	}
 }
 
-func (calendar *IcalCalendar) eventFromIcal(props *ical.Props) (*IcalEvent, *errors.ErrorTrace) {
-	parsedProps, _, err := common.ParseIcalEvent(props)
+func (calendar *IcalCalendar) eventFromIcal(component *ical.Component) (*IcalEvent, *errors.ErrorTrace) {
+	parsedProps, _, err := common.ParseIcalEvent(component)
 	if err != nil {
 		uid := "unknown"
-		if uidProp := props.Get(ical.PropUID); uidProp != nil {
+		if uidProp := component.Props.Get(ical.PropUID); uidProp != nil {
 			uid = uidProp.Value
 		}
 		return nil, errors.New().Status(http.StatusInternalServerError).
@@ -59,8 +62,11 @@ func (calendar *IcalCalendar) eventFromIcal(props *ical.Props) (*IcalEvent, *err
 			IsFirstRecurrence: parsedProps.RecurrenceId == "",
 			//rawEvent: icalEvent,
 		},
-		calendar:  calendar,
-		eventDate: parsedProps.EventDate,
+		calendar:     calendar,
+		eventDate:    parsedProps.EventDate,
+		reminders:    parsedProps.Reminders,
+		location:     parsedProps.Location,
+		participants: parsedProps.Participants,
 	}
 
 	return event, nil
@@ -132,15 +138,30 @@ func (event *IcalEvent) GetDate() *types.EventDate {
 	return event.eventDate
 }
 
+func (event *IcalEvent) GetReminders() []*types.EventReminder {
+	return event.reminders
+}
+
+func (event *IcalEvent) GetLocation() *types.EventLocation {
+	return event.location
+}
+
+func (event *IcalEvent) GetParticipants() *types.EventParticipants {
+	return event.participants
+}
+
 func (event *IcalEvent) Clone() types.Event {
 	return &IcalEvent{
-		name:       event.name,
-		desc:       event.desc,
-		color:      event.color.Clone(),
-		overridden: event.overridden,
-		settings:   event.settings.Clone(),
-		calendar:   event.calendar,
-		eventDate:  event.eventDate.Clone(),
+		name:         event.name,
+		desc:         event.desc,
+		color:        event.color.Clone(),
+		overridden:   event.overridden,
+		settings:     event.settings.Clone(),
+		calendar:     event.calendar,
+		eventDate:    event.eventDate.Clone(),
+		reminders:    types.CloneReminders(event.reminders),
+		location:     event.location.Clone(),
+		participants: event.participants.Clone(),
 	}
 }
 
@@ -149,6 +170,10 @@ func (event *IcalEvent) SupplyMasterEvent(masterEvent types.Event) {
 	event.settings.IsFirstRecurrence = masterEvent.GetDate().Start().Equal(*event.eventDate.Start())
 }
 
+func (event *IcalEvent) GetUid() string {
+	return event.settings.Uid
+}
+
 func (event *IcalEvent) GetRecurrenceId() string {
 	return event.settings.RecurrenceId
 }
@@ -0,0 +1,133 @@
+package ical
+
+import (
+	"luna-backend/errors"
+	common "luna-backend/protocols/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// Creates an empty iCal calendar carrying Luna's product id and the given calendar metadata.
+// The returned calendar can be filled with components created by EventToIcal.
+func NewIcalCalendar(name string, desc string, color *types.Color) *ical.Calendar {
+	cal := ical.NewCalendar()
+
+	cal.Props.SetText(ical.PropProductID, common.IcalProductId)
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropCalendarScale, "GREGORIAN")
+
+	if name != "" {
+		cal.Props.SetText(ical.PropName, common.EscapeIcalString(name))
+		cal.Props.SetText("X-WR-CALNAME", common.EscapeIcalString(name))
+	}
+	if desc != "" {
+		cal.Props.SetText(ical.PropDescription, common.EscapeIcalString(desc))
+		cal.Props.SetText("X-WR-CALDESC", common.EscapeIcalString(desc))
+	}
+	if color != nil && !color.IsEmpty() {
+		colorName, _ := types.ColorToName(color)
+		cal.Props.SetText(ical.PropColor, colorName)
+		cal.Props.SetText(common.PropColor, color.String())
+	}
+
+	return cal
+}
+
+// Times in the "Local" location cannot be referenced by a TZID, so they are written out in UTC
+func newIcalDateProp(name string, t time.Time, allDay bool) *ical.Prop {
+	prop := ical.NewProp(name)
+	if allDay {
+		prop.SetDate(t)
+	} else if t.Location() == time.Local {
+		prop.SetDateTime(t.UTC())
+	} else {
+		prop.SetDateTime(t)
+	}
+	return prop
+}
+
+// Converts any Luna event into a VEVENT component.
+// Recurring events are written as a single master event with their RRULE, EXDATE and RDATE properties.
+func EventToIcal(event types.Event, uid string) (*ical.Component, *errors.ErrorTrace) {
+	date := event.GetDate()
+	if date == nil || date.Start() == nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			Append(errors.LvlDebug, "Event %v has no start date", event.GetId()).
+			Append(errors.LvlWordy, "Could not convert event to iCal")
+	}
+
+	vevent := ical.NewEvent()
+
+	vevent.Props.SetText(ical.PropUID, uid)
+	vevent.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	vevent.Props.SetText(ical.PropSummary, common.EscapeIcalString(event.GetName()))
+
+	if event.GetDesc() != "" {
+		vevent.Props.SetText(ical.PropDescription, common.EscapeIcalString(event.GetDesc()))
+	}
+
+	color := event.GetColor()
+	if color != nil && !color.IsEmpty() {
+		colorName, exact := types.ColorToName(color)
+		vevent.Props.SetText(ical.PropColor, colorName)
+		if !exact {
+			vevent.Props.SetText(common.PropColor, color.String())
+			vevent.Props.SetText(common.PropLastColorName, colorName)
+		}
+	}
+
+	vevent.Props.Set(newIcalDateProp(ical.PropDateTimeStart, *date.Start(), date.AllDay()))
+	if date.SpecifyDuration() && date.Duration() != nil {
+		prop := ical.NewProp(ical.PropDuration)
+		prop.SetDuration(*date.Duration())
+		vevent.Props.Set(prop)
+	} else if date.End() != nil {
+		vevent.Props.Set(newIcalDateProp(ical.PropDateTimeEnd, *date.End(), date.AllDay()))
+	}
+
+	recurrence := date.Recurrence()
+	if recurrence != nil && recurrence.Repeats() {
+		if recurrence.Rule() != nil {
+			vevent.Props.SetRecurrenceRule(recurrence.Rule())
+		}
+		for _, exception := range recurrence.Except() {
+			vevent.Props.Add(newIcalDateProp(ical.PropExceptionDates, exception, date.AllDay()))
+		}
+		for _, additional := range recurrence.Additional() {
+			vevent.Props.Add(newIcalDateProp(ical.PropRecurrenceDates, additional, date.AllDay()))
+		}
+	}
+
+	common.SetIcalLocation(&vevent.Props, event.GetLocation())
+	common.SetIcalParticipants(&vevent.Props, event.GetParticipants())
+
+	for _, reminder := range event.GetReminders() {
+		vevent.Children = append(vevent.Children, reminder.ToIcal(common.EscapeIcalString(event.GetName())))
+	}
+
+	return vevent.Component, nil
+}
+
+// Converts a single expanded instance of an event into a standalone VEVENT.
+// Used where clients cannot be relied on to expand recurrence rules with Luna's modified instances.
+func EventInstanceToIcal(event types.Event, uid string) (*ical.Component, *errors.ErrorTrace) {
+	vevent, tr := EventToIcal(event, uid)
+	if tr != nil {
+		return nil, tr
+	}
+
+	vevent.Props.Del(ical.PropRecurrenceRule)
+	vevent.Props.Del(ical.PropExceptionDates)
+	vevent.Props.Del(ical.PropRecurrenceDates)
+
+	return vevent, nil
+}
+
+// Parses a VEVENT received from another client, e.g. through Luna's own CalDAV server
+func ParseIcalEvent(component *ical.Component) (*common.IcalEventProps, error) {
+	parsedProps, _, err := common.ParseIcalEvent(component)
+	return parsedProps, err
+}
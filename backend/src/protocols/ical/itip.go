@@ -0,0 +1,141 @@
+package ical
+
+import (
+	"fmt"
+	"luna-backend/errors"
+	common "luna-backend/protocols/internal"
+	"luna-backend/types"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-ical"
+)
+
+// iTIP methods (RFC 5546) supported by Luna
+const (
+	ItipMethodRequest = "REQUEST"
+	ItipMethodCancel  = "CANCEL"
+	ItipMethodReply   = "REPLY"
+)
+
+// A participation status received from an attendee
+type ItipReply struct {
+	Uid          string
+	RecurrenceId string
+	Attendee     *types.EventAttendee
+}
+
+func newMessage(method string, vevent *ical.Component) *ical.Calendar {
+	cal := NewIcalCalendar("", "", nil)
+	cal.Props.SetText(ical.PropMethod, method)
+	cal.Children = append(cal.Children, vevent)
+	return cal
+}
+
+func setSequence(vevent *ical.Component, sequence int) {
+	prop := ical.NewProp(ical.PropSequence)
+	prop.Value = strconv.Itoa(sequence)
+	vevent.Props.Set(prop)
+}
+
+// Alarms belong to the organizer and are not meant to be copied by attendees
+func eventToItip(event types.Event) (*ical.Component, *errors.ErrorTrace) {
+	vevent, tr := EventToIcal(event, event.GetUid())
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not create scheduling message")
+	}
+	vevent.Children = []*ical.Component{}
+	return vevent, nil
+}
+
+// Creates a REQUEST that invites the attendees to the event or informs them about changes
+func NewItipRequest(event types.Event, sequence int) (*ical.Calendar, *errors.ErrorTrace) {
+	vevent, tr := eventToItip(event)
+	if tr != nil {
+		return nil, tr
+	}
+	setSequence(vevent, sequence)
+
+	return newMessage(ItipMethodRequest, vevent), nil
+}
+
+// Creates a CANCEL for the given attendees, which are either removed from the event or, if the whole event is cancelled, all attendees
+func NewItipCancel(event types.Event, attendees []*types.EventAttendee, sequence int) (*ical.Calendar, *errors.ErrorTrace) {
+	vevent, tr := eventToItip(event)
+	if tr != nil {
+		return nil, tr
+	}
+	setSequence(vevent, sequence)
+	vevent.Props.SetText(ical.PropStatus, "CANCELLED")
+
+	participants := &types.EventParticipants{
+		Organizer: event.GetParticipants().Organizer,
+		Attendees: attendees,
+	}
+	common.SetIcalParticipants(&vevent.Props, participants)
+
+	return newMessage(ItipMethodCancel, vevent), nil
+}
+
+// Creates a REPLY informing the organizer about the participation status of a single attendee
+func NewItipReply(event types.Event, attendee *types.EventAttendee) (*ical.Calendar, *errors.ErrorTrace) {
+	vevent, tr := eventToItip(event)
+	if tr != nil {
+		return nil, tr
+	}
+
+	// The reply only contains the replying attendee, and the sequence is that of the organizer, which Luna does not track
+	participants := &types.EventParticipants{
+		Organizer: event.GetParticipants().Organizer,
+		Attendees: []*types.EventAttendee{attendee},
+	}
+	common.SetIcalParticipants(&vevent.Props, participants)
+	vevent.Props.Del(ical.PropSequence)
+
+	return newMessage(ItipMethodReply, vevent), nil
+}
+
+// Extracts the replies of attendees from a REPLY message.
+// Every VEVENT may refer to a different instance of a recurring event.
+func ParseItipReplies(cal *ical.Calendar) ([]*ItipReply, error) {
+	method := cal.Props.Get(ical.PropMethod)
+	if method == nil || !strings.EqualFold(method.Value, ItipMethodReply) {
+		return nil, fmt.Errorf("expected an iTIP %v", ItipMethodReply)
+	}
+
+	replies := []*ItipReply{}
+	for _, child := range cal.Children {
+		if child.Name != ical.CompEvent {
+			continue
+		}
+
+		uid := child.Props.Get(ical.PropUID)
+		if uid == nil || uid.Value == "" {
+			return nil, fmt.Errorf("reply is missing a uid")
+		}
+
+		recurrenceId := ""
+		if prop := child.Props.Get(ical.PropRecurrenceID); prop != nil {
+			recurrenceId = prop.Value
+		}
+
+		participants := common.ParseIcalParticipants(&child.Props)
+		if participants == nil || len(participants.Attendees) == 0 {
+			return nil, fmt.Errorf("reply for %v does not contain an attendee", uid.Value)
+		}
+
+		for _, attendee := range participants.Attendees {
+			replies = append(replies, &ItipReply{
+				Uid:          uid.Value,
+				RecurrenceId: recurrenceId,
+				Attendee:     attendee,
+			})
+		}
+	}
+
+	if len(replies) == 0 {
+		return nil, fmt.Errorf("reply does not contain any events")
+	}
+	return replies, nil
+}
@@ -22,12 +22,13 @@ type IcalSource struct {
 }
 
 type IcalSourceSettings struct {
-	Location     string         `json:"location"`
-	Url          *types.Url     `json:"url"`  // for Location == "remote"
-	Path         *types.Path    `json:"path"` // for Location == "local"
-	FileId       types.ID       `json:"file"` // for Location == "database"
-	file         types.File     `json:"-"`
-	icalCalendar *ical.Calendar `json:"-"`
+	Location          string         `json:"location"`
+	Url               *types.Url     `json:"url"`                // for Location == "remote"
+	BackgroundRefetch bool           `json:"background_refetch"` // for Location == "remote", lets the periodic refetch decrypt the credentials
+	Path              *types.Path    `json:"path"`               // for Location == "local"
+	FileId            types.ID       `json:"file"`               // for Location == "database"
+	file              types.File     `json:"-"`
+	icalCalendar      *ical.Calendar `json:"-"`
 }
 
 func (source *IcalSource) getIcalFile(q types.DatabaseQueries) (*ical.Calendar, *errors.ErrorTrace) {
@@ -85,7 +86,7 @@ func (source *IcalSource) CanAddCalendars() bool {
 	return false
 }
 
-func NewRemoteIcalSource(name string, url *types.Url, auth types.AuthMethod, user types.ID, q types.DatabaseQueries) (*IcalSource, *errors.ErrorTrace) {
+func NewRemoteIcalSource(name string, url *types.Url, auth types.AuthMethod, backgroundRefetch bool, user types.ID, q types.DatabaseQueries) (*IcalSource, *errors.ErrorTrace) {
 	file, err := files.NewRemoteFile(url, "text/calendar", auth, user, q)
 	if err != nil {
 		return nil, err
@@ -96,9 +97,10 @@ func NewRemoteIcalSource(name string, url *types.Url, auth types.AuthMethod, use
 		name: name,
 		auth: auth,
 		settings: &IcalSourceSettings{
-			Location: "remote",
-			Url:      url,
-			file:     file,
+			Location:          "remote",
+			Url:               url,
+			BackgroundRefetch: backgroundRefetch,
+			file:              file,
 		},
 	}, nil
 }
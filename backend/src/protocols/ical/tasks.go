@@ -0,0 +1,111 @@
+package ical
+
+import (
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	common "luna-backend/protocols/internal"
+	"luna-backend/types"
+	"net/http"
+
+	"github.com/emersion/go-ical"
+)
+
+type IcalTask struct {
+	uid      string
+	props    *types.TaskProps
+	calendar *IcalCalendar
+}
+
+func (calendar *IcalCalendar) taskFromIcal(component *ical.Component) (*IcalTask, *errors.ErrorTrace) {
+	uid, props, err := common.ParseIcalTask(component)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not parse iCal task")
+	}
+
+	return &IcalTask{
+		uid:      uid,
+		props:    props,
+		calendar: calendar,
+	}, nil
+}
+
+func (task *IcalTask) GetId() types.ID {
+	return crypto.DeriveID(task.calendar.GetId(), task.uid)
+}
+
+func (task *IcalTask) GetCalendar() types.Calendar {
+	return task.calendar
+}
+
+func (task *IcalTask) GetUid() string {
+	return task.uid
+}
+
+func (task *IcalTask) CanEdit() bool {
+	return false
+}
+
+func (task *IcalTask) CanDelete() bool {
+	return false
+}
+
+func (task *IcalTask) GetProps() *types.TaskProps {
+	return task.props
+}
+
+func (calendar *IcalCalendar) CanAddTasks() bool {
+	return false
+}
+
+func (calendar *IcalCalendar) GetTasks(q types.DatabaseQueries) ([]types.Task, *errors.ErrorTrace) {
+	tasks := []types.Task{}
+	for _, comp := range calendar.icalCalendar.Children {
+		// Modified instances of recurring tasks are not supported
+		if comp.Name != ical.CompToDo || comp.Props.Get(ical.PropRecurrenceID) != nil {
+			continue
+		}
+
+		task, tr := calendar.taskFromIcal(comp)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlDebug, "Could not get tasks from calendar %v (%v)", calendar.GetName(), calendar.GetId()).
+				AltStr(errors.LvlPlain, "Could not get tasks from calendar %v", calendar.GetName())
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+func (calendar *IcalCalendar) GetTask(taskId types.ID, q types.DatabaseQueries) (types.Task, *errors.ErrorTrace) {
+	tasks, tr := calendar.GetTasks(q)
+	if tr != nil {
+		return nil, tr
+	}
+
+	for _, task := range tasks {
+		if task.GetId() == taskId {
+			return task, nil
+		}
+	}
+
+	return nil, errors.New().Status(http.StatusNotFound).
+		Append(errors.LvlWordy, "Task %v not found", taskId).
+		AltStr(errors.LvlPlain, "Task not found")
+}
+
+/* Ical calendar is read-only */
+
+func (calendar *IcalCalendar) AddTask(props *types.TaskProps, q types.DatabaseQueries) (types.Task, *errors.ErrorTrace) {
+	return nil, errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (calendar *IcalCalendar) EditTask(task types.Task, props *types.TaskProps, q types.DatabaseQueries) (types.Task, *errors.ErrorTrace) {
+	return nil, errors.New().Status(http.StatusMethodNotAllowed)
+}
+
+func (calendar *IcalCalendar) DeleteTask(task types.Task, q types.DatabaseQueries) *errors.ErrorTrace {
+	return errors.New().Status(http.StatusMethodNotAllowed)
+}
@@ -2,7 +2,9 @@ package common
 
 import (
 	"fmt"
+	"luna-backend/constants"
 	"luna-backend/types"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,10 +41,14 @@ type IcalEventProps struct {
 	Uid          string
 	RecurrenceId string
 	EventDate    *types.EventDate
+	Reminders    []*types.EventReminder
+	Location     *types.EventLocation
+	Participants *types.EventParticipants
 }
 
-func ParseIcalEvent(props *ical.Props) (*IcalEventProps, bool, error) {
+func ParseIcalEvent(component *ical.Component) (*IcalEventProps, bool, error) {
 	mustUpdate := false
+	props := &component.Props
 
 	// Basic info
 	uid := props.Get(ical.PropUID)
@@ -148,11 +154,361 @@ func ParseIcalEvent(props *ical.Props) (*IcalEventProps, bool, error) {
 		Uid:          uid.Value,
 		RecurrenceId: recurrenceIdStr,
 		EventDate:    eventDate,
+		Reminders:    types.EventRemindersFromIcal(component),
+		Location:     ParseIcalLocation(props),
+		Participants: ParseIcalParticipants(props),
 	}
 
 	return parsedProps, mustUpdate, nil
 }
 
+// Parses the LOCATION, URL and GEO properties of an event.
+// Malformed URLs and coordinates are ignored. Returns nil if the event has no location.
+func ParseIcalLocation(props *ical.Props) *types.EventLocation {
+	location := &types.EventLocation{}
+
+	if prop := props.Get(ical.PropLocation); prop != nil {
+		location.Name = UnespaceIcalString(prop.Value)
+	}
+	if prop := props.Get(ical.PropURL); prop != nil && prop.Value != "" {
+		url, err := types.NewUrl(prop.Value)
+		if err == nil {
+			location.Url = url
+		}
+	}
+	if prop := props.Get(ical.PropGeo); prop != nil {
+		geo, err := types.ParseGeoCoordinates(prop.Value, ";")
+		if err == nil {
+			location.Geo = geo
+		}
+	}
+
+	if location.IsEmpty() {
+		return nil
+	}
+	return location
+}
+
+// Sets the LOCATION, URL and GEO properties of an event, or removes them if they are not set
+func SetIcalLocation(props *ical.Props, location *types.EventLocation) {
+	if location == nil {
+		location = &types.EventLocation{}
+	}
+
+	if location.Name != "" {
+		props.SetText(ical.PropLocation, EscapeIcalString(location.Name))
+	} else {
+		props.Del(ical.PropLocation)
+	}
+
+	if location.Url != nil {
+		prop := ical.NewProp(ical.PropURL)
+		prop.Value = location.Url.String()
+		props.Set(prop)
+	} else {
+		props.Del(ical.PropURL)
+	}
+
+	// GEO is a structured value, so the separator must not be escaped like in text values
+	if location.Geo != nil {
+		prop := ical.NewProp(ical.PropGeo)
+		prop.Value = strconv.FormatFloat(location.Geo.Latitude, 'f', -1, 64) + ";" + strconv.FormatFloat(location.Geo.Longitude, 'f', -1, 64)
+		props.Set(prop)
+	} else {
+		props.Del(ical.PropGeo)
+	}
+}
+
+var icalAttendeeRoles = map[string]string{
+	"CHAIR":           constants.AttendeeRoleChair,
+	"REQ-PARTICIPANT": constants.AttendeeRoleRequired,
+	"OPT-PARTICIPANT": constants.AttendeeRoleOptional,
+	"NON-PARTICIPANT": constants.AttendeeRoleNonParticipant,
+}
+
+var icalParticipationStatuses = map[string]string{
+	"NEEDS-ACTION": constants.ParticipationNeedsAction,
+	"ACCEPTED":     constants.ParticipationAccepted,
+	"DECLINED":     constants.ParticipationDeclined,
+	"TENTATIVE":    constants.ParticipationTentative,
+	"DELEGATED":    constants.ParticipationDelegated,
+}
+
+func findIcalKey(values map[string]string, value string) string {
+	for key, candidate := range values {
+		if candidate == value {
+			return key
+		}
+	}
+	return ""
+}
+
+// Extracts the email address from a calendar user address, falling back to the EMAIL parameter for non-mailto addresses
+func icalAddressToEmail(prop *ical.Prop) string {
+	if len(prop.Value) > len("mailto:") && strings.EqualFold(prop.Value[:len("mailto:")], "mailto:") {
+		return prop.Value[len("mailto:"):]
+	}
+	return prop.Params.Get(ical.ParamEmail)
+}
+
+// Parses the ORGANIZER and ATTENDEE properties of an event.
+// Attendees without an email address are ignored, and missing parameters take the defaults from RFC 5545.
+// Returns nil if the event has neither an organizer nor attendees.
+func ParseIcalParticipants(props *ical.Props) *types.EventParticipants {
+	participants := &types.EventParticipants{
+		Attendees: []*types.EventAttendee{},
+	}
+
+	if prop := props.Get(ical.PropOrganizer); prop != nil {
+		email := icalAddressToEmail(prop)
+		if email != "" {
+			participants.Organizer = &types.EventOrganizer{
+				Email: email,
+				Name:  prop.Params.Get(ical.ParamCommonName),
+			}
+		}
+	}
+
+	for _, prop := range props.Values(ical.PropAttendee) {
+		email := icalAddressToEmail(&prop)
+		if email == "" || participants.Find(email) != nil {
+			continue
+		}
+
+		role, known := icalAttendeeRoles[strings.ToUpper(prop.Params.Get(ical.ParamRole))]
+		if !known {
+			role = constants.AttendeeRoleRequired
+		}
+		status, known := icalParticipationStatuses[strings.ToUpper(prop.Params.Get(ical.ParamParticipationStatus))]
+		if !known {
+			status = constants.ParticipationNeedsAction
+		}
+
+		participants.Attendees = append(participants.Attendees, &types.EventAttendee{
+			Email:  email,
+			Name:   prop.Params.Get(ical.ParamCommonName),
+			Role:   role,
+			Status: status,
+			Rsvp:   strings.EqualFold(prop.Params.Get(ical.ParamRSVP), "TRUE"),
+		})
+	}
+
+	if participants.IsEmpty() {
+		return nil
+	}
+	return participants
+}
+
+// Sets the ORGANIZER and ATTENDEE properties of an event, or removes them if there are no participants.
+// Parameters unknown to Luna, like CUTYPE or DELEGATED-TO, are kept for participants that already existed.
+func SetIcalParticipants(props *ical.Props, participants *types.EventParticipants) {
+	if participants == nil {
+		participants = &types.EventParticipants{}
+	}
+
+	existing := map[string]ical.Prop{}
+	for _, prop := range props.Values(ical.PropAttendee) {
+		existing[strings.ToLower(icalAddressToEmail(&prop))] = prop
+	}
+
+	if participants.Organizer != nil {
+		prop := ical.NewProp(ical.PropOrganizer)
+		if old := props.Get(ical.PropOrganizer); old != nil && strings.EqualFold(icalAddressToEmail(old), participants.Organizer.Email) {
+			prop.Params = old.Params
+		}
+		prop.Value = "mailto:" + participants.Organizer.Email
+		setIcalParam(prop, ical.ParamCommonName, participants.Organizer.Name)
+		props.Set(prop)
+	} else {
+		props.Del(ical.PropOrganizer)
+	}
+
+	props.Del(ical.PropAttendee)
+	for _, attendee := range participants.Attendees {
+		prop := ical.NewProp(ical.PropAttendee)
+		if old, exists := existing[strings.ToLower(attendee.Email)]; exists {
+			prop.Params = old.Params
+		}
+		prop.Value = "mailto:" + attendee.Email
+		setIcalParam(prop, ical.ParamCommonName, attendee.Name)
+		setIcalParam(prop, ical.ParamRole, findIcalKey(icalAttendeeRoles, attendee.Role))
+		setIcalParam(prop, ical.ParamParticipationStatus, findIcalKey(icalParticipationStatuses, attendee.Status))
+		if attendee.Rsvp {
+			setIcalParam(prop, ical.ParamRSVP, "TRUE")
+		} else {
+			setIcalParam(prop, ical.ParamRSVP, "")
+		}
+		props.Add(prop)
+	}
+}
+
+func setIcalParam(prop *ical.Prop, name string, value string) {
+	if value == "" {
+		prop.Params.Del(name)
+	} else {
+		prop.Params.Set(name, value)
+	}
+}
+
+var icalTaskStatuses = map[string]string{
+	"NEEDS-ACTION": constants.TaskStatusNeedsAction,
+	"IN-PROCESS":   constants.TaskStatusInProcess,
+	"COMPLETED":    constants.TaskStatusCompleted,
+	"CANCELLED":    constants.TaskStatusCancelled,
+}
+
+// Parses a VTODO component and returns its UID alongside the task properties.
+// Only RELATED-TO properties with the PARENT relation type are considered, which is the default according to RFC 5545.
+func ParseIcalTask(component *ical.Component) (string, *types.TaskProps, error) {
+	props := &component.Props
+
+	uid := props.Get(ical.PropUID)
+	if uid == nil || uid.Value == "" {
+		return "", nil, fmt.Errorf("task has no uid")
+	}
+
+	task := &types.TaskProps{
+		Status: constants.TaskStatusNeedsAction,
+	}
+
+	if summary := props.Get(ical.PropSummary); summary != nil {
+		task.Name = UnespaceIcalString(summary.Value)
+	}
+	if description := props.Get(ical.PropDescription); description != nil {
+		task.Desc = UnespaceIcalString(description.Value)
+	}
+
+	if dtstart := props.Get(ical.PropDateTimeStart); dtstart != nil {
+		startTime, _, err := types.ParseIcalTime(dtstart)
+		if err != nil {
+			return "", nil, fmt.Errorf("could not parse start time %v: %v", dtstart.Value, err)
+		}
+		task.Start = startTime
+		task.AllDay = isIcalDate(dtstart)
+	}
+	if due := props.Get(ical.PropDue); due != nil {
+		dueTime, _, err := types.ParseIcalTime(due)
+		if err != nil {
+			return "", nil, fmt.Errorf("could not parse due time %v: %v", due.Value, err)
+		}
+		task.Due = dueTime
+		task.AllDay = isIcalDate(due)
+	}
+
+	if status := props.Get(ical.PropStatus); status != nil {
+		if parsed, exists := icalTaskStatuses[strings.ToUpper(status.Value)]; exists {
+			task.Status = parsed
+		}
+	}
+	if completed := props.Get(ical.PropCompleted); completed != nil {
+		completedTime, _, err := types.ParseIcalTime(completed)
+		if err != nil {
+			return "", nil, fmt.Errorf("could not parse completion time %v: %v", completed.Value, err)
+		}
+		task.Completed = completedTime
+	}
+
+	// Malformed numbers are ignored, since some clients write them with surrounding whitespace or as floats
+	if percent := props.Get(ical.PropPercentComplete); percent != nil {
+		parsed, err := strconv.Atoi(strings.TrimSpace(percent.Value))
+		if err == nil && parsed >= 0 && parsed <= 100 {
+			task.Percent = parsed
+		}
+	}
+	if priority := props.Get(ical.PropPriority); priority != nil {
+		parsed, err := strconv.Atoi(strings.TrimSpace(priority.Value))
+		if err == nil && parsed >= 0 && parsed <= 9 {
+			task.Priority = parsed
+		}
+	}
+
+	for _, related := range props.Values(ical.PropRelatedTo) {
+		relType := related.Params.Get(ical.ParamRelationshipType)
+		if relType == "" || strings.EqualFold(relType, "PARENT") {
+			task.Parent = related.Value
+			break
+		}
+	}
+
+	return uid.Value, task, nil
+}
+
+// Writes the task properties into a VTODO component.
+// Relations other than PARENT are preserved, so that subtasks created by other clients keep their siblings.
+func SetIcalTask(component *ical.Component, uid string, task *types.TaskProps) {
+	props := &component.Props
+
+	props.SetText(ical.PropUID, uid)
+	props.SetText(ical.PropSummary, EscapeIcalString(task.Name))
+
+	if task.Desc != "" {
+		props.SetText(ical.PropDescription, EscapeIcalString(task.Desc))
+	} else {
+		props.Del(ical.PropDescription)
+	}
+
+	setIcalTaskTime(props, ical.PropDateTimeStart, task.Start, task.AllDay)
+	setIcalTaskTime(props, ical.PropDue, task.Due, task.AllDay)
+
+	props.SetText(ical.PropStatus, findIcalKey(icalTaskStatuses, task.Status))
+	if task.Completed != nil {
+		props.SetDateTime(ical.PropCompleted, task.Completed.UTC())
+	} else {
+		props.Del(ical.PropCompleted)
+	}
+
+	setIcalInteger(props, ical.PropPercentComplete, task.Percent)
+	setIcalInteger(props, ical.PropPriority, task.Priority)
+
+	relations := []ical.Prop{}
+	for _, related := range props.Values(ical.PropRelatedTo) {
+		relType := related.Params.Get(ical.ParamRelationshipType)
+		if relType != "" && !strings.EqualFold(relType, "PARENT") {
+			relations = append(relations, related)
+		}
+	}
+	if task.Parent != "" {
+		parent := ical.NewProp(ical.PropRelatedTo)
+		parent.Value = task.Parent
+		relations = append(relations, *parent)
+	}
+	if len(relations) > 0 {
+		(*props)[ical.PropRelatedTo] = relations
+	} else {
+		props.Del(ical.PropRelatedTo)
+	}
+
+	timestamp := time.Now().UTC()
+	props.SetDateTime(ical.PropDateTimeStamp, timestamp)
+	props.SetDateTime(ical.PropLastModified, timestamp)
+}
+
+func setIcalTaskTime(props *ical.Props, name string, value *time.Time, allDay bool) {
+	switch {
+	case value == nil:
+		props.Del(name)
+	case allDay:
+		props.SetDate(name, *value)
+	default:
+		props.SetDateTime(name, *value)
+	}
+}
+
+// Integers are written without a VALUE parameter, since SetText would mark them as text
+func setIcalInteger(props *ical.Props, name string, value int) {
+	if value == 0 {
+		props.Del(name)
+		return
+	}
+	prop := ical.NewProp(name)
+	prop.Value = strconv.Itoa(value)
+	props.Set(prop)
+}
+
+func isIcalDate(prop *ical.Prop) bool {
+	return strings.EqualFold(prop.Params.Get(ical.ParamValue), string(ical.ValueDate)) || !strings.Contains(prop.Value, "T")
+}
+
 // TODO: timezones?
 func CalculateRecurrenceId(startTime *time.Time, allDay bool) string {
 	if allDay {
@@ -169,3 +525,18 @@ func ExtractDateFromRecurrenceId(event types.Event) *time.Time {
 	})
 	return parsedTime
 }
+
+// Inverse of CalculateRecurrenceId
+func ParseRecurrenceId(recurrenceId string) (*time.Time, error) {
+	var parsedTime time.Time
+	var err error
+	if len(recurrenceId) == len("20060102") {
+		parsedTime, err = time.ParseInLocation("20060102", recurrenceId, time.UTC)
+	} else {
+		parsedTime, err = time.Parse(time.RFC3339, recurrenceId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse recurrence id %v: %v", recurrenceId, err)
+	}
+	return &parsedTime, nil
+}
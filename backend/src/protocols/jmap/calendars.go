@@ -0,0 +1,372 @@
+package jmap
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	jmap "luna-backend/protocols/jmap/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+type JmapCalendar struct {
+	name       string
+	desc       string
+	color      *types.Color
+	overridden bool
+	settings   *JmapCalendarSettings
+	source     *JmapSource
+	canWrite   bool
+	canDelete  bool
+}
+
+type JmapCalendarSettings struct {
+	JmapId string `json:"jmap_id"`
+}
+
+// Servers that do not report rights are assumed to allow everything
+func (source *JmapSource) calendarFromJmap(jmapCalendar *jmap.Calendar) *JmapCalendar {
+	desc := ""
+	if jmapCalendar.Description != nil {
+		desc = *jmapCalendar.Description
+	}
+
+	var color *types.Color
+	if jmapCalendar.Color != nil {
+		color, _ = types.ParseColor(*jmapCalendar.Color)
+	}
+
+	canWrite := true
+	canDelete := true
+	if jmapCalendar.MyRights != nil {
+		canWrite = jmapCalendar.MyRights.MayWriteAll
+		canDelete = jmapCalendar.MyRights.MayDelete
+	}
+
+	return &JmapCalendar{
+		name:       jmapCalendar.Name,
+		desc:       desc,
+		color:      color,
+		overridden: false,
+		settings: &JmapCalendarSettings{
+			JmapId: jmapCalendar.Id,
+		},
+		source:    source,
+		canWrite:  canWrite,
+		canDelete: canDelete && !jmapCalendar.IsDefault,
+	}
+}
+
+func (settings *JmapCalendarSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (calendar *JmapCalendar) GetId() types.ID {
+	return crypto.DeriveID(calendar.source.id, calendar.settings.JmapId)
+}
+
+func (calendar *JmapCalendar) GetName() string {
+	return calendar.name
+}
+
+func (calendar *JmapCalendar) SetName(name string) {
+	calendar.name = name
+}
+
+func (calendar *JmapCalendar) GetDesc() string {
+	return calendar.desc
+}
+
+func (calendar *JmapCalendar) SetDesc(desc string) {
+	calendar.desc = desc
+}
+
+func (calendar *JmapCalendar) GetSource() types.Source {
+	return calendar.source
+}
+
+func (calendar *JmapCalendar) GetSettings() types.CalendarSettings {
+	return calendar.settings
+}
+
+func (calendar *JmapCalendar) GetColor() *types.Color {
+	if calendar.color == nil {
+		return types.ColorEmpty
+	} else {
+		return calendar.color
+	}
+}
+
+func (calendar *JmapCalendar) SetColor(color *types.Color) {
+	calendar.color = color
+}
+
+func (calendar *JmapCalendar) GetOverridden() bool {
+	return calendar.overridden
+}
+
+func (calendar *JmapCalendar) SetOverridden(overridden bool) {
+	calendar.overridden = overridden
+}
+
+func (calendar *JmapCalendar) CanEdit() bool {
+	return calendar.canWrite
+}
+
+func (calendar *JmapCalendar) CanDelete() bool {
+	return calendar.canDelete
+}
+
+func (calendar *JmapCalendar) CanAddEvents() bool {
+	return calendar.canWrite
+}
+
+func (calendar *JmapCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
+	tr := calendar.source.discoverSession(q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get events")
+	}
+
+	jmapEvents, tr := calendar.sync(start, end, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get events")
+	}
+
+	result := []types.Event{}
+	for _, jmapEvent := range jmapEvents {
+		events, tr := calendar.eventsFromJmap(jmapEvent)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlBroad, "Could not get events")
+		}
+
+		for _, event := range events {
+			// Stored events are returned in full if the server could not be reached, so we filter them here
+			date := event.GetDate()
+			if !date.Recurrence().Repeats() && (!date.Start().Before(end) || date.End().Before(start)) {
+				continue
+			}
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
+
+func (calendar *JmapCalendar) GetEvent(settings types.EventSettings, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	event, tr := calendar.getEvent(settings.(*JmapEventSettings), q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get event")
+	}
+	return event, nil
+}
+
+// Returns the recurring event itself or one of its overridden occurrences
+func (calendar *JmapCalendar) getEvent(settings *JmapEventSettings, q types.DatabaseQueries) (*JmapEvent, *errors.ErrorTrace) {
+	tr := calendar.source.discoverSession(q)
+	if tr != nil {
+		return nil, tr
+	}
+
+	jmapEvents, _, _, tr := calendar.getEvents([]string{settings.JmapId}, q)
+	if tr != nil {
+		return nil, tr
+	}
+	if len(jmapEvents) == 0 {
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Event %v not found", settings.JmapId).
+			AltStr(errors.LvlPlain, "Event not found")
+	}
+
+	events, tr := calendar.eventsFromJmap(jmapEvents[0])
+	if tr != nil {
+		return nil, tr
+	}
+
+	for _, event := range events {
+		if event.settings.OverrideKey == settings.OverrideKey {
+			return event, nil
+		}
+	}
+	return nil, errors.New().Status(http.StatusNotFound).
+		Append(errors.LvlDebug, "Occurrence %v of event %v not found", settings.OverrideKey, settings.JmapId).
+		AltStr(errors.LvlPlain, "Event not found")
+}
+
+// Runs CalendarEvent/set and returns the server-set properties of the created event, if any
+func (calendar *JmapCalendar) setEvents(args map[string]any, q types.DatabaseQueries) (map[string]any, *errors.ErrorTrace) {
+	source := calendar.source
+
+	tr := source.discoverSession(q)
+	if tr != nil {
+		return nil, tr
+	}
+	args["accountId"] = source.accountId
+	// Invitations and updates are sent by the server itself
+	args["sendSchedulingMessages"] = true
+
+	results, tr := source.call(q, invocation("CalendarEvent/set", args, "set"))
+	if tr != nil {
+		return nil, tr
+	}
+
+	var res jmap.SetResponse
+	tr = unmarshalResult(results, "set", &res)
+	if tr != nil {
+		return nil, tr
+	}
+	tr = setError(&res)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return res.Created["new"], nil
+}
+
+func (calendar *JmapCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	props := eventProps(name, desc, color, date, location, reminders)
+	props["@type"] = "Event"
+	props["uid"] = types.RandomId().String()
+	props["calendarIds"] = map[string]bool{calendar.settings.JmapId: true}
+	props["participants"], props["replyTo"] = participantsToJmap(participants)
+
+	created, tr := calendar.setEvents(map[string]any{
+		"create": map[string]any{
+			"new": props,
+		},
+	}, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not add event to calendar %v", calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not add event")
+	}
+
+	jmapId, _ := created["id"].(string)
+	if jmapId == "" {
+		return nil, errors.New().Status(http.StatusBadGateway).
+			Append(errors.LvlDebug, "Server did not report the created event").
+			Append(errors.LvlDebug, "Could not add event to calendar %v", calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not add event")
+	}
+
+	event, tr := calendar.getEvent(&JmapEventSettings{JmapId: jmapId}, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not add event to calendar %v", calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not add event")
+	}
+	return event, nil
+}
+
+// Overridden occurrences are edited by replacing their patch inside of the recurring event
+func (calendar *JmapCalendar) EditEvent(originalEvent types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, _ bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	original := originalEvent.(*JmapEvent)
+
+	props := eventProps(name, desc, color, date, location, reminders)
+	// Participants are only sent if they changed, since the server keeps details that Luna does not know about
+	if !participants.Equal(original.participants) {
+		props["participants"], props["replyTo"] = participantsToJmap(participants)
+	}
+
+	var update map[string]any
+	if original.settings.OverrideKey == "" {
+		update = props
+	} else {
+		delete(props, "recurrenceRules")
+		update = map[string]any{
+			"recurrenceOverrides/" + original.settings.OverrideKey: props,
+		}
+	}
+
+	_, tr := calendar.setEvents(map[string]any{
+		"update": map[string]any{
+			original.settings.JmapId: update,
+		},
+	}, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not edit event %v in calendar %v", originalEvent.GetId(), calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not edit event")
+	}
+
+	event, tr := calendar.getEvent(original.settings, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not edit event %v in calendar %v", originalEvent.GetId(), calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not edit event")
+	}
+	return event, nil
+}
+
+// Deleting an overridden occurrence excludes it from the recurring event
+func (calendar *JmapCalendar) DeleteEvent(event types.Event, q types.DatabaseQueries) *errors.ErrorTrace {
+	settings := event.GetSettings().(*JmapEventSettings)
+
+	args := map[string]any{}
+	if settings.OverrideKey == "" {
+		args["destroy"] = []string{settings.JmapId}
+	} else {
+		args["update"] = map[string]any{
+			settings.JmapId: map[string]any{
+				"recurrenceOverrides/" + settings.OverrideKey: map[string]any{"excluded": true},
+			},
+		}
+	}
+
+	_, tr := calendar.setEvents(args, q)
+	if tr != nil {
+		return tr.
+			Append(errors.LvlDebug, "Could not delete event %v from calendar %v", event.GetId(), calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not delete event")
+	}
+	return nil
+}
+
+// Every property that Luna knows about is sent, so that a patch replaces them completely
+func eventProps(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, reminders []*types.EventReminder) map[string]any {
+	props := map[string]any{
+		"title":            name,
+		"description":      desc,
+		"showWithoutTime":  date.AllDay(),
+		"duration":         jmap.FormatDuration(*date.Duration()),
+		"alerts":           remindersToJmap(reminders),
+		"useDefaultAlerts": false,
+	}
+
+	start := date.Start()
+	if date.AllDay() {
+		props["start"] = start.UTC().Format("2006-01-02") + "T00:00:00"
+		props["timeZone"] = nil
+	} else if start.Location() == time.Local || start.Location() == time.UTC {
+		// Luna stores times in UTC unless it knows the time zone of the event
+		props["start"] = start.UTC().Format(jmap.LocalDateTimeLayout)
+		props["timeZone"] = "Etc/UTC"
+	} else {
+		props["start"] = start.Format(jmap.LocalDateTimeLayout)
+		props["timeZone"] = start.Location().String()
+	}
+
+	if color.IsEmpty() {
+		props["color"] = nil
+	} else {
+		props["color"] = color.String()
+	}
+
+	props["recurrenceRules"] = recurrenceToJmap(date.Recurrence(), start.Location())
+	props["locations"], props["virtualLocations"] = locationToJmap(location)
+
+	return props
+}
+
+func (calendar *JmapCalendar) SupplyContext(ctx context.Context) {
+	calendar.source.SupplyContext(ctx)
+}
@@ -0,0 +1,417 @@
+package jmap
+
+import (
+	"encoding/json"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	common "luna-backend/protocols/internal"
+	jmap "luna-backend/protocols/jmap/internal"
+	"luna-backend/types"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type JmapEvent struct {
+	name         string
+	desc         string
+	color        *types.Color
+	overridden   bool
+	settings     *JmapEventSettings
+	calendar     *JmapCalendar
+	eventDate    *types.EventDate
+	reminders    []*types.EventReminder
+	location     *types.EventLocation
+	participants *types.EventParticipants
+}
+
+// Overrides of single occurrences are stored inside of their recurring event and addressed by the local start time they replace
+type JmapEventSettings struct {
+	JmapId            string `json:"jmap_id"`
+	Uid               string `json:"uid"`
+	RecurrenceId      string `json:"recurrence_id"`
+	OverrideKey       string `json:"override_key"`
+	IsFirstRecurrence bool   `json:"is_first_recurrence"`
+}
+
+func (settings *JmapEventSettings) Clone() *JmapEventSettings {
+	return &JmapEventSettings{
+		JmapId:            settings.JmapId,
+		Uid:               settings.Uid,
+		RecurrenceId:      settings.RecurrenceId,
+		OverrideKey:       settings.OverrideKey,
+		IsFirstRecurrence: settings.IsFirstRecurrence,
+	}
+}
+
+func (settings *JmapEventSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// Converts a JMAP event into the recurring event itself and one event for every overridden occurrence
+func (calendar *JmapCalendar) eventsFromJmap(jmapEvent *jmap.CalendarEvent) ([]*JmapEvent, *errors.ErrorTrace) {
+	master, location, tr := calendar.eventFromJmap(jmapEvent, "")
+	if tr != nil {
+		return nil, tr
+	}
+	events := []*JmapEvent{master}
+
+	keys := make([]string, 0, len(jmapEvent.RecurrenceOverrides))
+	for key := range jmapEvent.RecurrenceOverrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		patch := jmapEvent.RecurrenceOverrides[key]
+
+		occurrence, err := time.ParseInLocation(jmap.LocalDateTimeLayout, key, location)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusBadGateway).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not parse recurrence id %v", key).
+				Append(errors.LvlDebug, "Could not parse event %v", jmapEvent.Id).
+				AltStr(errors.LvlWordy, "Could not parse event")
+		}
+
+		if excluded, _ := patch["excluded"].(bool); excluded {
+			master.eventDate.Recurrence().AddException(&occurrence)
+			continue
+		}
+		master.eventDate.Recurrence().AddModifiedInstance(&occurrence)
+
+		overridden, tr := applyOverride(jmapEvent, key, patch)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlDebug, "Could not parse event %v", jmapEvent.Id).
+				AltStr(errors.LvlWordy, "Could not parse event")
+		}
+
+		event, _, tr := calendar.eventFromJmap(overridden, key)
+		if tr != nil {
+			return nil, tr
+		}
+		event.settings.RecurrenceId = common.CalculateRecurrenceId(&occurrence, master.eventDate.AllDay())
+		event.settings.IsFirstRecurrence = occurrence.Equal(*master.eventDate.Start())
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (calendar *JmapCalendar) eventFromJmap(jmapEvent *jmap.CalendarEvent, overrideKey string) (*JmapEvent, *time.Location, *errors.ErrorTrace) {
+	timeZone := jmapEvent.TimeZone
+	if jmapEvent.ShowWithoutTime {
+		// Whole days are the same everywhere
+		utc := "UTC"
+		timeZone = &utc
+	}
+
+	start, location, err := jmap.ParseLocalDateTime(jmapEvent.Start, timeZone)
+	if err != nil {
+		return nil, nil, errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not parse start time").
+			Append(errors.LvlDebug, "Could not parse event %v", jmapEvent.Id).
+			AltStr(errors.LvlWordy, "Could not parse event")
+	}
+
+	duration, err := jmap.ParseDuration(jmapEvent.Duration)
+	if err != nil {
+		return nil, nil, errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Could not parse duration").
+			Append(errors.LvlDebug, "Could not parse event %v", jmapEvent.Id).
+			AltStr(errors.LvlWordy, "Could not parse event")
+	}
+
+	recurrence := types.EmptyEventRecurrence()
+	if overrideKey == "" {
+		recurrence, err = recurrenceFromJmap(jmapEvent.RecurrenceRules, location)
+		if err != nil {
+			return nil, nil, errors.New().Status(http.StatusBadGateway).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlWordy, "Could not parse recurrence").
+				Append(errors.LvlDebug, "Could not parse event %v", jmapEvent.Id).
+				AltStr(errors.LvlWordy, "Could not parse event")
+		}
+	}
+
+	eventDate := types.NewEventDateFromDuration(start, &duration, jmapEvent.ShowWithoutTime, recurrence)
+	eventDate.SetTimezone(location)
+
+	// Named CSS colors are not supported, so those events take the color of their calendar
+	var color *types.Color
+	if jmapEvent.Color != nil {
+		color, _ = types.ParseColor(*jmapEvent.Color)
+	}
+
+	event := &JmapEvent{
+		name:       jmapEvent.Title,
+		desc:       jmapEvent.Description,
+		color:      color,
+		overridden: false,
+		settings: &JmapEventSettings{
+			JmapId:            jmapEvent.Id,
+			Uid:               jmapEvent.Uid,
+			OverrideKey:       overrideKey,
+			IsFirstRecurrence: overrideKey == "",
+		},
+		calendar:     calendar,
+		eventDate:    eventDate,
+		reminders:    remindersFromJmap(jmapEvent.Alerts),
+		location:     locationFromJmap(jmapEvent),
+		participants: participantsFromJmap(jmapEvent.Participants),
+	}
+
+	return event, location, nil
+}
+
+// Overrides are patches relative to the recurring event, whose paths are JSON pointers without the leading slash.
+// The start of an occurrence defaults to the start time that it replaces.
+func applyOverride(jmapEvent *jmap.CalendarEvent, key string, patch map[string]any) (*jmap.CalendarEvent, *errors.ErrorTrace) {
+	raw, err := json.Marshal(jmapEvent)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not marshal event")
+	}
+
+	var object map[string]any
+	err = json.Unmarshal(raw, &object)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not unmarshal event")
+	}
+
+	delete(object, "recurrenceRules")
+	delete(object, "recurrenceOverrides")
+	object["start"] = key
+
+	for path, value := range patch {
+		setPointer(object, path, value)
+	}
+
+	raw, err = json.Marshal(object)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not marshal override %v", key)
+	}
+
+	overridden := &jmap.CalendarEvent{}
+	err = json.Unmarshal(raw, overridden)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not apply override %v", key)
+	}
+	return overridden, nil
+}
+
+// A null value removes the property
+func setPointer(object map[string]any, path string, value any) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+	}
+
+	current := object
+	for _, segment := range segments[:len(segments)-1] {
+		next, isObject := current[segment].(map[string]any)
+		if !isObject {
+			next = map[string]any{}
+			current[segment] = next
+		}
+		current = next
+	}
+
+	last := segments[len(segments)-1]
+	if value == nil {
+		delete(current, last)
+	} else {
+		current[last] = value
+	}
+}
+
+// Only the first physical and virtual location are used
+func locationFromJmap(jmapEvent *jmap.CalendarEvent) *types.EventLocation {
+	location := &types.EventLocation{}
+
+	if physical := firstByKey(jmapEvent.Locations); physical != nil {
+		location.Name = physical.Name
+		if physical.Coordinates != "" {
+			coordinates := strings.TrimPrefix(strings.ToLower(physical.Coordinates), "geo:")
+			coordinates, _, _ = strings.Cut(coordinates, ";")
+			geo, err := types.ParseGeoCoordinates(coordinates, ",")
+			if err == nil {
+				location.Geo = geo
+			}
+		}
+	}
+
+	if virtual := firstByKey(jmapEvent.VirtualLocations); virtual != nil && virtual.Uri != "" {
+		url, err := types.NewUrl(virtual.Uri)
+		if err == nil {
+			location.Url = url
+		}
+	}
+
+	if location.IsEmpty() {
+		return nil
+	}
+	return location
+}
+
+func firstByKey[V any](values map[string]*V) *V {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	return values[keys[0]]
+}
+
+func locationToJmap(location *types.EventLocation) (map[string]*jmap.Location, map[string]*jmap.VirtualLocation) {
+	locations := map[string]*jmap.Location{}
+	virtualLocations := map[string]*jmap.VirtualLocation{}
+	if location == nil {
+		return locations, virtualLocations
+	}
+
+	if location.Name != "" || location.Geo != nil {
+		physical := &jmap.Location{
+			Type: "Location",
+			Name: location.Name,
+		}
+		if location.Geo != nil {
+			physical.Coordinates = "geo:" + strconv.FormatFloat(location.Geo.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(location.Geo.Longitude, 'f', -1, 64)
+		}
+		locations["location"] = physical
+	}
+
+	if location.Url != nil {
+		virtualLocations["url"] = &jmap.VirtualLocation{
+			Type: "VirtualLocation",
+			Uri:  location.Url.String(),
+		}
+	}
+
+	return locations, virtualLocations
+}
+
+func (event *JmapEvent) GetId() types.ID {
+	masterEventId := crypto.DeriveID(event.calendar.GetId(), event.settings.JmapId)
+
+	if event.settings.RecurrenceId == "" || event.settings.IsFirstRecurrence {
+		return masterEventId
+	}
+
+	return crypto.DeriveID(masterEventId, event.settings.RecurrenceId)
+}
+
+func (event *JmapEvent) GetName() string {
+	return event.name
+}
+
+func (event *JmapEvent) SetName(name string) {
+	event.name = name
+}
+
+func (event *JmapEvent) GetDesc() string {
+	return event.desc
+}
+
+func (event *JmapEvent) SetDesc(desc string) {
+	event.desc = desc
+}
+
+func (event *JmapEvent) GetCalendar() types.Calendar {
+	return event.calendar
+}
+
+func (event *JmapEvent) GetSettings() types.EventSettings {
+	return event.settings
+}
+
+func (event *JmapEvent) GetColor() *types.Color {
+	if event.color == nil {
+		return event.calendar.GetColor()
+	} else {
+		return event.color
+	}
+}
+
+func (event *JmapEvent) SetColor(color *types.Color) {
+	event.color = color
+}
+
+func (event *JmapEvent) GetOverridden() bool {
+	return event.overridden
+}
+
+func (event *JmapEvent) SetOverridden(overridden bool) {
+	event.overridden = overridden
+}
+
+func (event *JmapEvent) GetDate() *types.EventDate {
+	return event.eventDate
+}
+
+func (event *JmapEvent) GetReminders() []*types.EventReminder {
+	return event.reminders
+}
+
+func (event *JmapEvent) GetLocation() *types.EventLocation {
+	return event.location
+}
+
+func (event *JmapEvent) GetParticipants() *types.EventParticipants {
+	return event.participants
+}
+
+func (event *JmapEvent) Clone() types.Event {
+	return &JmapEvent{
+		name:         event.name,
+		desc:         event.desc,
+		color:        event.color.Clone(),
+		overridden:   event.overridden,
+		settings:     event.settings.Clone(),
+		calendar:     event.calendar,
+		eventDate:    event.eventDate.Clone(),
+		reminders:    types.CloneReminders(event.reminders),
+		location:     event.location.Clone(),
+		participants: event.participants.Clone(),
+	}
+}
+
+func (event *JmapEvent) SupplyMasterEvent(masterEvent types.Event) {
+	event.settings.RecurrenceId = common.CalculateRecurrenceId(event.eventDate.Start(), event.eventDate.AllDay())
+	event.settings.IsFirstRecurrence = masterEvent.GetDate().Start().Equal(*event.eventDate.Start())
+}
+
+func (event *JmapEvent) GetUid() string {
+	return event.settings.Uid
+}
+
+func (event *JmapEvent) GetRecurrenceId() string {
+	return event.settings.RecurrenceId
+}
+
+func (event *JmapEvent) CanEdit() bool {
+	return event.calendar.canWrite && !event.eventDate.Recurrence().Repeats()
+}
+
+func (event *JmapEvent) CanDelete() bool {
+	return event.calendar.canWrite && !event.eventDate.Recurrence().Repeats()
+}
@@ -0,0 +1,75 @@
+package jmap
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+const (
+	LocalDateTimeLayout = "2006-01-02T15:04:05"
+	UTCDateTimeLayout   = "2006-01-02T15:04:05Z"
+)
+
+// Events without a time zone are floating and take place at the same local time everywhere
+func ParseLocalDateTime(value string, timeZone *string) (*time.Time, *time.Location, error) {
+	location := time.Local
+	if timeZone != nil && *timeZone != "" {
+		var err error
+		location, err = time.LoadLocation(*timeZone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not load time zone %v: %v", *timeZone, err)
+		}
+	}
+
+	parsed, err := time.ParseInLocation(LocalDateTimeLayout, value, location)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse local date-time %v: %v", value, err)
+	}
+	return &parsed, location, nil
+}
+
+// JSCalendar durations use the same syntax as iCalendar durations
+func ParseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	prop := ical.NewProp(ical.PropDuration)
+	prop.Value = value
+	return prop.Duration()
+}
+
+func FormatDuration(duration time.Duration) string {
+	sign := ""
+	if duration < 0 {
+		sign = "-"
+		duration = -duration
+	}
+
+	days := duration / (24 * time.Hour)
+	duration -= days * 24 * time.Hour
+
+	var builder strings.Builder
+	builder.WriteString(sign + "P")
+	if days > 0 {
+		fmt.Fprintf(&builder, "%dD", days)
+	}
+	if duration > 0 || days == 0 {
+		builder.WriteString("T")
+		hours := duration / time.Hour
+		minutes := (duration % time.Hour) / time.Minute
+		seconds := (duration % time.Minute) / time.Second
+		if hours > 0 {
+			fmt.Fprintf(&builder, "%dH", hours)
+		}
+		if minutes > 0 {
+			fmt.Fprintf(&builder, "%dM", minutes)
+		}
+		if seconds > 0 || (hours == 0 && minutes == 0) {
+			fmt.Fprintf(&builder, "%dS", seconds)
+		}
+	}
+	return builder.String()
+}
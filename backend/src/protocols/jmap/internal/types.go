@@ -0,0 +1,212 @@
+package jmap
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// https://www.rfc-editor.org/rfc/rfc8620
+// https://datatracker.ietf.org/doc/draft-ietf-jmap-calendars/
+// https://www.rfc-editor.org/rfc/rfc8984 (JSCalendar)
+
+const (
+	CapabilityCore      = "urn:ietf:params:jmap:core"
+	CapabilityCalendars = "urn:ietf:params:jmap:calendars"
+)
+
+type Session struct {
+	ApiUrl          string            `json:"apiUrl"`
+	PrimaryAccounts map[string]string `json:"primaryAccounts"`
+	State           string            `json:"state"`
+}
+
+type Request struct {
+	Using       []string      `json:"using"`
+	MethodCalls []*Invocation `json:"methodCalls"`
+}
+
+type Response struct {
+	MethodResponses []*Invocation `json:"methodResponses"`
+	SessionState    string        `json:"sessionState"`
+}
+
+// Method calls and their responses are sent as [name, arguments, call id] triples
+type Invocation struct {
+	Name   string
+	Args   any
+	CallId string
+}
+
+func (invocation *Invocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{invocation.Name, invocation.Args, invocation.CallId})
+}
+
+// The arguments of responses are kept raw until the caller knows which type to expect
+func (invocation *Invocation) UnmarshalJSON(data []byte) error {
+	var triple []json.RawMessage
+	err := json.Unmarshal(data, &triple)
+	if err != nil {
+		return err
+	}
+	if len(triple) != 3 {
+		return fmt.Errorf("invocation has %v elements instead of 3", len(triple))
+	}
+
+	err = json.Unmarshal(triple[0], &invocation.Name)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(triple[2], &invocation.CallId)
+	if err != nil {
+		return err
+	}
+	invocation.Args = triple[1]
+	return nil
+}
+
+// Lets a method call use the result of an earlier call in the same request
+type ResultReference struct {
+	ResultOf string `json:"resultOf"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+}
+
+// Returned in place of a method response if the call failed
+type MethodError struct {
+	Type        string `json:"type"` // e.g. "cannotCalculateChanges" or "invalidArguments"
+	Description string `json:"description,omitempty"`
+}
+
+type SetError struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+type Calendar struct {
+	Id          string          `json:"id,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Color       *string         `json:"color,omitempty"` // a CSS color value
+	IsDefault   bool            `json:"isDefault,omitempty"`
+	MyRights    *CalendarRights `json:"myRights,omitempty"`
+}
+
+type CalendarRights struct {
+	MayWriteAll bool `json:"mayWriteAll"`
+	MayDelete   bool `json:"mayDelete"`
+	MayAdmin    bool `json:"mayAdmin"`
+}
+
+type GetResponse[T any] struct {
+	State    string   `json:"state"`
+	List     []T      `json:"list"`
+	NotFound []string `json:"notFound"`
+}
+
+type QueryResponse struct {
+	Ids      []string `json:"ids"`
+	Position int      `json:"position"`
+	Total    int      `json:"total"`
+}
+
+type ChangesResponse struct {
+	OldState       string   `json:"oldState"`
+	NewState       string   `json:"newState"`
+	HasMoreChanges bool     `json:"hasMoreChanges"`
+	Created        []string `json:"created"`
+	Updated        []string `json:"updated"`
+	Destroyed      []string `json:"destroyed"`
+}
+
+type SetResponse struct {
+	Created      map[string]map[string]any `json:"created"`
+	Updated      map[string]map[string]any `json:"updated"`
+	Destroyed    []string                  `json:"destroyed"`
+	NotCreated   map[string]*SetError      `json:"notCreated"`
+	NotUpdated   map[string]*SetError      `json:"notUpdated"`
+	NotDestroyed map[string]*SetError      `json:"notDestroyed"`
+}
+
+// A JSCalendar event with the JMAP specific properties.
+// Times are local date-times in the time zone of the event, or floating if there is none.
+type CalendarEvent struct {
+	Type                string                      `json:"@type"` // always "Event"
+	Id                  string                      `json:"id,omitempty"`
+	CalendarIds         map[string]bool             `json:"calendarIds"`
+	Uid                 string                      `json:"uid,omitempty"`
+	Title               string                      `json:"title"`
+	Description         string                      `json:"description"`
+	Start               string                      `json:"start"`
+	TimeZone            *string                     `json:"timeZone"`
+	Duration            string                      `json:"duration"`
+	ShowWithoutTime     bool                        `json:"showWithoutTime"`
+	Color               *string                     `json:"color"`
+	RecurrenceRules     []*RecurrenceRule           `json:"recurrenceRules"`
+	RecurrenceOverrides map[string]map[string]any   `json:"recurrenceOverrides"` // patches keyed by the local start of the replaced occurrence
+	Locations           map[string]*Location        `json:"locations"`
+	VirtualLocations    map[string]*VirtualLocation `json:"virtualLocations"`
+	Participants        map[string]*Participant     `json:"participants"`
+	ReplyTo             map[string]string           `json:"replyTo"`
+	Alerts              map[string]*Alert           `json:"alerts"`
+	UseDefaultAlerts    bool                        `json:"useDefaultAlerts"`
+}
+
+type RecurrenceRule struct {
+	Type           string   `json:"@type"` // always "RecurrenceRule"
+	Frequency      string   `json:"frequency"`
+	Interval       int      `json:"interval,omitempty"`
+	FirstDayOfWeek string   `json:"firstDayOfWeek,omitempty"`
+	ByDay          []*NDay  `json:"byDay,omitempty"`
+	ByMonthDay     []int    `json:"byMonthDay,omitempty"`
+	ByMonth        []string `json:"byMonth,omitempty"`
+	ByYearDay      []int    `json:"byYearDay,omitempty"`
+	ByWeekNo       []int    `json:"byWeekNo,omitempty"`
+	ByHour         []int    `json:"byHour,omitempty"`
+	ByMinute       []int    `json:"byMinute,omitempty"`
+	BySecond       []int    `json:"bySecond,omitempty"`
+	BySetPosition  []int    `json:"bySetPosition,omitempty"`
+	Count          int      `json:"count,omitempty"`
+	Until          string   `json:"until,omitempty"`
+}
+
+type NDay struct {
+	Type        string `json:"@type"` // always "NDay"
+	Day         string `json:"day"`   // "mo", "tu", ...
+	NthOfPeriod int    `json:"nthOfPeriod,omitempty"`
+}
+
+type Location struct {
+	Type        string `json:"@type"` // always "Location"
+	Name        string `json:"name,omitempty"`
+	Coordinates string `json:"coordinates,omitempty"` // a geo: URI
+}
+
+type VirtualLocation struct {
+	Type string `json:"@type"` // always "VirtualLocation"
+	Name string `json:"name,omitempty"`
+	Uri  string `json:"uri"`
+}
+
+type Participant struct {
+	Type                string            `json:"@type"` // always "Participant"
+	Name                string            `json:"name,omitempty"`
+	Email               string            `json:"email,omitempty"`
+	SendTo              map[string]string `json:"sendTo,omitempty"` // method -> URI, e.g. "imip" -> "mailto:..."
+	Roles               map[string]bool   `json:"roles"`            // "owner", "attendee", "optional", "informational", "chair"
+	ParticipationStatus string            `json:"participationStatus,omitempty"`
+	ExpectReply         bool              `json:"expectReply,omitempty"`
+}
+
+type Alert struct {
+	Type    string   `json:"@type"` // always "Alert"
+	Trigger *Trigger `json:"trigger"`
+	Action  string   `json:"action,omitempty"` // "display" or "email"
+}
+
+// Either an offset relative to the start or end of the event, or an absolute UTC time
+type Trigger struct {
+	Type       string `json:"@type"` // "OffsetTrigger" or "AbsoluteTrigger"
+	Offset     string `json:"offset,omitempty"`
+	RelativeTo string `json:"relativeTo,omitempty"`
+	When       string `json:"when,omitempty"`
+}
@@ -0,0 +1,203 @@
+package jmap
+
+import (
+	"fmt"
+	"luna-backend/constants"
+	jmap "luna-backend/protocols/jmap/internal"
+	"luna-backend/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSCalendar uses the same participation statuses as iCalendar, just in lowercase
+var jmapParticipationStatuses = map[string]bool{
+	constants.ParticipationNeedsAction: true,
+	constants.ParticipationAccepted:    true,
+	constants.ParticipationDeclined:    true,
+	constants.ParticipationTentative:   true,
+	constants.ParticipationDelegated:   true,
+}
+
+func participantEmail(participant *jmap.Participant) string {
+	if participant.Email != "" {
+		return participant.Email
+	}
+	if imip, exists := participant.SendTo["imip"]; exists {
+		return strings.TrimPrefix(strings.TrimPrefix(imip, "mailto:"), "MAILTO:")
+	}
+	return ""
+}
+
+// The participant with the owner role organizes the event.
+// Participants are sorted by their ids, since JSON objects have no order.
+func participantsFromJmap(participants map[string]*jmap.Participant) *types.EventParticipants {
+	result := &types.EventParticipants{
+		Attendees: []*types.EventAttendee{},
+	}
+
+	ids := make([]string, 0, len(participants))
+	for id := range participants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		participant := participants[id]
+		email := participantEmail(participant)
+		if email == "" {
+			continue
+		}
+
+		if participant.Roles["owner"] {
+			result.Organizer = &types.EventOrganizer{
+				Email: email,
+				Name:  participant.Name,
+			}
+		}
+		if !participant.Roles["attendee"] && !participant.Roles["optional"] && !participant.Roles["informational"] && !participant.Roles["chair"] {
+			continue
+		}
+
+		role := constants.AttendeeRoleRequired
+		switch {
+		case participant.Roles["chair"]:
+			role = constants.AttendeeRoleChair
+		case participant.Roles["optional"]:
+			role = constants.AttendeeRoleOptional
+		case participant.Roles["informational"]:
+			role = constants.AttendeeRoleNonParticipant
+		}
+
+		status := participant.ParticipationStatus
+		if !jmapParticipationStatuses[status] {
+			status = constants.ParticipationNeedsAction
+		}
+
+		result.Attendees = append(result.Attendees, &types.EventAttendee{
+			Email:  email,
+			Name:   participant.Name,
+			Role:   role,
+			Status: status,
+			Rsvp:   participant.ExpectReply,
+		})
+	}
+
+	if result.IsEmpty() {
+		return nil
+	}
+	return result
+}
+
+// The organizer is also listed as a participant, as JSCalendar expects
+func participantsToJmap(participants *types.EventParticipants) (map[string]*jmap.Participant, map[string]string) {
+	result := map[string]*jmap.Participant{}
+	if participants.IsEmpty() {
+		return result, nil
+	}
+
+	var replyTo map[string]string
+	if participants.Organizer != nil {
+		replyTo = map[string]string{"imip": "mailto:" + participants.Organizer.Email}
+		result["organizer"] = &jmap.Participant{
+			Type:   "Participant",
+			Name:   participants.Organizer.Name,
+			Email:  participants.Organizer.Email,
+			SendTo: replyTo,
+			Roles:  map[string]bool{"owner": true},
+		}
+	}
+
+	for i, attendee := range participants.Attendees {
+		roles := map[string]bool{"attendee": true}
+		switch attendee.Role {
+		case constants.AttendeeRoleChair:
+			roles["chair"] = true
+		case constants.AttendeeRoleOptional:
+			roles["optional"] = true
+		case constants.AttendeeRoleNonParticipant:
+			roles = map[string]bool{"informational": true}
+		}
+
+		// The organizer may attend their own event
+		id := fmt.Sprintf("attendee%d", i)
+		if participants.Organizer != nil && strings.EqualFold(attendee.Email, participants.Organizer.Email) {
+			id = "organizer"
+			roles["owner"] = true
+		}
+
+		result[id] = &jmap.Participant{
+			Type:                "Participant",
+			Name:                attendee.Name,
+			Email:               attendee.Email,
+			SendTo:              map[string]string{"imip": "mailto:" + attendee.Email},
+			Roles:               roles,
+			ParticipationStatus: attendee.Status,
+			ExpectReply:         attendee.Rsvp,
+		}
+	}
+
+	return result, replyTo
+}
+
+// Alerts with unknown triggers are skipped
+func remindersFromJmap(alerts map[string]*jmap.Alert) []*types.EventReminder {
+	ids := make([]string, 0, len(alerts))
+	for id := range alerts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	reminders := []*types.EventReminder{}
+	for _, id := range ids {
+		alert := alerts[id]
+		if alert.Trigger == nil {
+			continue
+		}
+
+		action := constants.ReminderActionDisplay
+		if alert.Action == constants.ReminderActionEmail {
+			action = constants.ReminderActionEmail
+		}
+
+		switch alert.Trigger.Type {
+		case "OffsetTrigger":
+			offset, err := jmap.ParseDuration(alert.Trigger.Offset)
+			if err != nil {
+				continue
+			}
+			reminders = append(reminders, types.NewRelativeReminder(action, offset, alert.Trigger.RelativeTo == "end"))
+		case "AbsoluteTrigger":
+			when, err := time.Parse(time.RFC3339, alert.Trigger.When)
+			if err != nil {
+				continue
+			}
+			reminders = append(reminders, types.NewAbsoluteReminder(action, when))
+		}
+	}
+	return reminders
+}
+
+func remindersToJmap(reminders []*types.EventReminder) map[string]*jmap.Alert {
+	alerts := map[string]*jmap.Alert{}
+	for i, reminder := range reminders {
+		trigger := &jmap.Trigger{}
+		if reminder.IsAbsolute() {
+			trigger.Type = "AbsoluteTrigger"
+			trigger.When = reminder.Time().UTC().Format(jmap.UTCDateTimeLayout)
+		} else {
+			trigger.Type = "OffsetTrigger"
+			trigger.Offset = jmap.FormatDuration(*reminder.Offset())
+			if reminder.RelatedToEnd() {
+				trigger.RelativeTo = "end"
+			}
+		}
+
+		alerts[fmt.Sprintf("alert%d", i)] = &jmap.Alert{
+			Type:    "Alert",
+			Trigger: trigger,
+			Action:  reminder.Action(),
+		}
+	}
+	return alerts
+}
@@ -0,0 +1,144 @@
+package jmap
+
+import (
+	"fmt"
+	jmap "luna-backend/protocols/jmap/internal"
+	"luna-backend/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+var jmapFrequencies = map[string]rrule.Frequency{
+	"yearly":   rrule.YEARLY,
+	"monthly":  rrule.MONTHLY,
+	"weekly":   rrule.WEEKLY,
+	"daily":    rrule.DAILY,
+	"hourly":   rrule.HOURLY,
+	"minutely": rrule.MINUTELY,
+	"secondly": rrule.SECONDLY,
+}
+
+// Indexed by rrule's day numbers, which start on Monday
+var jmapWeekdays = []string{"mo", "tu", "we", "th", "fr", "sa", "su"}
+var rruleWeekdays = []rrule.Weekday{rrule.MO, rrule.TU, rrule.WE, rrule.TH, rrule.FR, rrule.SA, rrule.SU}
+
+func weekdayFromJmap(day string) (rrule.Weekday, error) {
+	for i, name := range jmapWeekdays {
+		if name == strings.ToLower(day) {
+			return rruleWeekdays[i], nil
+		}
+	}
+	return rrule.MO, fmt.Errorf("unknown weekday %v", day)
+}
+
+// JSCalendar recurrence rules are a JSON representation of RRULEs, so they convert without loss.
+// Only the first rule is used, since events in Luna can only have a single rule.
+func recurrenceFromJmap(rules []*jmap.RecurrenceRule, location *time.Location) (*types.EventRecurrence, error) {
+	if len(rules) == 0 {
+		return types.EmptyEventRecurrence(), nil
+	}
+	rule := rules[0]
+
+	freq, known := jmapFrequencies[rule.Frequency]
+	if !known {
+		return nil, fmt.Errorf("unknown frequency %v", rule.Frequency)
+	}
+
+	option := &rrule.ROption{
+		Freq:       freq,
+		Interval:   rule.Interval,
+		Count:      rule.Count,
+		Bymonthday: rule.ByMonthDay,
+		Byyearday:  rule.ByYearDay,
+		Byweekno:   rule.ByWeekNo,
+		Byhour:     rule.ByHour,
+		Byminute:   rule.ByMinute,
+		Bysecond:   rule.BySecond,
+		Bysetpos:   rule.BySetPosition,
+	}
+
+	if rule.FirstDayOfWeek != "" {
+		wkst, err := weekdayFromJmap(rule.FirstDayOfWeek)
+		if err != nil {
+			return nil, err
+		}
+		option.Wkst = wkst
+	}
+
+	for _, nday := range rule.ByDay {
+		weekday, err := weekdayFromJmap(nday.Day)
+		if err != nil {
+			return nil, err
+		}
+		if nday.NthOfPeriod != 0 {
+			weekday = weekday.Nth(nday.NthOfPeriod)
+		}
+		option.Byweekday = append(option.Byweekday, weekday)
+	}
+
+	// Leap months (e.g. "5L") only exist in other calendar systems
+	for _, month := range rule.ByMonth {
+		parsed, err := strconv.Atoi(month)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported month %v", month)
+		}
+		option.Bymonth = append(option.Bymonth, parsed)
+	}
+
+	if rule.Until != "" {
+		until, err := time.ParseInLocation(jmap.LocalDateTimeLayout, rule.Until, location)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse end of recurrence %v: %v", rule.Until, err)
+		}
+		option.Until = until.UTC()
+	}
+
+	return types.EventRecurrenceFromLines([]string{"RRULE:" + option.RRuleString()})
+}
+
+// UNTIL is a local date-time in the time zone of the event
+func recurrenceToJmap(recurrence *types.EventRecurrence, location *time.Location) []*jmap.RecurrenceRule {
+	if !recurrence.Repeats() || recurrence.Rule() == nil {
+		return []*jmap.RecurrenceRule{}
+	}
+	option := recurrence.Rule()
+
+	rule := &jmap.RecurrenceRule{
+		Type:          "RecurrenceRule",
+		Frequency:     strings.ToLower(option.Freq.String()),
+		Interval:      option.Interval,
+		Count:         option.Count,
+		ByMonthDay:    option.Bymonthday,
+		ByYearDay:     option.Byyearday,
+		ByWeekNo:      option.Byweekno,
+		ByHour:        option.Byhour,
+		ByMinute:      option.Byminute,
+		BySecond:      option.Bysecond,
+		BySetPosition: option.Bysetpos,
+	}
+
+	if option.Wkst != rrule.MO {
+		rule.FirstDayOfWeek = jmapWeekdays[option.Wkst.Day()]
+	}
+
+	for _, weekday := range option.Byweekday {
+		rule.ByDay = append(rule.ByDay, &jmap.NDay{
+			Type:        "NDay",
+			Day:         jmapWeekdays[weekday.Day()],
+			NthOfPeriod: weekday.N(),
+		})
+	}
+
+	for _, month := range option.Bymonth {
+		rule.ByMonth = append(rule.ByMonth, strconv.Itoa(month))
+	}
+
+	if !option.Until.IsZero() {
+		rule.Until = option.Until.In(location).Format(jmap.LocalDateTimeLayout)
+	}
+
+	return []*jmap.RecurrenceRule{rule}
+}
@@ -0,0 +1,400 @@
+package jmap
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/net"
+	jmap "luna-backend/protocols/jmap/internal"
+	"luna-backend/types"
+	"net/http"
+)
+
+type JmapSource struct {
+	id       types.ID
+	name     string
+	settings *JmapSourceSettings
+	auth     types.AuthMethod
+
+	session   *jmap.Session
+	apiUrl    *types.Url
+	accountId string
+}
+
+// The URL of the session resource, e.g. https://api.fastmail.com/jmap/session
+type JmapSourceSettings struct {
+	Url *types.Url `json:"url"`
+}
+
+func (settings *JmapSourceSettings) GetBytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (source *JmapSource) GetType() string {
+	return constants.SourceJmap
+}
+
+func (source *JmapSource) GetId() types.ID {
+	return source.id
+}
+
+func (source *JmapSource) GetName() string {
+	return source.name
+}
+
+func (source *JmapSource) GetAuth() types.AuthMethod {
+	return source.auth
+}
+
+func (source *JmapSource) GetSettings() types.SourceSettings {
+	return source.settings
+}
+
+func (source *JmapSource) CanAddCalendars() bool {
+	return true
+}
+
+func NewJmapSource(name string, url *types.Url, auth types.AuthMethod) *JmapSource {
+	return &JmapSource{
+		id:   types.EmptyId(), // Placeholder until the database assigns an ID
+		name: name,
+		auth: auth,
+		settings: &JmapSourceSettings{
+			Url: url,
+		},
+	}
+}
+
+func PackJmapSource(id types.ID, name string, settings *JmapSourceSettings, auth types.AuthMethod) *JmapSource {
+	return &JmapSource{
+		id:       id,
+		name:     name,
+		settings: settings,
+		auth:     auth,
+	}
+}
+
+// Fetches the session resource once, which tells us where to send requests and which account holds the calendars
+func (source *JmapSource) discoverSession(q types.DatabaseQueries) *errors.ErrorTrace {
+	if source.session != nil {
+		return nil
+	}
+
+	var session jmap.Session
+	tr := net.FetchJson(source.settings.Url, "GET", source.auth, nil, "", q.GetContext(), &session)
+	if tr != nil {
+		return tr.
+			Append(errors.LvlWordy, "Could not fetch JMAP session")
+	}
+
+	accountId, exists := session.PrimaryAccounts[jmap.CapabilityCalendars]
+	if !exists {
+		return errors.New().Status(http.StatusBadRequest).
+			Append(errors.LvlPlain, "The JMAP server does not support calendars")
+	}
+
+	// The API URL may be relative to the session resource
+	apiUrl, err := source.settings.Url.URL().Parse(session.ApiUrl)
+	if err != nil {
+		return errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse API URL %v", session.ApiUrl).
+			Append(errors.LvlWordy, "Could not fetch JMAP session")
+	}
+
+	source.session = &session
+	source.apiUrl = (*types.Url)(apiUrl)
+	source.accountId = accountId
+	return nil
+}
+
+// Sends all method calls in a single request and returns the raw arguments of their responses by call id.
+// Method errors are returned as the error trace of the first failed call, with the JMAP error type as the last message.
+func (source *JmapSource) call(q types.DatabaseQueries, calls ...*jmap.Invocation) (map[string]json.RawMessage, *errors.ErrorTrace) {
+	tr := source.discoverSession(q)
+	if tr != nil {
+		return nil, tr
+	}
+
+	request := &jmap.Request{
+		Using:       []string{jmap.CapabilityCore, jmap.CapabilityCalendars},
+		MethodCalls: calls,
+	}
+
+	var response jmap.Response
+	tr = net.FetchJson(source.apiUrl, "POST", source.auth, request, "application/json", q.GetContext(), &response)
+	if tr != nil {
+		return nil, tr
+	}
+
+	results := make(map[string]json.RawMessage, len(response.MethodResponses))
+	for _, invocation := range response.MethodResponses {
+		args := invocation.Args.(json.RawMessage)
+		if invocation.Name == "error" {
+			methodErr := &jmap.MethodError{}
+			err := json.Unmarshal(args, methodErr)
+			if err != nil {
+				return nil, errors.New().Status(http.StatusBadGateway).
+					AddErr(errors.LvlDebug, err).
+					Append(errors.LvlDebug, "Could not unmarshal method error")
+			}
+			return nil, methodError(methodErr)
+		}
+		results[invocation.CallId] = args
+	}
+
+	return results, nil
+}
+
+func methodError(methodErr *jmap.MethodError) *errors.ErrorTrace {
+	status := http.StatusBadGateway
+	switch methodErr.Type {
+	case "cannotCalculateChanges":
+		status = http.StatusGone
+	case "forbidden", "accountReadOnly":
+		status = http.StatusForbidden
+	case "invalidArguments":
+		status = http.StatusBadRequest
+	}
+
+	tr := errors.New().Status(status)
+	if methodErr.Description != "" {
+		tr.Append(errors.LvlDebug, "%v", methodErr.Description)
+	}
+	return tr.Append(errors.LvlDebug, "JMAP method failed with %v", methodErr.Type)
+}
+
+func invocation(name string, args map[string]any, callId string) *jmap.Invocation {
+	return &jmap.Invocation{
+		Name:   name,
+		Args:   args,
+		CallId: callId,
+	}
+}
+
+func resultOf(callId string, name string, path string) *jmap.ResultReference {
+	return &jmap.ResultReference{
+		ResultOf: callId,
+		Name:     name,
+		Path:     path,
+	}
+}
+
+func unmarshalResult(results map[string]json.RawMessage, callId string, target any) *errors.ErrorTrace {
+	raw, exists := results[callId]
+	if !exists {
+		return errors.New().Status(http.StatusBadGateway).
+			Append(errors.LvlDebug, "Missing response to method call %v", callId)
+	}
+
+	err := json.Unmarshal(raw, target)
+	if err != nil {
+		return errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not unmarshal response to method call %v", callId)
+	}
+	return nil
+}
+
+// Turns the first failed create, update or destroy into an error
+func setError(response *jmap.SetResponse) *errors.ErrorTrace {
+	for _, failures := range []map[string]*jmap.SetError{response.NotCreated, response.NotUpdated, response.NotDestroyed} {
+		for id, failure := range failures {
+			status := http.StatusBadGateway
+			switch failure.Type {
+			case "notFound":
+				status = http.StatusNotFound
+			case "forbidden":
+				status = http.StatusForbidden
+			case "invalidProperties", "invalidPatch":
+				status = http.StatusBadRequest
+			}
+
+			tr := errors.New().Status(status)
+			if failure.Description != "" {
+				tr.Append(errors.LvlDebug, "%v", failure.Description)
+			}
+			return tr.Append(errors.LvlDebug, "Could not modify %v: %v", id, failure.Type)
+		}
+	}
+	return nil
+}
+
+func (source *JmapSource) GetCalendars(q types.DatabaseQueries) ([]types.Calendar, *errors.ErrorTrace) {
+	tr := source.discoverSession(q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get calendars")
+	}
+
+	results, tr := source.call(q, invocation("Calendar/get", map[string]any{
+		"accountId": source.accountId,
+		"ids":       nil,
+	}, "0"))
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get calendars")
+	}
+
+	var res jmap.GetResponse[*jmap.Calendar]
+	tr = unmarshalResult(results, "0", &res)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get calendars")
+	}
+
+	calendars := make([]types.Calendar, len(res.List))
+	for i, calendar := range res.List {
+		calendars[i] = source.calendarFromJmap(calendar)
+	}
+	return calendars, nil
+}
+
+func (source *JmapSource) GetCalendar(settings types.CalendarSettings, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	calendar, tr := source.getCalendar(settings.(*JmapCalendarSettings).JmapId, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get calendar")
+	}
+	return calendar, nil
+}
+
+func (source *JmapSource) getCalendar(jmapId string, q types.DatabaseQueries) (*JmapCalendar, *errors.ErrorTrace) {
+	tr := source.discoverSession(q)
+	if tr != nil {
+		return nil, tr
+	}
+
+	results, tr := source.call(q, invocation("Calendar/get", map[string]any{
+		"accountId": source.accountId,
+		"ids":       []string{jmapId},
+	}, "0"))
+	if tr != nil {
+		return nil, tr
+	}
+
+	var res jmap.GetResponse[*jmap.Calendar]
+	tr = unmarshalResult(results, "0", &res)
+	if tr != nil {
+		return nil, tr
+	}
+	if len(res.List) == 0 {
+		return nil, errors.New().Status(http.StatusNotFound).
+			Append(errors.LvlDebug, "Calendar %v not found", jmapId).
+			AltStr(errors.LvlPlain, "Calendar not found")
+	}
+
+	return source.calendarFromJmap(res.List[0]), nil
+}
+
+// Creates, updates or destroys a calendar and returns its new state, or nil if it was destroyed
+func (source *JmapSource) setCalendar(args map[string]any, jmapId string, q types.DatabaseQueries) (*JmapCalendar, *errors.ErrorTrace) {
+	tr := source.discoverSession(q)
+	if tr != nil {
+		return nil, tr
+	}
+	args["accountId"] = source.accountId
+
+	results, tr := source.call(q, invocation("Calendar/set", args, "0"))
+	if tr != nil {
+		return nil, tr
+	}
+
+	var res jmap.SetResponse
+	tr = unmarshalResult(results, "0", &res)
+	if tr != nil {
+		return nil, tr
+	}
+	tr = setError(&res)
+	if tr != nil {
+		return nil, tr
+	}
+
+	if _, destroyed := args["destroy"]; destroyed {
+		return nil, nil
+	}
+
+	if jmapId == "" {
+		created, exists := res.Created["new"]
+		if !exists {
+			return nil, errors.New().Status(http.StatusBadGateway).
+				Append(errors.LvlDebug, "Server did not report the created calendar")
+		}
+		jmapId, _ = created["id"].(string)
+	}
+
+	return source.getCalendar(jmapId, q)
+}
+
+func (source *JmapSource) AddCalendar(name string, desc string, color *types.Color, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	calendar, tr := source.setCalendar(map[string]any{
+		"create": map[string]any{
+			"new": calendarProps(name, desc, color),
+		},
+	}, "", q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not add calendar to source %v", source.GetId()).
+			AltStr(errors.LvlBroad, "Could not add calendar")
+	}
+	return calendar, nil
+}
+
+func (source *JmapSource) EditCalendar(calendar types.Calendar, name string, desc string, color *types.Color, override bool, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	jmapId := calendar.GetSettings().(*JmapCalendarSettings).JmapId
+
+	edited, tr := source.setCalendar(map[string]any{
+		"update": map[string]any{
+			jmapId: calendarProps(name, desc, color),
+		},
+	}, jmapId, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not edit calendar %v of source %v", calendar.GetId(), source.GetId()).
+			AltStr(errors.LvlBroad, "Could not edit calendar")
+	}
+	return edited, nil
+}
+
+func (source *JmapSource) DeleteCalendar(calendar types.Calendar, q types.DatabaseQueries) *errors.ErrorTrace {
+	jmapId := calendar.GetSettings().(*JmapCalendarSettings).JmapId
+
+	_, tr := source.setCalendar(map[string]any{
+		"destroy":               []string{jmapId},
+		"onDestroyRemoveEvents": true,
+	}, jmapId, q)
+	if tr != nil {
+		return tr.
+			Append(errors.LvlDebug, "Could not delete calendar %v of source %v", calendar.GetId(), source.GetId()).
+			AltStr(errors.LvlBroad, "Could not delete calendar")
+	}
+
+	return nil
+}
+
+func calendarProps(name string, desc string, color *types.Color) map[string]any {
+	props := map[string]any{
+		"name":        name,
+		"description": desc,
+	}
+	if color.IsEmpty() {
+		props["color"] = nil
+	} else {
+		props["color"] = color.String()
+	}
+	return props
+}
+
+func (source *JmapSource) Cleanup(_ types.DatabaseQueries) *errors.ErrorTrace { return nil }
+
+func (source *JmapSource) SupplyContext(ctx context.Context) {
+	if source.auth.GetType() == constants.AuthOauth {
+		source.auth.(*auth.OauthAuth).SupplyContext(ctx)
+	}
+}
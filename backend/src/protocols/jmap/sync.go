@@ -0,0 +1,300 @@
+package jmap
+
+import (
+	"encoding/json"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	jmap "luna-backend/protocols/jmap/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+// Brings the locally stored events within the window up to date and returns them.
+// The query only returns ids, so events that are already stored are only fetched again if /changes reports them as updated.
+// The state of CalendarEvent objects is kept as the sync token, which covers the whole account.
+// If the server cannot be reached, the stored events are returned.
+func (calendar *JmapCalendar) sync(start time.Time, end time.Time, q types.DatabaseQueries) ([]*jmap.CalendarEvent, *errors.ErrorTrace) {
+	calendarId := calendar.GetId()
+
+	state, tr := q.GetCalendarSyncState(calendarId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	objects, tr := q.GetRemoteObjects(calendarId)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	stored := make(map[string]*jmap.CalendarEvent, len(objects))
+	for _, object := range objects {
+		event := &jmap.CalendarEvent{}
+		err := json.Unmarshal([]byte(object.Data), event)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlDebug, "Could not unmarshal stored event %v", object.Href).
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+		stored[object.Href] = event
+	}
+
+	token := ""
+	if state.Method == constants.SyncMethodSyncToken {
+		token = state.Token
+	}
+
+	inWindow, changed, destroyed, newToken, tr := calendar.listChanges(start, end, token, q)
+	// The server no longer knows the old state, so we have to start over
+	if tr != nil && tr.GetStatus() == http.StatusGone {
+		destroyed = make([]string, 0, len(stored))
+		for id := range stored {
+			destroyed = append(destroyed, id)
+		}
+		token = ""
+		inWindow, changed, _, newToken, tr = calendar.listChanges(start, end, token, q)
+	}
+
+	deleted := []string{}
+	updated := map[string]*types.RemoteObjectDatabaseEntry{}
+
+	if tr == nil {
+		for _, id := range destroyed {
+			if _, exists := stored[id]; exists {
+				deleted = append(deleted, id)
+				delete(stored, id)
+			}
+		}
+
+		toFetch := []string{}
+		for _, id := range inWindow {
+			if _, exists := stored[id]; !exists {
+				toFetch = append(toFetch, id)
+			}
+		}
+		for _, id := range changed {
+			if _, exists := stored[id]; exists {
+				toFetch = append(toFetch, id)
+			}
+		}
+
+		var fetched []*jmap.CalendarEvent
+		var notFound []string
+		var fetchedState string
+		// The first synchronization always fetches, so that we learn the current state
+		if len(toFetch) > 0 || token == "" {
+			fetched, notFound, fetchedState, tr = calendar.getEvents(toFetch, q)
+		}
+		if token == "" {
+			newToken = fetchedState
+		}
+
+		if tr == nil {
+			for _, id := range notFound {
+				if _, exists := stored[id]; exists {
+					deleted = append(deleted, id)
+					delete(stored, id)
+				}
+			}
+
+			for _, event := range fetched {
+				// Events can be moved to a different calendar
+				if !event.CalendarIds[calendar.settings.JmapId] {
+					if _, exists := stored[event.Id]; exists {
+						deleted = append(deleted, event.Id)
+						delete(stored, event.Id)
+					}
+					continue
+				}
+
+				entry, tr := calendar.remoteObjectFromJmap(event)
+				if tr != nil {
+					return nil, tr.
+						Append(errors.LvlWordy, "Could not synchronize calendar")
+				}
+
+				updated[event.Id] = entry
+				stored[event.Id] = event
+			}
+		}
+	}
+
+	if tr != nil {
+		// Without a previous synchronization, there is nothing that we could fall back to
+		if state.LastSync == nil {
+			return nil, tr.
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+
+		// The stored events are still returned, but the source is not healthy
+		q.ReportSourceHealth(calendar.GetSource().GetId(), tr)
+		state.Status = constants.SyncStatusFailed
+		tr = q.SetCalendarSyncState(calendarId, state)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlWordy, "Could not synchronize calendar")
+		}
+
+		return mapValues(stored), nil
+	}
+
+	tr = q.DeleteRemoteObjects(calendarId, deleted)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	tr = q.SetRemoteObjects(mapValues(updated))
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	now := time.Now()
+	tr = q.SetCalendarSyncState(calendarId, &types.CalendarSyncState{
+		Token:    newToken,
+		Method:   constants.SyncMethodSyncToken,
+		Status:   constants.SyncStatusOk,
+		LastSync: &now,
+	})
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not synchronize calendar")
+	}
+
+	events := make([]*jmap.CalendarEvent, 0, len(inWindow))
+	for _, id := range inWindow {
+		if event, exists := stored[id]; exists {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// Lists the ids of all events within the window, and the changes since the given state if there is one.
+// Recurring events are matched by the server if any of their occurrences fall within the window.
+func (calendar *JmapCalendar) listChanges(start time.Time, end time.Time, sinceState string, q types.DatabaseQueries) ([]string, []string, []string, string, *errors.ErrorTrace) {
+	source := calendar.source
+
+	inWindow := []string{}
+	changed := []string{}
+	destroyed := []string{}
+
+	position := 0
+	for {
+		calls := []*jmap.Invocation{
+			invocation("CalendarEvent/query", map[string]any{
+				"accountId": source.accountId,
+				"filter": map[string]any{
+					"inCalendar": calendar.settings.JmapId,
+					"after":      start.UTC().Format(jmap.UTCDateTimeLayout),
+					"before":     end.UTC().Format(jmap.UTCDateTimeLayout),
+				},
+				"position":       position,
+				"calculateTotal": true,
+			}, "query"),
+		}
+		if sinceState != "" {
+			calls = append(calls, invocation("CalendarEvent/changes", map[string]any{
+				"accountId":  source.accountId,
+				"sinceState": sinceState,
+			}, "changes"))
+		}
+
+		results, tr := source.call(q, calls...)
+		if tr != nil {
+			return nil, nil, nil, "", tr.
+				Append(errors.LvlWordy, "Could not list events")
+		}
+
+		var query jmap.QueryResponse
+		tr = unmarshalResult(results, "query", &query)
+		if tr != nil {
+			return nil, nil, nil, "", tr.
+				Append(errors.LvlWordy, "Could not list events")
+		}
+		inWindow = append(inWindow, query.Ids...)
+		position += len(query.Ids)
+
+		moreChanges := false
+		if sinceState != "" {
+			var changes jmap.ChangesResponse
+			tr = unmarshalResult(results, "changes", &changes)
+			if tr != nil {
+				return nil, nil, nil, "", tr.
+					Append(errors.LvlWordy, "Could not list events")
+			}
+			changed = append(changed, changes.Created...)
+			changed = append(changed, changes.Updated...)
+			destroyed = append(destroyed, changes.Destroyed...)
+			sinceState = changes.NewState
+			moreChanges = changes.HasMoreChanges
+		}
+
+		moreIds := len(query.Ids) > 0 && position < query.Total
+		if !moreIds && !moreChanges {
+			return inWindow, changed, destroyed, sinceState, nil
+		}
+		if !moreIds {
+			// The query is repeated past its end, which returns no further ids
+			position = query.Total
+		}
+	}
+}
+
+func (calendar *JmapCalendar) getEvents(ids []string, q types.DatabaseQueries) ([]*jmap.CalendarEvent, []string, string, *errors.ErrorTrace) {
+	source := calendar.source
+
+	results, tr := source.call(q, invocation("CalendarEvent/get", map[string]any{
+		"accountId": source.accountId,
+		"ids":       ids,
+	}, "get"))
+	if tr != nil {
+		return nil, nil, "", tr.
+			Append(errors.LvlWordy, "Could not get events")
+	}
+
+	var res jmap.GetResponse[*jmap.CalendarEvent]
+	tr = unmarshalResult(results, "get", &res)
+	if tr != nil {
+		return nil, nil, "", tr.
+			Append(errors.LvlWordy, "Could not get events")
+	}
+
+	return res.List, res.NotFound, res.State, nil
+}
+
+func (calendar *JmapCalendar) remoteObjectFromJmap(event *jmap.CalendarEvent) (*types.RemoteObjectDatabaseEntry, *errors.ErrorTrace) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not marshal event %v", event.Id)
+	}
+
+	settings := &JmapEventSettings{
+		JmapId:            event.Id,
+		Uid:               event.Uid,
+		IsFirstRecurrence: true,
+	}
+
+	return &types.RemoteObjectDatabaseEntry{
+		Id:       crypto.DeriveID(calendar.GetId(), event.Id),
+		Calendar: calendar.GetId(),
+		Settings: settings.Bytes(),
+		Href:     event.Id,
+		Data:     string(data),
+	}, nil
+}
+
+func mapValues[K comparable, V any](m map[K]V) []V {
+	values := make([]V, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,320 @@
+package luna
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	common "luna-backend/protocols/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+type LunaCalendar struct {
+	name       string
+	desc       string
+	color      *types.Color
+	overridden bool
+	settings   *LunaCalendarSettings
+	source     *LunaSource
+}
+
+type LunaCalendarSettings struct {
+	Id types.ID `json:"id"`
+}
+
+func (settings *LunaCalendarSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (source *LunaSource) calendarFromEntry(entry *types.LunaCalendarDatabaseEntry) *LunaCalendar {
+	return &LunaCalendar{
+		name:       entry.Name,
+		desc:       entry.Description,
+		color:      entry.Color,
+		overridden: false,
+		settings: &LunaCalendarSettings{
+			Id: entry.Id,
+		},
+		source: source,
+	}
+}
+
+func (calendar *LunaCalendar) GetId() types.ID {
+	return calendar.settings.Id
+}
+
+func (calendar *LunaCalendar) GetName() string {
+	return calendar.name
+}
+
+func (calendar *LunaCalendar) SetName(name string) {
+	calendar.name = name
+}
+
+func (calendar *LunaCalendar) GetDesc() string {
+	return calendar.desc
+}
+
+func (calendar *LunaCalendar) SetDesc(desc string) {
+	calendar.desc = desc
+}
+
+func (calendar *LunaCalendar) GetSource() types.Source {
+	return calendar.source
+}
+
+func (calendar *LunaCalendar) GetSettings() types.CalendarSettings {
+	return calendar.settings
+}
+
+func (calendar *LunaCalendar) GetColor() *types.Color {
+	if calendar.color == nil {
+		return types.ColorEmpty
+	} else {
+		return calendar.color
+	}
+}
+
+func (calendar *LunaCalendar) SetColor(color *types.Color) {
+	calendar.color = color
+}
+
+func (calendar *LunaCalendar) GetOverridden() bool {
+	return calendar.overridden
+}
+
+func (calendar *LunaCalendar) SetOverridden(overridden bool) {
+	calendar.overridden = overridden
+}
+
+func (calendar *LunaCalendar) CanEdit() bool {
+	return true
+}
+
+func (calendar *LunaCalendar) CanDelete() bool {
+	return true
+}
+
+func (calendar *LunaCalendar) CanAddEvents() bool {
+	return true
+}
+
+func (calendar *LunaCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
+	entries, tr := q.GetLunaEvents(calendar.GetId(), start, end)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not get events from calendar %v (%v)", calendar.GetName(), calendar.GetId()).
+			AltStr(errors.LvlPlain, "Could not get events from calendar %v", calendar.GetName())
+	}
+
+	events := make([]types.Event, len(entries))
+	for i, entry := range entries {
+		events[i] = calendar.eventFromEntry(entry)
+	}
+
+	return events, nil
+}
+
+func (calendar *LunaCalendar) GetEvent(settings types.EventSettings, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	lunaSettings := settings.(*LunaEventSettings)
+
+	if !lunaSettings.isInstance() {
+		entry, tr := q.GetLunaEvent(lunaSettings.EventId)
+		if tr != nil {
+			return nil, tr.Append(errors.LvlBroad, "Could not get event")
+		}
+
+		event := calendar.eventFromEntry(entry)
+		event.settings = lunaSettings.Clone()
+		return event, nil
+	}
+
+	// Instances of recurring events are either stored as modified instances or derived from the master event
+	instanceEntry, tr := q.GetLunaEvent(crypto.DeriveID(lunaSettings.EventId, lunaSettings.RecurrenceId))
+	if tr == nil {
+		return calendar.eventFromEntry(instanceEntry), nil
+	} else if tr.GetStatus() != http.StatusNotFound {
+		return nil, tr.Append(errors.LvlBroad, "Could not get event")
+	}
+
+	masterEntry, tr := q.GetLunaEvent(lunaSettings.EventId)
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not get event")
+	}
+
+	instanceStart, err := common.ParseRecurrenceId(lunaSettings.RecurrenceId)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlBroad, "Could not get event")
+	}
+
+	event := calendar.eventFromEntry(masterEntry)
+	event.settings = lunaSettings.Clone()
+	event.eventDate = instanceDate(masterEntry.Date, instanceStart)
+
+	return event, nil
+}
+
+func (calendar *LunaCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	entry := &types.LunaEventDatabaseEntry{
+		Id:           types.RandomId(),
+		Calendar:     calendar.GetId(),
+		Master:       types.EmptyId(),
+		Name:         name,
+		Description:  desc,
+		Color:        color,
+		Date:         date,
+		Reminders:    reminders,
+		Location:     location,
+		Participants: participants,
+	}
+
+	tr := q.SetLunaEvent(entry)
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not add event")
+	}
+
+	return calendar.eventFromEntry(entry), nil
+}
+
+func (calendar *LunaCalendar) EditEvent(event types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, override bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	if override {
+		anyOverrides := false
+		if name != "" {
+			event.SetName(name)
+			anyOverrides = true
+		}
+		if desc != "" {
+			event.SetDesc(desc)
+			anyOverrides = true
+		}
+		if color != nil && !color.IsEmpty() {
+			event.SetColor(color)
+			anyOverrides = true
+		}
+
+		if anyOverrides {
+			tr := q.SetEventOverrides(event.GetId(), name, desc, color)
+			if tr != nil {
+				return nil, tr.Append(errors.LvlBroad, "Could not edit event")
+			}
+			return event, nil
+		} else {
+			tr := q.DeleteEventOverrides(event.GetId())
+			if tr != nil {
+				return nil, tr.Append(errors.LvlBroad, "Could not edit event")
+			}
+			return calendar.GetEvent(event.GetSettings(), q)
+		}
+	}
+
+	settings := event.GetSettings().(*LunaEventSettings)
+
+	var entry *types.LunaEventDatabaseEntry
+	if settings.isInstance() {
+		// Editing a single instance of a recurring event creates a modified instance
+		instanceStart, err := common.ParseRecurrenceId(settings.RecurrenceId)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusInternalServerError).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlBroad, "Could not edit event")
+		}
+
+		entry = &types.LunaEventDatabaseEntry{
+			Id:              event.GetId(),
+			Calendar:        calendar.GetId(),
+			Master:          settings.EventId,
+			RecurrenceId:    settings.RecurrenceId,
+			RecurrenceStart: instanceStart,
+			Name:            name,
+			Description:     desc,
+			Color:           color,
+			Date:            withoutRecurrence(date),
+			Reminders:       reminders,
+			Location:        location,
+			Participants:    participants,
+		}
+	} else {
+		originalEntry, tr := q.GetLunaEvent(settings.EventId)
+		if tr != nil {
+			return nil, tr.Append(errors.LvlBroad, "Could not edit event")
+		}
+
+		entry = &types.LunaEventDatabaseEntry{
+			Id:              originalEntry.Id,
+			Calendar:        originalEntry.Calendar,
+			Master:          originalEntry.Master,
+			RecurrenceId:    originalEntry.RecurrenceId,
+			RecurrenceStart: originalEntry.RecurrenceStart,
+			Name:            name,
+			Description:     desc,
+			Color:           color,
+			Date:            withStoredExceptions(date, originalEntry.Date),
+			Reminders:       reminders,
+			Location:        location,
+			Participants:    participants,
+		}
+	}
+
+	tr := q.SetLunaEvent(entry)
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not edit event")
+	}
+
+	tr = q.DeleteEventOverrides(event.GetId())
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not edit event")
+	}
+
+	return calendar.GetEvent(settings, q)
+}
+
+// Deleting a single instance of a recurring event excludes it from the recurrence.
+// Deleting the master event or its first instance deletes the whole series.
+func (calendar *LunaCalendar) DeleteEvent(event types.Event, q types.DatabaseQueries) *errors.ErrorTrace {
+	settings := event.GetSettings().(*LunaEventSettings)
+
+	if !settings.isInstance() {
+		tr := q.DeleteLunaEvent(settings.EventId)
+		if tr != nil {
+			return tr.Append(errors.LvlBroad, "Could not delete event")
+		}
+		return nil
+	}
+
+	instanceStart, err := common.ParseRecurrenceId(settings.RecurrenceId)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlBroad, "Could not delete event")
+	}
+
+	masterEntry, tr := q.GetLunaEvent(settings.EventId)
+	if tr != nil {
+		return tr.Append(errors.LvlBroad, "Could not delete event")
+	}
+	masterEntry.Date.Recurrence().AddException(instanceStart)
+
+	tr = q.SetLunaEvent(masterEntry)
+	if tr != nil {
+		return tr.Append(errors.LvlBroad, "Could not delete event")
+	}
+
+	tr = q.DeleteLunaEvent(event.GetId())
+	if tr != nil {
+		return tr.Append(errors.LvlBroad, "Could not delete event")
+	}
+
+	return nil
+}
+
+func (calendar *LunaCalendar) SupplyContext(ctx context.Context) {
+	calendar.source.SupplyContext(ctx)
+}
@@ -0,0 +1,229 @@
+package luna
+
+import (
+	"encoding/json"
+	"luna-backend/crypto"
+	common "luna-backend/protocols/internal"
+	"luna-backend/types"
+	"time"
+)
+
+type LunaEvent struct {
+	name         string
+	desc         string
+	color        *types.Color
+	overridden   bool
+	settings     *LunaEventSettings
+	calendar     *LunaCalendar
+	eventDate    *types.EventDate
+	reminders    []*types.EventReminder
+	location     *types.EventLocation
+	participants *types.EventParticipants
+}
+
+type LunaEventSettings struct {
+	EventId           types.ID `json:"event"` // the stored event, or the master event for instances of recurring events
+	RecurrenceId      string   `json:"recurrence_id"`
+	IsFirstRecurrence bool     `json:"is_first_recurrence"`
+}
+
+func (settings *LunaEventSettings) Clone() *LunaEventSettings {
+	return &LunaEventSettings{
+		EventId:           settings.EventId,
+		RecurrenceId:      settings.RecurrenceId,
+		IsFirstRecurrence: settings.IsFirstRecurrence,
+	}
+}
+
+func (settings *LunaEventSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// Whether the settings refer to a single instance of a recurring event rather than the whole series
+func (settings *LunaEventSettings) isInstance() bool {
+	return settings.RecurrenceId != "" && !settings.IsFirstRecurrence
+}
+
+func (calendar *LunaCalendar) eventFromEntry(entry *types.LunaEventDatabaseEntry) *LunaEvent {
+	settings := &LunaEventSettings{
+		EventId: entry.Id,
+	}
+	if !entry.Master.IsEmpty() {
+		settings.EventId = entry.Master
+		settings.RecurrenceId = entry.RecurrenceId
+	}
+
+	return &LunaEvent{
+		name:         entry.Name,
+		desc:         entry.Description,
+		color:        entry.Color,
+		overridden:   false,
+		settings:     settings,
+		calendar:     calendar,
+		eventDate:    entry.Date,
+		reminders:    entry.Reminders,
+		location:     entry.Location,
+		participants: entry.Participants,
+	}
+}
+
+// Rebuilds the date with a different start and recurrence, keeping the duration and timezone
+func rebuildDate(date *types.EventDate, start *time.Time, recurrence *types.EventRecurrence) *types.EventDate {
+	location, err := time.LoadLocation(date.Timezone())
+	if err != nil {
+		location = start.Location()
+	}
+
+	if !date.AllDay() {
+		localStart := start.In(location)
+		start = &localStart
+	}
+
+	var newDate *types.EventDate
+	if date.SpecifyDuration() {
+		duration := *date.Duration()
+		newDate = types.NewEventDateFromDuration(start, &duration, date.AllDay(), recurrence)
+	} else {
+		end := start.Add(*date.Duration())
+		newDate = types.NewEventDateFromEndTime(start, &end, date.AllDay(), recurrence)
+	}
+	newDate.SetTimezone(location)
+
+	return newDate
+}
+
+func instanceDate(masterDate *types.EventDate, instanceStart *time.Time) *types.EventDate {
+	return rebuildDate(masterDate, instanceStart, nil)
+}
+
+func withoutRecurrence(date *types.EventDate) *types.EventDate {
+	return rebuildDate(date, date.Start(), nil)
+}
+
+// Exceptions and additional dates cannot be edited directly, so they are kept when the series is edited
+func withStoredExceptions(date *types.EventDate, storedDate *types.EventDate) *types.EventDate {
+	if !date.Recurrence().Repeats() || date.Recurrence().Rule() == nil {
+		return withoutRecurrence(date)
+	}
+
+	recurrence, err := types.EventRecurrenceFromLines([]string{date.Recurrence().Rule().RRuleString()})
+	if err != nil {
+		return date
+	}
+	for _, exception := range storedDate.Recurrence().Except() {
+		recurrence.AddException(&exception)
+	}
+	for _, additional := range storedDate.Recurrence().Additional() {
+		recurrence.AddAdditional(&additional)
+	}
+
+	return rebuildDate(date, date.Start(), recurrence)
+}
+
+func (event *LunaEvent) GetId() types.ID {
+	if !event.settings.isInstance() {
+		return event.settings.EventId
+	}
+
+	return crypto.DeriveID(event.settings.EventId, event.settings.RecurrenceId)
+}
+
+func (event *LunaEvent) GetName() string {
+	return event.name
+}
+
+func (event *LunaEvent) SetName(name string) {
+	event.name = name
+}
+
+func (event *LunaEvent) GetDesc() string {
+	return event.desc
+}
+
+func (event *LunaEvent) SetDesc(desc string) {
+	event.desc = desc
+}
+
+func (event *LunaEvent) GetCalendar() types.Calendar {
+	return event.calendar
+}
+
+func (event *LunaEvent) GetSettings() types.EventSettings {
+	return event.settings
+}
+
+func (event *LunaEvent) GetColor() *types.Color {
+	if event.color == nil {
+		return event.calendar.GetColor()
+	} else {
+		return event.color
+	}
+}
+
+func (event *LunaEvent) SetColor(color *types.Color) {
+	event.color = color
+}
+
+func (event *LunaEvent) GetOverridden() bool {
+	return event.overridden
+}
+
+func (event *LunaEvent) SetOverridden(overridden bool) {
+	event.overridden = overridden
+}
+
+func (event *LunaEvent) GetDate() *types.EventDate {
+	return event.eventDate
+}
+
+func (event *LunaEvent) GetReminders() []*types.EventReminder {
+	return event.reminders
+}
+
+func (event *LunaEvent) GetLocation() *types.EventLocation {
+	return event.location
+}
+
+func (event *LunaEvent) GetParticipants() *types.EventParticipants {
+	return event.participants
+}
+
+func (event *LunaEvent) Clone() types.Event {
+	return &LunaEvent{
+		name:         event.name,
+		desc:         event.desc,
+		color:        event.color.Clone(),
+		overridden:   event.overridden,
+		settings:     event.settings.Clone(),
+		calendar:     event.calendar,
+		eventDate:    event.eventDate.Clone(),
+		reminders:    types.CloneReminders(event.reminders),
+		location:     event.location.Clone(),
+		participants: event.participants.Clone(),
+	}
+}
+
+func (event *LunaEvent) SupplyMasterEvent(masterEvent types.Event) {
+	event.settings.RecurrenceId = common.CalculateRecurrenceId(event.eventDate.Start(), event.eventDate.AllDay())
+	event.settings.IsFirstRecurrence = masterEvent.GetDate().Start().Equal(*event.eventDate.Start())
+}
+
+func (event *LunaEvent) GetUid() string {
+	return event.settings.EventId.String()
+}
+
+func (event *LunaEvent) GetRecurrenceId() string {
+	return event.settings.RecurrenceId
+}
+
+func (event *LunaEvent) CanEdit() bool {
+	return true
+}
+
+func (event *LunaEvent) CanDelete() bool {
+	return true
+}
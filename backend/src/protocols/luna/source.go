@@ -0,0 +1,194 @@
+package luna
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/types"
+)
+
+// Calendars hosted by Luna itself.
+// All calendars and events are stored in the database, so no remote server is required.
+type LunaSource struct {
+	id       types.ID
+	name     string
+	settings *LunaSourceSettings
+	auth     types.AuthMethod
+}
+
+type LunaSourceSettings struct{}
+
+func (settings *LunaSourceSettings) GetBytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (source *LunaSource) GetType() string {
+	return constants.SourceLuna
+}
+
+func (source *LunaSource) GetId() types.ID {
+	return source.id
+}
+
+func (source *LunaSource) GetName() string {
+	return source.name
+}
+
+func (source *LunaSource) GetAuth() types.AuthMethod {
+	return source.auth
+}
+
+func (source *LunaSource) GetSettings() types.SourceSettings {
+	return source.settings
+}
+
+func (source *LunaSource) CanAddCalendars() bool {
+	return true
+}
+
+func NewLunaSource(name string) *LunaSource {
+	return &LunaSource{
+		id:       types.EmptyId(), // Placeholder until the database assigns an ID
+		name:     name,
+		settings: &LunaSourceSettings{},
+		auth:     auth.NewNoAuth(),
+	}
+}
+
+func PackLunaSource(id types.ID, name string, settings *LunaSourceSettings, auth types.AuthMethod) *LunaSource {
+	return &LunaSource{
+		id:       id,
+		name:     name,
+		settings: settings,
+		auth:     auth,
+	}
+}
+
+func (source *LunaSource) GetCalendars(q types.DatabaseQueries) ([]types.Calendar, *errors.ErrorTrace) {
+	entries, tr := q.GetLunaCalendars(source.id)
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not get calendars")
+	}
+
+	calendars := make([]types.Calendar, len(entries))
+	for i, entry := range entries {
+		calendars[i] = source.calendarFromEntry(entry)
+	}
+
+	return calendars, nil
+}
+
+func (source *LunaSource) GetCalendar(settings types.CalendarSettings, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	lunaSettings := settings.(*LunaCalendarSettings)
+
+	entry, tr := q.GetLunaCalendar(lunaSettings.Id)
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not get calendar")
+	}
+
+	return source.calendarFromEntry(entry), nil
+}
+
+func (source *LunaSource) AddCalendar(name string, desc string, color *types.Color, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	entry := &types.LunaCalendarDatabaseEntry{
+		Id:          types.RandomId(),
+		Source:      source.id,
+		Name:        name,
+		Description: desc,
+		Color:       color,
+	}
+
+	tr := q.InsertLunaCalendar(entry)
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not add calendar")
+	}
+
+	return source.calendarFromEntry(entry), nil
+}
+
+func (source *LunaSource) EditCalendar(calendar types.Calendar, name string, desc string, color *types.Color, override bool, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	// Overrides are not necessary for calendars that Luna owns,
+	// but we still honor them so that Luna calendars behave like all others.
+	if override {
+		anyOverrides := false
+		if name != "" {
+			calendar.SetName(name)
+			anyOverrides = true
+		}
+		if desc != "" {
+			calendar.SetDesc(desc)
+			anyOverrides = true
+		}
+		if color != nil && !color.IsEmpty() {
+			calendar.SetColor(color)
+			anyOverrides = true
+		}
+
+		if anyOverrides {
+			tr := q.SetCalendarOverrides(calendar.GetId(), name, desc, color)
+			if tr != nil {
+				return nil, tr.Append(errors.LvlBroad, "Could not edit calendar")
+			}
+			return calendar, nil
+		} else {
+			tr := q.DeleteCalendarOverrides(calendar.GetId())
+			if tr != nil {
+				return nil, tr.Append(errors.LvlBroad, "Could not edit calendar")
+			}
+			return source.GetCalendar(calendar.GetSettings(), q)
+		}
+	}
+
+	entry := &types.LunaCalendarDatabaseEntry{
+		Id:          calendar.GetId(),
+		Source:      source.id,
+		Name:        name,
+		Description: desc,
+		Color:       color,
+	}
+
+	tr := q.UpdateLunaCalendar(entry)
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not edit calendar")
+	}
+
+	tr = q.DeleteCalendarOverrides(calendar.GetId())
+	if tr != nil {
+		return nil, tr.Append(errors.LvlBroad, "Could not edit calendar")
+	}
+
+	return source.calendarFromEntry(entry), nil
+}
+
+func (source *LunaSource) DeleteCalendar(calendar types.Calendar, q types.DatabaseQueries) *errors.ErrorTrace {
+	tr := q.DeleteLunaCalendar(calendar.GetId())
+	if tr != nil {
+		return tr.Append(errors.LvlBroad, "Could not delete calendar")
+	}
+	return nil
+}
+
+func (source *LunaSource) Cleanup(q types.DatabaseQueries) *errors.ErrorTrace {
+	entries, tr := q.GetLunaCalendars(source.id)
+	if tr != nil {
+		return tr.Append(errors.LvlWordy, "Could not get calendars to clean up")
+	}
+
+	for _, entry := range entries {
+		tr = q.DeleteLunaCalendar(entry.Id)
+		if tr != nil {
+			return tr.Append(errors.LvlWordy, "Could not clean up calendar %v", entry.Id)
+		}
+	}
+
+	return nil
+}
+
+func (source *LunaSource) SupplyContext(ctx context.Context) {
+}
@@ -0,0 +1,290 @@
+package microsoft
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	"luna-backend/net"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+type MicrosoftCalendar struct {
+	name       string
+	color      *types.Color
+	overridden bool
+	settings   *MicrosoftCalendarSettings
+	source     *MicrosoftSource
+	canEdit    bool
+	isDefault  bool
+}
+
+type MicrosoftCalendarSettings struct {
+	GraphId string `json:"graph_id"`
+}
+
+func (source *MicrosoftSource) calendarFromMicrosoft(calendar *microsoft.Calendar) *MicrosoftCalendar {
+	return &MicrosoftCalendar{
+		name:       calendar.Name,
+		color:      calendarColorFromMicrosoft(calendar),
+		overridden: false,
+		settings: &MicrosoftCalendarSettings{
+			GraphId: calendar.Id,
+		},
+		source:    source,
+		canEdit:   calendar.CanEdit,
+		isDefault: calendar.IsDefaultCalendar,
+	}
+}
+
+func (settings *MicrosoftCalendarSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func (calendar *MicrosoftCalendar) GetId() types.ID {
+	return crypto.DeriveID(calendar.source.id, calendar.settings.GraphId)
+}
+
+func (calendar *MicrosoftCalendar) GetName() string {
+	return calendar.name
+}
+
+func (calendar *MicrosoftCalendar) SetName(name string) {
+	calendar.name = name
+}
+
+// Outlook calendars do not have a description
+func (calendar *MicrosoftCalendar) GetDesc() string {
+	return ""
+}
+
+func (calendar *MicrosoftCalendar) SetDesc(desc string) {}
+
+func (calendar *MicrosoftCalendar) GetSource() types.Source {
+	return calendar.source
+}
+
+func (calendar *MicrosoftCalendar) GetSettings() types.CalendarSettings {
+	return calendar.settings
+}
+
+func (calendar *MicrosoftCalendar) GetColor() *types.Color {
+	if calendar.color == nil {
+		return types.ColorEmpty
+	} else {
+		return calendar.color
+	}
+}
+
+func (calendar *MicrosoftCalendar) SetColor(color *types.Color) {
+	calendar.color = color
+}
+
+func (calendar *MicrosoftCalendar) GetOverridden() bool {
+	return calendar.overridden
+}
+
+func (calendar *MicrosoftCalendar) SetOverridden(overridden bool) {
+	calendar.overridden = overridden
+}
+
+func (calendar *MicrosoftCalendar) CanEdit() bool {
+	return true
+}
+
+func (calendar *MicrosoftCalendar) CanDelete() bool {
+	return !calendar.isDefault
+}
+
+// Calendars shared with the user may be read-only
+func (calendar *MicrosoftCalendar) CanAddEvents() bool {
+	return calendar.canEdit
+}
+
+// The calendar view expands recurring events into their occurrences on the server,
+// so there is no need to mirror the calendar locally like for Google.
+func (calendar *MicrosoftCalendar) GetEvents(start time.Time, end time.Time, q types.DatabaseQueries) ([]types.Event, *errors.ErrorTrace) {
+	result := []types.Event{}
+
+	url := microsoft.ApiUrl().Subpage("me", "calendars", calendar.settings.GraphId, "calendarView")
+	query := url.Query()
+	query.Set("startDateTime", start.UTC().Format(time.RFC3339))
+	query.Set("endDateTime", end.UTC().Format(time.RFC3339))
+	url = url.SetQuery(query)
+
+	for url != nil {
+		var res microsoft.Events
+
+		tr := net.FetchJsonWithHeaders(url, "GET", calendar.source.auth, nil, "", preferHeaders, q.GetContext(), &res)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlBroad, "Could not get events")
+		}
+
+		for _, event := range res.Value {
+			if event.IsCancelled {
+				continue
+			}
+
+			converted, tr := calendar.eventFromMicrosoft(event, q)
+			if tr != nil {
+				return nil, tr.
+					Append(errors.LvlBroad, "Could not get events")
+			}
+
+			result = append(result, converted)
+		}
+
+		url, tr = nextPage(res.NextLink)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlBroad, "Could not get events")
+		}
+	}
+
+	return result, nil
+}
+
+func (calendar *MicrosoftCalendar) GetEvent(settings types.EventSettings, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	microsoftSettings := settings.(*MicrosoftEventSettings)
+
+	var res microsoft.Event
+
+	url := microsoft.ApiUrl().Subpage("me", "events", microsoftSettings.GraphId)
+
+	tr := net.FetchJsonWithHeaders(url, "GET", calendar.source.auth, nil, "", preferHeaders, q.GetContext(), &res)
+	if tr != nil {
+		return nil, tr
+	}
+
+	converted, tr := calendar.eventFromMicrosoft(&res, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get event")
+	}
+
+	return converted, nil
+}
+
+func (calendar *MicrosoftCalendar) AddEvent(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	event, tr := calendar.eventToMicrosoft(name, desc, color, date, location, reminders, nil, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not add event to calendar %v", calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not add event")
+	}
+
+	recurrence, err := recurrenceToMicrosoft(date)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadRequest).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlWordy, "Recurrence is not supported by Outlook").
+			Append(errors.LvlDebug, "Could not add event to calendar %v", calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not add event")
+	}
+	event.Recurrence = recurrence
+
+	if !participants.IsEmpty() {
+		event.Attendees = participantsToMicrosoft(participants)
+	}
+
+	url := microsoft.ApiUrl().Subpage("me", "calendars", calendar.settings.GraphId, "events")
+
+	var res microsoft.Event
+
+	tr = net.FetchJsonWithHeaders(url, "POST", calendar.source.auth, event, "application/json", preferHeaders, q.GetContext(), &res)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not add event to calendar %v", calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not add event")
+	}
+
+	converted, tr := calendar.eventFromMicrosoft(&res, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not add event to calendar %v", calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not add event")
+	}
+
+	return converted, nil
+}
+
+// Editing an occurrence of a recurring event turns it into an exception, so its recurrence can not be changed
+func (calendar *MicrosoftCalendar) EditEvent(originalEvent types.Event, name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, participants *types.EventParticipants, reminders []*types.EventReminder, _ bool, q types.DatabaseQueries) (types.Event, *errors.ErrorTrace) {
+	originalMicrosoftEvent := originalEvent.(*MicrosoftEvent)
+
+	event, tr := calendar.eventToMicrosoft(name, desc, color, date, location, reminders, originalMicrosoftEvent.categories, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not edit event %v in calendar %v", originalEvent.GetId(), calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not edit event")
+	}
+
+	// Categories are left alone if the color did not change, which also keeps events without colored categories
+	// inheriting the color of their calendar
+	if color.Equals(originalMicrosoftEvent.GetColor()) {
+		event.Categories = nil
+	}
+
+	if originalMicrosoftEvent.settings.SeriesMasterId == "" {
+		recurrence, err := recurrenceToMicrosoft(date)
+		if err != nil {
+			return nil, errors.New().Status(http.StatusBadRequest).
+				AddErr(errors.LvlDebug, err).
+				Append(errors.LvlWordy, "Recurrence is not supported by Outlook").
+				Append(errors.LvlDebug, "Could not edit event %v in calendar %v", originalEvent.GetId(), calendar.GetId()).
+				AltStr(errors.LvlBroad, "Could not edit event")
+		}
+		event.Recurrence = recurrence
+	}
+
+	// Attendees are only sent if they changed, since sending them makes Exchange send out updated invitations
+	if !participants.Equal(originalMicrosoftEvent.participants) {
+		event.Attendees = participantsToMicrosoft(participants)
+	}
+
+	url := microsoft.ApiUrl().Subpage("me", "events", originalMicrosoftEvent.settings.GraphId)
+
+	var res microsoft.Event
+
+	tr = net.FetchJsonWithHeaders(url, "PATCH", calendar.source.auth, event, "application/json", preferHeaders, q.GetContext(), &res)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not edit event %v in calendar %v", originalEvent.GetId(), calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not edit event")
+	}
+
+	converted, tr := calendar.eventFromMicrosoft(&res, q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not edit event %v in calendar %v", originalEvent.GetId(), calendar.GetId()).
+			AltStr(errors.LvlBroad, "Could not edit event")
+	}
+
+	return converted, nil
+}
+
+// Exchange sends cancellations to the attendees by itself
+func (calendar *MicrosoftCalendar) DeleteEvent(event types.Event, q types.DatabaseQueries) *errors.ErrorTrace {
+	microsoftSettings := event.GetSettings().(*MicrosoftEventSettings)
+
+	url := microsoft.ApiUrl().Subpage("me", "events", microsoftSettings.GraphId)
+
+	_, tr := net.FetchBytes(url, "DELETE", calendar.source.auth, nil, "", "", q.GetContext())
+	if tr != nil {
+		return tr
+	}
+
+	return nil
+}
+
+func (calendar *MicrosoftCalendar) SupplyContext(ctx context.Context) {
+	calendar.source.SupplyContext(ctx)
+}
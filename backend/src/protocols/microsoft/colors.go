@@ -0,0 +1,166 @@
+package microsoft
+
+import (
+	"luna-backend/errors"
+	"luna-backend/net"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+)
+
+// Approximations of the calendar colors displayed by Outlook.
+// Graph only accepts these names when changing the color of a calendar.
+var calendarColors = map[string]string{
+	"lightBlue":   "#a6d1f5",
+	"lightGreen":  "#87d28e",
+	"lightOrange": "#fcab73",
+	"lightGray":   "#c0c0c0",
+	"lightYellow": "#f4d07a",
+	"lightTeal":   "#6fd4d0",
+	"lightPink":   "#f08cc0",
+	"lightBrown":  "#cba287",
+	"lightRed":    "#f88c9b",
+}
+
+// Approximations of the category colors displayed by Outlook
+var categoryColors = map[string]string{
+	"preset0":  "#e74856",
+	"preset1":  "#ff8c00",
+	"preset2":  "#ab7b4a",
+	"preset3":  "#fff100",
+	"preset4":  "#47d041",
+	"preset5":  "#30c6cc",
+	"preset6":  "#73aa24",
+	"preset7":  "#00bcf2",
+	"preset8":  "#8764b8",
+	"preset9":  "#f495bf",
+	"preset10": "#4a76a0",
+	"preset11": "#485d74",
+	"preset12": "#abadb0",
+	"preset13": "#6e6e6e",
+	"preset14": "#1f1f1f",
+	"preset15": "#a4262c",
+	"preset16": "#ca5010",
+	"preset17": "#8e562e",
+	"preset18": "#c19c00",
+	"preset19": "#0b6a0b",
+	"preset20": "#038387",
+	"preset21": "#5b7c0f",
+	"preset22": "#004e8c",
+	"preset23": "#5c2e91",
+	"preset24": "#9b0062",
+}
+
+// Custom colors picked in Outlook take precedence over the named presets
+func calendarColorFromMicrosoft(calendar *microsoft.Calendar) *types.Color {
+	if calendar.HexColor != "" {
+		color, err := types.ParseColor(calendar.HexColor)
+		if err == nil {
+			return color
+		}
+	}
+	if hex, exists := calendarColors[calendar.Color]; exists {
+		return presetColor(hex)
+	}
+	return types.ColorEmpty.Clone()
+}
+
+// Empty colors are mapped to "auto", which lets Outlook pick a color
+func calendarColorToMicrosoft(color *types.Color) string {
+	if color.IsEmpty() {
+		return "auto"
+	}
+	return closestColor(color, calendarColors)
+}
+
+// The presets above are known to be valid colors
+func presetColor(hex string) *types.Color {
+	color, _ := types.ParseColor(hex)
+	return color
+}
+
+func closestColor(color *types.Color, colors map[string]string) string {
+	closestDist := ^uint(0)
+	closest := ""
+	for name, hex := range colors {
+		dist := color.Distance(presetColor(hex))
+		if dist < closestDist {
+			closestDist = dist
+			closest = name
+		}
+	}
+	return closest
+}
+
+func (source *MicrosoftSource) fetchCategories(q types.DatabaseQueries) *errors.ErrorTrace {
+	if source.categories == nil {
+		var res microsoft.Categories
+
+		tr := net.FetchJson(microsoft.ApiUrl().Subpage("me", "outlook", "masterCategories"), "GET", source.auth, nil, "", q.GetContext(), &res)
+		if tr != nil {
+			return tr
+		}
+
+		source.categories = res.Value
+	}
+
+	return nil
+}
+
+// Outlook has no event colors, so the color of the first category of an event is used instead.
+// Events without colored categories take the color of their calendar.
+func (source *MicrosoftSource) eventColorFromCategories(categories *[]string, q types.DatabaseQueries) *types.Color {
+	if categories == nil || len(*categories) == 0 || source.fetchCategories(q) != nil {
+		return nil
+	}
+
+	for _, name := range *categories {
+		for _, category := range source.categories {
+			if category.DisplayName != name {
+				continue
+			}
+			if hex, exists := categoryColors[category.Color]; exists {
+				return presetColor(hex)
+			}
+		}
+	}
+	return nil
+}
+
+// Picks the category whose color is closest to the requested one and puts it first, keeping all other categories.
+// If the color is empty, colored categories are removed so that the event takes the color of its calendar.
+// Returns nil if the categories do not need to change.
+func (source *MicrosoftSource) categoriesForColor(color *types.Color, current *[]string, q types.DatabaseQueries) (*[]string, *errors.ErrorTrace) {
+	tr := source.fetchCategories(q)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not map color %v to an Outlook category", color.String())
+	}
+
+	colored := map[string]string{}
+	for _, category := range source.categories {
+		if hex, exists := categoryColors[category.Color]; exists {
+			colored[category.DisplayName] = hex
+		}
+	}
+
+	others := []string{}
+	if current != nil {
+		for _, name := range *current {
+			if _, isColored := colored[name]; !isColored {
+				others = append(others, name)
+			}
+		}
+	}
+
+	if color.IsEmpty() {
+		return &others, nil
+	}
+
+	// The user has no colored categories that we could assign
+	if len(colored) == 0 {
+		return nil, nil
+	}
+
+	categories := append([]string{closestColor(color, colored)}, others...)
+	return &categories, nil
+}
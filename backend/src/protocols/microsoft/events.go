@@ -0,0 +1,308 @@
+package microsoft
+
+import (
+	"encoding/json"
+	"luna-backend/crypto"
+	"luna-backend/errors"
+	common "luna-backend/protocols/internal"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+	"net/http"
+	"time"
+)
+
+type MicrosoftEvent struct {
+	name         string
+	desc         string
+	color        *types.Color
+	overridden   bool
+	settings     *MicrosoftEventSettings
+	calendar     *MicrosoftCalendar
+	eventDate    *types.EventDate
+	reminders    []*types.EventReminder
+	location     *types.EventLocation
+	participants *types.EventParticipants
+
+	categories *[]string
+}
+
+type MicrosoftEventSettings struct {
+	GraphId        string `json:"graph_id"`
+	Uid            string `json:"ical_id"`
+	RecurrenceId   string `json:"recurrence_id"`
+	SeriesMasterId string `json:"series_master_id"`
+}
+
+func (settings *MicrosoftEventSettings) Clone() *MicrosoftEventSettings {
+	return &MicrosoftEventSettings{
+		GraphId:        settings.GraphId,
+		Uid:            settings.Uid,
+		RecurrenceId:   settings.RecurrenceId,
+		SeriesMasterId: settings.SeriesMasterId,
+	}
+}
+
+func (settings *MicrosoftEventSettings) Bytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// Recurring events are always returned as individual occurrences, so the recurrence of the series is not read back
+func (calendar *MicrosoftCalendar) eventFromMicrosoft(graphEvent *microsoft.Event, q types.DatabaseQueries) (*MicrosoftEvent, *errors.ErrorTrace) {
+	if graphEvent.Start == nil || graphEvent.End == nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			Append(errors.LvlDebug, "Event %v has no start or end", graphEvent.Id).
+			Append(errors.LvlDebug, "Could not parse event %v", graphEvent.Id).
+			AltStr(errors.LvlWordy, "Could not parse event")
+	}
+
+	startTime, tr := graphEvent.Start.Parse()
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not parse start time").
+			Append(errors.LvlDebug, "Could not parse event %v", graphEvent.Id).
+			AltStr(errors.LvlWordy, "Could not parse event")
+	}
+	endTime, tr := graphEvent.End.Parse()
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlWordy, "Could not parse end time").
+			Append(errors.LvlDebug, "Could not parse event %v", graphEvent.Id).
+			AltStr(errors.LvlWordy, "Could not parse event")
+	}
+
+	eventDate := types.NewEventDateFromEndTime(startTime, endTime, graphEvent.IsAllDay, types.EmptyEventRecurrence())
+
+	// Exceptions may have been moved, so they are identified by the start of the occurrence they replace
+	var recurrenceId string
+	if graphEvent.SeriesMasterId != "" {
+		originalStart, err := time.Parse(time.RFC3339, graphEvent.OriginalStart)
+		if err != nil {
+			originalStart = *startTime
+		}
+		recurrenceId = common.CalculateRecurrenceId(&originalStart, graphEvent.IsAllDay)
+	}
+
+	settings := &MicrosoftEventSettings{
+		GraphId:        graphEvent.Id,
+		Uid:            graphEvent.ICalUId,
+		RecurrenceId:   recurrenceId,
+		SeriesMasterId: graphEvent.SeriesMasterId,
+	}
+
+	desc := ""
+	if graphEvent.Body != nil {
+		desc = graphEvent.Body.Content
+	}
+
+	event := &MicrosoftEvent{
+		name:         graphEvent.Subject,
+		desc:         desc,
+		color:        calendar.source.eventColorFromCategories(graphEvent.Categories, q),
+		overridden:   false,
+		settings:     settings,
+		calendar:     calendar,
+		eventDate:    eventDate,
+		reminders:    remindersFromMicrosoft(graphEvent),
+		location:     locationFromMicrosoft(graphEvent),
+		participants: participantsFromMicrosoft(graphEvent.Organizer, graphEvent.Attendees),
+
+		categories: graphEvent.Categories,
+	}
+
+	return event, nil
+}
+
+// Builds the fields shared by new and edited events.
+// The current categories are needed to keep the categories that do not carry a color.
+func (calendar *MicrosoftCalendar) eventToMicrosoft(name string, desc string, color *types.Color, date *types.EventDate, location *types.EventLocation, reminders []*types.EventReminder, categories *[]string, q types.DatabaseQueries) (*microsoft.Event, *errors.ErrorTrace) {
+	event := &microsoft.Event{
+		Subject: name,
+		Body: &microsoft.ItemBody{
+			ContentType: "text",
+			Content:     desc,
+		},
+		IsAllDay: date.AllDay(),
+		Location: locationToMicrosoft(location),
+	}
+
+	if date.AllDay() {
+		event.Start = microsoft.NewAllDayDateTimeTimeZone(*date.Start())
+		event.End = microsoft.NewAllDayDateTimeTimeZone(*date.End())
+	} else {
+		event.Start = microsoft.NewDateTimeTimeZone(*date.Start())
+		event.End = microsoft.NewDateTimeTimeZone(*date.End())
+	}
+
+	newCategories, tr := calendar.source.categoriesForColor(color, categories, q)
+	if tr != nil {
+		return nil, tr
+	}
+	event.Categories = newCategories
+
+	remindersToMicrosoft(reminders, date, event)
+
+	return event, nil
+}
+
+func locationFromMicrosoft(graphEvent *microsoft.Event) *types.EventLocation {
+	location := &types.EventLocation{}
+
+	uri := graphEvent.MeetingUri()
+	if graphEvent.Location != nil {
+		location.Name = graphEvent.Location.DisplayName
+		if graphEvent.Location.LocationUri != "" {
+			uri = graphEvent.Location.LocationUri
+		}
+		coordinates := graphEvent.Location.Coordinates
+		if coordinates != nil && coordinates.Latitude != nil && coordinates.Longitude != nil {
+			location.Geo = &types.GeoCoordinates{
+				Latitude:  *coordinates.Latitude,
+				Longitude: *coordinates.Longitude,
+			}
+		}
+	}
+
+	if uri != "" {
+		url, err := types.NewUrl(uri)
+		if err == nil {
+			location.Url = url
+		}
+	}
+
+	if location.IsEmpty() {
+		return nil
+	}
+	return location
+}
+
+// The location is always sent, since an omitted location would be left unchanged by a PATCH
+func locationToMicrosoft(location *types.EventLocation) *microsoft.Location {
+	result := &microsoft.Location{}
+	if location == nil {
+		return result
+	}
+
+	result.DisplayName = location.Name
+	if location.Url != nil {
+		result.LocationUri = location.Url.String()
+	}
+	if location.Geo != nil {
+		result.Coordinates = &microsoft.Coordinates{
+			Latitude:  &location.Geo.Latitude,
+			Longitude: &location.Geo.Longitude,
+		}
+	}
+	return result
+}
+
+func (event *MicrosoftEvent) GetId() types.ID {
+	return crypto.DeriveID(event.calendar.GetId(), event.settings.GraphId)
+}
+
+func (event *MicrosoftEvent) GetName() string {
+	return event.name
+}
+
+func (event *MicrosoftEvent) SetName(name string) {
+	event.name = name
+}
+
+func (event *MicrosoftEvent) GetDesc() string {
+	return event.desc
+}
+
+func (event *MicrosoftEvent) SetDesc(desc string) {
+	event.desc = desc
+}
+
+func (event *MicrosoftEvent) GetCalendar() types.Calendar {
+	return event.calendar
+}
+
+func (event *MicrosoftEvent) GetSettings() types.EventSettings {
+	return event.settings
+}
+
+func (event *MicrosoftEvent) GetColor() *types.Color {
+	if event.color == nil {
+		return event.calendar.GetColor()
+	} else {
+		return event.color
+	}
+}
+
+func (event *MicrosoftEvent) SetColor(color *types.Color) {
+	event.color = color
+}
+
+func (event *MicrosoftEvent) GetOverridden() bool {
+	return event.overridden
+}
+
+func (event *MicrosoftEvent) SetOverridden(overridden bool) {
+	event.overridden = overridden
+}
+
+func (event *MicrosoftEvent) GetDate() *types.EventDate {
+	return event.eventDate
+}
+
+func (event *MicrosoftEvent) GetReminders() []*types.EventReminder {
+	return event.reminders
+}
+
+func (event *MicrosoftEvent) GetLocation() *types.EventLocation {
+	return event.location
+}
+
+func (event *MicrosoftEvent) GetParticipants() *types.EventParticipants {
+	return event.participants
+}
+
+func (event *MicrosoftEvent) Clone() types.Event {
+	var categories *[]string
+	if event.categories != nil {
+		cloned := append([]string{}, *event.categories...)
+		categories = &cloned
+	}
+
+	return &MicrosoftEvent{
+		name:         event.name,
+		desc:         event.desc,
+		color:        event.color.Clone(),
+		overridden:   event.overridden,
+		settings:     event.settings.Clone(),
+		calendar:     event.calendar,
+		eventDate:    event.eventDate.Clone(),
+		reminders:    types.CloneReminders(event.reminders),
+		location:     event.location.Clone(),
+		participants: event.participants.Clone(),
+
+		categories: categories,
+	}
+}
+
+// Occurrences are expanded by Graph, so events of this source never repeat locally
+func (event *MicrosoftEvent) SupplyMasterEvent(masterEvent types.Event) {
+	event.settings.RecurrenceId = common.CalculateRecurrenceId(event.eventDate.Start(), event.eventDate.AllDay())
+}
+
+func (event *MicrosoftEvent) GetUid() string {
+	return event.settings.Uid
+}
+
+func (event *MicrosoftEvent) GetRecurrenceId() string {
+	return event.settings.RecurrenceId
+}
+
+func (event *MicrosoftEvent) CanEdit() bool {
+	return event.calendar.canEdit
+}
+
+func (event *MicrosoftEvent) CanDelete() bool {
+	return event.calendar.canEdit
+}
@@ -0,0 +1,13 @@
+package microsoft
+
+import "luna-backend/types"
+
+var apiUrl = "https://graph.microsoft.com/v1.0"
+
+func ApiUrl() *types.Url {
+	return types.NewUrlSafe(apiUrl)
+}
+
+func SetApiUrl(url *types.Url) {
+	apiUrl = url.String()
+}
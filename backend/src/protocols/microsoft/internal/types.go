@@ -0,0 +1,175 @@
+package microsoft
+
+import (
+	"luna-backend/errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// https://learn.microsoft.com/en-us/graph/api/resources/calendar
+
+type Calendar struct {
+	Id                string `json:"id,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Color             string `json:"color,omitempty"`    // one of the named presets, e.g. "lightBlue" or "auto"
+	HexColor          string `json:"hexColor,omitempty"` // read-only, empty if the user never picked a custom color
+	IsDefaultCalendar bool   `json:"isDefaultCalendar,omitempty"`
+	CanEdit           bool   `json:"canEdit,omitempty"`
+}
+
+// Single page of a collection.
+// Further pages are requested through the absolute next link.
+type Calendars struct {
+	Value    []*Calendar `json:"value"`
+	NextLink string      `json:"@odata.nextLink,omitempty"`
+}
+
+type Events struct {
+	Value    []*Event `json:"value"`
+	NextLink string   `json:"@odata.nextLink,omitempty"`
+}
+
+type Categories struct {
+	Value []*Category `json:"value"`
+}
+
+// Categories are defined per mailbox and referenced by their display name
+type Category struct {
+	Id          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Color       string `json:"color"` // "none" or "preset0" to "preset24"
+}
+
+type Event struct {
+	Id                         string               `json:"id,omitempty"`
+	ICalUId                    string               `json:"iCalUId,omitempty"`
+	Subject                    string               `json:"subject"`
+	Body                       *ItemBody            `json:"body,omitempty"`
+	Start                      *DateTimeTimeZone    `json:"start,omitempty"`
+	End                        *DateTimeTimeZone    `json:"end,omitempty"`
+	IsAllDay                   bool                 `json:"isAllDay"`
+	Location                   *Location            `json:"location,omitempty"`
+	OnlineMeeting              *OnlineMeeting       `json:"onlineMeeting,omitempty"`
+	Categories                 *[]string            `json:"categories,omitempty"` // a pointer, so that an empty list can be sent to remove all categories
+	Recurrence                 *PatternedRecurrence `json:"recurrence,omitempty"`
+	SeriesMasterId             string               `json:"seriesMasterId,omitempty"`
+	Type                       string               `json:"type,omitempty"` // "singleInstance", "occurrence", "exception" or "seriesMaster"
+	OriginalStart              string               `json:"originalStart,omitempty"`
+	IsCancelled                bool                 `json:"isCancelled,omitempty"`
+	IsReminderOn               *bool                `json:"isReminderOn,omitempty"`
+	ReminderMinutesBeforeStart *int                 `json:"reminderMinutesBeforeStart,omitempty"`
+	Organizer                  *Recipient           `json:"organizer,omitempty"`
+	Attendees                  *[]Attendee          `json:"attendees,omitempty"` // a pointer, so that an empty list can be sent to remove all attendees
+}
+
+type ItemBody struct {
+	ContentType string `json:"contentType"` // "text" or "html"
+	Content     string `json:"content"`
+}
+
+// Graph returns all times in UTC unless asked otherwise
+type DateTimeTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type Location struct {
+	DisplayName string       `json:"displayName"`
+	LocationUri string       `json:"locationUri,omitempty"`
+	Coordinates *Coordinates `json:"coordinates,omitempty"`
+}
+
+// Graph returns an empty object for locations without coordinates
+type Coordinates struct {
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// Online meetings are created by Exchange itself, so they are never sent back
+type OnlineMeeting struct {
+	JoinUrl string `json:"joinUrl,omitempty"`
+}
+
+type Recipient struct {
+	EmailAddress EmailAddress `json:"emailAddress"`
+}
+
+type EmailAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+type Attendee struct {
+	EmailAddress EmailAddress    `json:"emailAddress"`
+	Type         string          `json:"type"` // "required", "optional" or "resource"
+	Status       *ResponseStatus `json:"status,omitempty"`
+}
+
+type ResponseStatus struct {
+	Response string `json:"response,omitempty"` // "none", "organizer", "tentativelyAccepted", "accepted", "declined" or "notResponded"
+}
+
+type PatternedRecurrence struct {
+	Pattern RecurrencePattern `json:"pattern"`
+	Range   RecurrenceRange   `json:"range"`
+}
+
+type RecurrencePattern struct {
+	Type       string   `json:"type"` // "daily", "weekly", "absoluteMonthly", "relativeMonthly", "absoluteYearly" or "relativeYearly"
+	Interval   int      `json:"interval"`
+	Month      int      `json:"month,omitempty"`
+	DayOfMonth int      `json:"dayOfMonth,omitempty"`
+	DaysOfWeek []string `json:"daysOfWeek,omitempty"`
+	Index      string   `json:"index,omitempty"` // "first", "second", "third", "fourth" or "last"
+}
+
+type RecurrenceRange struct {
+	Type                string `json:"type"` // "endDate", "noEnd" or "numbered"
+	StartDate           string `json:"startDate"`
+	EndDate             string `json:"endDate,omitempty"`
+	NumberOfOccurrences int    `json:"numberOfOccurrences,omitempty"`
+}
+
+const dateTimeLayout = "2006-01-02T15:04:05.9999999"
+
+// Time zones may also be given as Windows names, which are not understood by Go, so UTC is assumed for those
+func (dateTime *DateTimeTimeZone) Parse() (*time.Time, *errors.ErrorTrace) {
+	timezone, err := time.LoadLocation(dateTime.TimeZone)
+	if err != nil {
+		timezone = time.UTC
+	}
+
+	parsedTime, err := time.ParseInLocation(dateTimeLayout, strings.TrimSuffix(dateTime.DateTime, "Z"), timezone)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse datetime %v", dateTime.DateTime).
+			AltStr(errors.LvlWordy, "Could not parse datetime")
+	}
+
+	return &parsedTime, nil
+}
+
+func NewDateTimeTimeZone(t time.Time) *DateTimeTimeZone {
+	return &DateTimeTimeZone{
+		DateTime: t.UTC().Format("2006-01-02T15:04:05"),
+		TimeZone: "UTC",
+	}
+}
+
+// All-day events must start and end at midnight, so only the date is kept
+func NewAllDayDateTimeTimeZone(t time.Time) *DateTimeTimeZone {
+	return &DateTimeTimeZone{
+		DateTime: t.Format("2006-01-02") + "T00:00:00",
+		TimeZone: "UTC",
+	}
+}
+
+// The link to the online meeting of the event, if any
+func (event *Event) MeetingUri() string {
+	if event.OnlineMeeting != nil {
+		return event.OnlineMeeting.JoinUrl
+	}
+	return ""
+}
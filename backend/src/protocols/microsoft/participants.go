@@ -0,0 +1,98 @@
+package microsoft
+
+import (
+	"luna-backend/constants"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+)
+
+var graphResponses = map[string]string{
+	"none":                constants.ParticipationNeedsAction,
+	"notResponded":        constants.ParticipationNeedsAction,
+	"organizer":           constants.ParticipationAccepted,
+	"accepted":            constants.ParticipationAccepted,
+	"tentativelyAccepted": constants.ParticipationTentative,
+	"declined":            constants.ParticipationDeclined,
+}
+
+var graphAttendeeTypes = map[string]string{
+	"required": constants.AttendeeRoleRequired,
+	"optional": constants.AttendeeRoleOptional,
+	"resource": constants.AttendeeRoleNonParticipant,
+}
+
+// Exchange lists the organizer of every event, even without attendees, so the organizer alone does not count as participants
+func participantsFromMicrosoft(organizer *microsoft.Recipient, attendees *[]microsoft.Attendee) *types.EventParticipants {
+	if attendees == nil || len(*attendees) == 0 {
+		return nil
+	}
+
+	participants := &types.EventParticipants{
+		Attendees: []*types.EventAttendee{},
+	}
+
+	if organizer != nil && organizer.EmailAddress.Address != "" {
+		participants.Organizer = &types.EventOrganizer{
+			Email: organizer.EmailAddress.Address,
+			Name:  organizer.EmailAddress.Name,
+		}
+	}
+
+	for _, attendee := range *attendees {
+		if attendee.EmailAddress.Address == "" {
+			continue
+		}
+
+		role, known := graphAttendeeTypes[attendee.Type]
+		if !known {
+			role = constants.AttendeeRoleRequired
+		}
+		status := constants.ParticipationNeedsAction
+		if attendee.Status != nil {
+			if parsed, known := graphResponses[attendee.Status.Response]; known {
+				status = parsed
+			}
+		}
+
+		participants.Attendees = append(participants.Attendees, &types.EventAttendee{
+			Email:  attendee.EmailAddress.Address,
+			Name:   attendee.EmailAddress.Name,
+			Role:   role,
+			Status: status,
+			Rsvp:   true,
+		})
+	}
+
+	if participants.IsEmpty() {
+		return nil
+	}
+	return participants
+}
+
+// Responses can only be changed by the attendees themselves, and the organizer is always the owner of the calendar,
+// so only the addresses and types of the attendees are sent.
+func participantsToMicrosoft(participants *types.EventParticipants) *[]microsoft.Attendee {
+	attendees := []microsoft.Attendee{}
+	if participants == nil {
+		return &attendees
+	}
+
+	for _, attendee := range participants.Attendees {
+		attendeeType := "required"
+		switch attendee.Role {
+		case constants.AttendeeRoleOptional:
+			attendeeType = "optional"
+		case constants.AttendeeRoleNonParticipant:
+			attendeeType = "resource"
+		}
+
+		attendees = append(attendees, microsoft.Attendee{
+			EmailAddress: microsoft.EmailAddress{
+				Address: attendee.Email,
+				Name:    attendee.Name,
+			},
+			Type: attendeeType,
+		})
+	}
+	return &attendees
+}
@@ -0,0 +1,128 @@
+package microsoft
+
+import (
+	"fmt"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+var graphWeekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+var graphIndices = map[int]string{
+	1:  "first",
+	2:  "second",
+	3:  "third",
+	4:  "fourth",
+	-1: "last",
+}
+
+// Graph describes recurrences with patterns instead of RRULEs.
+// Only rules that map onto a single pattern are supported, e.g. "every second Tuesday of the month", but not "every day at 9 and 17".
+func recurrenceToMicrosoft(date *types.EventDate) (*microsoft.PatternedRecurrence, error) {
+	if !date.Recurrence().Repeats() {
+		return nil, nil
+	}
+
+	rule := date.Recurrence().Rule()
+	start := *date.Start()
+
+	if len(rule.Byhour) > 0 || len(rule.Byminute) > 0 || len(rule.Bysecond) > 0 || len(rule.Byyearday) > 0 || len(rule.Byweekno) > 0 || len(rule.Byeaster) > 0 || len(rule.Bymonthday) > 1 || len(rule.Bymonth) > 1 {
+		return nil, fmt.Errorf("recurrence rule is too complex")
+	}
+
+	interval := rule.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	pattern := microsoft.RecurrencePattern{
+		Interval: interval,
+	}
+
+	// A BYSETPOS with a single position is equivalent to an ordinal weekday, e.g. BYDAY=TU;BYSETPOS=2 and BYDAY=2TU
+	ordinal := 0
+	if len(rule.Bysetpos) == 1 {
+		ordinal = rule.Bysetpos[0]
+	} else if len(rule.Bysetpos) > 1 {
+		return nil, fmt.Errorf("multiple set positions are not supported")
+	}
+
+	days := []string{}
+	for _, weekday := range rule.Byweekday {
+		days = append(days, graphWeekdays[weekday.Day()])
+		if weekday.N() != 0 {
+			if ordinal != 0 && ordinal != weekday.N() {
+				return nil, fmt.Errorf("weekdays with different ordinals are not supported")
+			}
+			ordinal = weekday.N()
+		}
+	}
+
+	dayOfMonth := start.Day()
+	if len(rule.Bymonthday) == 1 {
+		dayOfMonth = rule.Bymonthday[0]
+	}
+	month := int(start.Month())
+	if len(rule.Bymonth) == 1 {
+		month = rule.Bymonth[0]
+	}
+
+	switch rule.Freq {
+	case rrule.DAILY:
+		pattern.Type = "daily"
+	case rrule.WEEKLY:
+		pattern.Type = "weekly"
+		if len(days) == 0 {
+			days = []string{graphWeekdays[(int(start.Weekday())+6)%7]}
+		}
+		pattern.DaysOfWeek = days
+	case rrule.MONTHLY, rrule.YEARLY:
+		relative := len(days) > 0
+		if relative {
+			index, exists := graphIndices[ordinal]
+			if !exists {
+				return nil, fmt.Errorf("ordinal %v is not supported", ordinal)
+			}
+			pattern.DaysOfWeek = days
+			pattern.Index = index
+		} else {
+			if dayOfMonth < 1 {
+				return nil, fmt.Errorf("negative days of the month are not supported")
+			}
+			pattern.DayOfMonth = dayOfMonth
+		}
+
+		if rule.Freq == rrule.MONTHLY {
+			pattern.Type = "absoluteMonthly"
+		} else {
+			pattern.Type = "absoluteYearly"
+			pattern.Month = month
+		}
+		if relative {
+			pattern.Type = strings.Replace(pattern.Type, "absolute", "relative", 1)
+		}
+	default:
+		return nil, fmt.Errorf("frequency %v is not supported", rule.Freq)
+	}
+
+	rangeDef := microsoft.RecurrenceRange{
+		Type:      "noEnd",
+		StartDate: start.Format(time.DateOnly),
+	}
+	if rule.Count > 0 {
+		rangeDef.Type = "numbered"
+		rangeDef.NumberOfOccurrences = rule.Count
+	} else if !rule.Until.IsZero() {
+		rangeDef.Type = "endDate"
+		rangeDef.EndDate = rule.Until.Format(time.DateOnly)
+	}
+
+	return &microsoft.PatternedRecurrence{
+		Pattern: pattern,
+		Range:   rangeDef,
+	}, nil
+}
@@ -0,0 +1,36 @@
+package microsoft
+
+import (
+	"luna-backend/constants"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+	"time"
+)
+
+func remindersFromMicrosoft(event *microsoft.Event) []*types.EventReminder {
+	if event.IsReminderOn == nil || !*event.IsReminderOn || event.ReminderMinutesBeforeStart == nil {
+		return []*types.EventReminder{}
+	}
+
+	return []*types.EventReminder{
+		types.NewRelativeReminder(constants.ReminderActionDisplay, -time.Duration(*event.ReminderMinutesBeforeStart)*time.Minute, false),
+	}
+}
+
+// Outlook only supports a single reminder before the start of the event.
+// The earliest reminder that fires before the start is kept, all other reminders are dropped.
+func remindersToMicrosoft(reminders []*types.EventReminder, date *types.EventDate, event *microsoft.Event) {
+	minutes := -1
+	for _, reminder := range reminders {
+		before := int(date.Start().Sub(reminder.TriggerTime(date)) / time.Minute)
+		if before >= 0 && before > minutes {
+			minutes = before
+		}
+	}
+
+	reminderOn := minutes >= 0
+	event.IsReminderOn = &reminderOn
+	if reminderOn {
+		event.ReminderMinutesBeforeStart = &minutes
+	}
+}
@@ -0,0 +1,200 @@
+package microsoft
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/net"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+	"net/http"
+)
+
+type MicrosoftSource struct {
+	id       types.ID
+	name     string
+	settings *MicrosoftSourceSettings
+	auth     types.AuthMethod
+
+	categories []*microsoft.Category
+}
+
+type MicrosoftSourceSettings struct{}
+
+func (settings *MicrosoftSourceSettings) GetBytes() []byte {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// Points all requests at a different implementation of the Microsoft Graph API, e.g. a local mock server for testing
+func SetApiUrl(url *types.Url) {
+	microsoft.SetApiUrl(url)
+}
+
+// Graph returns HTML bodies by default, which Luna cannot display
+var preferHeaders = http.Header{
+	"Prefer": {`outlook.body-content-type="text"`, `outlook.timezone="UTC"`},
+}
+
+func (source *MicrosoftSource) GetType() string {
+	return constants.SourceMicrosoft
+}
+
+func (source *MicrosoftSource) GetId() types.ID {
+	return source.id
+}
+
+func (source *MicrosoftSource) GetName() string {
+	return source.name
+}
+
+func (source *MicrosoftSource) GetAuth() types.AuthMethod {
+	return source.auth
+}
+
+func (source *MicrosoftSource) GetSettings() types.SourceSettings {
+	return source.settings
+}
+
+func (source *MicrosoftSource) CanAddCalendars() bool {
+	return true
+}
+
+func NewMicrosoftSource(name string, auth types.AuthMethod) *MicrosoftSource {
+	return &MicrosoftSource{
+		id:       types.EmptyId(), // Placeholder until the database assigns an ID
+		name:     name,
+		auth:     auth,
+		settings: &MicrosoftSourceSettings{},
+	}
+}
+
+func PackMicrosoftSource(id types.ID, name string, settings *MicrosoftSourceSettings, auth types.AuthMethod) *MicrosoftSource {
+	return &MicrosoftSource{
+		id:       id,
+		name:     name,
+		settings: settings,
+		auth:     auth,
+	}
+}
+
+func (source *MicrosoftSource) GetCalendars(q types.DatabaseQueries) ([]types.Calendar, *errors.ErrorTrace) {
+	result := []types.Calendar{}
+
+	url := microsoft.ApiUrl().Subpage("me", "calendars")
+	for url != nil {
+		var res microsoft.Calendars
+
+		tr := net.FetchJson(url, "GET", source.auth, nil, "", q.GetContext(), &res)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlBroad, "Could not get calendars")
+		}
+
+		for _, calendar := range res.Value {
+			result = append(result, source.calendarFromMicrosoft(calendar))
+		}
+
+		url, tr = nextPage(res.NextLink)
+		if tr != nil {
+			return nil, tr.
+				Append(errors.LvlBroad, "Could not get calendars")
+		}
+	}
+
+	return result, nil
+}
+
+// Returns nil after the last page
+func nextPage(nextLink string) (*types.Url, *errors.ErrorTrace) {
+	if nextLink == "" {
+		return nil, nil
+	}
+
+	url, err := types.NewUrl(nextLink)
+	if err != nil {
+		return nil, errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not parse next page link %v", nextLink)
+	}
+	return url, nil
+}
+
+func (source *MicrosoftSource) GetCalendar(settings types.CalendarSettings, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	microsoftSettings := settings.(*MicrosoftCalendarSettings)
+
+	var res microsoft.Calendar
+
+	tr := net.FetchJson(microsoft.ApiUrl().Subpage("me", "calendars", microsoftSettings.GraphId), "GET", source.auth, nil, "", q.GetContext(), &res)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlBroad, "Could not get calendar")
+	}
+
+	return source.calendarFromMicrosoft(&res), nil
+}
+
+// Outlook calendars do not have a description, so it is dropped
+func (source *MicrosoftSource) AddCalendar(name string, desc string, color *types.Color, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	calendar := microsoft.Calendar{
+		Name:  name,
+		Color: calendarColorToMicrosoft(color),
+	}
+
+	var res microsoft.Calendar
+
+	tr := net.FetchJson(microsoft.ApiUrl().Subpage("me", "calendars"), "POST", source.auth, &calendar, "application/json", q.GetContext(), &res)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not add calendar to source %v", source.GetId()).
+			AltStr(errors.LvlBroad, "Could not add calendar")
+	}
+
+	return source.calendarFromMicrosoft(&res), nil
+}
+
+func (source *MicrosoftSource) EditCalendar(calendar types.Calendar, name string, desc string, color *types.Color, override bool, q types.DatabaseQueries) (types.Calendar, *errors.ErrorTrace) {
+	patch := microsoft.Calendar{
+		Name:  name,
+		Color: calendarColorToMicrosoft(color),
+	}
+
+	url := microsoft.ApiUrl().Subpage("me", "calendars", calendar.GetSettings().(*MicrosoftCalendarSettings).GraphId)
+
+	var res microsoft.Calendar
+
+	tr := net.FetchJson(url, "PATCH", source.auth, &patch, "application/json", q.GetContext(), &res)
+	if tr != nil {
+		return nil, tr.
+			Append(errors.LvlDebug, "Could not edit calendar %v of source %v", calendar.GetId(), source.GetId()).
+			AltStr(errors.LvlBroad, "Could not edit calendar")
+	}
+
+	return source.calendarFromMicrosoft(&res), nil
+}
+
+func (source *MicrosoftSource) DeleteCalendar(calendar types.Calendar, q types.DatabaseQueries) *errors.ErrorTrace {
+	url := microsoft.ApiUrl().Subpage("me", "calendars", calendar.GetSettings().(*MicrosoftCalendarSettings).GraphId)
+
+	_, tr := net.FetchBytes(url, "DELETE", source.auth, nil, "", "", q.GetContext())
+	if tr != nil {
+		return tr.
+			Append(errors.LvlDebug, "Could not delete calendar %v of source %v", calendar.GetId(), source.GetId()).
+			AltStr(errors.LvlBroad, "Could not delete calendar")
+	}
+
+	return nil
+}
+
+func (source *MicrosoftSource) Cleanup(_ types.DatabaseQueries) *errors.ErrorTrace { return nil }
+
+func (source *MicrosoftSource) SupplyContext(ctx context.Context) {
+	if source.auth.GetType() == constants.AuthOauth {
+		source.auth.(*auth.OauthAuth).SupplyContext(ctx)
+	}
+}
@@ -0,0 +1,208 @@
+package microsoft
+
+import (
+	"context"
+	"encoding/json"
+	"luna-backend/auth"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	microsoft "luna-backend/protocols/microsoft/internal"
+	"luna-backend/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Graph requests only need the context of the queries
+type graphTestQueries struct {
+	types.DatabaseQueries
+}
+
+func (q *graphTestQueries) GetContext() context.Context {
+	return context.Background()
+}
+
+func newGraphTestSource(t *testing.T, handler http.HandlerFunc) (*MicrosoftSource, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	SetApiUrl(types.NewUrlSafe(server.URL))
+
+	return PackMicrosoftSource(types.RandomId(), "Outlook", &MicrosoftSourceSettings{}, auth.NewNoAuth()), server
+}
+
+func writeJson(t *testing.T, w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		t.Errorf("could not encode response: %v", err)
+	}
+}
+
+var testCategories = &microsoft.Categories{
+	Value: []*microsoft.Category{
+		{Id: "1", DisplayName: "Work", Color: "preset7"},
+		{Id: "2", DisplayName: "Private", Color: "preset0"},
+		{Id: "3", DisplayName: "Later", Color: "none"},
+	},
+}
+
+func TestGetCalendarsFollowsNextLink(t *testing.T) {
+	var server *httptest.Server
+	source, server := newGraphTestSource(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/me/calendars" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("page") == "" {
+			writeJson(t, w, &microsoft.Calendars{
+				Value: []*microsoft.Calendar{
+					{Id: "default", Name: "Calendar", Color: "lightBlue", HexColor: "#123456", IsDefaultCalendar: true, CanEdit: true},
+				},
+				NextLink: server.URL + "/me/calendars?page=2",
+			})
+			return
+		}
+
+		writeJson(t, w, &microsoft.Calendars{
+			Value: []*microsoft.Calendar{
+				{Id: "shared", Name: "Shared", Color: "lightGreen"},
+				{Id: "plain", Name: "Plain", Color: "auto", CanEdit: true},
+			},
+		})
+	})
+
+	cals, tr := source.GetCalendars(&graphTestQueries{})
+	if tr != nil {
+		t.Fatalf("could not get calendars: %v", tr.Serialize(errors.LvlDebug))
+	}
+	if len(cals) != 3 {
+		t.Fatalf("expected 3 calendars from both pages, got %v", len(cals))
+	}
+
+	if cals[0].GetColor().String() != "#123456" {
+		t.Errorf("expected the custom color to take precedence, got %v", cals[0].GetColor().String())
+	}
+	if cals[0].CanDelete() || !cals[0].CanAddEvents() {
+		t.Errorf("expected the default calendar to be writable but not deletable")
+	}
+	if cals[1].GetColor().String() != calendarColors["lightGreen"] {
+		t.Errorf("expected the preset color %v, got %v", calendarColors["lightGreen"], cals[1].GetColor().String())
+	}
+	if cals[1].CanAddEvents() {
+		t.Errorf("expected the shared calendar to be read-only")
+	}
+	if !cals[2].GetColor().IsEmpty() {
+		t.Errorf("expected no color for calendars colored automatically, got %v", cals[2].GetColor().String())
+	}
+}
+
+func TestGetEventsMapsCategoriesToColors(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(7 * 24 * time.Hour)
+	reminderOn := true
+	reminderMinutes := 15
+
+	categoryRequests := 0
+	source, _ := newGraphTestSource(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/me/outlook/masterCategories":
+			categoryRequests++
+			writeJson(t, w, testCategories)
+		case "/me/calendars/work/calendarView":
+			query := r.URL.Query()
+			if query.Get("startDateTime") != start.Format(time.RFC3339) || query.Get("endDateTime") != end.Format(time.RFC3339) {
+				t.Errorf("unexpected range %v to %v", query.Get("startDateTime"), query.Get("endDateTime"))
+			}
+			writeJson(t, w, &microsoft.Events{
+				Value: []*microsoft.Event{
+					{
+						Id:                         "meeting",
+						Subject:                    "Meeting",
+						Start:                      microsoft.NewDateTimeTimeZone(start.Add(9 * time.Hour)),
+						End:                        microsoft.NewDateTimeTimeZone(start.Add(10 * time.Hour)),
+						Categories:                 &[]string{"Later", "Work"},
+						IsReminderOn:               &reminderOn,
+						ReminderMinutesBeforeStart: &reminderMinutes,
+					},
+					{
+						Id:         "errand",
+						Subject:    "Errand",
+						Start:      microsoft.NewDateTimeTimeZone(start.Add(33 * time.Hour)),
+						End:        microsoft.NewDateTimeTimeZone(start.Add(34 * time.Hour)),
+						Categories: &[]string{"Later"},
+					},
+					{
+						Id:          "cancelled",
+						Subject:     "Cancelled",
+						Start:       microsoft.NewDateTimeTimeZone(start.Add(57 * time.Hour)),
+						End:         microsoft.NewDateTimeTimeZone(start.Add(58 * time.Hour)),
+						IsCancelled: true,
+					},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	calendar := source.calendarFromMicrosoft(&microsoft.Calendar{Id: "work", Name: "Work", Color: "lightRed", CanEdit: true})
+
+	events, tr := calendar.GetEvents(start, end, &graphTestQueries{})
+	if tr != nil {
+		t.Fatalf("could not get events: %v", tr.Serialize(errors.LvlDebug))
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events without the cancelled one, got %v", len(events))
+	}
+
+	meeting, errand := events[0], events[1]
+	if meeting.GetName() != "Meeting" || !meeting.GetDate().Start().Equal(start.Add(9*time.Hour)) {
+		t.Errorf("unexpected event %v starting at %v", meeting.GetName(), meeting.GetDate().Start())
+	}
+	if meeting.GetColor().String() != categoryColors["preset7"] {
+		t.Errorf("expected the color of the first colored category %v, got %v", categoryColors["preset7"], meeting.GetColor().String())
+	}
+	if errand.GetColor().String() != calendar.GetColor().String() {
+		t.Errorf("expected an event without colored categories to take the calendar color, got %v", errand.GetColor().String())
+	}
+	if categoryRequests != 1 {
+		t.Errorf("expected the categories to be requested once, got %v requests", categoryRequests)
+	}
+
+	reminders := meeting.GetReminders()
+	if len(reminders) != 1 || reminders[0].Action() != constants.ReminderActionDisplay || !reminders[0].TriggerTime(meeting.GetDate()).Equal(start.Add(9*time.Hour-15*time.Minute)) {
+		t.Errorf("expected a single reminder 15 minutes before the start")
+	}
+	if len(errand.GetReminders()) != 0 {
+		t.Errorf("expected no reminders for an event without a reminder")
+	}
+}
+
+func TestCategoriesForColorKeepsUncoloredCategories(t *testing.T) {
+	source, _ := newGraphTestSource(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/me/outlook/masterCategories" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJson(t, w, testCategories)
+	})
+	q := &graphTestQueries{}
+	current := &[]string{"Later", "Private"}
+
+	categories, tr := source.categoriesForColor(presetColor("#00b0f0"), current, q)
+	if tr != nil {
+		t.Fatalf("could not map color: %v", tr.Serialize(errors.LvlDebug))
+	}
+	if categories == nil || len(*categories) != 2 || (*categories)[0] != "Work" || (*categories)[1] != "Later" {
+		t.Errorf("expected [Work Later], got %v", categories)
+	}
+
+	categories, tr = source.categoriesForColor(types.ColorEmpty, current, q)
+	if tr != nil {
+		t.Fatalf("could not remove color: %v", tr.Serialize(errors.LvlDebug))
+	}
+	if categories == nil || len(*categories) != 1 || (*categories)[0] != "Later" {
+		t.Errorf("expected [Later], got %v", categories)
+	}
+}
@@ -0,0 +1,238 @@
+package scheduling
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"luna-backend/errors"
+	"luna-backend/notifications"
+	icalProtocol "luna-backend/protocols/ical"
+	"luna-backend/types"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// Delivers iTIP messages by email as described in RFC 6047 (iMIP)
+type Mailer struct {
+	server *notifications.SmtpServer
+}
+
+func NewMailer(server *notifications.SmtpServer) *Mailer {
+	return &Mailer{
+		server: server,
+	}
+}
+
+// Sends the message from Luna's own address, while replies go to the user that caused it.
+// The attendee is only used to describe replies.
+func (mailer *Mailer) Send(cal *ical.Calendar, event types.Event, sender *types.EventOrganizer, recipients []string, attendee *types.EventAttendee, ctx context.Context) *errors.ErrorTrace {
+	method := cal.Props.Get(ical.PropMethod).Value
+
+	msg, err := mailer.buildMessage(cal, method, event, sender, recipients, attendee)
+	if err != nil {
+		return errors.New().Status(http.StatusInternalServerError).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not assemble iTIP %v for %v", method, event.GetId()).
+			Append(errors.LvlWordy, "Could not send scheduling message")
+	}
+
+	err = mailer.server.Send(recipients, msg, ctx)
+	if err != nil {
+		return errors.New().Status(http.StatusBadGateway).
+			AddErr(errors.LvlDebug, err).
+			Append(errors.LvlDebug, "Could not send iTIP %v for %v to %v", method, event.GetId(), recipients).
+			Append(errors.LvlWordy, "Could not send scheduling message")
+	}
+	return nil
+}
+
+// The calendar is included inline, so that mail clients display the invitation, and as an attachment for clients that do not
+func (mailer *Mailer) buildMessage(cal *ical.Calendar, method string, event types.Event, sender *types.EventOrganizer, recipients []string, attendee *types.EventAttendee) ([]byte, error) {
+	subject, text := describe(method, event, attendee)
+
+	var calBuf bytes.Buffer
+	err := ical.NewEncoder(&calBuf).Encode(cal)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode calendar: %v", err)
+	}
+
+	var alternativeBuf bytes.Buffer
+	alternative := multipart.NewWriter(&alternativeBuf)
+	err = writePart(alternative, "text/plain; charset=utf-8", "", []byte(text+"\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	err = writePart(alternative, "text/calendar; charset=utf-8; method="+method, "", calBuf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = alternative.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var mixedBuf bytes.Buffer
+	mixed := multipart.NewWriter(&mixedBuf)
+	part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()}})
+	if err != nil {
+		return nil, err
+	}
+	_, err = part.Write(alternativeBuf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = writePart(mixed, "application/ics; name=invite.ics", "attachment; filename=invite.ics", calBuf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = mixed.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	fromName := sender.Name
+	if fromName == "" {
+		fromName = sender.Email
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", (&mail.Address{Name: fromName, Address: mailer.server.From()}).String())
+	fmt.Fprintf(&msg, "Reply-To: %s\r\n", (&mail.Address{Name: sender.Name, Address: sender.Email}).String())
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n", mixed.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(mixedBuf.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// Parts are base64 encoded, so that long lines and non-ASCII characters survive every mail server
+func writePart(writer *multipart.Writer, contentType string, disposition string, content []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+	if disposition != "" {
+		header.Set("Content-Disposition", disposition)
+	}
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		_, err = io.WriteString(part, encoded[:76]+"\r\n")
+		if err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = io.WriteString(part, encoded+"\r\n")
+	return err
+}
+
+// Extracts the iTIP message from either a bare iCalendar object or a complete email.
+// In emails, the first text/calendar or application/ics part is used.
+func ParseMessage(contentType string, raw []byte) (*ical.Calendar, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "text/calendar" {
+		return ical.NewDecoder(bytes.NewReader(raw)).Decode()
+	}
+
+	email, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse email: %v", err)
+	}
+
+	content, err := findCalendarPart(textproto.MIMEHeader(email.Header), email.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ical.NewDecoder(bytes.NewReader(content)).Decode()
+}
+
+// Nested parts are only followed a few levels deep, since no mail client produces more
+func findCalendarPart(header textproto.MIMEHeader, body io.Reader, depth int) ([]byte, error) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse content type %v: %v", contentType, err)
+	}
+
+	switch {
+	case mediaType == "text/calendar" || mediaType == "application/ics":
+		return decodePart(header.Get("Content-Transfer-Encoding"), body)
+	case strings.HasPrefix(mediaType, "multipart/") && depth < 4:
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not read multipart body: %v", err)
+			}
+
+			content, err := findCalendarPart(part.Header, part, depth+1)
+			if err == nil {
+				return content, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("email does not contain a calendar")
+}
+
+func decodePart(encoding string, body io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, body))
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(body))
+	default:
+		return io.ReadAll(body)
+	}
+}
+
+// Short summary of a message for the subject and the plain text part of emails
+func describe(method string, event types.Event, attendee *types.EventAttendee) (string, string) {
+	date := event.GetDate()
+	when := ""
+	if date != nil && date.Start() != nil {
+		if date.AllDay() {
+			when = date.Start().Format("Monday, January 2, 2006")
+		} else {
+			when = date.Start().Format(time.RFC1123)
+		}
+	}
+
+	switch method {
+	case icalProtocol.ItipMethodCancel:
+		return "Cancelled: " + event.GetName(), fmt.Sprintf("The event \"%v\" on %v has been cancelled.", event.GetName(), when)
+	case icalProtocol.ItipMethodReply:
+		name := attendee.Name
+		if name == "" {
+			name = attendee.Email
+		}
+		return fmt.Sprintf("%v: %v", strings.ToUpper(attendee.Status[:1])+attendee.Status[1:], event.GetName()), fmt.Sprintf("%v replied to \"%v\" on %v with status %v.", name, event.GetName(), when, attendee.Status)
+	default:
+		return "Invitation: " + event.GetName(), fmt.Sprintf("You have been invited to \"%v\" on %v.", event.GetName(), when)
+	}
+}
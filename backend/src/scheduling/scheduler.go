@@ -0,0 +1,132 @@
+package scheduling
+
+import (
+	"context"
+	"luna-backend/config"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"luna-backend/notifications"
+	icalProtocol "luna-backend/protocols/ical"
+	"luna-backend/types"
+	"strings"
+)
+
+// Decides which scheduling messages a change to an event requires and sends them.
+// Google, Exchange and JMAP servers deliver invitations for their own events, so they are never handled here.
+type Scheduler struct {
+	mailer *Mailer
+}
+
+// Returns nil if no SMTP server is configured
+func NewScheduler(env *config.Environmental) *Scheduler {
+	if env.SMTP_HOST == "" {
+		return nil
+	}
+
+	return &Scheduler{
+		mailer: NewMailer(notifications.NewSmtpServer(env.SMTP_HOST, env.SMTP_PORT, env.SMTP_USERNAME, env.SMTP_PASSWORD, env.SMTP_FROM)),
+	}
+}
+
+// Changes to single instances of recurring events are not sent, since their recurrence ids are not comparable across sources
+func isSchedulable(event types.Event) bool {
+	return event != nil &&
+		!event.GetParticipants().IsEmpty() &&
+		event.GetRecurrenceId() == "" &&
+		event.GetCalendar().GetSource().GetType() != constants.SourceGoogle &&
+		event.GetCalendar().GetSource().GetType() != constants.SourceMicrosoft &&
+		event.GetCalendar().GetSource().GetType() != constants.SourceJmap
+}
+
+// Whether the user organizes the event and is therefore responsible for inviting its attendees
+func IsOrganizer(user *types.User, event types.Event) bool {
+	if !isSchedulable(event) {
+		return false
+	}
+	organizer := event.GetParticipants().Organizer
+	return organizer != nil && strings.EqualFold(organizer.Email, user.Email)
+}
+
+func attendeeEmails(attendees []*types.EventAttendee, except string) []string {
+	emails := []string{}
+	for _, attendee := range attendees {
+		if !strings.EqualFold(attendee.Email, except) {
+			emails = append(emails, attendee.Email)
+		}
+	}
+	return emails
+}
+
+func organizerFor(user *types.User, event types.Event) *types.EventOrganizer {
+	organizer := &types.EventOrganizer{
+		Email: user.Email,
+		Name:  user.Username,
+	}
+	if participants := event.GetParticipants(); participants != nil && participants.Organizer != nil && participants.Organizer.Name != "" {
+		organizer.Name = participants.Organizer.Name
+	}
+	return organizer
+}
+
+// Sends invitations to all attendees after the organizer created or changed an event, and cancellations to removed attendees.
+// The previous version is nil for new events, and the new version is nil for deleted events.
+func (scheduler *Scheduler) EventChanged(user *types.User, before types.Event, after types.Event, sequence int, ctx context.Context) *errors.ErrorTrace {
+	if IsOrganizer(user, after) {
+		recipients := attendeeEmails(after.GetParticipants().Attendees, user.Email)
+		if len(recipients) > 0 {
+			cal, tr := icalProtocol.NewItipRequest(after, sequence)
+			if tr != nil {
+				return tr
+			}
+			tr = scheduler.mailer.Send(cal, after, organizerFor(user, after), recipients, nil, ctx)
+			if tr != nil {
+				return tr
+			}
+		}
+	}
+
+	if IsOrganizer(user, before) {
+		removed := []*types.EventAttendee{}
+		for _, attendee := range before.GetParticipants().Attendees {
+			if after == nil || after.GetParticipants().Find(attendee.Email) == nil {
+				removed = append(removed, attendee)
+			}
+		}
+
+		recipients := attendeeEmails(removed, user.Email)
+		if len(recipients) > 0 {
+			cal, tr := icalProtocol.NewItipCancel(before, removed, sequence)
+			if tr != nil {
+				return tr
+			}
+			tr = scheduler.mailer.Send(cal, before, organizerFor(user, before), recipients, nil, ctx)
+			if tr != nil {
+				return tr
+			}
+		}
+	}
+
+	return nil
+}
+
+// Informs the organizer after the user changed their participation status
+func (scheduler *Scheduler) ParticipationChanged(user *types.User, event types.Event, attendee *types.EventAttendee, ctx context.Context) *errors.ErrorTrace {
+	if !isSchedulable(event) || IsOrganizer(user, event) {
+		return nil
+	}
+	organizer := event.GetParticipants().Organizer
+	if organizer == nil {
+		return nil
+	}
+
+	cal, tr := icalProtocol.NewItipReply(event, attendee)
+	if tr != nil {
+		return tr
+	}
+
+	sender := &types.EventOrganizer{
+		Email: attendee.Email,
+		Name:  attendee.Name,
+	}
+	return scheduler.mailer.Send(cal, event, sender, []string{organizer.Email}, attendee, ctx)
+}
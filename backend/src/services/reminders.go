@@ -0,0 +1,403 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"luna-backend/config"
+	"luna-backend/constants"
+	"luna-backend/crypto"
+	"luna-backend/db"
+	"luna-backend/errors"
+	"luna-backend/notifications"
+	"luna-backend/types"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reminders further away from their event than this are not delivered
+const reminderHorizon = 4 * 7 * 24 * time.Hour
+
+// How often due reminders are delivered from the list of upcoming reminders
+const reminderDeliveryInterval = 15 * time.Second
+
+// Calendars without any reminders are only scanned again after this long
+const reminderQuietInterval = time.Hour
+
+type pendingReminder struct {
+	deliveryId   types.ID
+	notification *notifications.Notification
+	notifiers    []notifications.Notifier
+}
+
+// Periodically collects the upcoming reminders of all users and delivers them once they are due.
+// Only sources whose owner opted in are scanned, since their credentials have to be decrypted without a request.
+// Every delivery is recorded before it is sent, so reminders are never sent twice, even across restarts.
+type ReminderService struct {
+	db           *db.Database
+	commonConfig *config.CommonConfig
+	logger       *logrus.Entry
+	email        notifications.Notifier // nil if no SMTP server is configured
+	pending      []*pendingReminder
+	quiet        map[types.ID]time.Time // calendars without reminders, until they are scanned again
+}
+
+func NewReminderService(db *db.Database, commonConfig *config.CommonConfig, logger *logrus.Entry) *ReminderService {
+	service := ReminderService{
+		db:           db,
+		commonConfig: commonConfig,
+		logger:       logger,
+		pending:      []*pendingReminder{},
+		quiet:        map[types.ID]time.Time{},
+	}
+
+	env := commonConfig.Env
+	if env.SMTP_HOST != "" {
+		service.email = notifications.NewEmailNotifier(notifications.NewSmtpServer(env.SMTP_HOST, env.SMTP_PORT, env.SMTP_USERNAME, env.SMTP_PASSWORD, env.SMTP_FROM))
+	}
+
+	return &service
+}
+
+func (s *ReminderService) Start() {
+	go func() {
+		scanTicker := time.NewTicker(s.commonConfig.Env.REMINDER_SCAN_INTERVAL)
+		deliveryTicker := time.NewTicker(reminderDeliveryInterval)
+
+		s.scan()
+		s.deliver()
+
+		for {
+			select {
+			case <-scanTicker.C:
+				s.scan()
+			case <-deliveryTicker.C:
+				s.deliver()
+			}
+		}
+	}()
+}
+
+// Replaces the list of pending reminders with all reminders that fire before the next scan.
+// Reminders that were missed less than REMINDER_MAX_DELAY ago, e.g. during a restart, are included as well.
+func (s *ReminderService) scan() {
+	now := time.Now()
+	from := now.Add(-s.commonConfig.Env.REMINDER_MAX_DELAY)
+	until := now.Add(s.commonConfig.Env.REMINDER_SCAN_INTERVAL + reminderDeliveryInterval)
+
+	for calendarId, rescan := range s.quiet {
+		if !now.Before(rescan) {
+			delete(s.quiet, calendarId)
+		}
+	}
+
+	users, tr := s.getUsers()
+	if tr != nil {
+		s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Error("failed to get users")
+		return
+	}
+
+	pending := []*pendingReminder{}
+	for _, user := range users {
+		if !user.Enabled {
+			continue
+		}
+
+		userPending, tr := s.scanUser(user, from, until)
+		if tr != nil {
+			s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Errorf("failed to collect reminders for user %v", user.Id)
+			continue
+		}
+		pending = append(pending, userPending...)
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].notification.Trigger.Before(pending[j].notification.Trigger)
+	})
+	s.pending = pending
+
+	s.logger.Infof("found %v upcoming reminders", len(pending))
+}
+
+func (s *ReminderService) getUsers() ([]*types.User, *errors.ErrorTrace) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, tr := s.db.BeginTransaction(ctx)
+	if tr != nil {
+		return nil, tr
+	}
+	defer tx.Rollback(s.logger)
+
+	return tx.Queries().GetUsers(true)
+}
+
+func (s *ReminderService) scanUser(user *types.User, from time.Time, until time.Time) ([]*pendingReminder, *errors.ErrorTrace) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	tx, tr := s.db.BeginTransaction(ctx)
+	if tr != nil {
+		return nil, tr
+	}
+	defer tx.Rollback(s.logger)
+	q := tx.Queries()
+
+	notifiers, tr := s.getNotifiers(user, tx)
+	if tr != nil {
+		return nil, tr
+	}
+	if len(notifiers) == 0 {
+		return []*pendingReminder{}, nil
+	}
+
+	now := time.Now()
+	sources, tr := q.GetSourcesForReminders(user.Id, ctx)
+	if tr != nil {
+		return nil, tr
+	}
+
+	start := from.Add(-reminderHorizon)
+	end := until.Add(reminderHorizon)
+
+	pending := []*pendingReminder{}
+	for _, source := range sources {
+		// Failing sources are retried less and less often
+		health, tr := q.GetSourceHealth(source.GetId())
+		if tr != nil {
+			return nil, tr
+		}
+		if health.NextRetry != nil && health.NextRetry.After(now) {
+			continue
+		}
+
+		cals, tr := source.GetCalendars(q)
+		q.ReportSourceHealth(source.GetId(), tr)
+		if tr != nil {
+			s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Warnf("failed to get calendars of source %v", source.GetId())
+			continue
+		}
+
+		cals, tr = q.OverrideCalendars(cals)
+		if tr != nil {
+			return nil, tr
+		}
+
+		for _, cal := range cals {
+			if rescan, quiet := s.quiet[cal.GetId()]; quiet && now.Before(rescan) {
+				continue
+			}
+
+			events, tr := s.getEvents(cal, start, end, tx)
+			q.ReportSourceHealth(source.GetId(), tr)
+			if tr != nil {
+				s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Warnf("failed to get events of calendar %v", cal.GetId())
+				continue
+			}
+
+			hasReminders := false
+			for _, event := range events {
+				hasReminders = hasReminders || len(event.GetReminders()) > 0
+				pending = append(pending, s.collectReminders(user, event, notifiers, from, until)...)
+			}
+
+			if hasReminders {
+				delete(s.quiet, cal.GetId())
+			} else {
+				s.quiet[cal.GetId()] = now.Add(reminderQuietInterval)
+			}
+		}
+	}
+
+	tr = tx.Commit(s.logger)
+	if tr != nil {
+		return nil, tr
+	}
+
+	return pending, nil
+}
+
+func (s *ReminderService) getEvents(cal types.Calendar, start time.Time, end time.Time, tx *db.Transaction) ([]types.Event, *errors.ErrorTrace) {
+	eventsFromCal, tr := cal.GetEvents(start, end, tx.Queries())
+	if tr != nil {
+		return nil, tr
+	}
+
+	expandedEvents := []types.Event{}
+	for _, event := range eventsFromCal {
+		expanded, tr := types.ExpandRecurrence(event, &start, &end)
+		if tr != nil {
+			return nil, tr
+		}
+		expandedEvents = append(expandedEvents, expanded...)
+	}
+
+	return tx.Queries().OverrideEvents(expandedEvents)
+}
+
+func (s *ReminderService) collectReminders(user *types.User, event types.Event, notifiers map[string][]notifications.Notifier, from time.Time, until time.Time) []*pendingReminder {
+	pending := []*pendingReminder{}
+
+	date := event.GetDate()
+	if date == nil || date.Start() == nil || date.End() == nil {
+		return pending
+	}
+
+	for _, reminder := range event.GetReminders() {
+		actionNotifiers := notifiers[reminder.Action()]
+		if len(actionNotifiers) == 0 {
+			continue
+		}
+
+		trigger := reminder.TriggerTime(date)
+		if trigger.Before(from) || !trigger.Before(until) {
+			continue
+		}
+		if trigger.Sub(*date.Start()).Abs() > reminderHorizon {
+			continue
+		}
+
+		pending = append(pending, &pendingReminder{
+			deliveryId: crypto.DeriveID(event.GetId(), fmt.Sprintf("%v %v", reminder.Action(), trigger.UTC().Format(time.RFC3339))),
+			notification: &notifications.Notification{
+				User:     user,
+				Event:    event,
+				Start:    *date.Start(),
+				End:      *date.End(),
+				AllDay:   date.AllDay(),
+				Trigger:  trigger,
+				Reminder: reminder,
+			},
+			notifiers: actionNotifiers,
+		})
+	}
+
+	return pending
+}
+
+// Returns the notifiers that the user configured for each reminder action
+func (s *ReminderService) getNotifiers(user *types.User, tx *db.Transaction) (map[string][]notifications.Notifier, *errors.ErrorTrace) {
+	notifiers := map[string][]notifications.Notifier{}
+
+	emailSetting := &config.NotificationEmail{}
+	tr := tx.Queries().GetUserSettingOrDefault(user.Id, emailSetting)
+	if tr != nil {
+		return nil, tr
+	}
+	if emailSetting.Enabled && s.email != nil && user.Email != "" {
+		notifiers[constants.ReminderActionEmail] = append(notifiers[constants.ReminderActionEmail], s.email)
+	}
+
+	webhookSetting := &config.NotificationWebhook{}
+	tr = tx.Queries().GetUserSettingOrDefault(user.Id, webhookSetting)
+	if tr != nil {
+		return nil, tr
+	}
+	if webhookSetting.Url != "" {
+		url, err := types.NewUrl(webhookSetting.Url)
+		if err == nil {
+			notifiers[constants.ReminderActionDisplay] = append(notifiers[constants.ReminderActionDisplay], notifications.NewWebhookNotifier(url))
+		}
+	}
+
+	pushSetting := &config.NotificationPush{}
+	tr = tx.Queries().GetUserSettingOrDefault(user.Id, pushSetting)
+	if tr != nil {
+		return nil, tr
+	}
+	if pushSetting.Service != "" {
+		url, err := types.NewUrl(pushSetting.Url)
+		if err == nil {
+			var notifier notifications.Notifier
+			switch pushSetting.Service {
+			case config.PushServiceNtfy:
+				notifier = notifications.NewNtfyNotifier(url, pushSetting.Token)
+			case config.PushServiceGotify:
+				notifier = notifications.NewGotifyNotifier(url, pushSetting.Token)
+			}
+			if notifier != nil {
+				notifiers[constants.ReminderActionDisplay] = append(notifiers[constants.ReminderActionDisplay], notifier)
+			}
+		}
+	}
+
+	return notifiers, nil
+}
+
+// Delivers all pending reminders that are due
+func (s *ReminderService) deliver() {
+	now := time.Now()
+
+	due := 0
+	for due < len(s.pending) && !s.pending[due].notification.Trigger.After(now) {
+		due++
+	}
+	if due == 0 {
+		return
+	}
+
+	for _, reminder := range s.pending[:due] {
+		// The service might have been stalled for a while
+		if now.Sub(reminder.notification.Trigger) > s.commonConfig.Env.REMINDER_MAX_DELAY {
+			continue
+		}
+		s.deliverReminder(reminder)
+	}
+
+	s.pending = s.pending[due:]
+}
+
+func (s *ReminderService) deliverReminder(reminder *pendingReminder) {
+	notification := reminder.notification
+
+	claimed, tr := s.claim(reminder)
+	if tr != nil {
+		s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Errorf("failed to record delivery of reminder for event %v", notification.Event.GetId())
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	delivered := false
+	for _, notifier := range reminder.notifiers {
+		tr = notifier.Notify(notification, ctx)
+		if tr != nil {
+			s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Errorf("failed to deliver reminder for event %v to user %v", notification.Event.GetId(), notification.User.Id)
+			continue
+		}
+		delivered = true
+	}
+
+	if delivered {
+		s.logger.Infof("delivered reminder for event %v to user %v", notification.Event.GetId(), notification.User.Id)
+	}
+}
+
+func (s *ReminderService) claim(reminder *pendingReminder) (bool, *errors.ErrorTrace) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, tr := s.db.BeginTransaction(ctx)
+	if tr != nil {
+		return false, tr
+	}
+	defer tx.Rollback(s.logger)
+
+	notification := reminder.notification
+	claimed, tr := tx.Queries().ClaimReminderDelivery(reminder.deliveryId, notification.User.Id, notification.Event.GetId(), notification.Trigger)
+	if tr != nil {
+		return false, tr
+	}
+
+	tr = tx.Commit(s.logger)
+	if tr != nil {
+		return false, tr
+	}
+
+	return claimed, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"luna-backend/config"
+	"luna-backend/db"
+	"luna-backend/errors"
+	"luna-backend/types"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Successes of sources that are known to be healthy are written at most this often,
+// so that fetching events does not cause a database write every time
+const sourceHealthSuccessInterval = time.Minute
+
+// Records the health of sources in a separate transaction,
+// so that failures are kept even though the request that noticed them is rolled back
+type SourceHealthService struct {
+	receiveChannel chan *types.SourceHealthReport
+	db             *db.Database
+	commonConfig   *config.CommonConfig
+	logger         *logrus.Entry
+	healthySince   map[types.ID]time.Time
+}
+
+func NewSourceHealthService(db *db.Database, commonConfig *config.CommonConfig, logger *logrus.Entry) *SourceHealthService {
+	service := SourceHealthService{
+		receiveChannel: make(chan *types.SourceHealthReport, 100),
+		db:             db,
+		commonConfig:   commonConfig,
+		logger:         logger,
+		healthySince:   map[types.ID]time.Time{},
+	}
+
+	commonConfig.SourceHealthChannel = service.Channel()
+
+	return &service
+}
+
+func (s *SourceHealthService) Start() {
+	go func() {
+		for report := range s.receiveChannel {
+			s.record(report)
+		}
+	}()
+}
+
+func (s *SourceHealthService) record(report *types.SourceHealthReport) {
+	if report.Error == nil {
+		if written, known := s.healthySince[report.SourceId]; known && time.Since(written) < sourceHealthSuccessInterval {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tx, tr := s.db.BeginTransaction(ctx)
+	if tr != nil {
+		s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Error("failed to begin transaction")
+		return
+	}
+
+	defer func() {
+		tr = tx.Rollback(s.logger)
+		if tr != nil {
+			s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Error("failed to rollback transaction")
+		}
+	}()
+
+	if report.Error == nil {
+		tr = tx.Queries().RecordSourceSuccess(report.SourceId)
+	} else {
+		// The same detail as the user got in the response of the failed request
+		tr = tx.Queries().RecordSourceFailure(report.SourceId, report.Error.Serialize(s.commonConfig.LoggingVerbosity()))
+	}
+	if tr != nil {
+		s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Errorf("failed to record health of source %v", report.SourceId)
+		return
+	}
+
+	tr = tx.Commit(s.logger)
+	if tr != nil {
+		s.logger.WithError(tr.SerializeError(errors.LvlDebug)).Error("failed to commit transaction")
+		return
+	}
+
+	if report.Error == nil {
+		s.healthySince[report.SourceId] = time.Now()
+	} else {
+		delete(s.healthySince, report.SourceId)
+		s.logger.Warnf("source %v failed: %v", report.SourceId, report.Error.Serialize(errors.LvlDebug))
+	}
+}
+
+func (s *SourceHealthService) Channel() chan *types.SourceHealthReport {
+	return s.receiveChannel
+}
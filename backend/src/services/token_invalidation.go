@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"luna-backend/config"
+	"luna-backend/constants"
 	"luna-backend/db"
 	"luna-backend/errors"
 	"luna-backend/types"
@@ -63,6 +64,9 @@ func (t *TokenInvalidationService) invalidate(s *types.Session) {
 		return
 	}
 
+	// Open change streams of the session are told about the forced logout and closed afterwards
+	tx.Queries().NotifyChange(s.UserId, types.NewSessionChange(constants.ChangeActionRevoked, s.SessionId))
+
 	tr = tx.Commit(t.logger)
 	if tr != nil {
 		t.logger.WithError(tr.SerializeError(errors.LvlDebug)).Error("failed to commit transaction")
@@ -9,6 +9,8 @@ import (
 	"luna-backend/errors"
 	"luna-backend/files"
 	"luna-backend/protocols/ical"
+	"luna-backend/types"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -26,7 +28,7 @@ func RefetchIcalFiles(tx *db.Transaction, logger *logrus.Entry, config *config.C
 
 	//wg := sync.WaitGroup{}
 
-	for _, setting := range settings {
+	for sourceId, setting := range settings {
 		//wg.Add(1)
 		//go func(setting []byte) {
 		//defer wg.Done()
@@ -36,6 +38,7 @@ func RefetchIcalFiles(tx *db.Transaction, logger *logrus.Entry, config *config.C
 		err := json.Unmarshal(setting, icalSourceSettings)
 		if err != nil {
 			logger.Errorf("could not unmarshal iCal settings: %v", err)
+			continue
 		}
 
 		if icalSourceSettings.Location != "remote" {
@@ -43,16 +46,38 @@ func RefetchIcalFiles(tx *db.Transaction, logger *logrus.Entry, config *config.C
 			//return
 		}
 
-		// We assume no authentication is needed for this file.
-		// This will fail for users whose remote iCal files require authentication.
-		// This will not be fixed in this task, because we don't want to expose users' encryption keys unnecessarily.
-		// Instead, refetching of access-controlled iCal files might become an opt-in feature later on.
-		file := files.GetRemoteFile(icalSourceSettings.Url, "text/calendar", auth.NewNoAuth())
+		// Failing sources are retried less and less often
+		health, tr := tx.Queries().GetSourceHealth(sourceId)
+		if tr != nil {
+			logger.Errorf("could not get health of source %v: %v", sourceId, tr.Serialize(errors.LvlDebug))
+		} else if health.NextRetry != nil && health.NextRetry.After(time.Now()) {
+			continue
+		}
+
+		// Unless the user opted in, we assume no authentication is needed for this file.
+		// This will fail for users whose remote iCal files require authentication,
+		// because we don't want to expose users' encryption keys unnecessarily.
+		var sourceAuth types.AuthMethod = auth.NewNoAuth()
+		if icalSourceSettings.BackgroundRefetch {
+			source, tr := tx.Queries().GetSourceForBackgroundRefetch(sourceId, tx.Queries().GetContext())
+			if tr != nil {
+				logger.Errorf("could not get credentials to refetch iCal file %v: %v", icalSourceSettings.Url, tr.Serialize(errors.LvlDebug))
+				tx.Queries().ReportSourceHealth(sourceId, tr)
+				continue
+			}
+			sourceAuth = source.GetAuth()
+		}
+
+		file := files.GetRemoteFile(icalSourceSettings.Url, "text/calendar", sourceAuth)
 		tr = file.ForceFetchFromRemote(tx.Queries())
 
 		if tr != nil {
 			logger.Errorf("could not refetch iCal file %v: %v", icalSourceSettings.Url, tr.Serialize(errors.LvlDebug))
 		}
+		// Without the credentials, a failure does not mean that the source is broken
+		if tr == nil || icalSourceSettings.BackgroundRefetch {
+			tx.Queries().ReportSourceHealth(sourceId, tr)
+		}
 		//}(setting)
 	}
 
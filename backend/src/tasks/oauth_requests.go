@@ -11,3 +11,7 @@ import (
 func DeleteExpiredOauthAuthorizationRequests(tx *db.Transaction, logger *logrus.Entry, config *config.CommonConfig) *errors.ErrorTrace {
 	return tx.Queries().DeleteExpiredOauthAuthorizationRequests()
 }
+
+func DeleteExpiredOauthLoginRequests(tx *db.Transaction, logger *logrus.Entry, config *config.CommonConfig) *errors.ErrorTrace {
+	return tx.Queries().DeleteExpiredOauthLoginRequests()
+}
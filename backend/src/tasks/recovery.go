@@ -0,0 +1,17 @@
+package tasks
+
+import (
+	"luna-backend/config"
+	"luna-backend/db"
+	"luna-backend/errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+func DeleteExpiredPasswordResetTokens(tx *db.Transaction, logger *logrus.Entry, config *config.CommonConfig) *errors.ErrorTrace {
+	return tx.Queries().DeleteExpiredPasswordResetTokens()
+}
+
+func DeleteExpiredEmailVerificationTokens(tx *db.Transaction, logger *logrus.Entry, config *config.CommonConfig) *errors.ErrorTrace {
+	return tx.Queries().DeleteExpiredEmailVerificationTokens()
+}
@@ -0,0 +1,17 @@
+package tasks
+
+import (
+	"luna-backend/config"
+	"luna-backend/db"
+	"luna-backend/errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Deliveries only need to be remembered for as long as the reminder service could still pick them up again
+func DeleteStaleReminderDeliveries(tx *db.Transaction, logger *logrus.Entry, config *config.CommonConfig) *errors.ErrorTrace {
+	currentTime := time.Now()
+
+	return tx.Queries().DeleteStaleReminderDeliveries(currentTime.AddDate(0, 0, -7))
+}
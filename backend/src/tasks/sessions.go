@@ -20,3 +20,11 @@ func DeleteStaleLongLivedSessions(tx *db.Transaction, logger *logrus.Entry, conf
 
 	return tx.Queries().DeleteExpiredSessions(currentTime.AddDate(0, -1, 0), true)
 }
+
+func DeleteExpiredPreauthSessions(tx *db.Transaction, logger *logrus.Entry, config *config.CommonConfig) *errors.ErrorTrace {
+	return tx.Queries().DeleteExpiredPreauthSessions()
+}
+
+func DeleteExpiredWebauthnCeremonies(tx *db.Transaction, logger *logrus.Entry, config *config.CommonConfig) *errors.ErrorTrace {
+	return tx.Queries().DeleteExpiredWebauthnCeremonies()
+}
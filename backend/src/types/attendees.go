@@ -0,0 +1,154 @@
+package types
+
+import (
+	"fmt"
+	"luna-backend/constants"
+	"strings"
+)
+
+// The organizer and attendees of an event.
+// Attendees are identified by their email address.
+type EventParticipants struct {
+	Organizer *EventOrganizer  `json:"organizer"`
+	Attendees []*EventAttendee `json:"attendees"`
+}
+
+type EventOrganizer struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type EventAttendee struct {
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Status string `json:"status"`
+	Rsvp   bool   `json:"rsvp"`
+	Self   bool   `json:"self"` // only known for sources that report which attendee is the account owner
+}
+
+func IsValidAttendeeRole(role string) bool {
+	switch role {
+	case constants.AttendeeRoleChair, constants.AttendeeRoleRequired, constants.AttendeeRoleOptional, constants.AttendeeRoleNonParticipant:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsValidParticipationStatus(status string) bool {
+	switch status {
+	case constants.ParticipationNeedsAction, constants.ParticipationAccepted, constants.ParticipationDeclined, constants.ParticipationTentative, constants.ParticipationDelegated:
+		return true
+	default:
+		return false
+	}
+}
+
+func (participants *EventParticipants) IsEmpty() bool {
+	return participants == nil || (participants.Organizer == nil && len(participants.Attendees) == 0)
+}
+
+func (participants *EventParticipants) Clone() *EventParticipants {
+	if participants == nil {
+		return nil
+	}
+
+	clone := &EventParticipants{
+		Attendees: make([]*EventAttendee, len(participants.Attendees)),
+	}
+	if participants.Organizer != nil {
+		organizer := *participants.Organizer
+		clone.Organizer = &organizer
+	}
+	for i, attendee := range participants.Attendees {
+		attendeeClone := *attendee
+		clone.Attendees[i] = &attendeeClone
+	}
+	return clone
+}
+
+func (participants *EventParticipants) Equal(other *EventParticipants) bool {
+	if participants.IsEmpty() || other.IsEmpty() {
+		return participants.IsEmpty() == other.IsEmpty()
+	}
+
+	if (participants.Organizer == nil) != (other.Organizer == nil) {
+		return false
+	}
+	if participants.Organizer != nil && *participants.Organizer != *other.Organizer {
+		return false
+	}
+
+	if len(participants.Attendees) != len(other.Attendees) {
+		return false
+	}
+	for i := range participants.Attendees {
+		if *participants.Attendees[i] != *other.Attendees[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Finds the attendee belonging to the account owner, either through the self flag or by the given email address
+func (participants *EventParticipants) FindSelf(email string) *EventAttendee {
+	if participants == nil {
+		return nil
+	}
+
+	for _, attendee := range participants.Attendees {
+		if attendee.Self {
+			return attendee
+		}
+	}
+	return participants.Find(email)
+}
+
+// Finds an attendee by email address, ignoring case
+func (participants *EventParticipants) Find(email string) *EventAttendee {
+	if participants == nil || email == "" {
+		return nil
+	}
+
+	for _, attendee := range participants.Attendees {
+		if strings.EqualFold(attendee.Email, email) {
+			return attendee
+		}
+	}
+	return nil
+}
+
+// Checks the attendees received from a client and fills in the default role and status
+func (participants *EventParticipants) Validate() error {
+	if participants == nil {
+		return nil
+	}
+
+	if participants.Organizer != nil && participants.Organizer.Email == "" {
+		return fmt.Errorf("organizer is missing an email address")
+	}
+
+	seen := map[string]bool{}
+	for _, attendee := range participants.Attendees {
+		if attendee == nil || attendee.Email == "" {
+			return fmt.Errorf("attendee is missing an email address")
+		}
+		if seen[strings.ToLower(attendee.Email)] {
+			return fmt.Errorf("duplicate attendee %v", attendee.Email)
+		}
+		seen[strings.ToLower(attendee.Email)] = true
+
+		if attendee.Role == "" {
+			attendee.Role = constants.AttendeeRoleRequired
+		} else if !IsValidAttendeeRole(attendee.Role) {
+			return fmt.Errorf("unknown attendee role %v", attendee.Role)
+		}
+		if attendee.Status == "" {
+			attendee.Status = constants.ParticipationNeedsAction
+		} else if !IsValidParticipationStatus(attendee.Status) {
+			return fmt.Errorf("unknown participation status %v", attendee.Status)
+		}
+	}
+	return nil
+}
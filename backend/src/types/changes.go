@@ -0,0 +1,36 @@
+package types
+
+import "luna-backend/constants"
+
+// Notification about a modified resource, delivered to the change streams of its owner.
+// Clients are expected to refetch the resource, so only identifiers are included.
+type Change struct {
+	Token    string `json:"token"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Id       ID     `json:"id"`
+	Parent   ID     `json:"parent"`        // source of a calendar, calendar of an event
+	Key      string `json:"key,omitempty"` // name of a changed setting
+}
+
+func NewSourceChange(action string, sourceId ID) *Change {
+	return &Change{Resource: constants.ChangeResourceSource, Action: action, Id: sourceId}
+}
+
+func NewCalendarChange(action string, calendarId ID, sourceId ID) *Change {
+	return &Change{Resource: constants.ChangeResourceCalendar, Action: action, Id: calendarId, Parent: sourceId}
+}
+
+func NewEventChange(action string, eventId ID, calendarId ID) *Change {
+	return &Change{Resource: constants.ChangeResourceEvent, Action: action, Id: eventId, Parent: calendarId}
+}
+
+// An empty key means that all settings changed
+func NewSettingsChange(action string, key string) *Change {
+	return &Change{Resource: constants.ChangeResourceSettings, Action: action, Key: key}
+}
+
+// An empty session ID means that several sessions changed
+func NewSessionChange(action string, sessionId ID) *Change {
+	return &Change{Resource: constants.ChangeResourceSession, Action: action, Id: sessionId}
+}
@@ -0,0 +1,9 @@
+package types
+
+// The resource name and UID a CalDAV client chose when it created an event.
+// Events without one are served as {eventId}.ics with their ID as the UID.
+type DavObject struct {
+	EventId  ID     `json:"event_id" db:"event_id"`
+	FileName string `json:"file_name" db:"file_name"`
+	Uid      string `json:"uid" db:"uid"`
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"luna-backend/errors"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 type EventDatabaseEntry struct {
@@ -49,21 +51,74 @@ type SourceDatabaseEntry struct {
 	Auth     []byte `db:"auth" encrypted:"true"`
 }
 
+type LunaCalendarDatabaseEntry struct {
+	Id          ID
+	Source      ID
+	Name        string
+	Description string
+	Color       *Color
+}
+
+type LunaEventDatabaseEntry struct {
+	Id              ID
+	Calendar        ID
+	Master          ID         // EmptyId() unless the event is a modified instance of a recurring event
+	RecurrenceId    string     // for modified instances
+	RecurrenceStart *time.Time // for modified instances, the start of the instance that is replaced
+	Name            string
+	Description     string
+	Color           *Color
+	Date            *EventDate
+	Reminders       []*EventReminder
+	Location        *EventLocation
+	Participants    *EventParticipants
+}
+
+// Local copy of a calendar object for protocols that synchronize incrementally
+type RemoteObjectDatabaseEntry struct {
+	Id       ID     `db:"id" encrypted:"false"`
+	Calendar ID     `db:"calendar" encrypted:"false"`
+	Settings []byte `db:"settings" encrypted:"false"`
+	Href     string `db:"remote_href" encrypted:"false"`
+	Etag     string `db:"remote_etag" encrypted:"false"`
+	Data     string `db:"remote_data" encrypted:"false"`
+}
+
 // Subset of database queries required for protocol implementations
 // Required to avoid circular dependencies
 type DatabaseQueries interface {
 	GetContext() context.Context
+	GetLogger() *logrus.Entry
 
 	GetSourceOwner(sourceId ID) (ID, *errors.ErrorTrace)
+	ReportSourceHealth(sourceId ID, tr *errors.ErrorTrace)
 
 	GetFilecache(file File) (string, io.Reader, *time.Time, *errors.ErrorTrace)
 	SetFilecache(file File, content io.Reader, user ID) *errors.ErrorTrace
 	SetFilecacheWithoutId(file File, content io.Reader, user ID) (ID, *errors.ErrorTrace)
 	UpdateFileCache(file File, content io.Reader) *errors.ErrorTrace
+	GetFilecacheValidators(file File) (*FileValidators, *errors.ErrorTrace)
+	TouchFilecache(file File, validators *FileValidators) *errors.ErrorTrace
 	DeleteFilecache(file File, user ID) *errors.ErrorTrace
 
 	SetCalendarOverrides(calendarId ID, name string, desc string, color *Color) *errors.ErrorTrace
 	DeleteCalendarOverrides(calendarId ID) *errors.ErrorTrace
 	SetEventOverrides(eventId ID, name string, desc string, color *Color) *errors.ErrorTrace
 	DeleteEventOverrides(eventId ID) *errors.ErrorTrace
+
+	GetLunaCalendars(sourceId ID) ([]*LunaCalendarDatabaseEntry, *errors.ErrorTrace)
+	GetLunaCalendar(calendarId ID) (*LunaCalendarDatabaseEntry, *errors.ErrorTrace)
+	InsertLunaCalendar(entry *LunaCalendarDatabaseEntry) *errors.ErrorTrace
+	UpdateLunaCalendar(entry *LunaCalendarDatabaseEntry) *errors.ErrorTrace
+	DeleteLunaCalendar(calendarId ID) *errors.ErrorTrace
+	GetLunaEvents(calendarId ID, start time.Time, end time.Time) ([]*LunaEventDatabaseEntry, *errors.ErrorTrace)
+	GetLunaEvent(eventId ID) (*LunaEventDatabaseEntry, *errors.ErrorTrace)
+	SetLunaEvent(entry *LunaEventDatabaseEntry) *errors.ErrorTrace
+	DeleteLunaEvent(eventId ID) *errors.ErrorTrace
+
+	GetCalendarSyncState(calendarId ID) (*CalendarSyncState, *errors.ErrorTrace)
+	SetCalendarSyncState(calendarId ID, state *CalendarSyncState) *errors.ErrorTrace
+	GetRemoteObjects(calendarId ID) ([]*RemoteObjectDatabaseEntry, *errors.ErrorTrace)
+	SetRemoteObjects(objects []*RemoteObjectDatabaseEntry) *errors.ErrorTrace
+	DeleteRemoteObjects(calendarId ID, hrefs []string) *errors.ErrorTrace
 }
@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+type CalendarFeed struct {
+	FeedId     ID         `json:"feed_id" db:"feedid"`
+	UserId     ID         `json:"user_id" db:"userid"`
+	CalendarId ID         `json:"calendar_id" db:"calendar"`
+	Name       string     `json:"name" db:"name"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsed   *time.Time `json:"last_used" db:"last_used"`
+	DaysPast   int        `json:"days_past" db:"days_past"`
+	DaysFuture int        `json:"days_future" db:"days_future"`
+	SecretHash []byte     `json:"-" db:"hash"`
+}
@@ -12,3 +12,9 @@ type File interface {
 	GetContent(q DatabaseQueries) (io.Reader, *errors.ErrorTrace)
 	GetBytes(q DatabaseQueries) ([]byte, *errors.ErrorTrace)
 }
+
+// Sent back to the server of a remote file, so it only returns the file if it changed
+type FileValidators struct {
+	ETag         string
+	LastModified string
+}
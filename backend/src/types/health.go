@@ -0,0 +1,33 @@
+package types
+
+import (
+	"luna-backend/errors"
+	"time"
+)
+
+// Sent after talking to the remote server of a source, so that its health is recorded outside of the request's transaction
+type SourceHealthReport struct {
+	SourceId ID
+	Error    *errors.ErrorTrace // nil if the source responded successfully
+}
+
+type SourceHealth struct {
+	LastSuccess         *time.Time `json:"last_success"`
+	LastFailure         *time.Time `json:"last_failure"`
+	LastError           *string    `json:"last_error"` // kept after the source recovers
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	NextRetry           *time.Time `json:"next_retry"` // background tasks leave the source alone until then
+}
+
+func (health *SourceHealth) IsHealthy() bool {
+	return health.ConsecutiveFailures == 0
+}
+
+// Only contains unencrypted information about the source, so administrators can see it
+type UnhealthySource struct {
+	Id     ID            `json:"id"`
+	UserId ID            `json:"user"`
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	Health *SourceHealth `json:"health"`
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Where an event takes place.
+// The name is a room or an address, while the URL usually points to an online meeting.
+type EventLocation struct {
+	Name string          `json:"name"`
+	Url  *Url            `json:"url"`
+	Geo  *GeoCoordinates `json:"geo"`
+}
+
+type GeoCoordinates struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+}
+
+func (location *EventLocation) IsEmpty() bool {
+	return location == nil || (location.Name == "" && location.Url == nil && location.Geo == nil)
+}
+
+func (location *EventLocation) Clone() *EventLocation {
+	if location == nil {
+		return nil
+	}
+
+	clone := &EventLocation{
+		Name: location.Name,
+	}
+	if location.Url != nil {
+		url := *location.Url
+		clone.Url = &url
+	}
+	if location.Geo != nil {
+		geo := *location.Geo
+		clone.Geo = &geo
+	}
+	return clone
+}
+
+// Parses coordinates in the form "latitude<separator>longitude"
+func ParseGeoCoordinates(raw string, separator string) (*GeoCoordinates, error) {
+	parts := strings.Split(raw, separator)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected latitude and longitude separated by %q", separator)
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse latitude: %v", err)
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse longitude: %v", err)
+	}
+
+	if latitude < -90 || latitude > 90 {
+		return nil, fmt.Errorf("latitude %v out of range", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		return nil, fmt.Errorf("longitude %v out of range", longitude)
+	}
+
+	return &GeoCoordinates{
+		Latitude:  latitude,
+		Longitude: longitude,
+	}, nil
+}
@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+type TotpEntry struct {
+	Secret   []byte
+	Enabled  bool
+	LastStep int64
+}
+
+type RecoveryCode struct {
+	Id ID
+	*PasswordEntry
+}
+
+// Intermediate state of a login that still requires a second factor
+type PreauthSession struct {
+	Id           ID
+	UserId       ID
+	CreatedAt    time.Time
+	IsShortLived bool
+	SecretHash   []byte
+}
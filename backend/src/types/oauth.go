@@ -3,15 +3,22 @@ package types
 import "time"
 
 type OauthClient struct {
-	Id               ID     `json:"id" db:"id" encrypted:"false"`
-	Name             string `json:"name" db:"name" encrypted:"false"`
-	ClientId         string `json:"client_id" db:"client_id" encrypted:"false"`
-	ClientSecret     string `json:"client_secret" db:"client_secret" encrypted:"true"`
-	BaseUrl          *Url   `json:"base_url" db:"base_url" encrypted:"false"`
-	AuthorizationUrl *Url   `json:"-" db:"" encrypted:"false"`
-	TokenUrl         *Url   `json:"-" db:"" encrypted:"false"`
-	UserinfoUrl      *Url   `json:"-" db:"" encrypted:"false"`
-	Scope            string `json:"scope" db:"scope" encrypted:"false"`
+	Id               ID       `json:"id" db:"id" encrypted:"false"`
+	Name             string   `json:"name" db:"name" encrypted:"false"`
+	ClientId         string   `json:"client_id" db:"client_id" encrypted:"false"`
+	ClientSecret     string   `json:"client_secret" db:"client_secret" encrypted:"true"`
+	BaseUrl          *Url     `json:"base_url" db:"base_url" encrypted:"false"`
+	AuthorizationUrl *Url     `json:"-" db:"" encrypted:"false"`
+	TokenUrl         *Url     `json:"-" db:"" encrypted:"false"`
+	UserinfoUrl      *Url     `json:"-" db:"" encrypted:"false"`
+	Scope            string   `json:"scope" db:"scope" encrypted:"false"`
+	LoginProvider    bool     `json:"login_provider" db:"login_provider" encrypted:"false"`
+	AutoRegister     bool     `json:"auto_register" db:"auto_register" encrypted:"false"`
+	GroupClaim       string   `json:"group_claim" db:"group_claim" encrypted:"false"`
+	AdminGroup       string   `json:"admin_group" db:"admin_group" encrypted:"false"`
+	Issuer           string   `json:"-" db:"" encrypted:"false"`
+	JwksUrl          *Url     `json:"-" db:"" encrypted:"false"`
+	IdTokenAlgs      []string `json:"-" db:"" encrypted:"false"`
 }
 
 type OauthAuthorizationRequest struct {
@@ -30,4 +37,24 @@ type OauthTokens struct {
 	AccessToken  string    `json:"-" db:"access_token" encrypted:"true"`
 	RefreshToken string    `json:"-" db:"refresh_token" encrypted:"true"`
 	Expires      time.Time `json:"-" db:"expires_at" encrypted:"false"`
+	IdToken      string    `json:"-" db:"" encrypted:"false"`
+}
+
+type OauthLoginRequest struct {
+	Id           ID        `json:"request_id" db:"request_id" encrypted:"false"`
+	ClientId     ID        `json:"client_id" db:"client_id" encrypted:"false"`
+	UserId       ID        `json:"-" db:"user_id" encrypted:"false"`
+	CodeVerifier string    `json:"-" db:"code_verifier" encrypted:"false"`
+	Nonce        string    `json:"-" db:"nonce" encrypted:"false"`
+	IsShortLived bool      `json:"-" db:"is_short_lived" encrypted:"false"`
+	Expires      time.Time `json:"expires_at" db:"expires_at" encrypted:"false"`
+}
+
+type OauthIdentity struct {
+	Id        ID        `json:"id" db:"id" encrypted:"false"`
+	ClientId  ID        `json:"client_id" db:"client_id" encrypted:"false"`
+	UserId    ID        `json:"-" db:"user_id" encrypted:"false"`
+	Subject   string    `json:"-" db:"subject" encrypted:"false"`
+	Name      string    `json:"name" db:"name" encrypted:"false"`
+	CreatedAt time.Time `json:"created_at" db:"created_at" encrypted:"false"`
 }
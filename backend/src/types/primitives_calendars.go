@@ -27,8 +27,8 @@ type Calendar interface {
 
 	GetEvents(start time.Time, end time.Time, q DatabaseQueries) ([]Event, *errors.ErrorTrace)
 	GetEvent(settings EventSettings, q DatabaseQueries) (Event, *errors.ErrorTrace)
-	AddEvent(name string, desc string, color *Color, date *EventDate, q DatabaseQueries) (Event, *errors.ErrorTrace)
-	EditEvent(event Event, name string, desc string, color *Color, date *EventDate, override bool, q DatabaseQueries) (Event, *errors.ErrorTrace)
+	AddEvent(name string, desc string, color *Color, date *EventDate, location *EventLocation, participants *EventParticipants, reminders []*EventReminder, q DatabaseQueries) (Event, *errors.ErrorTrace)
+	EditEvent(event Event, name string, desc string, color *Color, date *EventDate, location *EventLocation, participants *EventParticipants, reminders []*EventReminder, override bool, q DatabaseQueries) (Event, *errors.ErrorTrace)
 	DeleteEvent(event Event, q DatabaseQueries) *errors.ErrorTrace
 
 	SupplyContext(ctx context.Context)
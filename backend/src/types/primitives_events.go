@@ -27,10 +27,14 @@ type Event interface {
 
 	GetSettings() EventSettings
 	GetDate() *EventDate
+	GetReminders() []*EventReminder
+	GetLocation() *EventLocation
+	GetParticipants() *EventParticipants
 
 	Clone() Event
 
 	SupplyMasterEvent(masterEvent Event)
+	GetUid() string // shared by all instances of a recurring event
 	GetRecurrenceId() string
 }
 
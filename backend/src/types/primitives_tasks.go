@@ -0,0 +1,108 @@
+package types
+
+import (
+	"fmt"
+	"luna-backend/constants"
+	"luna-backend/errors"
+	"time"
+)
+
+// A to-do item (VTODO) stored alongside the events of a calendar
+type Task interface {
+	GetId() ID
+	GetCalendar() Calendar
+	GetUid() string
+
+	CanEdit() bool
+	CanDelete() bool
+
+	GetProps() *TaskProps
+}
+
+// Implemented by calendars whose source can also hold tasks.
+// Tasks are only looked up through their calendar, so they are not cached in the database.
+type TaskCalendar interface {
+	Calendar
+
+	CanAddTasks() bool
+
+	GetTasks(q DatabaseQueries) ([]Task, *errors.ErrorTrace)
+	GetTask(taskId ID, q DatabaseQueries) (Task, *errors.ErrorTrace)
+	AddTask(props *TaskProps, q DatabaseQueries) (Task, *errors.ErrorTrace)
+	EditTask(task Task, props *TaskProps, q DatabaseQueries) (Task, *errors.ErrorTrace)
+	DeleteTask(task Task, q DatabaseQueries) *errors.ErrorTrace
+}
+
+type TaskProps struct {
+	Name      string     `json:"name"`
+	Desc      string     `json:"desc"`
+	Start     *time.Time `json:"start"`
+	Due       *time.Time `json:"due"`
+	AllDay    bool       `json:"all_day"`
+	Status    string     `json:"status"`
+	Completed *time.Time `json:"completed"`
+	Percent   int        `json:"percent"`
+	Priority  int        `json:"priority"` // 1 is the highest, 9 the lowest and 0 means undefined
+	Parent    string     `json:"parent"`   // UID of the parent task
+}
+
+func IsValidTaskStatus(status string) bool {
+	switch status {
+	case constants.TaskStatusNeedsAction, constants.TaskStatusInProcess, constants.TaskStatusCompleted, constants.TaskStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (props *TaskProps) Clone() *TaskProps {
+	if props == nil {
+		return nil
+	}
+
+	clone := *props
+	if props.Start != nil {
+		start := *props.Start
+		clone.Start = &start
+	}
+	if props.Due != nil {
+		due := *props.Due
+		clone.Due = &due
+	}
+	if props.Completed != nil {
+		completed := *props.Completed
+		clone.Completed = &completed
+	}
+	return &clone
+}
+
+// Checks the ranges defined by RFC 5545 and keeps the completion fields consistent with the status
+func (props *TaskProps) Validate() error {
+	if props.Status == "" {
+		props.Status = constants.TaskStatusNeedsAction
+	}
+	if !IsValidTaskStatus(props.Status) {
+		return fmt.Errorf("invalid status %v", props.Status)
+	}
+	if props.Percent < 0 || props.Percent > 100 {
+		return fmt.Errorf("percent %v is out of range", props.Percent)
+	}
+	if props.Priority < 0 || props.Priority > 9 {
+		return fmt.Errorf("priority %v is out of range", props.Priority)
+	}
+	if props.Start != nil && props.Due != nil && props.Due.Before(*props.Start) {
+		return fmt.Errorf("due date is before the start date")
+	}
+
+	if props.Status == constants.TaskStatusCompleted {
+		if props.Completed == nil {
+			now := time.Now().UTC()
+			props.Completed = &now
+		}
+		props.Percent = 100
+	} else {
+		props.Completed = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"luna-backend/constants"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// A reminder (VALARM) attached to an event.
+// The trigger is either an offset relative to the start or end of the event, or an absolute time.
+type EventReminder struct {
+	action  string
+	offset  *time.Duration
+	related string
+	time    *time.Time
+}
+
+func NewRelativeReminder(action string, offset time.Duration, relatedToEnd bool) *EventReminder {
+	related := constants.ReminderRelatedStart
+	if relatedToEnd {
+		related = constants.ReminderRelatedEnd
+	}
+
+	return &EventReminder{
+		action:  action,
+		offset:  &offset,
+		related: related,
+	}
+}
+
+func NewAbsoluteReminder(action string, triggerTime time.Time) *EventReminder {
+	triggerTime = triggerTime.UTC()
+	return &EventReminder{
+		action: action,
+		time:   &triggerTime,
+	}
+}
+
+func (reminder *EventReminder) Action() string {
+	return reminder.action
+}
+
+// Offset of a relative trigger, negative for reminders before the event
+func (reminder *EventReminder) Offset() *time.Duration {
+	return reminder.offset
+}
+
+func (reminder *EventReminder) RelatedToEnd() bool {
+	return reminder.related == constants.ReminderRelatedEnd
+}
+
+// Time of an absolute trigger
+func (reminder *EventReminder) Time() *time.Time {
+	return reminder.time
+}
+
+func (reminder *EventReminder) IsAbsolute() bool {
+	return reminder.time != nil
+}
+
+// Calculates when the reminder fires for an occurrence of an event
+func (reminder *EventReminder) TriggerTime(date *EventDate) time.Time {
+	if reminder.IsAbsolute() {
+		return *reminder.time
+	}
+	if reminder.RelatedToEnd() {
+		return date.End().Add(*reminder.offset)
+	}
+	return date.Start().Add(*reminder.offset)
+}
+
+func (reminder *EventReminder) Equal(other *EventReminder) bool {
+	if reminder.action != other.action || reminder.IsAbsolute() != other.IsAbsolute() {
+		return false
+	}
+	if reminder.IsAbsolute() {
+		return reminder.time.Equal(*other.time)
+	}
+	return *reminder.offset == *other.offset && reminder.related == other.related
+}
+
+func (reminder *EventReminder) Clone() *EventReminder {
+	clone := &EventReminder{
+		action:  reminder.action,
+		related: reminder.related,
+	}
+	if reminder.offset != nil {
+		offset := *reminder.offset
+		clone.offset = &offset
+	}
+	if reminder.time != nil {
+		triggerTime := *reminder.time
+		clone.time = &triggerTime
+	}
+	return clone
+}
+
+type exposedReminder struct {
+	Action  string     `json:"action"`
+	Offset  *int64     `json:"offset,omitempty"` // seconds
+	Related string     `json:"related,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+}
+
+func (reminder *EventReminder) MarshalJSON() ([]byte, error) {
+	exposed := exposedReminder{
+		Action:  reminder.action,
+		Related: reminder.related,
+		Time:    reminder.time,
+	}
+	if reminder.offset != nil {
+		seconds := int64(reminder.offset.Seconds())
+		exposed.Offset = &seconds
+	}
+	return json.Marshal(exposed)
+}
+
+func (reminder *EventReminder) UnmarshalJSON(data []byte) error {
+	var exposed exposedReminder
+	err := json.Unmarshal(data, &exposed)
+	if err != nil {
+		return err
+	}
+
+	switch exposed.Action {
+	case constants.ReminderActionDisplay, constants.ReminderActionEmail:
+	default:
+		return fmt.Errorf("unsupported reminder action %v", exposed.Action)
+	}
+
+	switch {
+	case exposed.Time != nil && exposed.Offset == nil:
+		*reminder = *NewAbsoluteReminder(exposed.Action, *exposed.Time)
+	case exposed.Time == nil && exposed.Offset != nil:
+		switch exposed.Related {
+		case "", constants.ReminderRelatedStart, constants.ReminderRelatedEnd:
+		default:
+			return fmt.Errorf("unsupported reminder relation %v", exposed.Related)
+		}
+		*reminder = *NewRelativeReminder(exposed.Action, time.Duration(*exposed.Offset)*time.Second, exposed.Related == constants.ReminderRelatedEnd)
+	default:
+		return fmt.Errorf("reminder must have either an offset or a time")
+	}
+
+	return nil
+}
+
+func CloneReminders(reminders []*EventReminder) []*EventReminder {
+	if reminders == nil {
+		return nil
+	}
+	clones := make([]*EventReminder, len(reminders))
+	for i, reminder := range reminders {
+		clones[i] = reminder.Clone()
+	}
+	return clones
+}
+
+func RemindersEqual(a []*EventReminder, b []*EventReminder) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses all VALARM components of an event.
+// Alarms with actions that Luna does not support (e.g. AUDIO) and malformed alarms are skipped.
+func EventRemindersFromIcal(component *ical.Component) []*EventReminder {
+	reminders := []*EventReminder{}
+
+	for _, child := range component.Children {
+		if child.Name != ical.CompAlarm {
+			continue
+		}
+
+		var action string
+		actionProp := child.Props.Get(ical.PropAction)
+		if actionProp == nil {
+			continue
+		}
+		switch actionProp.Value {
+		case "DISPLAY":
+			action = constants.ReminderActionDisplay
+		case "EMAIL":
+			action = constants.ReminderActionEmail
+		default:
+			continue
+		}
+
+		trigger := child.Props.Get(ical.PropTrigger)
+		if trigger == nil {
+			continue
+		}
+
+		if trigger.ValueType() == ical.ValueDateTime {
+			triggerTime, err := trigger.DateTime(time.UTC)
+			if err != nil {
+				continue
+			}
+			reminders = append(reminders, NewAbsoluteReminder(action, triggerTime))
+		} else {
+			offset, err := trigger.Duration()
+			if err != nil {
+				continue
+			}
+			relatedToEnd := trigger.Params.Get(ical.ParamRelated) == "END"
+			reminders = append(reminders, NewRelativeReminder(action, offset, relatedToEnd))
+		}
+	}
+
+	return reminders
+}
+
+// Creates a VALARM component. The summary is used as the alarm text.
+func (reminder *EventReminder) ToIcal(summary string) *ical.Component {
+	alarm := ical.NewComponent(ical.CompAlarm)
+
+	switch reminder.action {
+	case constants.ReminderActionEmail:
+		alarm.Props.SetText(ical.PropAction, "EMAIL")
+		alarm.Props.SetText(ical.PropSummary, summary)
+	default:
+		alarm.Props.SetText(ical.PropAction, "DISPLAY")
+	}
+	alarm.Props.SetText(ical.PropDescription, summary)
+
+	trigger := ical.NewProp(ical.PropTrigger)
+	if reminder.IsAbsolute() {
+		trigger.SetDateTime(*reminder.time)
+	} else {
+		trigger.SetDuration(*reminder.offset)
+		if reminder.RelatedToEnd() {
+			trigger.Params.Set(ical.ParamRelated, "END")
+		}
+	}
+	alarm.Props.Set(trigger)
+
+	return alarm
+}
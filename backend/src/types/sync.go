@@ -0,0 +1,12 @@
+package types
+
+import "time"
+
+// State of the incremental synchronization of a calendar with its remote server.
+// The token is opaque and its meaning depends on the method, e.g. a sync token or a ctag.
+type CalendarSyncState struct {
+	Token    string     `json:"token"`
+	Method   string     `json:"method"`
+	Status   string     `json:"status"`
+	LastSync *time.Time `json:"last_sync"`
+}
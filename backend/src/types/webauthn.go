@@ -0,0 +1,17 @@
+package types
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// A passkey or security key registered by a user
+type WebauthnCredential struct {
+	Id         ID                  `json:"id"`
+	UserId     ID                  `json:"-"`
+	Name       string              `json:"name"`
+	Credential webauthn.Credential `json:"-"`
+	CreatedAt  time.Time           `json:"created_at"`
+	LastUsed   *time.Time          `json:"last_used"`
+}